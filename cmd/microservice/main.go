@@ -0,0 +1,314 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/binary-1024/go-build-test/internal/api"
+	"github.com/binary-1024/go-build-test/internal/auth"
+	"github.com/binary-1024/go-build-test/internal/cache"
+	"github.com/binary-1024/go-build-test/internal/config"
+	"github.com/binary-1024/go-build-test/internal/database"
+	"github.com/binary-1024/go-build-test/internal/logger"
+	"github.com/binary-1024/go-build-test/internal/mailer"
+	"github.com/binary-1024/go-build-test/internal/middleware"
+	"github.com/binary-1024/go-build-test/internal/models"
+	"github.com/binary-1024/go-build-test/internal/repository"
+	"github.com/binary-1024/go-build-test/internal/server"
+	"github.com/binary-1024/go-build-test/internal/service"
+	"github.com/binary-1024/go-build-test/internal/storage"
+
+	"github.com/gin-gonic/gin"
+	"github.com/joho/godotenv"
+)
+
+// redisPingTimeout 启动时检查Redis连接的超时时间
+const redisPingTimeout = 5 * time.Second
+
+// cacheWarmupTimeout 启动时预热缓存的最长时间
+const cacheWarmupTimeout = time.Minute
+
+//go:generate go run github.com/swaggo/swag/cmd/swag@v1.16.2 init -d ../../ -g cmd/microservice/main.go -o ../../internal/api/docs --outputTypes go,json,yaml
+
+// @title Go 微服务示例 API
+// @version 1.0.0
+// @description 用户、分类和产品管理接口。
+// @description 所有 /api/v1 接口默认返回 {success, message, data} 格式的响应，请求头 X-Bare-Response: true 时只返回 data（错误为 application/problem+json）。
+// @description 需要认证的接口按权限范围（scopes）检查访问token或API Key，例如 products:read、products:write，缺少权限时返回 403，error 字段为缺少的权限。
+// @BasePath /api/v1
+// @tag.name auth
+// @tag.description 登录与令牌
+// @tag.name users
+// @tag.description 用户管理
+// @tag.name categories
+// @tag.description 分类管理
+// @tag.name products
+// @tag.description 产品管理
+// @tag.name admin
+// @tag.description 管理接口（X-Admin-Token）
+// @tag.name api-keys
+// @tag.description API Key管理
+// @securityDefinitions.apikey BearerAuth
+// @in header
+// @name Authorization
+// @description 格式为 "Bearer {token}"
+// @securityDefinitions.apikey APIKeyAuth
+// @in header
+// @name X-API-Key
+// @securityDefinitions.apikey AdminToken
+// @in header
+// @name X-Admin-Token
+func main() {
+	// 加载 .env 文件（不存在时忽略）
+	_ = godotenv.Load()
+
+	// 加载配置
+	cfg := config.Load()
+
+	// 初始化日志
+	log := logger.NewLogger(cfg.LogLevel, cfg.LogFormat, logger.FileOutput{
+		Path:       cfg.LogFile,
+		MaxSize:    cfg.LogMaxSize,
+		MaxBackups: cfg.LogMaxBackups,
+		MaxAge:     cfg.LogMaxAge,
+		Stdout:     cfg.LogStdout,
+	})
+	log.Info("服务启动中", "environment", cfg.Environment, "port", cfg.Port)
+
+	if err := cfg.Validate(); err != nil {
+		log.Fatal("配置无效，请检查环境变量", "error", err)
+	}
+
+	// 响应时间和价格格式
+	models.SetTimeFormat(cfg.TimeFormat)
+	if err := models.SetPriceFormat(cfg.PriceLocale, cfg.DefaultCurrency); err != nil {
+		log.Warn("价格格式配置无效，使用默认格式", "error", err)
+	}
+
+	// 初始化数据库
+	db, err := database.NewConnection(cfg.DBDriver, cfg.DatabaseURL, database.PoolConfig{
+		MaxOpenConns:    cfg.DBMaxOpenConns,
+		MaxIdleConns:    cfg.DBMaxIdleConns,
+		ConnMaxLifetime: cfg.DBConnMaxLifetime,
+	}, log)
+	if err != nil {
+		log.Fatal("数据库连接失败", "error", err)
+	}
+	if err := database.MigrateNormalizedEmails(db, cfg.EmailNormalization, log); err != nil {
+		log.Fatal("迁移归一化邮箱失败", "error", err)
+	}
+
+	// 初始化Redis
+	redisClient := cache.NewRedisClient(cfg.RedisURL, cfg.CacheKeyPrefix, log)
+	pingCtx, cancelPing := context.WithTimeout(context.Background(), redisPingTimeout)
+	redisErr := redisClient.Ping(pingCtx)
+	if redisErr != nil {
+		if cfg.RedisRequired {
+			log.Fatal("Redis连接失败", "error", redisErr)
+		}
+		log.Warn("Redis不可用，缓存降级为直接访问数据库", "error", redisErr)
+	}
+	cancelPing()
+	warmup := cache.NewWarmupState()
+	readiness := server.NewReadiness()
+	readOnly := server.NewReadOnlyMode(cfg.ReadOnly)
+
+	// 初始化JWT管理器
+	jwtManager := auth.NewJWTManager(cfg.JWTSecret)
+
+	// 初始化仓库
+	userRepo := repository.NewUserRepository(db)
+	productRepo := repository.NewProductRepository(db)
+	categoryRepo := repository.NewCategoryRepository(db)
+	auditRepo := repository.NewAuditRepository(db)
+	apiKeyRepo := repository.NewAPIKeyRepository(db)
+	txManager := repository.NewTransactioner(db)
+
+	// 初始化文件存储
+	avatarStorage := storage.NewLocalStorage(cfg.AvatarDir, cfg.AvatarBaseURL)
+
+	// 初始化邮件发送
+	mail := mailer.NewLogMailer(log)
+	if cfg.Mailer == "none" {
+		mail = mailer.NewNopMailer()
+	}
+
+	// 初始化服务
+	userService := service.NewUserService(userRepo, auditRepo, txManager, redisClient, avatarStorage, mail, jwtManager, service.UserOptions{
+		BatchAtomic:    cfg.UserBatchAtomic,
+		NormalizeEmail: cfg.EmailNormalization,
+		CacheTTL:       cfg.UserCacheTTL,
+		VerifyURL:      cfg.EmailVerifyURL,
+		VerifyTokenTTL: cfg.EmailVerifyTTL,
+	}, log)
+	productService := service.NewProductService(productRepo, categoryRepo, redisClient, cfg.ProductCacheTTL, service.PricePolicy{
+		Min: cfg.ProductMinPrice,
+		Max: cfg.ProductMaxPrice,
+	}, log)
+	categoryService := service.NewCategoryService(categoryRepo, productService, log)
+	authService := service.NewAuthService(userRepo, jwtManager, redisClient, mail, service.LoginProtection{
+		Threshold: cfg.LoginFailThreshold,
+		Window:    cfg.LoginFailWindow,
+		Cooldown:  cfg.LoginLockCooldown,
+	}, service.AuthOptions{
+		RequireVerifiedEmail: cfg.RequireEmailVerification,
+		ResetURL:             cfg.PasswordResetURL,
+		ResetTokenTTL:        cfg.PasswordResetTTL,
+		RefreshTokenTTL:      cfg.RefreshTokenTTL,
+		NormalizeEmail:       cfg.EmailNormalization,
+	}, log)
+
+	apiKeyService := service.NewAPIKeyService(apiKeyRepo, userRepo, redisClient, log)
+	stockHub := service.NewStockHub(redisClient, log)
+
+	// 预热缓存，在后台进行，不阻塞服务启动
+	if cfg.CacheWarmupEnabled && cfg.CacheWarmupCount > 0 {
+		if redisErr != nil {
+			log.Warn("Redis不可用，跳过缓存预热")
+		} else {
+			warmup.Start()
+			go warmProductCache(productService, warmup, cfg.CacheWarmupCount, log)
+		}
+	}
+
+	// 初始化处理器
+	handler := api.NewHandler(userService, productService, categoryService, authService, apiKeyService, stockHub, cfg, db, redisClient, warmup, readiness, readOnly, log)
+
+	// 设置路由
+	if cfg.Environment == "production" {
+		gin.SetMode(gin.ReleaseMode)
+	}
+	router := gin.New()
+	router.Use(middleware.RequestID())
+	router.Use(middleware.Route())
+	router.Use(middleware.Logger(log))
+	router.Use(middleware.Recovery(log))
+	router.Use(middleware.CORS(cfg.AllowedOrigins))
+	router.Use(middleware.MaxBodySize(cfg.MaxBodySize, handler.BodySizeLimits()))
+	router.Use(middleware.Timeout(cfg.RequestTimeout, api.StreamingRoutes...))
+
+	handler.SetupRoutes(router, jwtManager)
+
+	// 后台归还到期的库存预留
+	sweepCtx, stopSweeper := context.WithCancel(context.Background())
+	defer stopSweeper()
+	if cfg.StockReservationSweepInterval > 0 {
+		go sweepStockReservations(sweepCtx, productService, cfg.StockReservationSweepInterval, log)
+	}
+
+	srv := &http.Server{
+		Addr:    ":" + cfg.Port,
+		Handler: router,
+	}
+	// SSE长连接不会自行结束，Shutdown 会一直等到超时；关闭时主动结束所有库存推送
+	srv.RegisterOnShutdown(stockHub.Close)
+
+	useTLS := cfg.TLSCertFile != "" && cfg.TLSKeyFile != ""
+	if useTLS {
+		tlsConfig, err := server.NewTLSConfig(cfg.TLSMinVersion, cfg.TLSCipherSuites)
+		if err != nil {
+			log.Fatal("TLS配置错误", "error", err)
+		}
+		srv.TLSConfig = tlsConfig
+	}
+
+	// 在后台启动服务
+	serverErr := make(chan error, 1)
+	go func() {
+		log.Info("服务启动成功", "port", cfg.Port, "tls", useTLS)
+		var err error
+		if useTLS {
+			err = srv.ListenAndServeTLS(cfg.TLSCertFile, cfg.TLSKeyFile)
+		} else {
+			err = srv.ListenAndServe()
+		}
+		if err != nil && err != http.ErrServerClosed {
+			serverErr <- err
+		}
+	}()
+
+	// 等待退出信号
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	select {
+	case err := <-serverErr:
+		log.Fatal("服务启动失败", "error", err)
+	case <-ctx.Done():
+		log.Info("收到退出信号，开始优雅关闭", "timeout", cfg.ShutdownTimeout)
+	}
+
+	// 先标记为未就绪并继续服务一段时间，等待负载均衡摘除实例
+	readiness.StartDraining()
+	if cfg.ShutdownDrainDelay > 0 {
+		log.Info("等待负载均衡摘除实例", "delay", cfg.ShutdownDrainDelay)
+		time.Sleep(cfg.ShutdownDrainDelay)
+	}
+
+	// 停止接收新请求并等待处理中的请求完成
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+	defer cancel()
+
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		log.Error("HTTP服务关闭超时", "error", err)
+	} else {
+		log.Info("HTTP服务已关闭")
+	}
+
+	stopSweeper()
+
+	// 关闭Redis连接
+	if err := redisClient.Close(); err != nil {
+		log.Error("关闭Redis连接失败", "error", err)
+	} else {
+		log.Info("Redis连接已关闭")
+	}
+
+	// 关闭数据库连接
+	if sqlDB, err := db.DB(); err != nil {
+		log.Error("获取数据库连接失败", "error", err)
+	} else if err := sqlDB.Close(); err != nil {
+		log.Error("关闭数据库连接失败", "error", err)
+	} else {
+		log.Info("数据库连接已关闭")
+	}
+
+	log.Info("服务已退出")
+}
+
+// warmProductCache 将最近更新的产品写入缓存，预热期间列表接口返回 X-Cache-Warming 响应头
+// 预热失败只记录日志，请求会在缓存未命中时回退到数据库
+func warmProductCache(productService service.ProductService, warmup *cache.WarmupState, count int, log logger.Logger) {
+	defer warmup.Done()
+
+	ctx, cancel := context.WithTimeout(context.Background(), cacheWarmupTimeout)
+	defer cancel()
+
+	start := time.Now()
+	warmed, err := productService.WarmCache(ctx, count)
+	if err != nil {
+		log.Warn("缓存预热未完成", "warmed", warmed, "duration", time.Since(start), "error", err)
+		return
+	}
+	log.Info("缓存预热完成", "warmed", warmed, "duration", time.Since(start))
+}
+
+// sweepStockReservations 定期归还到期未确认的库存预留，直到 ctx 被取消
+func sweepStockReservations(ctx context.Context, productService service.ProductService, interval time.Duration, log logger.Logger) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := productService.SweepExpiredReservations(ctx); err != nil && ctx.Err() == nil {
+				log.Warn("清理到期的库存预留失败", "error", err)
+			}
+		}
+	}
+}
@@ -0,0 +1,105 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/binary-1024/go-build-test/internal/models"
+	"github.com/binary-1024/go-build-test/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ListAPIKeys 获取当前用户的API Key列表，不包含明文密钥
+// @Summary 获取当前用户的API Key列表
+// @Description 只接受JWT认证。列表不包含明文密钥，已撤销的密钥带 revoked_at。
+// @Tags api-keys
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} api.Response{data=[]models.APIKeyResponse} "获取成功"
+// @Failure 401 {object} api.ErrorResponse "未认证或令牌无效"
+// @Router /api-keys [get]
+func (h *Handler) ListAPIKeys(c *gin.Context) {
+	keys, err := h.apiKeyService.ListAPIKeys(c.Request.Context(), c.GetUint("user_id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"message": "获取API Key列表失败",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "获取API Key列表成功",
+		"data":    models.ToAPIKeyResponses(keys),
+	})
+}
+
+// CreateAPIKey 为当前用户创建API Key，明文密钥只在响应中返回一次
+// @Summary 创建API Key
+// @Description 只接受JWT认证。明文密钥只在本次响应中返回。
+// @Tags api-keys
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body models.CreateAPIKeyRequest true "API Key信息"
+// @Success 201 {object} api.Response{data=models.CreateAPIKeyResponse} "创建成功"
+// @Failure 400 {object} api.ValidationErrorResponse "请求参数错误"
+// @Failure 401 {object} api.ErrorResponse "未认证或令牌无效"
+// @Router /api-keys [post]
+func (h *Handler) CreateAPIKey(c *gin.Context) {
+	var req models.CreateAPIKeyRequest
+	if !h.bindJSON(c, &req) {
+		return
+	}
+
+	resp, err := h.apiKeyService.CreateAPIKey(c.Request.Context(), c.GetUint("user_id"), &req)
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidAPIKeyExpiry) || errors.Is(err, service.ErrScopeNotAllowed) {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"success": false,
+				"message": err.Error(),
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"message": "创建API Key失败",
+		})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"success": true,
+		"message": "API Key创建成功，请妥善保存，密钥不会再次显示",
+		"data":    resp,
+	})
+}
+
+// RevokeAPIKey 撤销当前用户的API Key
+// @Summary 撤销API Key
+// @Description 只接受JWT认证，只能撤销自己的密钥。重复撤销视为成功。
+// @Tags api-keys
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "API KeyID"
+// @Success 200 {object} api.Response "已撤销"
+// @Success 204 "已撤销（IDEMPOTENT_DELETE 开启时）"
+// @Failure 401 {object} api.ErrorResponse "未认证或令牌无效"
+// @Failure 404 {object} api.ErrorResponse "资源不存在"
+// @Router /api-keys/{id} [delete]
+func (h *Handler) RevokeAPIKey(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"message": "无效的API Key ID",
+		})
+		return
+	}
+
+	err = h.apiKeyService.RevokeAPIKey(c.Request.Context(), c.GetUint("user_id"), uint(id))
+	h.respondDelete(c, err, "API Key不存在", "API Key已撤销")
+}
@@ -0,0 +1,201 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/binary-1024/go-build-test/internal/middleware"
+	"github.com/binary-1024/go-build-test/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+)
+
+func init() {
+	// 校验错误中使用JSON/表单字段名，便于前端定位字段
+	if v, ok := binding.Validator.Engine().(*validator.Validate); ok {
+		v.RegisterTagNameFunc(func(field reflect.StructField) string {
+			for _, tag := range []string{"json", "form"} {
+				name := strings.Split(field.Tag.Get(tag), ",")[0]
+				if name == "-" {
+					return ""
+				}
+				if name != "" {
+					return name
+				}
+			}
+			return field.Name
+		})
+
+		// 密码强度校验，规则见 models.IsStrongPassword
+		_ = v.RegisterValidation("password", func(fl validator.FieldLevel) bool {
+			return models.IsStrongPassword(fl.Field().String())
+		})
+	}
+}
+
+// StrictJSONHeader 客户端可通过该请求头开启严格JSON解析
+const StrictJSONHeader = "X-Strict-JSON"
+
+// UnknownFieldsError 请求体包含未知字段
+type UnknownFieldsError struct {
+	Fields []string
+}
+
+func (e *UnknownFieldsError) Error() string {
+	return fmt.Sprintf("未知字段: %s", strings.Join(e.Fields, ", "))
+}
+
+// bindJSON 绑定JSON请求体，失败时直接写入400响应并返回false
+func (h *Handler) bindJSON(c *gin.Context, obj interface{}) bool {
+	var err error
+	if h.strictJSON(c) {
+		err = bindStrictJSON(c.Request, obj)
+	} else {
+		err = c.ShouldBindJSON(obj)
+	}
+	if err == nil {
+		return true
+	}
+
+	// 未声明 Content-Length 的请求在读取时才会超出 MaxBodySize 限制
+	var maxBytesErr *http.MaxBytesError
+	if errors.As(err, &maxBytesErr) {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{
+			"success": false,
+			"message": fmt.Sprintf("请求体不能超过%d字节", maxBytesErr.Limit),
+		})
+		return false
+	}
+
+	resp := gin.H{
+		"success": false,
+		"message": "请求参数错误",
+		"error":   err.Error(),
+	}
+	if unknownErr, ok := err.(*UnknownFieldsError); ok {
+		resp["unknown_fields"] = unknownErr.Fields
+	}
+	if fieldErrors := validationErrors(err); fieldErrors != nil {
+		resp["errors"] = fieldErrors
+	}
+	c.JSON(http.StatusBadRequest, resp)
+	return false
+}
+
+// bindQuery 绑定查询参数，失败时直接写入400响应并返回false
+func (h *Handler) bindQuery(c *gin.Context, obj interface{}) bool {
+	err := c.ShouldBindQuery(obj)
+	if err == nil {
+		return true
+	}
+
+	resp := gin.H{
+		"success": false,
+		"message": "查询参数错误",
+		"error":   err.Error(),
+	}
+	if fieldErrors := validationErrors(err); fieldErrors != nil {
+		resp["errors"] = fieldErrors
+	}
+	c.JSON(http.StatusBadRequest, resp)
+	return false
+}
+
+// validationErrors 将校验错误转换为 字段→规则 的映射，如 {"email": "required"}
+// 非校验错误返回nil
+func validationErrors(err error) map[string]string {
+	var verrs validator.ValidationErrors
+	if !errors.As(err, &verrs) {
+		return nil
+	}
+
+	fields := make(map[string]string, len(verrs))
+	for _, fe := range verrs {
+		message := fe.Tag()
+		if fe.Param() != "" {
+			message += "=" + fe.Param()
+		}
+		fields[fe.Field()] = message
+	}
+	return fields
+}
+
+// strictJSON 是否对本次请求启用严格JSON解析，请求头缺失或无效时使用默认配置
+func (h *Handler) strictJSON(c *gin.Context) bool {
+	if strict, err := middleware.ParseBool(c.GetHeader(StrictJSONHeader)); err == nil {
+		return strict
+	}
+	return h.config.StrictJSON
+}
+
+// bindStrictJSON 使用 DisallowUnknownFields 解析请求体并执行校验
+func bindStrictJSON(req *http.Request, obj interface{}) error {
+	if req == nil || req.Body == nil {
+		return fmt.Errorf("invalid request")
+	}
+
+	body, err := io.ReadAll(req.Body)
+	if err != nil {
+		return err
+	}
+
+	// 先收集全部未知字段，便于一次性返回给客户端
+	if fields := unknownFields(body, obj); len(fields) > 0 {
+		return &UnknownFieldsError{Fields: fields}
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(obj); err != nil {
+		return err
+	}
+
+	return binding.Validator.ValidateStruct(obj)
+}
+
+// unknownFields 返回请求体顶层中目标结构体不存在的字段
+func unknownFields(body []byte, obj interface{}) []string {
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(body, &raw); err != nil {
+		return nil
+	}
+
+	t := reflect.TypeOf(obj)
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return nil
+	}
+
+	known := make(map[string]bool)
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name := strings.Split(field.Tag.Get("json"), ",")[0]
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		known[strings.ToLower(name)] = true
+	}
+
+	var fields []string
+	for key := range raw {
+		if !known[strings.ToLower(key)] {
+			fields = append(fields, key)
+		}
+	}
+	sort.Strings(fields)
+	return fields
+}
@@ -0,0 +1,74 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/binary-1024/go-build-test/internal/config"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestBindJSONUnknownFields(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	type priceRequest struct {
+		Name  string   `json:"name"`
+		Price *float64 `json:"price" binding:"omitempty,min=0"`
+	}
+
+	tests := []struct {
+		name        string
+		strict      bool
+		header      string
+		body        string
+		want        int
+		wantUnknown []string
+	}{
+		{name: "默认宽松模式忽略未知字段", body: `{"name":"widget","pric":5}`, want: http.StatusOK},
+		{name: "配置开启严格模式", strict: true, body: `{"name":"widget","pric":5,"colour":"red"}`, want: http.StatusBadRequest, wantUnknown: []string{"colour", "pric"}},
+		{name: "请求头开启严格模式", header: "true", body: `{"name":"widget","pric":5}`, want: http.StatusBadRequest, wantUnknown: []string{"pric"}},
+		{name: "请求头关闭严格模式", strict: true, header: "false", body: `{"name":"widget","pric":5}`, want: http.StatusOK},
+		{name: "严格模式下已知字段正常解析", strict: true, body: `{"name":"widget","price":5}`, want: http.StatusOK},
+		{name: "严格模式仍执行校验", strict: true, body: `{"price":-1}`, want: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := &Handler{config: &config.Config{StrictJSON: tt.strict}}
+			router := gin.New()
+			router.POST("/bind", func(c *gin.Context) {
+				var req priceRequest
+				if h.bindJSON(c, &req) {
+					c.Status(http.StatusOK)
+				}
+			})
+
+			req := httptest.NewRequest(http.MethodPost, "/bind", bytes.NewBufferString(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			if tt.header != "" {
+				req.Header.Set(StrictJSONHeader, tt.header)
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			if w.Code != tt.want {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.want, w.Body.String())
+			}
+			if w.Code != http.StatusBadRequest {
+				return
+			}
+			var resp ValidationErrorResponse
+			if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+				t.Fatalf("decode response: %v", err)
+			}
+			if !reflect.DeepEqual(resp.UnknownFields, tt.wantUnknown) {
+				t.Errorf("unknown_fields = %v, want %v", resp.UnknownFields, tt.wantUnknown)
+			}
+		})
+	}
+}
@@ -0,0 +1,236 @@
+package api
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/binary-1024/go-build-test/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 导出格式
+const (
+	ExportFormatCSV  = "csv"
+	ExportFormatJSON = "json"
+)
+
+// exportFlushEvery 每写出多少条记录刷新一次响应
+const exportFlushEvery = 100
+
+// exporter 将记录逐条写入响应，不在内存中缓存整个结果集
+type exporter struct {
+	c     *gin.Context
+	csv   *csv.Writer
+	json  *json.Encoder
+	count int
+}
+
+// newExporter 校验导出格式并写出响应头，格式不支持时直接写入400响应并返回false
+// CSV 以UTF-8 BOM开头并写入表头，便于Excel正确识别中文；JSON 输出为一个数组
+func newExporter(c *gin.Context, name string, header []string) (*exporter, bool) {
+	format := c.DefaultQuery("format", ExportFormatCSV)
+	if format != ExportFormatCSV && format != ExportFormatJSON {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"message": "不支持的导出格式",
+			"error":   format,
+		})
+		return nil, false
+	}
+
+	filename := fmt.Sprintf("%s-%s.%s", name, time.Now().Format("20060102150405"), format)
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, filename))
+
+	e := &exporter{c: c}
+	if format == ExportFormatCSV {
+		c.Header("Content-Type", "text/csv; charset=utf-8")
+		c.Status(http.StatusOK)
+		c.Writer.WriteString("\ufeff")
+		e.csv = csv.NewWriter(c.Writer)
+		e.csv.Write(header)
+	} else {
+		c.Header("Content-Type", "application/json; charset=utf-8")
+		c.Status(http.StatusOK)
+		c.Writer.WriteString("[")
+		e.json = json.NewEncoder(c.Writer)
+	}
+	return e, true
+}
+
+// Write 写出一条记录，CSV 使用 row，JSON 使用 item
+func (e *exporter) Write(row []string, item interface{}) error {
+	var err error
+	if e.csv != nil {
+		err = e.csv.Write(escapeCSVRow(row))
+	} else {
+		if e.count > 0 {
+			if _, err := e.c.Writer.WriteString(","); err != nil {
+				return err
+			}
+		}
+		err = e.json.Encode(item)
+	}
+	if err != nil {
+		return err
+	}
+
+	e.count++
+	if e.count%exportFlushEvery == 0 {
+		return e.flush()
+	}
+	return nil
+}
+
+// Close 结束输出，返回写出剩余内容时的错误
+func (e *exporter) Close() error {
+	if e.json != nil {
+		if _, err := e.c.Writer.WriteString("]"); err != nil {
+			return err
+		}
+	}
+	return e.flush()
+}
+
+// flush 将缓冲的内容写出到客户端，CSV 写出失败（如客户端断开）时返回错误
+func (e *exporter) flush() error {
+	if e.csv != nil {
+		e.csv.Flush()
+		if err := e.csv.Error(); err != nil {
+			return err
+		}
+	}
+	e.c.Writer.Flush()
+	return nil
+}
+
+// csvFormulaPrefixes 表格软件会当作公式执行的单元格开头字符
+const csvFormulaPrefixes = "=+-@\t\r"
+
+// escapeCSVRow 在以公式字符开头的单元格前加上单引号，防止导出文件在Excel中打开时执行公式（CSV注入）
+func escapeCSVRow(row []string) []string {
+	escaped := make([]string, len(row))
+	for i, cell := range row {
+		if cell != "" && strings.ContainsRune(csvFormulaPrefixes, rune(cell[0])) {
+			cell = "'" + cell
+		}
+		escaped[i] = cell
+	}
+	return escaped
+}
+
+// productExportHeader 产品导出的列，前五列与CSV导入的列一致，导出文件可直接重新导入
+var productExportHeader = []string{"name", "description", "price", "stock", "category", "id", "is_active", "created_at"}
+
+func productExportRow(product *models.Product) []string {
+	category := ""
+	if product.Category != nil {
+		category = product.Category.Name
+	}
+	return []string{
+		product.Name,
+		product.Description,
+		strconv.FormatFloat(product.Price, 'f', -1, 64),
+		strconv.Itoa(product.Stock),
+		category,
+		strconv.FormatUint(uint64(product.ID), 10),
+		strconv.FormatBool(product.IsActive),
+		product.CreatedAt.Format(time.RFC3339),
+	}
+}
+
+// userExportHeader 用户导出的列
+var userExportHeader = []string{"id", "username", "email", "full_name", "avatar_url", "is_active", "created_at"}
+
+func userExportRow(user *models.User) []string {
+	return []string{
+		strconv.FormatUint(uint64(user.ID), 10),
+		user.Username,
+		user.Email,
+		user.FullName,
+		user.AvatarURL,
+		strconv.FormatBool(user.IsActive),
+		user.CreatedAt.Format(time.RFC3339),
+	}
+}
+
+// ExportProducts 导出符合条件的全部产品（format=csv|json），支持与产品列表相同的过滤参数
+// @Summary 导出产品
+// @Description 流式导出全部匹配的产品，支持与产品列表相同的过滤参数（分页参数除外）；CSV前五列与导入格式一致
+// @Tags products
+// @Produce text/csv,json
+// @Security BearerAuth
+// @Security APIKeyAuth
+// @Param format query string false "导出格式" Enums(csv, json) default(csv)
+// @Param category query string false "分类名称"
+// @Param categories query string false "逗号分隔的多个分类名称"
+// @Param category_id query int false "分类ID"
+// @Param min_price query number false "最低价格"
+// @Param max_price query number false "最高价格"
+// @Param search query string false "按名称和描述模糊搜索"
+// @Param active query bool false "是否上架"
+// @Param in_stock query bool false "是否有库存"
+// @Success 200 {array} models.ProductResponse "附件形式的CSV或JSON数组"
+// @Header 200 {string} Content-Disposition "附件文件名"
+// @Failure 400 {object} api.ValidationErrorResponse "请求参数错误"
+// @Failure 401 {object} api.ErrorResponse "未认证或令牌无效"
+// @Failure 403 {object} api.ErrorResponse "无权访问，或缺少所需的权限（error 字段为缺少的权限）"
+// @Router /products/export [get]
+func (h *Handler) ExportProducts(c *gin.Context) {
+	var query models.ProductQuery
+	if !h.bindQuery(c, &query) || !bindProductFilters(c, &query) {
+		return
+	}
+
+	e, ok := newExporter(c, "products", productExportHeader)
+	if !ok {
+		return
+	}
+	err := h.productService.StreamProducts(c.Request.Context(), &query, func(product *models.Product) error {
+		return e.Write(productExportRow(product), product.ToResponse())
+	})
+	if err == nil {
+		err = e.Close()
+	}
+	if err != nil {
+		// 响应头已发送，只能记录日志并中断输出
+		h.logger.Error("导出产品失败", "error", err, "count", e.count)
+	}
+}
+
+// ExportUsers 导出全部用户（format=csv|json），search 参数与用户列表一致
+// @Summary 导出用户
+// @Description 流式导出全部用户，search 参数与用户列表一致
+// @Tags users
+// @Produce text/csv,json
+// @Security BearerAuth
+// @Security APIKeyAuth
+// @Param format query string false "导出格式" Enums(csv, json) default(csv)
+// @Param search query string false "按用户名、邮箱、姓名模糊搜索"
+// @Success 200 {array} models.UserPublic "附件形式的CSV或JSON数组"
+// @Header 200 {string} Content-Disposition "附件文件名"
+// @Failure 400 {object} api.ValidationErrorResponse "请求参数错误"
+// @Failure 401 {object} api.ErrorResponse "未认证或令牌无效"
+// @Failure 403 {object} api.ErrorResponse "无权访问，或缺少所需的权限（error 字段为缺少的权限）"
+// @Router /users/export [get]
+func (h *Handler) ExportUsers(c *gin.Context) {
+	e, ok := newExporter(c, "users", userExportHeader)
+	if !ok {
+		return
+	}
+	err := h.userService.StreamUsers(c.Request.Context(), c.Query("search"), func(user *models.User) error {
+		return e.Write(userExportRow(user), user.ToPublic())
+	})
+	if err == nil {
+		err = e.Close()
+	}
+	if err != nil {
+		// 响应头已发送，只能记录日志并中断输出
+		h.logger.Error("导出用户失败", "error", err, "count", e.count)
+	}
+}
@@ -0,0 +1,150 @@
+package api
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/binary-1024/go-build-test/internal/config"
+	"github.com/binary-1024/go-build-test/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestEscapeCSVRow(t *testing.T) {
+	tests := []struct {
+		name string
+		row  []string
+		want []string
+	}{
+		{name: "普通单元格不变", row: []string{"widget", "12.5", ""}, want: []string{"widget", "12.5", ""}},
+		{name: "等号开头", row: []string{"=HYPERLINK(\"http://evil\")"}, want: []string{"'=HYPERLINK(\"http://evil\")"}},
+		{name: "加号开头", row: []string{"+1+1"}, want: []string{"'+1+1"}},
+		{name: "减号开头", row: []string{"-2+3"}, want: []string{"'-2+3"}},
+		{name: "@开头", row: []string{"@SUM(A1)"}, want: []string{"'@SUM(A1)"}},
+		{name: "制表符开头", row: []string{"\t=1"}, want: []string{"'\t=1"}},
+		{name: "中间的公式字符不处理", row: []string{"a=b", "x-y"}, want: []string{"a=b", "x-y"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := escapeCSVRow(tt.row); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("escapeCSVRow(%q) = %q, want %q", tt.row, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExporter(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name   string
+		format string
+		want   string
+		status int
+	}{
+		{name: "CSV", format: "csv", want: "\ufeffname,note\nwidget,'=1+1\n", status: http.StatusOK},
+		{name: "JSON", format: "json", want: "[{\"name\":\"widget\"}\n]", status: http.StatusOK},
+		{name: "不支持的格式", format: "xml", status: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Request = httptest.NewRequest(http.MethodGet, "/export?format="+tt.format, nil)
+
+			e, ok := newExporter(c, "items", []string{"name", "note"})
+			if ok {
+				if err := e.Write([]string{"widget", "=1+1"}, gin.H{"name": "widget"}); err != nil {
+					t.Fatalf("Write: %v", err)
+				}
+				if err := e.Close(); err != nil {
+					t.Fatalf("Close: %v", err)
+				}
+			}
+
+			if w.Code != tt.status {
+				t.Fatalf("status = %d, want %d", w.Code, tt.status)
+			}
+			if tt.want != "" && w.Body.String() != tt.want {
+				t.Errorf("body = %q, want %q", w.Body.String(), tt.want)
+			}
+			if ok && !strings.Contains(w.Header().Get("Content-Disposition"), "items-") {
+				t.Errorf("Content-Disposition = %q", w.Header().Get("Content-Disposition"))
+			}
+		})
+	}
+}
+
+func TestExportProductsCategory(t *testing.T) {
+	h, db := newTestProductHandler(t, &config.Config{})
+	category := &models.Category{Name: "tools"}
+	if err := db.Create(category).Error; err != nil {
+		t.Fatalf("create category: %v", err)
+	}
+	for _, product := range []*models.Product{
+		{Name: "widget", Price: 10, Stock: 5, CategoryID: &category.ID},
+		{Name: "gadget", Price: 20, Stock: 1},
+	} {
+		if err := db.Create(product).Error; err != nil {
+			t.Fatalf("create product: %v", err)
+		}
+	}
+	router := gin.New()
+	router.GET("/products/export", h.ExportProducts)
+
+	tests := []struct {
+		name   string
+		format string
+		want   map[string]string
+	}{
+		{name: "CSV", format: "csv", want: map[string]string{"widget": "tools", "gadget": ""}},
+		{name: "JSON", format: "json", want: map[string]string{"widget": "tools", "gadget": ""}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/products/export?format="+tt.format, nil))
+			if w.Code != http.StatusOK {
+				t.Fatalf("status = %d, body = %s", w.Code, w.Body.String())
+			}
+
+			got := make(map[string]string)
+			if tt.format == "csv" {
+				records, err := csv.NewReader(strings.NewReader(strings.TrimPrefix(w.Body.String(), "\ufeff"))).ReadAll()
+				if err != nil {
+					t.Fatalf("read csv: %v", err)
+				}
+				for _, record := range records[1:] {
+					got[record[0]] = record[4]
+				}
+			} else {
+				var products []struct {
+					Name     string `json:"name"`
+					Category *struct {
+						Name string `json:"name"`
+					} `json:"category"`
+				}
+				if err := json.Unmarshal(w.Body.Bytes(), &products); err != nil {
+					t.Fatalf("decode json: %v", err)
+				}
+				for _, product := range products {
+					got[product.Name] = ""
+					if product.Category != nil {
+						got[product.Name] = product.Category.Name
+					}
+				}
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("categories = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -1,184 +1,1566 @@
 package api
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
 	"net/http"
+	"sort"
 	"strconv"
+	"sync/atomic"
+	"time"
 
+	// 注册生成的 Swagger 文档
+	_ "github.com/binary-1024/go-build-test/internal/api/docs"
 	"github.com/binary-1024/go-build-test/internal/auth"
+	"github.com/binary-1024/go-build-test/internal/cache"
+	"github.com/binary-1024/go-build-test/internal/config"
 	"github.com/binary-1024/go-build-test/internal/logger"
 	"github.com/binary-1024/go-build-test/internal/middleware"
 	"github.com/binary-1024/go-build-test/internal/models"
+	"github.com/binary-1024/go-build-test/internal/repository"
+	"github.com/binary-1024/go-build-test/internal/server"
 	"github.com/binary-1024/go-build-test/internal/service"
 
 	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	swaggerFiles "github.com/swaggo/files"
+	ginSwagger "github.com/swaggo/gin-swagger"
+	"gorm.io/gorm"
 )
 
 // Handler API处理器
 type Handler struct {
-	userService    service.UserService
-	productService service.ProductService
-	authService    service.AuthService
-	logger         logger.Logger
+	userService     service.UserService
+	productService  service.ProductService
+	categoryService service.CategoryService
+	authService     service.AuthService
+	apiKeyService   service.APIKeyService
+	stockHub        *service.StockHub
+	config          *config.Config
+	db              *gorm.DB
+	cache           *cache.RedisClient
+	warmup          *cache.WarmupState
+	readiness       *server.Readiness
+	readOnly        *server.ReadOnlyMode
+	logger          logger.Logger
+
+	// purging 标记后台清理任务是否正在执行
+	purging atomic.Bool
 }
 
 // NewHandler 创建API处理器
-func NewHandler(userService service.UserService, productService service.ProductService, authService service.AuthService, logger logger.Logger) *Handler {
+func NewHandler(userService service.UserService, productService service.ProductService, categoryService service.CategoryService, authService service.AuthService, apiKeyService service.APIKeyService, stockHub *service.StockHub, cfg *config.Config, db *gorm.DB, redisClient *cache.RedisClient, warmup *cache.WarmupState, readiness *server.Readiness, readOnly *server.ReadOnlyMode, logger logger.Logger) *Handler {
 	return &Handler{
-		userService:    userService,
-		productService: productService,
-		authService:    authService,
-		logger:         logger,
+		userService:     userService,
+		productService:  productService,
+		categoryService: categoryService,
+		authService:     authService,
+		apiKeyService:   apiKeyService,
+		stockHub:        stockHub,
+		config:          cfg,
+		db:              db,
+		cache:           redisClient,
+		warmup:          warmup,
+		readiness:       readiness,
+		readOnly:        readOnly,
+		logger:          logger,
+	}
+}
+
+// avatarMultipartOverhead 头像上传请求体除文件外，给multipart的边界和头部预留的空间
+const avatarMultipartOverhead = 1 << 20
+
+// BodySizeLimits 文件上传路由的请求体大小限制，覆盖 MaxBodySize 的默认限制
+func (h *Handler) BodySizeLimits() map[string]int64 {
+	return map[string]int64{
+		"/api/v1/users/:id/avatar": h.config.AvatarMaxSize + avatarMultipartOverhead,
+		"/api/v1/products/import":  h.config.ProductImportMaxSize,
 	}
 }
 
 // SetupRoutes 设置路由
 func (h *Handler) SetupRoutes(router *gin.Engine, jwtManager *auth.JWTManager) {
 	api := router.Group("/api/v1")
+	api.Use(middleware.BareResponse(h.config.BareResponses))
+	api.Use(middleware.APIVersion(1, 1))
+	api.Use(middleware.ReadOnly(h.readOnly, "/api/v1/auth/login", "/api/v1/auth/refresh", "/api/v1/auth/introspect", "/api/v1/admin/read-only"))
+
+	idempotent := middleware.Idempotency(h.cache, h.config.IdempotencyTTL)
 
 	// 公开路由
-	api.POST("/auth/login", h.Login)
-	api.POST("/users", h.CreateUser)
+	api.POST("/auth/login", middleware.RateLimit(h.cache, h.config.LoginRateLimit, h.config.LoginRateWindow), h.Login)
+	api.POST("/auth/introspect", middleware.RateLimit(h.cache, h.config.IntrospectRateLimit, h.config.IntrospectRateWindow), h.Introspect)
+	api.POST("/auth/refresh", middleware.RateLimit(h.cache, h.config.LoginRateLimit, h.config.LoginRateWindow), h.RefreshToken)
+	api.POST("/auth/verify", h.VerifyEmail)
+	api.POST("/auth/forgot-password", middleware.RateLimit(h.cache, h.config.LoginRateLimit, h.config.LoginRateWindow), h.ForgotPassword)
+	api.POST("/auth/reset-password", middleware.RateLimit(h.cache, h.config.LoginRateLimit, h.config.LoginRateWindow), h.ResetPassword)
+	api.POST("/users", idempotent, h.CreateUser)
+
+	// 管理路由
+	admin := api.Group("/admin")
+	admin.Use(middleware.AdminToken(h.config.AdminToken))
+	{
+		admin.GET("/read-only", h.GetReadOnly)
+		admin.PUT("/read-only", h.SetReadOnly)
+		admin.POST("/purge", h.PurgeDeleted)
+		admin.PUT("/users/:id/scopes", h.SetUserScopes)
+	}
+
+	// API Key管理只接受JWT，避免用API Key签发新的API Key
+	apiKeys := api.Group("/api-keys")
+	apiKeys.Use(middleware.Auth(jwtManager, h.config.TokenFingerprintBinding))
+	{
+		apiKeys.GET("", h.ListAPIKeys)
+		apiKeys.POST("", h.CreateAPIKey)
+		apiKeys.DELETE("/:id", h.RevokeAPIKey)
+	}
+
+	// 需要认证的路由，接受JWT或API Key
+	protected := api.Group("")
+	protected.Use(middleware.AuthOrAPIKey(jwtManager, h.config.TokenFingerprintBinding, h.apiKeyService))
+	{
+		warming := middleware.CacheWarming(h.warmup, h.config.CacheWarmingRetryAfter)
+		usersRead := middleware.RequireScope(auth.ScopeUsersRead)
+		usersWrite := middleware.RequireScope(auth.ScopeUsersWrite)
+		categoriesRead := middleware.RequireScope(auth.ScopeCategoriesRead)
+		categoriesWrite := middleware.RequireScope(auth.ScopeCategoriesWrite)
+		productsRead := middleware.RequireScope(auth.ScopeProductsRead)
+		productsWrite := middleware.RequireScope(auth.ScopeProductsWrite)
+		profileWrite := middleware.RequireScopeForUser(auth.ScopeProfileWrite, auth.ScopeUsersWrite)
+		adminOnly := middleware.AdminToken(h.config.AdminToken)
+
+		// 用户路由
+		protected.GET("/users", usersRead, warming, h.ListUsers)
+		protected.POST("/users/batch", usersWrite, adminOnly, h.CreateUsers)
+		protected.GET("/users/export", usersRead, h.ExportUsers)
+		protected.GET("/users/:id", usersRead, h.GetUser)
+		protected.PUT("/users/:id", profileWrite, h.UpdateUser)
+		protected.DELETE("/users/:id", usersWrite, h.DeleteUser)
+		protected.POST("/users/:id/restore", usersWrite, h.RestoreUser)
+		protected.GET("/users/:id/audit", usersRead, h.GetUserAuditTrail)
+		protected.POST("/users/:id/avatar", profileWrite, h.UploadAvatar)
+
+		// 分类路由
+		protected.GET("/categories", categoriesRead, h.ListCategories)
+		protected.POST("/categories", categoriesWrite, h.CreateCategory)
+		protected.GET("/categories/:id", categoriesRead, h.GetCategory)
+		protected.PUT("/categories/:id", categoriesWrite, h.UpdateCategory)
+		protected.DELETE("/categories/:id", categoriesWrite, h.DeleteCategory)
+
+		// 产品路由
+		protected.GET("/products", productsRead, warming, h.ListProducts)
+		protected.GET("/products/stream", productsRead, h.StreamProducts)
+		protected.GET("/products/export", productsRead, h.ExportProducts)
+		protected.POST("/products", productsWrite, idempotent, h.CreateProduct)
+		protected.POST("/products/import", productsWrite, h.ImportProducts)
+		protected.GET("/products/batch", productsRead, h.GetProductsByIDs)
+		protected.GET("/products/:id", productsRead, h.GetProduct)
+		protected.GET("/products/:id/views", productsRead, h.GetProductViews)
+		protected.GET("/products/:id/stream", productsRead, h.StreamProductStock)
+		protected.PUT("/products/:id", productsWrite, h.UpdateProduct)
+		protected.DELETE("/products/:id", productsWrite, h.DeleteProduct)
+		protected.POST("/products/:id/purchase", productsWrite, h.PurchaseProduct)
+		protected.POST("/products/:id/reservations", productsWrite, h.ReserveStock)
+		protected.POST("/reservations/:id/confirm", productsWrite, h.ConfirmReservation)
+		protected.DELETE("/reservations/:id", productsWrite, h.CancelReservation)
+		protected.POST("/products/:id/images", productsWrite, h.AddProductImage)
+		protected.DELETE("/products/:id/images/:imageID", productsWrite, h.RemoveProductImage)
+	}
+
+	// 本地存储的上传文件
+	router.Static(h.config.AvatarBaseURL, h.config.AvatarDir)
+
+	// 健康检查
+	router.GET("/health", h.Health)
+	router.GET("/health/live", h.Live)
+	router.GET("/health/ready", h.Ready)
+	router.GET("/readyz", h.Ready)
+
+	// API 文档，由 swag 根据接口注释生成
+	router.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
+}
+
+// healthCheckTimeout 依赖探测的超时时间，保证健康检查不会挂起
+const healthCheckTimeout = 2 * time.Second
+
+// Health 健康检查，探测数据库和Redis，数据库或必需的Redis不可用时返回 503
+func (h *Handler) Health(c *gin.Context) {
+	checks, healthy := h.checkDependencies(c.Request.Context())
+	if !healthy {
+		c.JSON(http.StatusServiceUnavailable, gin.H{
+			"success": false,
+			"message": "依赖服务不可用",
+			"data": gin.H{
+				"service": "微服务API",
+				"version": "1.0.0",
+				"checks":  checks,
+			},
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "服务运行正常",
+		"data": gin.H{
+			"service": "微服务API",
+			"version": "1.0.0",
+			"checks":  checks,
+		},
+	})
+}
+
+// PurgeDeleted 彻底删除超过指定天数的软删除用户和产品
+// 清理在后台按批次执行，立即返回202；同一时间只允许一个清理任务，结果记录在日志中
+// @Summary 彻底删除软删除超过指定天数的用户和产品
+// @Description 清理在后台分批执行，删除的数量记录在日志中
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Security AdminToken
+// @Param request body models.PurgeRequest true "清理条件"
+// @Success 202 {object} api.Response{data=api.PurgeAccepted} "清理任务已开始"
+// @Failure 400 {object} api.ValidationErrorResponse "请求参数错误"
+// @Failure 403 {object} api.ErrorResponse "无权访问，或缺少所需的权限（error 字段为缺少的权限）"
+// @Failure 409 {object} api.ErrorResponse "清理任务正在执行"
+// @Router /admin/purge [post]
+func (h *Handler) PurgeDeleted(c *gin.Context) {
+	var req models.PurgeRequest
+	if !h.bindJSON(c, &req) {
+		return
+	}
+
+	if !h.purging.CompareAndSwap(false, true) {
+		c.JSON(http.StatusConflict, gin.H{
+			"success": false,
+			"message": "清理任务正在执行",
+		})
+		return
+	}
+
+	resp := models.PurgeResponse{Before: time.Now().AddDate(0, 0, -req.OlderThanDays)}
+	log := h.logger.With("before", resp.Before, "request_id", middleware.GetRequestID(c))
+	log.Warn("开始清理已删除记录")
+
+	// 清理可能耗时较长，不能随请求结束而取消
+	ctx := context.WithoutCancel(c.Request.Context())
+	go func() {
+		defer h.purging.Store(false)
+
+		var err error
+		if resp.Users, err = h.userService.PurgeDeleted(ctx, resp.Before); err == nil {
+			resp.Products, err = h.productService.PurgeDeleted(ctx, resp.Before)
+		}
+		if err != nil {
+			log.Error("清理已删除记录失败", "users", resp.Users, "products", resp.Products, "error", err)
+			return
+		}
+		log.Warn("清理已删除记录完成", "users", resp.Users, "products", resp.Products)
+	}()
+
+	c.JSON(http.StatusAccepted, gin.H{
+		"success": true,
+		"message": "清理任务已开始",
+		"data":    gin.H{"before": resp.Before},
+	})
+}
+
+// SetUserScopes 设置用户的权限范围
+// @Summary 设置用户的权限范围
+// @Description scopes 为 null 时恢复为新用户的默认权限（只读和 profile:write）。已签发的token在过期前保持原有权限。
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Security AdminToken
+// @Param id path int true "用户ID"
+// @Param request body models.SetUserScopesRequest true "权限范围"
+// @Success 200 {object} api.Response{data=models.UserPublic} "已更新"
+// @Failure 400 {object} api.ValidationErrorResponse "请求参数错误"
+// @Failure 403 {object} api.ErrorResponse "无权访问，或缺少所需的权限（error 字段为缺少的权限）"
+// @Failure 404 {object} api.ErrorResponse "资源不存在"
+// @Router /admin/users/{id}/scopes [put]
+func (h *Handler) SetUserScopes(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"message": "无效的用户ID",
+		})
+		return
+	}
+
+	var req models.SetUserScopesRequest
+	if !h.bindJSON(c, &req) {
+		return
+	}
+
+	user, err := h.userService.SetScopes(c.Request.Context(), uint(id), req.Scopes)
+	if err != nil {
+		if errors.Is(err, service.ErrUnknownScope) {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"success": false,
+				"message": err.Error(),
+			})
+			return
+		}
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{
+				"success": false,
+				"message": "用户不存在",
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"message": "设置用户权限失败",
+		})
+		return
+	}
+
+	h.logger.Warn("用户权限已变更", "user_id", id, "scopes", req.Scopes, "request_id", middleware.GetRequestID(c))
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "用户权限已更新",
+		"data":    user.ToPublic(),
+	})
+}
+
+// Live 存活检查，进程能处理请求即返回 200，不探测依赖
+func (h *Handler) Live(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "服务存活",
+	})
+}
+
+// Ready 就绪检查，依赖不可用或优雅关闭排空阶段返回 503，以便负载均衡摘除实例
+func (h *Handler) Ready(c *gin.Context) {
+	if !h.readiness.Ready() {
+		c.JSON(http.StatusServiceUnavailable, gin.H{
+			"success": false,
+			"message": "服务正在关闭",
+		})
+		return
+	}
+
+	checks, healthy := h.checkDependencies(c.Request.Context())
+	if !healthy {
+		c.JSON(http.StatusServiceUnavailable, gin.H{
+			"success": false,
+			"message": "依赖服务不可用",
+			"data":    gin.H{"checks": checks},
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "服务已就绪",
+		"data":    gin.H{"checks": checks},
+	})
+}
+
+// 依赖状态，探测失败的原因只记录在日志中，不在公开的健康检查响应中暴露
+const (
+	dependencyOK          = "ok"
+	dependencyUnavailable = "unavailable"
+)
+
+// checkDependencies 探测数据库和Redis，返回各依赖的状态以及服务是否可用
+// Redis不是必需依赖（REDIS_REQUIRED=false）时，Redis不可用只体现在状态中，不影响可用性
+func (h *Handler) checkDependencies(ctx context.Context) (gin.H, bool) {
+	ctx, cancel := context.WithTimeout(ctx, healthCheckTimeout)
+	defer cancel()
+
+	checks := gin.H{
+		"database": dependencyOK,
+		"redis":    dependencyOK,
+	}
+	healthy := true
+
+	if err := h.pingDatabase(ctx); err != nil {
+		h.logger.WarnCtx(ctx, "数据库健康检查失败", "error", err)
+		checks["database"] = dependencyUnavailable
+		healthy = false
+	}
+	if err := h.cache.Ping(ctx); err != nil {
+		h.logger.WarnCtx(ctx, "Redis健康检查失败", "error", err)
+		checks["redis"] = dependencyUnavailable
+		if h.config.RedisRequired {
+			healthy = false
+		}
+	}
+
+	return checks, healthy
+}
+
+// pingDatabase 检查数据库连接是否可用
+func (h *Handler) pingDatabase(ctx context.Context) error {
+	sqlDB, err := h.db.DB()
+	if err != nil {
+		return err
+	}
+	return sqlDB.PingContext(ctx)
+}
+
+// GetReadOnly 查询只读模式状态
+// @Summary 查询只读模式
+// @Tags admin
+// @Produce json
+// @Security AdminToken
+// @Success 200 {object} api.Response{data=api.ReadOnlyStatus} "当前状态"
+// @Failure 403 {object} api.ErrorResponse "无权访问，或缺少所需的权限（error 字段为缺少的权限）"
+// @Router /admin/read-only [get]
+func (h *Handler) GetReadOnly(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "获取只读模式状态成功",
+		"data":    gin.H{"enabled": h.readOnly.Enabled()},
+	})
+}
+
+// SetReadOnly 开启或关闭只读模式
+// @Summary 开启或关闭只读模式
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Security AdminToken
+// @Param request body models.ReadOnlyRequest true "只读模式开关"
+// @Success 200 {object} api.Response{data=api.ReadOnlyStatus} "切换成功"
+// @Failure 400 {object} api.ValidationErrorResponse "请求参数错误"
+// @Failure 403 {object} api.ErrorResponse "无权访问，或缺少所需的权限（error 字段为缺少的权限）"
+// @Router /admin/read-only [put]
+func (h *Handler) SetReadOnly(c *gin.Context) {
+	var req models.ReadOnlyRequest
+	if !h.bindJSON(c, &req) {
+		return
+	}
+
+	h.readOnly.Set(*req.Enabled)
+	h.logger.Warn("只读模式已切换", "enabled", *req.Enabled, "request_id", middleware.GetRequestID(c))
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "只读模式已更新",
+		"data":    gin.H{"enabled": *req.Enabled},
+	})
+}
+
+// Login 用户登录
+// @Summary 用户登录
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param request body models.LoginRequest true "登录信息"
+// @Success 200 {object} api.Response{data=models.LoginResponse} "登录成功"
+// @Failure 400 {object} api.ValidationErrorResponse "请求参数错误"
+// @Failure 401 {object} api.ErrorResponse "用户名或密码错误"
+// @Failure 403 {object} api.ErrorResponse "邮箱尚未验证（REQUIRE_EMAIL_VERIFICATION 开启时），或申请的权限超出用户的授权范围"
+// @Failure 429 {object} api.ErrorResponse "请求过于频繁"
+// @Router /auth/login [post]
+func (h *Handler) Login(c *gin.Context) {
+	var req models.LoginRequest
+	if !h.bindJSON(c, &req) {
+		return
+	}
+
+	if h.config.TokenFingerprintBinding {
+		req.Fingerprint = auth.Fingerprint(c.GetHeader("User-Agent"), c.GetHeader(auth.FingerprintHeader))
+	}
+
+	resp, err := h.authService.Login(c.Request.Context(), &req)
+	if err != nil {
+		if errors.Is(err, service.ErrEmailNotVerified) || errors.Is(err, service.ErrScopeNotAllowed) {
+			c.JSON(http.StatusForbidden, gin.H{
+				"success": false,
+				"message": err.Error(),
+			})
+			return
+		}
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "登录成功",
+		"data":    resp,
+	})
+}
+
+// RefreshToken 使用refresh token换取新的访问token和refresh token
+// @Summary 使用refresh token换取新的访问token和refresh token
+// @Description 每个refresh token只能使用一次。已使用的refresh token再次出现时，同一次登录签发的全部refresh token都会被撤销。
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param request body models.RefreshTokenRequest true "refresh token"
+// @Success 200 {object} api.Response{data=models.LoginResponse} "刷新成功"
+// @Failure 400 {object} api.ValidationErrorResponse "请求参数错误"
+// @Failure 401 {object} api.ErrorResponse "refresh token无效、已过期、已撤销或被重复使用"
+// @Failure 429 {object} api.ErrorResponse "请求过于频繁"
+// @Router /auth/refresh [post]
+func (h *Handler) RefreshToken(c *gin.Context) {
+	var req models.RefreshTokenRequest
+	if !h.bindJSON(c, &req) {
+		return
+	}
+
+	if h.config.TokenFingerprintBinding {
+		req.Fingerprint = auth.Fingerprint(c.GetHeader("User-Agent"), c.GetHeader(auth.FingerprintHeader))
+	}
+
+	resp, err := h.authService.Refresh(c.Request.Context(), &req)
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidRefreshToken) || errors.Is(err, service.ErrRefreshTokenReused) {
+			c.JSON(http.StatusUnauthorized, gin.H{
+				"success": false,
+				"message": err.Error(),
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"message": "刷新token失败",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "刷新token成功",
+		"data":    resp,
+	})
+}
+
+// VerifyEmail 使用验证邮件中的token确认邮箱
+// @Summary 使用验证邮件中的token确认邮箱
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param request body models.VerifyEmailRequest true "验证token"
+// @Success 200 {object} api.Response{data=models.UserPublic} "验证成功"
+// @Failure 400 {object} api.ErrorResponse "验证链接无效、已过期或邮箱已变更"
+// @Router /auth/verify [post]
+func (h *Handler) VerifyEmail(c *gin.Context) {
+	var req models.VerifyEmailRequest
+	if !h.bindJSON(c, &req) {
+		return
+	}
+
+	user, err := h.authService.VerifyEmail(c.Request.Context(), req.Token)
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidVerifyToken) {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"success": false,
+				"message": err.Error(),
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"message": "邮箱验证失败",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "邮箱验证成功",
+		"data":    user.ToPublic(),
+	})
+}
+
+// ForgotPassword 申请重置密码，无论邮箱是否存在都返回相同的响应
+// @Summary 申请重置密码
+// @Description 向邮箱发送重置密码链接。无论邮箱是否已注册都返回相同的响应。
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param request body models.ForgotPasswordRequest true "邮箱"
+// @Success 200 {object} api.Response "已受理"
+// @Failure 400 {object} api.ValidationErrorResponse "请求参数错误"
+// @Failure 429 {object} api.ErrorResponse "请求过于频繁"
+// @Router /auth/forgot-password [post]
+func (h *Handler) ForgotPassword(c *gin.Context) {
+	var req models.ForgotPasswordRequest
+	if !h.bindJSON(c, &req) {
+		return
+	}
+
+	if err := h.authService.ForgotPassword(c.Request.Context(), req.Email); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"message": "申请重置密码失败",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "如果该邮箱已注册，重置密码链接已发送到该邮箱",
+	})
+}
+
+// ResetPassword 使用重置密码链接中的token设置新密码
+// @Summary 使用重置密码邮件中的token设置新密码
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param request body models.ResetPasswordRequest true "重置token和新密码"
+// @Success 200 {object} api.Response "密码已重置"
+// @Failure 400 {object} api.ErrorResponse "请求参数错误，或重置链接无效、已过期、已使用"
+// @Failure 429 {object} api.ErrorResponse "请求过于频繁"
+// @Router /auth/reset-password [post]
+func (h *Handler) ResetPassword(c *gin.Context) {
+	var req models.ResetPasswordRequest
+	if !h.bindJSON(c, &req) {
+		return
+	}
+
+	if err := h.authService.ResetPassword(c.Request.Context(), req.Token, req.Password); err != nil {
+		if errors.Is(err, service.ErrInvalidResetToken) {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"success": false,
+				"message": err.Error(),
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"message": "重置密码失败",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "密码已重置，请使用新密码登录",
+	})
+}
+
+// Introspect 校验token并返回其中的声明，不执行任何操作
+// @Summary 校验令牌并返回其中的声明
+// @Description 开启 TOKEN_FINGERPRINT_BINDING 时需转发客户端的 User-Agent 和 X-Client-Fingerprint 请求头，绑定了指纹的token只对原客户端有效
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param request body models.IntrospectRequest true "令牌"
+// @Success 200 {object} api.Response{data=models.IntrospectResponse} "token有效"
+// @Failure 400 {object} api.ValidationErrorResponse "请求参数错误"
+// @Failure 401 {object} api.Response{data=models.IntrospectResponse} "token无效、已过期、已撤销，或所属用户已删除或禁用，data.active 为 false"
+// @Failure 429 {object} api.ErrorResponse "请求过于频繁"
+// @Router /auth/introspect [post]
+func (h *Handler) Introspect(c *gin.Context) {
+	var req models.IntrospectRequest
+	if !h.bindJSON(c, &req) {
+		return
+	}
+
+	if h.config.TokenFingerprintBinding {
+		req.Fingerprint = auth.Fingerprint(c.GetHeader("User-Agent"), c.GetHeader(auth.FingerprintHeader))
+	}
+
+	resp, err := h.authService.Introspect(c.Request.Context(), &req)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"message": "校验token失败",
+		})
+		return
+	}
+	if !resp.Active {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"success": false,
+			"message": "token无效或已过期",
+			"data":    resp,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "token有效",
+		"data":    resp,
+	})
+}
+
+// CreateUser 创建用户
+// @Summary 注册用户
+// @Tags users
+// @Accept json
+// @Produce json
+// @Param Idempotency-Key header string false "幂等键，重试时使用相同的值可避免重复创建，重放的响应带 Idempotent-Replayed 头"
+// @Param request body models.CreateUserRequest true "用户信息"
+// @Success 201 {object} api.Response{data=models.UserPublic} "创建成功"
+// @Header 201 {string} Location "新用户的地址"
+// @Failure 400 {object} api.ValidationErrorResponse "参数错误，或用户名、邮箱已存在"
+// @Failure 409 {object} api.ErrorResponse "相同幂等键的请求正在处理中"
+// @Failure 422 {object} api.ErrorResponse "幂等键已用于内容不同的请求"
+// @Router /users [post]
+func (h *Handler) CreateUser(c *gin.Context) {
+	var req models.CreateUserRequest
+	if !h.bindJSON(c, &req) {
+		return
+	}
+
+	user, err := h.userService.CreateUser(c.Request.Context(), &req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+
+	c.Header("Location", fmt.Sprintf("/api/v1/users/%d", user.ID))
+	c.JSON(http.StatusCreated, gin.H{
+		"success": true,
+		"message": "用户创建成功",
+		"data":    user.ToPublic(),
+	})
+}
+
+// CreateUsers 批量创建用户，仅限管理员
+// @Summary 批量创建用户
+// @Description 除登录认证外还需要 X-Admin-Token，未配置管理令牌时返回 404
+// @Tags users
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Security APIKeyAuth
+// @Param X-Admin-Token header string true "管理令牌"
+// @Param request body models.BatchCreateUsersRequest true "用户列表"
+// @Success 201 {object} api.Response{data=models.BatchCreateUsersResponse} "创建成功；尽力模式下部分失败时 success 为 false，errors 列出失败的行"
+// @Failure 400 {object} api.Response{data=models.BatchCreateUsersResponse} "参数错误，或没有任何用户创建成功（全有或全无模式下任意一行失败）"
+// @Failure 401 {object} api.ErrorResponse "未认证或令牌无效"
+// @Failure 403 {object} api.ErrorResponse "管理令牌错误，或缺少所需的权限（error 字段为缺少的权限）"
+// @Failure 404 {object} api.ErrorResponse "未配置管理令牌"
+// @Router /users/batch [post]
+func (h *Handler) CreateUsers(c *gin.Context) {
+	var req models.BatchCreateUsersRequest
+	if !h.bindJSON(c, &req) {
+		return
+	}
+
+	// 逐行校验，收集每一行的字段错误
+	resp := models.BatchCreateUsersResponse{
+		Created: []models.UserPublic{},
+		Errors:  []models.BatchRowError{},
+	}
+	valid := make([]*models.CreateUserRequest, 0, len(req.Users))
+	validIndex := make([]int, 0, len(req.Users))
+	for i, row := range req.Users {
+		if row == nil {
+			resp.Errors = append(resp.Errors, models.BatchRowError{Index: i, Error: "用户数据不能为空"})
+			continue
+		}
+		if err := binding.Validator.ValidateStruct(row); err != nil {
+			resp.Errors = append(resp.Errors, models.BatchRowError{
+				Index:  i,
+				Error:  "请求参数错误",
+				Fields: validationErrors(err),
+			})
+			continue
+		}
+		valid = append(valid, row)
+		validIndex = append(validIndex, i)
+	}
+
+	// 全有或全无模式下，存在校验失败的行时整批拒绝
+	if h.config.UserBatchAtomic && len(resp.Errors) > 0 {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"message": "批量创建失败",
+			"data":    resp,
+		})
+		return
+	}
+
+	users, errs := h.userService.CreateUsers(c.Request.Context(), valid)
+	for i, err := range errs {
+		if err != nil {
+			resp.Errors = append(resp.Errors, models.BatchRowError{Index: validIndex[i], Error: err.Error()})
+		}
+	}
+	if users != nil {
+		resp.Created = models.ToPublicUsers(users)
+	}
+	sort.Slice(resp.Errors, func(i, j int) bool {
+		return resp.Errors[i].Index < resp.Errors[j].Index
+	})
+
+	if len(resp.Created) == 0 && len(resp.Errors) > 0 {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"message": "批量创建失败",
+			"data":    resp,
+		})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"success": len(resp.Errors) == 0,
+		"message": fmt.Sprintf("成功创建%d个用户", len(resp.Created)),
+		"data":    resp,
+	})
+}
+
+// GetUser 获取用户
+// @Summary 获取用户
+// @Tags users
+// @Produce json
+// @Security BearerAuth
+// @Security APIKeyAuth
+// @Param id path int true "用户ID"
+// @Success 200 {object} api.Response{data=models.UserPublic} "获取成功"
+// @Failure 401 {object} api.ErrorResponse "未认证或令牌无效"
+// @Failure 403 {object} api.ErrorResponse "无权访问，或缺少所需的权限（error 字段为缺少的权限）"
+// @Failure 404 {object} api.ErrorResponse "资源不存在"
+// @Router /users/{id} [get]
+func (h *Handler) GetUser(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"message": "无效的用户ID",
+		})
+		return
+	}
+
+	ctx, cacheStatus := h.cacheStatus(c)
+	user, err := h.userService.GetUser(ctx, uint(id))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"success": false,
+			"message": "用户不存在",
+		})
+		return
+	}
+
+	h.setCacheHeaders(c, service.UserCacheKey(uint(id)), cacheStatus)
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "获取用户成功",
+		"data":    user.ToPublic(),
+	})
+}
+
+// cacheStatus 返回记录缓存读取结果的 context，用于设置缓存调试响应头
+func (h *Handler) cacheStatus(c *gin.Context) (context.Context, *cache.Status) {
+	return cache.WithStatus(c.Request.Context())
+}
+
+// setCacheHeaders 设置缓存调试响应头：X-Cache 标识本次读取是否命中缓存，X-Cache-Key 为使用的缓存键
+// 没有发生缓存读取时不输出；生产环境不输出 X-Cache-Key，避免暴露内部的缓存键
+func (h *Handler) setCacheHeaders(c *gin.Context, key string, status *cache.Status) {
+	if !status.Recorded {
+		return
+	}
+
+	if status.Hit {
+		c.Header("X-Cache", "HIT")
+	} else {
+		c.Header("X-Cache", "MISS")
+	}
+	if h.config.Environment != "production" {
+		c.Header("X-Cache-Key", key)
+	}
+}
+
+// UpdateUser 更新用户
+// @Summary 更新用户
+// @Description 持有 profile:write 权限时只能更新本人，持有 users:write 权限时可以更新任意用户
+// @Tags users
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Security APIKeyAuth
+// @Param id path int true "用户ID"
+// @Param request body models.UpdateUserRequest true "更新内容"
+// @Success 200 {object} api.Response{data=models.UserPublic} "更新成功"
+// @Failure 400 {object} api.ValidationErrorResponse "请求参数错误"
+// @Failure 401 {object} api.ErrorResponse "未认证或令牌无效"
+// @Failure 403 {object} api.ErrorResponse "无权访问，或缺少所需的权限（error 字段为缺少的权限）"
+// @Failure 404 {object} api.ErrorResponse "资源不存在"
+// @Router /users/{id} [put]
+func (h *Handler) UpdateUser(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"message": "无效的用户ID",
+		})
+		return
+	}
+
+	var req models.UpdateUserRequest
+	if !h.bindJSON(c, &req) {
+		return
+	}
+
+	user, err := h.userService.UpdateUser(c.Request.Context(), uint(id), &req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "用户更新成功",
+		"data":    user.ToPublic(),
+	})
+}
+
+// DeleteUser 删除用户（软删除，可恢复）
+// @Summary 删除用户
+// @Tags users
+// @Produce json
+// @Security BearerAuth
+// @Security APIKeyAuth
+// @Param id path int true "用户ID"
+// @Param purge query bool false "为 true 时彻底删除用户及其审计记录、API Key和头像文件，需要 X-Admin-Token"
+// @Success 200 {object} api.Response "删除成功"
+// @Success 204 "删除成功（IDEMPOTENT_DELETE 开启时）"
+// @Failure 400 {object} api.ValidationErrorResponse "请求参数错误"
+// @Failure 401 {object} api.ErrorResponse "未认证或令牌无效"
+// @Failure 403 {object} api.ErrorResponse "无权访问，或缺少所需的权限（error 字段为缺少的权限）"
+// @Failure 404 {object} api.ErrorResponse "资源不存在"
+// @Router /users/{id} [delete]
+func (h *Handler) DeleteUser(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"message": "无效的用户ID",
+		})
+		return
+	}
+
+	purge, err := parseBoolQuery(c, "purge")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"message": "查询参数错误",
+			"error":   err.Error(),
+		})
+		return
+	}
+
+	// purge=true 时彻底删除（不可恢复），仅限管理员
+	if purge != nil && *purge {
+		if !middleware.HasAdminToken(c, h.config.AdminToken) {
+			c.JSON(http.StatusForbidden, gin.H{
+				"success": false,
+				"message": "彻底删除用户需要管理员权限",
+			})
+			return
+		}
+		h.respondDelete(c, h.userService.PurgeUser(c.Request.Context(), uint(id)), "用户不存在", "用户已彻底删除")
+		return
+	}
+
+	h.respondDelete(c, h.userService.DeleteUser(c.Request.Context(), uint(id)), "用户不存在", "用户删除成功")
+}
+
+// isSelfOrAdmin 请求是否由该用户本人发起，或携带了正确的管理令牌
+func (h *Handler) isSelfOrAdmin(c *gin.Context, userID uint) bool {
+	return c.GetUint("user_id") == userID || middleware.HasAdminToken(c, h.config.AdminToken)
+}
+
+// respondDelete 按配置的删除语义输出删除结果
+// 严格模式下不存在的记录返回404；幂等模式下无论记录是否存在都返回204
+func (h *Handler) respondDelete(c *gin.Context, err error, notFoundMessage, successMessage string) {
+	if err != nil {
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"success": false,
+				"message": err.Error(),
+			})
+			return
+		}
+		if !h.config.IdempotentDelete {
+			c.JSON(http.StatusNotFound, gin.H{
+				"success": false,
+				"message": notFoundMessage,
+			})
+			return
+		}
+	}
+
+	if h.config.IdempotentDelete {
+		c.Status(http.StatusNoContent)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": successMessage,
+	})
+}
+
+// RestoreUser 恢复已删除的用户，仅限本人或管理员
+// @Summary 恢复已删除（软删除）的用户
+// @Description 只能恢复本人的账户，携带管理令牌时可以恢复任意用户
+// @Tags users
+// @Produce json
+// @Security BearerAuth
+// @Security APIKeyAuth
+// @Param X-Admin-Token header string false "管理令牌，恢复其他用户时需要"
+// @Param id path int true "用户ID"
+// @Success 200 {object} api.Response{data=models.UserPublic} "恢复成功"
+// @Failure 401 {object} api.ErrorResponse "未认证或令牌无效"
+// @Failure 403 {object} api.ErrorResponse "无权访问，或缺少所需的权限（error 字段为缺少的权限）"
+// @Failure 404 {object} api.ErrorResponse "用户不存在或未被删除"
+// @Router /users/{id}/restore [post]
+func (h *Handler) RestoreUser(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"message": "无效的用户ID",
+		})
+		return
+	}
+
+	if !h.isSelfOrAdmin(c, uint(id)) {
+		c.JSON(http.StatusForbidden, gin.H{
+			"success": false,
+			"message": "无权恢复该用户",
+		})
+		return
+	}
+
+	user, err := h.userService.RestoreUser(c.Request.Context(), uint(id))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{
+				"success": false,
+				"message": "用户不存在或未被删除",
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"message": "恢复用户失败",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "用户恢复成功",
+		"data":    user.ToPublic(),
+	})
+}
+
+// ListUsers 获取用户列表
+// @Summary 获取用户列表
+// @Tags users
+// @Produce json
+// @Security BearerAuth
+// @Security APIKeyAuth
+// @Param page query int false "页码" minimum(1) default(1)
+// @Param limit query int false "每页数量" minimum(1) maximum(100) default(10)
+// @Param search query string false "按用户名、邮箱、姓名模糊搜索"
+// @Success 200 {object} api.Response{data=models.UserListResponse} "获取成功"
+// @Failure 400 {object} api.ValidationErrorResponse "请求参数错误"
+// @Failure 401 {object} api.ErrorResponse "未认证或令牌无效"
+// @Failure 403 {object} api.ErrorResponse "无权访问，或缺少所需的权限（error 字段为缺少的权限）"
+// @Router /users [get]
+func (h *Handler) ListUsers(c *gin.Context) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "10"))
+
+	var users []*models.User
+	var total int64
+	var err error
+	if search := c.Query("search"); search != "" {
+		users, total, err = h.userService.SearchUsers(c.Request.Context(), search, page, limit)
+	} else {
+		users, total, err = h.userService.ListUsers(c.Request.Context(), page, limit)
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"message": "获取用户列表失败",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "获取用户列表成功",
+		"data": models.UserListResponse{
+			Users:      models.ToPublicUsers(users),
+			Pagination: models.NewPagination(total, page, limit),
+		},
+	})
+}
+
+// UploadAvatar 上传用户头像（multipart 表单字段 avatar）
+// @Summary 上传头像
+// @Description 持有 profile:write 权限时只能修改本人的头像
+// @Tags users
+// @Accept multipart/form-data
+// @Produce json
+// @Security BearerAuth
+// @Security APIKeyAuth
+// @Param id path int true "用户ID"
+// @Param avatar formData file true "JPEG、PNG、GIF 或 WebP 图片"
+// @Success 200 {object} api.Response{data=models.UserPublic} "上传成功"
+// @Failure 400 {object} api.ValidationErrorResponse "请求参数错误"
+// @Failure 401 {object} api.ErrorResponse "未认证或令牌无效"
+// @Failure 403 {object} api.ErrorResponse "无权访问，或缺少所需的权限（error 字段为缺少的权限）"
+// @Failure 404 {object} api.ErrorResponse "资源不存在"
+// @Failure 413 {object} api.ErrorResponse "请求体过大"
+// @Router /users/{id}/avatar [post]
+func (h *Handler) UploadAvatar(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"message": "无效的用户ID",
+		})
+		return
+	}
+
+	// 目前没有管理员角色，只允许修改本人的头像
+	if currentUserID, _ := c.Get("user_id"); currentUserID != uint(id) {
+		c.JSON(http.StatusForbidden, gin.H{
+			"success": false,
+			"message": "无权修改该用户的头像",
+		})
+		return
+	}
+
+	// 请求体大小由 MaxBodySize 按 BodySizeLimits 限制
+	fileHeader, err := c.FormFile("avatar")
+	if err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{
+				"success": false,
+				"message": fmt.Sprintf("请求体不能超过%d字节", maxBytesErr.Limit),
+			})
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"message": "请上传头像文件",
+			"error":   err.Error(),
+		})
+		return
+	}
+	if fileHeader.Size > h.config.AvatarMaxSize {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{
+			"success": false,
+			"message": fmt.Sprintf("头像文件不能超过%d字节", h.config.AvatarMaxSize),
+		})
+		return
+	}
+
+	file, err := fileHeader.Open()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"message": "读取头像文件失败",
+		})
+		return
+	}
+	defer file.Close()
+
+	// 根据文件内容判断类型，不信任客户端声明的Content-Type
+	head := make([]byte, 512)
+	n, _ := io.ReadFull(file, head)
+	contentType := http.DetectContentType(head[:n])
+	if _, ok := models.AvatarContentTypes[contentType]; !ok {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"message": "不支持的图片类型",
+			"error":   contentType,
+		})
+		return
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"message": "读取头像文件失败",
+		})
+		return
+	}
+
+	user, err := h.userService.UpdateAvatar(c.Request.Context(), uint(id), contentType, file)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{
+				"success": false,
+				"message": "用户不存在",
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"message": "上传头像失败",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "头像上传成功",
+		"data":    user.ToPublic(),
+	})
+}
+
+// GetUserAuditTrail 获取用户审计记录，仅限本人或管理员
+// @Summary 获取用户的审计记录
+// @Description 只能查看本人的审计记录，携带管理令牌时可以查看任意用户
+// @Tags users
+// @Produce json
+// @Security BearerAuth
+// @Security APIKeyAuth
+// @Param X-Admin-Token header string false "管理令牌，查看其他用户的审计记录时需要"
+// @Param id path int true "用户ID"
+// @Param page query int false "页码" minimum(1) default(1)
+// @Param limit query int false "每页数量" minimum(1) maximum(100) default(10)
+// @Success 200 {object} api.Response{data=models.AuditListResponse} "获取成功"
+// @Failure 401 {object} api.ErrorResponse "未认证或令牌无效"
+// @Failure 403 {object} api.ErrorResponse "无权访问，或缺少所需的权限（error 字段为缺少的权限）"
+// @Router /users/{id}/audit [get]
+func (h *Handler) GetUserAuditTrail(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"message": "无效的用户ID",
+		})
+		return
+	}
+
+	if !h.isSelfOrAdmin(c, uint(id)) {
+		c.JSON(http.StatusForbidden, gin.H{
+			"success": false,
+			"message": "无权查看该用户的审计记录",
+		})
+		return
+	}
 
-	// 需要认证的路由
-	protected := api.Group("")
-	protected.Use(middleware.Auth(jwtManager))
-	{
-		// 用户路由
-		protected.GET("/users", h.ListUsers)
-		protected.GET("/users/:id", h.GetUser)
-		protected.PUT("/users/:id", h.UpdateUser)
-		protected.DELETE("/users/:id", h.DeleteUser)
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "10"))
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 || limit > 100 {
+		limit = 10
+	}
 
-		// 产品路由
-		protected.GET("/products", h.ListProducts)
-		protected.POST("/products", h.CreateProduct)
-		protected.GET("/products/:id", h.GetProduct)
-		protected.PUT("/products/:id", h.UpdateProduct)
-		protected.DELETE("/products/:id", h.DeleteProduct)
+	resp, err := h.userService.GetAuditTrail(c.Request.Context(), uint(id), page, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"message": "获取审计记录失败",
+		})
+		return
 	}
 
-	// 健康检查
-	router.GET("/health", h.Health)
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "获取审计记录成功",
+		"data":    resp,
+	})
 }
 
-// Health 健康检查
-func (h *Handler) Health(c *gin.Context) {
-	c.JSON(http.StatusOK, gin.H{
+// CreateProduct 创建产品
+// @Summary 创建产品
+// @Tags products
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Security APIKeyAuth
+// @Param Idempotency-Key header string false "幂等键，重试时使用相同的值可避免重复创建，重放的响应带 Idempotent-Replayed 头"
+// @Param request body models.CreateProductRequest true "产品信息"
+// @Success 201 {object} api.Response{data=models.ProductResponse} "创建成功"
+// @Header 201 {string} Location "新产品的地址"
+// @Failure 400 {object} api.ValidationErrorResponse "请求参数错误"
+// @Failure 401 {object} api.ErrorResponse "未认证或令牌无效"
+// @Failure 403 {object} api.ErrorResponse "无权访问，或缺少所需的权限（error 字段为缺少的权限）"
+// @Failure 409 {object} api.ErrorResponse "相同幂等键的请求正在处理中"
+// @Failure 422 {object} api.ErrorResponse "幂等键已用于内容不同的请求"
+// @Router /products [post]
+func (h *Handler) CreateProduct(c *gin.Context) {
+	var req models.CreateProductRequest
+	if !h.bindJSON(c, &req) {
+		return
+	}
+
+	product, err := h.productService.CreateProduct(c.Request.Context(), &req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+
+	c.Header("Location", fmt.Sprintf("/api/v1/products/%d", product.ID))
+	c.JSON(http.StatusCreated, gin.H{
 		"success": true,
-		"message": "服务运行正常",
-		"data": gin.H{
-			"service": "微服务API",
-			"version": "1.0.0",
-		},
+		"message": "产品创建成功",
+		"data":    product.ToResponse(),
 	})
 }
 
-// Login 用户登录
-func (h *Handler) Login(c *gin.Context) {
-	var req models.LoginRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
+// GetProduct 获取产品
+// @Summary 获取产品
+// @Tags products
+// @Produce json
+// @Security BearerAuth
+// @Security APIKeyAuth
+// @Param id path int true "产品ID"
+// @Success 200 {object} api.Response{data=models.ProductResponse} "获取成功"
+// @Header 200 {string} ETag "产品版本号，更新时作为 If-Match 使用"
+// @Failure 401 {object} api.ErrorResponse "未认证或令牌无效"
+// @Failure 403 {object} api.ErrorResponse "无权访问，或缺少所需的权限（error 字段为缺少的权限）"
+// @Failure 404 {object} api.ErrorResponse "资源不存在"
+// @Router /products/{id} [get]
+func (h *Handler) GetProduct(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{
 			"success": false,
-			"message": "请求参数错误",
-			"error":   err.Error(),
+			"message": "无效的产品ID",
 		})
 		return
 	}
 
-	resp, err := h.authService.Login(&req)
+	ctx, cacheStatus := h.cacheStatus(c)
+	product, err := h.productService.GetProduct(ctx, uint(id))
 	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{
+		c.JSON(http.StatusNotFound, gin.H{
 			"success": false,
-			"message": err.Error(),
+			"message": "产品不存在",
 		})
 		return
 	}
 
+	var data interface{} = product.ToResponse()
+	if h.storefrontView() {
+		data = product.ToStorefront()
+	}
+
+	h.setCacheHeaders(c, service.ProductCacheKey(uint(id)), cacheStatus)
+	c.Header("ETag", versionETag(product.Version))
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
-		"message": "登录成功",
-		"data":    resp,
+		"message": "获取产品成功",
+		"data":    data,
 	})
 }
 
-// CreateUser 创建用户
-func (h *Handler) CreateUser(c *gin.Context) {
-	var req models.CreateUserRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
+// maxBatchProductIDs 批量获取产品时一次最多查询的ID数
+const maxBatchProductIDs = 100
+
+// GetProductsByIDs 按ID批量获取产品
+// @Summary 按ID批量获取产品
+// @Description 先批量读取缓存，未命中的产品一次查询数据库；不计入浏览次数
+// @Tags products
+// @Produce json
+// @Security BearerAuth
+// @Security APIKeyAuth
+// @Param ids query string true "逗号分隔的产品ID，最多100个" example(1,2,3)
+// @Success 200 {object} api.Response{data=models.ProductBatchResponse} "获取成功"
+// @Failure 400 {object} api.ErrorResponse "ids 缺失、格式无效或数量超过上限"
+// @Failure 401 {object} api.ErrorResponse "未认证或令牌无效"
+// @Failure 403 {object} api.ErrorResponse "无权访问，或缺少所需的权限（error 字段为缺少的权限）"
+// @Failure 500 {object} api.ErrorResponse "服务器内部错误"
+// @Router /products/batch [get]
+func (h *Handler) GetProductsByIDs(c *gin.Context) {
+	ids, err := parseIDList(c.Query("ids"))
+	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{
 			"success": false,
-			"message": "请求参数错误",
-			"error":   err.Error(),
+			"message": err.Error(),
+		})
+		return
+	}
+	if len(ids) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"message": "参数ids不能为空",
+		})
+		return
+	}
+	if len(ids) > maxBatchProductIDs {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"message": fmt.Sprintf("一次最多查询%d个产品", maxBatchProductIDs),
 		})
 		return
 	}
 
-	user, err := h.userService.CreateUser(&req)
+	found, err := h.productService.GetProductsByIDs(c.Request.Context(), ids)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
+		c.JSON(http.StatusInternalServerError, gin.H{
 			"success": false,
-			"message": err.Error(),
+			"message": "批量获取产品失败",
 		})
 		return
 	}
 
-	c.JSON(http.StatusCreated, gin.H{
+	resp := &models.ProductBatchResponse{
+		Products: make([]models.ProductResponse, 0, len(found)),
+		Missing:  []uint{},
+	}
+	seen := make(map[uint]bool, len(ids))
+	for _, id := range ids {
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+		if product, ok := found[id]; ok {
+			resp.Products = append(resp.Products, product.ToResponse())
+		} else {
+			resp.Missing = append(resp.Missing, id)
+		}
+	}
+
+	var data interface{} = resp
+	if h.storefrontView() {
+		data = resp.ToStorefront()
+	}
+	c.JSON(http.StatusOK, gin.H{
 		"success": true,
-		"message": "用户创建成功",
-		"data":    user,
+		"message": "获取产品成功",
+		"data":    data,
 	})
 }
 
-// GetUser 获取用户
-func (h *Handler) GetUser(c *gin.Context) {
+// GetProductViews 获取产品浏览次数
+// @Summary 获取产品浏览次数
+// @Tags products
+// @Produce json
+// @Security BearerAuth
+// @Security APIKeyAuth
+// @Param id path int true "产品ID"
+// @Success 200 {object} api.Response{data=models.ProductViewsResponse} "获取成功"
+// @Failure 401 {object} api.ErrorResponse "未认证或令牌无效"
+// @Failure 403 {object} api.ErrorResponse "无权访问，或缺少所需的权限（error 字段为缺少的权限）"
+// @Failure 404 {object} api.ErrorResponse "资源不存在"
+// @Router /products/{id}/views [get]
+func (h *Handler) GetProductViews(c *gin.Context) {
 	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{
 			"success": false,
-			"message": "无效的用户ID",
+			"message": "无效的产品ID",
 		})
 		return
 	}
 
-	user, err := h.userService.GetUser(uint(id))
+	views, err := h.productService.GetProductViews(c.Request.Context(), uint(id))
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{
+				"success": false,
+				"message": "产品不存在",
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{
 			"success": false,
-			"message": "用户不存在",
+			"message": "获取产品浏览次数失败",
 		})
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
-		"message": "获取用户成功",
-		"data":    user,
+		"message": "获取产品浏览次数成功",
+		"data":    views,
 	})
 }
 
-// UpdateUser 更新用户
-func (h *Handler) UpdateUser(c *gin.Context) {
+// UpdateProduct 更新产品
+// @Summary 更新产品
+// @Tags products
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Security APIKeyAuth
+// @Param id path int true "产品ID"
+// @Param If-Match header string false "产品版本号（GET 返回的 ETag），请求体未提供 version 时使用"
+// @Param request body models.UpdateProductRequest true "更新内容"
+// @Success 200 {object} api.Response{data=models.ProductResponse} "更新成功"
+// @Failure 400 {object} api.ValidationErrorResponse "请求参数错误"
+// @Failure 401 {object} api.ErrorResponse "未认证或令牌无效"
+// @Failure 403 {object} api.ErrorResponse "无权访问，或缺少所需的权限（error 字段为缺少的权限）"
+// @Failure 404 {object} api.ErrorResponse "资源不存在"
+// @Failure 409 {object} api.ErrorResponse "版本号不一致，产品已被其他请求修改"
+// @Failure 428 {object} api.ErrorResponse "未提供 version 或 If-Match"
+// @Router /products/{id} [put]
+func (h *Handler) UpdateProduct(c *gin.Context) {
 	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{
 			"success": false,
-			"message": "无效的用户ID",
+			"message": "无效的产品ID",
 		})
 		return
 	}
 
-	var req models.UpdateUserRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
+	var req models.UpdateProductRequest
+	if !h.bindJSON(c, &req) {
+		return
+	}
+
+	// 必须提供读取到的版本号，避免并发更新互相覆盖；请求体未提供时使用 If-Match 请求头
+	if req.Version == nil {
+		if ifMatch := c.GetHeader("If-Match"); ifMatch != "" {
+			version, err := parseVersionETag(ifMatch)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{
+					"success": false,
+					"message": "If-Match 须为产品的版本号",
+				})
+				return
+			}
+			req.Version = &version
+		}
+	}
+	if req.Version == nil {
+		c.JSON(http.StatusPreconditionRequired, gin.H{
 			"success": false,
-			"message": "请求参数错误",
-			"error":   err.Error(),
+			"message": "更新产品须提供 version 或 If-Match 请求头",
 		})
 		return
 	}
 
-	user, err := h.userService.UpdateUser(uint(id), &req)
+	product, err := h.productService.UpdateProduct(c.Request.Context(), uint(id), &req)
 	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{
+				"success": false,
+				"message": "产品不存在",
+			})
+			return
+		}
+		if errors.Is(err, repository.ErrConflict) {
+			c.JSON(http.StatusConflict, gin.H{
+				"success": false,
+				"message": err.Error(),
+			})
+			return
+		}
 		c.JSON(http.StatusBadRequest, gin.H{
 			"success": false,
 			"message": err.Error(),
@@ -188,92 +1570,259 @@ func (h *Handler) UpdateUser(c *gin.Context) {
 
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
-		"message": "用户更新成功",
-		"data":    user,
+		"message": "产品更新成功",
+		"data":    product.ToResponse(),
 	})
 }
 
-// DeleteUser 删除用户
-func (h *Handler) DeleteUser(c *gin.Context) {
+// DeleteProduct 删除产品
+// @Summary 删除产品
+// @Tags products
+// @Produce json
+// @Security BearerAuth
+// @Security APIKeyAuth
+// @Param id path int true "产品ID"
+// @Success 200 {object} api.Response "删除成功"
+// @Success 204 "删除成功（IDEMPOTENT_DELETE 开启时）"
+// @Failure 401 {object} api.ErrorResponse "未认证或令牌无效"
+// @Failure 403 {object} api.ErrorResponse "无权访问，或缺少所需的权限（error 字段为缺少的权限）"
+// @Failure 404 {object} api.ErrorResponse "资源不存在"
+// @Router /products/{id} [delete]
+func (h *Handler) DeleteProduct(c *gin.Context) {
 	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{
 			"success": false,
-			"message": "无效的用户ID",
+			"message": "无效的产品ID",
 		})
 		return
 	}
 
-	if err := h.userService.DeleteUser(uint(id)); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
+	h.respondDelete(c, h.productService.DeleteProduct(c.Request.Context(), uint(id)), "产品不存在", "产品删除成功")
+}
+
+// ListCategories 获取分类列表
+// @Summary 获取全部分类
+// @Tags categories
+// @Produce json
+// @Security BearerAuth
+// @Security APIKeyAuth
+// @Success 200 {object} api.Response{data=[]models.CategoryResponse} "获取成功"
+// @Failure 401 {object} api.ErrorResponse "未认证或令牌无效"
+// @Failure 403 {object} api.ErrorResponse "无权访问，或缺少所需的权限（error 字段为缺少的权限）"
+// @Router /categories [get]
+func (h *Handler) ListCategories(c *gin.Context) {
+	categories, err := h.categoryService.ListCategories(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
 			"success": false,
-			"message": err.Error(),
+			"message": "获取分类列表失败",
 		})
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
-		"message": "用户删除成功",
+		"message": "获取分类列表成功",
+		"data":    models.ToCategoryResponses(categories),
 	})
 }
 
-// ListUsers 获取用户列表
-func (h *Handler) ListUsers(c *gin.Context) {
-	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
-	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "10"))
+// CreateCategory 创建分类
+// @Summary 创建分类
+// @Tags categories
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Security APIKeyAuth
+// @Param request body models.CreateCategoryRequest true "分类信息"
+// @Success 201 {object} api.Response{data=models.CategoryResponse} "创建成功"
+// @Header 201 {string} Location "新分类的地址"
+// @Failure 400 {object} api.ValidationErrorResponse "请求参数错误"
+// @Failure 401 {object} api.ErrorResponse "未认证或令牌无效"
+// @Failure 403 {object} api.ErrorResponse "无权访问，或缺少所需的权限（error 字段为缺少的权限）"
+// @Failure 409 {object} api.ErrorResponse "分类名称已存在"
+// @Router /categories [post]
+func (h *Handler) CreateCategory(c *gin.Context) {
+	var req models.CreateCategoryRequest
+	if !h.bindJSON(c, &req) {
+		return
+	}
 
-	users, total, err := h.userService.ListUsers(page, limit)
+	category, err := h.categoryService.CreateCategory(c.Request.Context(), &req)
 	if err != nil {
+		if errors.Is(err, service.ErrCategoryExists) {
+			c.JSON(http.StatusConflict, gin.H{
+				"success": false,
+				"message": err.Error(),
+			})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"success": false,
-			"message": "获取用户列表失败",
+			"message": "创建分类失败",
 		})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{
+	c.Header("Location", fmt.Sprintf("/api/v1/categories/%d", category.ID))
+	c.JSON(http.StatusCreated, gin.H{
 		"success": true,
-		"message": "获取用户列表成功",
-		"data": gin.H{
-			"users": users,
-			"total": total,
-			"page":  page,
-			"limit": limit,
-		},
+		"message": "分类创建成功",
+		"data":    category.ToResponse(),
 	})
 }
 
-// CreateProduct 创建产品
-func (h *Handler) CreateProduct(c *gin.Context) {
-	var req models.CreateProductRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
+// GetCategory 获取分类
+// @Summary 获取分类
+// @Tags categories
+// @Produce json
+// @Security BearerAuth
+// @Security APIKeyAuth
+// @Param id path int true "分类ID"
+// @Success 200 {object} api.Response{data=models.CategoryResponse} "获取成功"
+// @Failure 401 {object} api.ErrorResponse "未认证或令牌无效"
+// @Failure 403 {object} api.ErrorResponse "无权访问，或缺少所需的权限（error 字段为缺少的权限）"
+// @Failure 404 {object} api.ErrorResponse "资源不存在"
+// @Router /categories/{id} [get]
+func (h *Handler) GetCategory(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{
 			"success": false,
-			"message": "请求参数错误",
-			"error":   err.Error(),
+			"message": "无效的分类ID",
+		})
+		return
+	}
+
+	category, err := h.categoryService.GetCategory(c.Request.Context(), uint(id))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"success": false,
+			"message": "分类不存在",
 		})
 		return
 	}
 
-	product, err := h.productService.CreateProduct(&req)
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "获取分类成功",
+		"data":    category.ToResponse(),
+	})
+}
+
+// UpdateCategory 更新分类
+// @Summary 更新分类
+// @Tags categories
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Security APIKeyAuth
+// @Param id path int true "分类ID"
+// @Param request body models.UpdateCategoryRequest true "更新内容"
+// @Success 200 {object} api.Response{data=models.CategoryResponse} "更新成功"
+// @Failure 400 {object} api.ValidationErrorResponse "请求参数错误"
+// @Failure 401 {object} api.ErrorResponse "未认证或令牌无效"
+// @Failure 403 {object} api.ErrorResponse "无权访问，或缺少所需的权限（error 字段为缺少的权限）"
+// @Failure 404 {object} api.ErrorResponse "资源不存在"
+// @Failure 409 {object} api.ErrorResponse "分类名称已存在"
+// @Router /categories/{id} [put]
+func (h *Handler) UpdateCategory(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{
 			"success": false,
-			"message": err.Error(),
+			"message": "无效的分类ID",
 		})
 		return
 	}
 
-	c.JSON(http.StatusCreated, gin.H{
+	var req models.UpdateCategoryRequest
+	if !h.bindJSON(c, &req) {
+		return
+	}
+
+	category, err := h.categoryService.UpdateCategory(c.Request.Context(), uint(id), &req)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{
+				"success": false,
+				"message": "分类不存在",
+			})
+			return
+		}
+		if errors.Is(err, service.ErrCategoryExists) {
+			c.JSON(http.StatusConflict, gin.H{
+				"success": false,
+				"message": err.Error(),
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"message": "更新分类失败",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
 		"success": true,
-		"message": "产品创建成功",
-		"data":    product,
+		"message": "分类更新成功",
+		"data":    category.ToResponse(),
 	})
 }
 
-// GetProduct 获取产品
-func (h *Handler) GetProduct(c *gin.Context) {
+// DeleteCategory 删除分类，分类下仍有产品时返回 409
+// @Summary 删除分类
+// @Tags categories
+// @Produce json
+// @Security BearerAuth
+// @Security APIKeyAuth
+// @Param id path int true "分类ID"
+// @Success 200 {object} api.Response "删除成功"
+// @Failure 401 {object} api.ErrorResponse "未认证或令牌无效"
+// @Failure 403 {object} api.ErrorResponse "无权访问，或缺少所需的权限（error 字段为缺少的权限）"
+// @Failure 404 {object} api.ErrorResponse "资源不存在"
+// @Failure 409 {object} api.ErrorResponse "分类下仍有产品"
+// @Router /categories/{id} [delete]
+func (h *Handler) DeleteCategory(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"message": "无效的分类ID",
+		})
+		return
+	}
+
+	err = h.categoryService.DeleteCategory(c.Request.Context(), uint(id))
+	if errors.Is(err, repository.ErrCategoryInUse) {
+		c.JSON(http.StatusConflict, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+
+	h.respondDelete(c, err, "分类不存在", "分类删除成功")
+}
+
+// AddProductImage 添加产品图片
+// @Summary 添加产品图片
+// @Tags products
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Security APIKeyAuth
+// @Param id path int true "产品ID"
+// @Param request body models.AddProductImageRequest true "图片信息"
+// @Success 201 {object} api.Response{data=models.ProductImage} "添加成功"
+// @Failure 400 {object} api.ValidationErrorResponse "请求参数错误"
+// @Failure 401 {object} api.ErrorResponse "未认证或令牌无效"
+// @Failure 403 {object} api.ErrorResponse "无权访问，或缺少所需的权限（error 字段为缺少的权限）"
+// @Failure 404 {object} api.ErrorResponse "资源不存在"
+// @Router /products/{id}/images [post]
+func (h *Handler) AddProductImage(c *gin.Context) {
 	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{
@@ -283,24 +1832,48 @@ func (h *Handler) GetProduct(c *gin.Context) {
 		return
 	}
 
-	product, err := h.productService.GetProduct(uint(id))
+	var req models.AddProductImageRequest
+	if !h.bindJSON(c, &req) {
+		return
+	}
+
+	image, err := h.productService.AddImage(c.Request.Context(), uint(id), &req)
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{
+				"success": false,
+				"message": "产品不存在",
+			})
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{
 			"success": false,
-			"message": "产品不存在",
+			"message": err.Error(),
 		})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{
+	c.JSON(http.StatusCreated, gin.H{
 		"success": true,
-		"message": "获取产品成功",
-		"data":    product,
+		"message": "产品图片添加成功",
+		"data":    image,
 	})
 }
 
-// UpdateProduct 更新产品
-func (h *Handler) UpdateProduct(c *gin.Context) {
+// RemoveProductImage 删除产品图片
+// @Summary 删除产品图片
+// @Tags products
+// @Produce json
+// @Security BearerAuth
+// @Security APIKeyAuth
+// @Param id path int true "产品ID"
+// @Param imageID path int true "图片ID"
+// @Success 200 {object} api.Response "删除成功"
+// @Failure 401 {object} api.ErrorResponse "未认证或令牌无效"
+// @Failure 403 {object} api.ErrorResponse "无权访问，或缺少所需的权限（error 字段为缺少的权限）"
+// @Failure 404 {object} api.ErrorResponse "资源不存在"
+// @Router /products/{id}/images/{imageID} [delete]
+func (h *Handler) RemoveProductImage(c *gin.Context) {
 	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{
@@ -310,18 +1883,55 @@ func (h *Handler) UpdateProduct(c *gin.Context) {
 		return
 	}
 
-	var req models.UpdateProductRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
+	imageID, err := strconv.ParseUint(c.Param("imageID"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"message": "无效的图片ID",
+		})
+		return
+	}
+
+	h.respondDelete(c, h.productService.RemoveImage(c.Request.Context(), uint(id), uint(imageID)), "产品图片不存在", "产品图片删除成功")
+}
+
+// ImportProducts 从CSV文件导入产品（multipart 表单字段 file）
+// 文件按行流式解析，不会整体读入内存或落盘
+// @Summary 从CSV导入产品
+// @Description CSV首行为表头，须包含 name,description,price,stock,category 列（顺序不限），category 为已存在的分类名称。校验通过的行在同一事务中写入。
+// @Tags products
+// @Accept multipart/form-data
+// @Produce json
+// @Security BearerAuth
+// @Security APIKeyAuth
+// @Param file formData file true "CSV文件"
+// @Success 201 {object} api.Response{data=models.ProductImportResponse} "导入完成；部分行失败时 success 为 false，errors 按行号列出未导入的行"
+// @Failure 400 {object} api.ErrorResponse "文件缺失、表头不完整、行数超限，或没有任何行导入成功"
+// @Failure 401 {object} api.ErrorResponse "未认证或令牌无效"
+// @Failure 403 {object} api.ErrorResponse "无权访问，或缺少所需的权限（error 字段为缺少的权限）"
+// @Failure 413 {object} api.ErrorResponse "请求体过大"
+// @Router /products/import [post]
+func (h *Handler) ImportProducts(c *gin.Context) {
+	file, err := multipartFile(c, "file")
+	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{
 			"success": false,
-			"message": "请求参数错误",
+			"message": "请上传CSV文件",
 			"error":   err.Error(),
 		})
 		return
 	}
 
-	product, err := h.productService.UpdateProduct(uint(id), &req)
+	resp, err := h.productService.ImportProducts(c.Request.Context(), file, h.config.ProductImportMaxRows)
 	if err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{
+				"success": false,
+				"message": fmt.Sprintf("CSV文件不能超过%d字节", maxBytesErr.Limit),
+			})
+			return
+		}
 		c.JSON(http.StatusBadRequest, gin.H{
 			"success": false,
 			"message": err.Error(),
@@ -329,15 +1939,59 @@ func (h *Handler) UpdateProduct(c *gin.Context) {
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"success": true,
-		"message": "产品更新成功",
-		"data":    product,
+	if resp.Imported == 0 && len(resp.Errors) > 0 {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"message": "导入失败",
+			"data":    resp,
+		})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"success": len(resp.Errors) == 0,
+		"message": fmt.Sprintf("成功导入%d个产品", resp.Imported),
+		"data":    resp,
 	})
 }
 
-// DeleteProduct 删除产品
-func (h *Handler) DeleteProduct(c *gin.Context) {
+// multipartFile 返回 multipart 请求中指定字段的文件内容，直接读取请求体而不缓存整个文件
+func multipartFile(c *gin.Context, field string) (io.Reader, error) {
+	reader, err := c.Request.MultipartReader()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			return nil, fmt.Errorf("缺少文件字段 %s", field)
+		}
+		if err != nil {
+			return nil, err
+		}
+		if part.FormName() == field {
+			return part, nil
+		}
+	}
+}
+
+// PurchaseProduct 购买产品（扣减库存）
+// @Summary 购买产品（扣减库存）
+// @Tags products
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Security APIKeyAuth
+// @Param id path int true "产品ID"
+// @Param request body models.PurchaseRequest true "购买数量"
+// @Success 200 {object} api.Response "购买成功"
+// @Failure 400 {object} api.ValidationErrorResponse "请求参数错误"
+// @Failure 401 {object} api.ErrorResponse "未认证或令牌无效"
+// @Failure 403 {object} api.ErrorResponse "无权访问，或缺少所需的权限（error 字段为缺少的权限）"
+// @Failure 404 {object} api.ErrorResponse "资源不存在"
+// @Failure 409 {object} api.ErrorResponse "库存不足"
+// @Router /products/{id}/purchase [post]
+func (h *Handler) PurchaseProduct(c *gin.Context) {
 	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{
@@ -347,8 +2001,24 @@ func (h *Handler) DeleteProduct(c *gin.Context) {
 		return
 	}
 
-	if err := h.productService.DeleteProduct(uint(id)); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
+	var req models.PurchaseRequest
+	if !h.bindJSON(c, &req) {
+		return
+	}
+
+	if err := h.productService.DecrementStock(c.Request.Context(), uint(id), req.Quantity); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{
+				"success": false,
+				"message": "产品不存在",
+			})
+			return
+		}
+		status := http.StatusBadRequest
+		if errors.Is(err, repository.ErrInsufficientStock) {
+			status = http.StatusConflict
+		}
+		c.JSON(status, gin.H{
 			"success": false,
 			"message": err.Error(),
 		})
@@ -357,34 +2027,139 @@ func (h *Handler) DeleteProduct(c *gin.Context) {
 
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
-		"message": "产品删除成功",
+		"message": "购买成功",
 	})
 }
 
 // ListProducts 获取产品列表
+// @Summary 获取产品列表
+// @Tags products
+// @Produce json
+// @Security BearerAuth
+// @Security APIKeyAuth
+// @Param page query int false "页码" minimum(1) default(1)
+// @Param limit query int false "每页数量" minimum(1) maximum(100) default(10)
+// @Param category query string false "分类名称"
+// @Param categories query string false "逗号分隔的多个分类名称"
+// @Param category_id query int false "分类ID"
+// @Param min_price query number false "最低价格"
+// @Param max_price query number false "最高价格"
+// @Param search query string false "按名称和描述模糊搜索"
+// @Param active query bool false "是否上架"
+// @Param in_stock query bool false "是否有库存"
+// @Param sort_by query string false "排序字段" Enums(name, price, created_at, stock)
+// @Param order query string false "排序方向" Enums(asc, desc)
+// @Param pagination query string false "分页方式，cursor 为按创建时间倒序的游标分页（忽略 page，传 sort_by 或 order 返回400）" Enums(offset, cursor) default(offset)
+// @Param cursor query string false "上一页返回的 next_cursor，提供时使用游标分页"
+// @Success 200 {object} api.Response{data=models.ProductListResponse} "获取成功，游标分页时 data 为 ProductCursorListResponse"
+// @Failure 400 {object} api.ValidationErrorResponse "请求参数错误"
+// @Failure 401 {object} api.ErrorResponse "未认证或令牌无效"
+// @Failure 403 {object} api.ErrorResponse "无权访问，或缺少所需的权限（error 字段为缺少的权限）"
+// @Router /products [get]
 func (h *Handler) ListProducts(c *gin.Context) {
 	var query models.ProductQuery
-	if err := c.ShouldBindQuery(&query); err != nil {
+	if !h.bindQuery(c, &query) || !bindProductFilters(c, &query) {
+		return
+	}
+
+	if len(query.CategoryList()) > models.MaxQueryCategories {
 		c.JSON(http.StatusBadRequest, gin.H{
 			"success": false,
-			"message": "查询参数错误",
-			"error":   err.Error(),
+			"message": fmt.Sprintf("分类数量不能超过%d个", models.MaxQueryCategories),
 		})
 		return
 	}
 
-	resp, err := h.productService.ListProducts(&query)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"success": false,
-			"message": "获取产品列表失败",
-		})
+	if !bindProductCursor(c, &query) {
 		return
 	}
 
+	var data interface{}
+	if query.UsesCursor() {
+		resp, err := h.productService.ListProductsByCursor(c.Request.Context(), &query)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"success": false,
+				"message": "获取产品列表失败",
+			})
+			return
+		}
+		data = resp
+		if h.storefrontView() {
+			data = resp.ToStorefront()
+		}
+	} else {
+		resp, err := h.productService.ListProducts(c.Request.Context(), &query)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"success": false,
+				"message": "获取产品列表失败",
+			})
+			return
+		}
+		data = resp
+		if h.storefrontView() {
+			data = resp.ToStorefront()
+		}
+	}
+
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
 		"message": "获取产品列表成功",
-		"data":    resp,
+		"data":    data,
+	})
+}
+
+// storefrontView 是否以店面视图输出产品
+func (h *Handler) storefrontView() bool {
+	return h.config.ProductView == models.ProductViewStorefront
+}
+
+// StreamProducts 以NDJSON格式流式输出产品（每行一个产品）
+// @Summary 以 NDJSON 流式返回符合条件的全部产品
+// @Description 支持与产品列表相同的过滤参数（分页参数除外）
+// @Tags products
+// @Produce application/x-ndjson
+// @Security BearerAuth
+// @Security APIKeyAuth
+// @Param category query string false "分类名称"
+// @Param categories query string false "逗号分隔的多个分类名称"
+// @Param category_id query int false "分类ID"
+// @Param min_price query number false "最低价格"
+// @Param max_price query number false "最高价格"
+// @Param search query string false "按名称和描述模糊搜索"
+// @Param active query bool false "是否上架"
+// @Param in_stock query bool false "是否有库存"
+// @Success 200 {object} models.ProductResponse "每行一个产品"
+// @Failure 400 {object} api.ValidationErrorResponse "请求参数错误"
+// @Failure 401 {object} api.ErrorResponse "未认证或令牌无效"
+// @Failure 403 {object} api.ErrorResponse "无权访问，或缺少所需的权限（error 字段为缺少的权限）"
+// @Router /products/stream [get]
+func (h *Handler) StreamProducts(c *gin.Context) {
+	var query models.ProductQuery
+	if !h.bindQuery(c, &query) || !bindProductFilters(c, &query) {
+		return
+	}
+
+	c.Header("Content-Type", "application/x-ndjson")
+	c.Status(http.StatusOK)
+
+	encoder := json.NewEncoder(c.Writer)
+	count := 0
+	err := h.productService.StreamProducts(c.Request.Context(), &query, func(product *models.Product) error {
+		if err := encoder.Encode(product.ToResponse()); err != nil {
+			return err
+		}
+		count++
+		if count%100 == 0 {
+			c.Writer.Flush()
+		}
+		return nil
 	})
+	if err != nil {
+		// 响应头已发送，只能记录日志并中断输出
+		h.logger.Error("流式输出产品失败", "error", err, "count", count)
+		return
+	}
+	c.Writer.Flush()
 }
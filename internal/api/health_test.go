@@ -0,0 +1,181 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/binary-1024/go-build-test/internal/cache"
+	"github.com/binary-1024/go-build-test/internal/config"
+	"github.com/binary-1024/go-build-test/internal/database"
+	"github.com/binary-1024/go-build-test/internal/logger"
+	"github.com/binary-1024/go-build-test/internal/server"
+
+	"github.com/gin-gonic/gin"
+)
+
+// healthDeps 健康检查测试中可以单独停掉的依赖
+type healthDeps struct {
+	databaseDown  bool
+	redisDown     bool
+	redisRequired bool
+}
+
+// newTestHealthRouter 创建注册了健康检查接口的路由，按 deps 停掉数据库或Redis
+func newTestHealthRouter(t *testing.T, deps healthDeps, readiness *server.Readiness) *gin.Engine {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+
+	log := logger.NewLogger("error", "json", logger.FileOutput{})
+	db, err := database.NewConnection(database.DriverSQLite, filepath.Join(t.TempDir(), "test.db"), database.PoolConfig{}, log)
+	if err != nil {
+		t.Fatalf("open database: %v", err)
+	}
+	if deps.databaseDown {
+		sqlDB, err := db.DB()
+		if err != nil {
+			t.Fatalf("get sql.DB: %v", err)
+		}
+		_ = sqlDB.Close()
+	}
+	mr := miniredis.RunT(t)
+	rdb := cache.NewRedisClient("redis://"+mr.Addr(), "test", log)
+	t.Cleanup(func() { _ = rdb.Close() })
+	if deps.redisDown {
+		mr.Close()
+	}
+
+	h := &Handler{db: db, cache: rdb, readiness: readiness, config: &config.Config{RedisRequired: deps.redisRequired}, logger: log}
+	router := gin.New()
+	router.GET("/health", h.Health)
+	router.GET("/health/ready", h.Ready)
+	return router
+}
+
+// healthChecks 解析响应中的依赖状态
+func healthChecks(t *testing.T, body []byte) map[string]string {
+	t.Helper()
+	var resp struct {
+		Data struct {
+			Checks map[string]string `json:"checks"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(body, &resp); err != nil {
+		t.Fatalf("decode response %s: %v", body, err)
+	}
+	return resp.Data.Checks
+}
+
+func TestHealth(t *testing.T) {
+	tests := []struct {
+		name         string
+		deps         healthDeps
+		wantStatus   int
+		wantDatabase string
+		wantRedis    string
+	}{
+		{name: "依赖正常", wantStatus: http.StatusOK, wantDatabase: "ok", wantRedis: "ok"},
+		{name: "数据库不可用", deps: healthDeps{databaseDown: true}, wantStatus: http.StatusServiceUnavailable, wantDatabase: "unavailable", wantRedis: "ok"},
+		{name: "Redis不可用但非必需", deps: healthDeps{redisDown: true}, wantStatus: http.StatusOK, wantDatabase: "ok", wantRedis: "unavailable"},
+		{name: "必需的Redis不可用", deps: healthDeps{redisDown: true, redisRequired: true}, wantStatus: http.StatusServiceUnavailable, wantDatabase: "ok", wantRedis: "unavailable"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router := newTestHealthRouter(t, tt.deps, server.NewReadiness())
+
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/health", nil))
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.wantStatus, w.Body.String())
+			}
+			checks := healthChecks(t, w.Body.Bytes())
+			if checks["database"] != tt.wantDatabase || checks["redis"] != tt.wantRedis {
+				t.Errorf("checks = %v, want database=%s redis=%s", checks, tt.wantDatabase, tt.wantRedis)
+			}
+			// 探测失败的原因（地址、驱动错误）不应出现在响应中
+			if body := w.Body.String(); strings.Contains(body, "closed") || strings.Contains(body, "refused") {
+				t.Errorf("response exposes error details: %s", body)
+			}
+		})
+	}
+}
+
+func TestReady(t *testing.T) {
+	tests := []struct {
+		name       string
+		deps       healthDeps
+		draining   bool
+		wantStatus int
+		wantChecks map[string]string
+	}{
+		{name: "就绪", wantStatus: http.StatusOK, wantChecks: map[string]string{"database": "ok", "redis": "ok"}},
+		{name: "排空中", draining: true, wantStatus: http.StatusServiceUnavailable},
+		{name: "数据库不可用", deps: healthDeps{databaseDown: true}, wantStatus: http.StatusServiceUnavailable, wantChecks: map[string]string{"database": "unavailable", "redis": "ok"}},
+		{name: "Redis不可用但非必需", deps: healthDeps{redisDown: true}, wantStatus: http.StatusOK, wantChecks: map[string]string{"database": "ok", "redis": "unavailable"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			readiness := server.NewReadiness()
+			if tt.draining {
+				readiness.StartDraining()
+			}
+			router := newTestHealthRouter(t, tt.deps, readiness)
+
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/health/ready", nil))
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.wantStatus, w.Body.String())
+			}
+			checks := healthChecks(t, w.Body.Bytes())
+			if len(checks) != len(tt.wantChecks) {
+				t.Fatalf("checks = %v, want %v", checks, tt.wantChecks)
+			}
+			for name, want := range tt.wantChecks {
+				if checks[name] != want {
+					t.Errorf("checks[%s] = %q, want %q", name, checks[name], want)
+				}
+			}
+		})
+	}
+}
+
+func TestDrainingKeepsServingRoutes(t *testing.T) {
+	tests := []struct {
+		name      string
+		path      string
+		wantReady int
+		wantDrain int
+	}{
+		{name: "就绪检查", path: "/health/ready", wantReady: http.StatusOK, wantDrain: http.StatusServiceUnavailable},
+		{name: "其他接口", path: "/health", wantReady: http.StatusOK, wantDrain: http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			readiness := server.NewReadiness()
+			router := newTestHealthRouter(t, healthDeps{}, readiness)
+
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, tt.path, nil))
+			if w.Code != tt.wantReady {
+				t.Fatalf("before draining status = %d, want %d: %s", w.Code, tt.wantReady, w.Body.String())
+			}
+
+			// 排空期间服务继续运行，只有就绪检查改变结果
+			readiness.StartDraining()
+			w = httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, tt.path, nil))
+			if w.Code != tt.wantDrain {
+				t.Errorf("while draining status = %d, want %d: %s", w.Code, tt.wantDrain, w.Body.String())
+			}
+		})
+	}
+}
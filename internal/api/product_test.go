@@ -0,0 +1,493 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/binary-1024/go-build-test/internal/cache"
+	"github.com/binary-1024/go-build-test/internal/config"
+	"github.com/binary-1024/go-build-test/internal/database"
+	"github.com/binary-1024/go-build-test/internal/logger"
+	"github.com/binary-1024/go-build-test/internal/models"
+	"github.com/binary-1024/go-build-test/internal/repository"
+	"github.com/binary-1024/go-build-test/internal/service"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// newTestProductHandler 创建使用SQLite和内存Redis的产品处理器，返回处理器和数据库
+func newTestProductHandler(t *testing.T, cfg *config.Config) (*Handler, *gorm.DB) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+
+	log := logger.NewLogger("error", "json", logger.FileOutput{})
+	db, err := database.NewConnection(database.DriverSQLite, filepath.Join(t.TempDir(), "test.db"), database.PoolConfig{}, log)
+	if err != nil {
+		t.Fatalf("open database: %v", err)
+	}
+	rdb := cache.NewRedisClient("redis://"+miniredis.RunT(t).Addr(), "test", log)
+	t.Cleanup(func() { _ = rdb.Close() })
+
+	products := service.NewProductService(repository.NewProductRepository(db), repository.NewCategoryRepository(db), rdb, time.Minute, service.PricePolicy{}, log)
+	return &Handler{productService: products, config: cfg, cache: rdb, logger: log}, db
+}
+
+// newTestProductRouter 创建产品路由，返回路由和一个版本为1的产品
+func newTestProductRouter(t *testing.T, cfg *config.Config) (*gin.Engine, *models.Product) {
+	t.Helper()
+	h, db := newTestProductHandler(t, cfg)
+
+	category := &models.Category{Name: "tools"}
+	if err := db.Create(category).Error; err != nil {
+		t.Fatalf("create category: %v", err)
+	}
+	product := &models.Product{Name: "widget", Price: 10, Stock: 5, CategoryID: &category.ID, IsActive: true}
+	if err := db.Create(product).Error; err != nil {
+		t.Fatalf("create product: %v", err)
+	}
+
+	router := gin.New()
+	router.GET("/products", h.ListProducts)
+	router.GET("/products/batch", h.GetProductsByIDs)
+	router.GET("/products/:id", h.GetProduct)
+	router.PUT("/products/:id", h.UpdateProduct)
+	router.POST("/products/:id/purchase", h.PurchaseProduct)
+	return router, product
+}
+
+func TestUpdateProductVersion(t *testing.T) {
+	tests := []struct {
+		name    string
+		body    string
+		ifMatch string
+		want    int
+	}{
+		{name: "请求体提供版本", body: `{"name":"renamed","version":1}`, want: http.StatusOK},
+		{name: "If-Match提供版本", body: `{"name":"renamed"}`, ifMatch: `"1"`, want: http.StatusOK},
+		{name: "弱校验If-Match", body: `{"name":"renamed"}`, ifMatch: `W/"1"`, want: http.StatusOK},
+		{name: "版本不一致", body: `{"name":"renamed","version":2}`, want: http.StatusConflict},
+		{name: "If-Match版本不一致", body: `{"name":"renamed"}`, ifMatch: `"2"`, want: http.StatusConflict},
+		{name: "未提供版本", body: `{"name":"renamed"}`, want: http.StatusPreconditionRequired},
+		{name: "无效的If-Match", body: `{"name":"renamed"}`, ifMatch: `*`, want: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router, product := newTestProductRouter(t, &config.Config{})
+
+			req := httptest.NewRequest(http.MethodPut, fmt.Sprintf("/products/%d", product.ID), bytes.NewBufferString(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			if tt.ifMatch != "" {
+				req.Header.Set("If-Match", tt.ifMatch)
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			if w.Code != tt.want {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.want, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestProductWriteStatusCodes(t *testing.T) {
+	tests := []struct {
+		name    string
+		method  string
+		path    string
+		body    string
+		missing bool
+		want    int
+	}{
+		{name: "更新不存在的产品", method: http.MethodPut, path: "/products/%d", body: `{"name":"renamed","version":1}`, missing: true, want: http.StatusNotFound},
+		{name: "购买不存在的产品", method: http.MethodPost, path: "/products/%d/purchase", body: `{"quantity":1}`, missing: true, want: http.StatusNotFound},
+		{name: "库存不足", method: http.MethodPost, path: "/products/%d/purchase", body: `{"quantity":6}`, want: http.StatusConflict},
+		{name: "购买成功", method: http.MethodPost, path: "/products/%d/purchase", body: `{"quantity":5}`, want: http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router, product := newTestProductRouter(t, &config.Config{})
+			id := product.ID
+			if tt.missing {
+				id = 999
+			}
+
+			req := httptest.NewRequest(tt.method, fmt.Sprintf(tt.path, id), bytes.NewBufferString(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			if w.Code != tt.want {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.want, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestGetProductReturnsVersionETag(t *testing.T) {
+	router, product := newTestProductRouter(t, &config.Config{})
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, fmt.Sprintf("/products/%d", product.ID), nil))
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", w.Code, w.Body.String())
+	}
+	if got := w.Header().Get("ETag"); got != `"1"` {
+		t.Errorf("ETag = %q, want %q", got, `"1"`)
+	}
+}
+
+func TestConcurrentUpdatesWithSameVersion(t *testing.T) {
+	router, product := newTestProductRouter(t, &config.Config{})
+
+	const concurrency = 2
+	codes := make(chan int, concurrency)
+	start := make(chan struct{})
+	var wg sync.WaitGroup
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			req := httptest.NewRequest(http.MethodPut, fmt.Sprintf("/products/%d", product.ID), bytes.NewBufferString(`{"stock":1,"version":1}`))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+			<-start
+			router.ServeHTTP(w, req)
+			codes <- w.Code
+		}()
+	}
+	close(start)
+	wg.Wait()
+	close(codes)
+
+	counts := map[int]int{}
+	for code := range codes {
+		counts[code]++
+	}
+	if counts[http.StatusOK] != 1 || counts[http.StatusConflict] != 1 {
+		t.Errorf("status counts = %v, want one 200 and one 409", counts)
+	}
+}
+
+func TestGetProductCacheHeaders(t *testing.T) {
+	tests := []struct {
+		name        string
+		environment string
+		want        []string
+		wantKey     string
+	}{
+		{name: "开发环境", environment: "development", want: []string{"MISS", "HIT"}, wantKey: "product:1"},
+		{name: "生产环境不输出缓存键", environment: "production", want: []string{"MISS", "HIT"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router, product := newTestProductRouter(t, &config.Config{Environment: tt.environment})
+
+			for i, want := range tt.want {
+				w := httptest.NewRecorder()
+				router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, fmt.Sprintf("/products/%d", product.ID), nil))
+				if w.Code != http.StatusOK {
+					t.Fatalf("status = %d: %s", w.Code, w.Body.String())
+				}
+				if got := w.Header().Get("X-Cache"); got != want {
+					t.Errorf("request %d: X-Cache = %q, want %q", i+1, got, want)
+				}
+				if got := w.Header().Get("X-Cache-Key"); got != tt.wantKey {
+					t.Errorf("request %d: X-Cache-Key = %q, want %q", i+1, got, tt.wantKey)
+				}
+			}
+		})
+	}
+}
+
+func TestGetProductsByIDs(t *testing.T) {
+	router, product := newTestProductRouter(t, &config.Config{})
+	tooMany := strings.TrimSuffix(strings.Repeat("1,", maxBatchProductIDs+1), ",")
+
+	tests := []struct {
+		name        string
+		ids         string
+		want        int
+		wantIDs     []uint
+		wantMissing []uint
+	}{
+		{name: "缺少ids", want: http.StatusBadRequest},
+		{name: "无效的ID", ids: "1,abc", want: http.StatusBadRequest},
+		{name: "超过上限", ids: tooMany, want: http.StatusBadRequest},
+		{
+			name:        "存在与不存在混合",
+			ids:         fmt.Sprintf("999,%d,%d", product.ID, product.ID),
+			want:        http.StatusOK,
+			wantIDs:     []uint{product.ID},
+			wantMissing: []uint{999},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/products/batch?ids="+tt.ids, nil)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			if w.Code != tt.want {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.want, w.Body.String())
+			}
+			if w.Code != http.StatusOK {
+				return
+			}
+
+			var resp struct {
+				Data models.ProductBatchResponse `json:"data"`
+			}
+			if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+				t.Fatalf("decode response: %v", err)
+			}
+			var ids []uint
+			for _, p := range resp.Data.Products {
+				ids = append(ids, p.ID)
+			}
+			if !reflect.DeepEqual(ids, tt.wantIDs) || !reflect.DeepEqual(resp.Data.Missing, tt.wantMissing) {
+				t.Errorf("products = %v, missing = %v; want %v, %v", ids, resp.Data.Missing, tt.wantIDs, tt.wantMissing)
+			}
+		})
+	}
+}
+
+func TestListProductsCategoriesLimit(t *testing.T) {
+	router, _ := newTestProductRouter(t, &config.Config{})
+	names := make([]string, models.MaxQueryCategories+1)
+	for i := range names {
+		names[i] = fmt.Sprintf("c%d", i)
+	}
+
+	tests := []struct {
+		name       string
+		categories string
+		want       int
+	}{
+		{name: "达到上限", categories: strings.Join(names[:models.MaxQueryCategories], ","), want: http.StatusOK},
+		{name: "超过上限", categories: strings.Join(names, ","), want: http.StatusBadRequest},
+		{name: "已有分类", categories: "tools,other", want: http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/products?categories="+tt.categories, nil))
+			if w.Code != tt.want {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.want, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestListProductsCursorRejectsSorting(t *testing.T) {
+	router, _ := newTestProductRouter(t, &config.Config{})
+
+	tests := []struct {
+		name  string
+		query string
+		want  int
+	}{
+		{name: "游标分页", query: "pagination=cursor", want: http.StatusOK},
+		{name: "游标分页带sort_by", query: "pagination=cursor&sort_by=price", want: http.StatusBadRequest},
+		{name: "游标分页带order", query: "pagination=cursor&order=asc", want: http.StatusBadRequest},
+		{name: "偏移分页带排序", query: "sort_by=price&order=asc", want: http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/products?"+tt.query, nil))
+			if w.Code != tt.want {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.want, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestStreamProducts(t *testing.T) {
+	h, db := newTestProductHandler(t, &config.Config{})
+	for i := 0; i < 250; i++ {
+		product := &models.Product{Name: fmt.Sprintf("item-%03d", i), Price: float64(i + 1), Stock: i % 2, IsActive: true, Version: 1}
+		if err := db.Create(product).Error; err != nil {
+			t.Fatalf("create product: %v", err)
+		}
+		// is_active 有默认值，创建时的false会被忽略，需要单独更新
+		if i%5 == 0 {
+			if err := db.Model(product).Update("is_active", false).Error; err != nil {
+				t.Fatalf("deactivate product: %v", err)
+			}
+		}
+	}
+	router := gin.New()
+	router.GET("/products/stream", h.StreamProducts)
+
+	tests := []struct {
+		name      string
+		query     string
+		wantCount int
+		check     func(p models.ProductResponse) bool
+	}{
+		{name: "全部产品", wantCount: 250, check: func(p models.ProductResponse) bool { return true }},
+		{name: "有库存", query: "in_stock=true", wantCount: 125, check: func(p models.ProductResponse) bool { return p.Stock > 0 }},
+		{name: "价格区间", query: "min_price=10&max_price=19", wantCount: 10, check: func(p models.ProductResponse) bool { return p.Price >= 10 && p.Price <= 19 }},
+		{name: "已下架", query: "active=false", wantCount: 50, check: func(p models.ProductResponse) bool { return !p.IsActive }},
+		{name: "无效参数", query: "active=maybe", wantCount: -1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/products/stream?"+tt.query, nil))
+			if tt.wantCount < 0 {
+				if w.Code != http.StatusBadRequest {
+					t.Errorf("status = %d, want 400", w.Code)
+				}
+				return
+			}
+
+			if w.Code != http.StatusOK || w.Header().Get("Content-Type") != "application/x-ndjson" {
+				t.Fatalf("status = %d, content type = %q", w.Code, w.Header().Get("Content-Type"))
+			}
+			lines := strings.Split(strings.TrimSuffix(w.Body.String(), "\n"), "\n")
+			if len(lines) != tt.wantCount {
+				t.Fatalf("lines = %d, want %d", len(lines), tt.wantCount)
+			}
+			for i, line := range lines {
+				var product models.ProductResponse
+				if err := json.Unmarshal([]byte(line), &product); err != nil {
+					t.Fatalf("line %d %q: %v", i, line, err)
+				}
+				if !tt.check(product) {
+					t.Errorf("line %d does not match filter: %s", i, line)
+				}
+			}
+		})
+	}
+}
+
+func TestDeleteProductSemantics(t *testing.T) {
+	tests := []struct {
+		name       string
+		idempotent bool
+		missing    bool
+		want       int
+	}{
+		{name: "严格模式删除已有产品", want: http.StatusOK},
+		{name: "严格模式删除不存在的产品", missing: true, want: http.StatusNotFound},
+		{name: "幂等模式删除已有产品", idempotent: true, want: http.StatusNoContent},
+		{name: "幂等模式删除不存在的产品", idempotent: true, missing: true, want: http.StatusNoContent},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h, db := newTestProductHandler(t, &config.Config{IdempotentDelete: tt.idempotent})
+			product := &models.Product{Name: "widget", Price: 10, IsActive: true, Version: 1}
+			if err := db.Create(product).Error; err != nil {
+				t.Fatalf("create product: %v", err)
+			}
+			router := gin.New()
+			router.DELETE("/products/:id", h.DeleteProduct)
+
+			id := product.ID
+			if tt.missing {
+				id = 999
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(http.MethodDelete, fmt.Sprintf("/products/%d", id), nil))
+
+			if w.Code != tt.want {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.want, w.Body.String())
+			}
+			if w.Code == http.StatusNoContent && w.Body.Len() != 0 {
+				t.Errorf("204 body = %q, want empty", w.Body.String())
+			}
+		})
+	}
+}
+
+func TestProductViews(t *testing.T) {
+	internalFields := []string{"stock", "is_active", "created_at", "updated_at", "version"}
+
+	tests := []struct {
+		name         string
+		view         string
+		path         func(p *models.Product) string
+		item         func(data json.RawMessage) (map[string]interface{}, error)
+		wantInternal bool
+	}{
+		{name: "管理视图获取产品", view: models.ProductViewAdmin, path: productPath, item: productItem, wantInternal: true},
+		{name: "店面视图获取产品", view: models.ProductViewStorefront, path: productPath, item: productItem},
+		{name: "管理视图产品列表", view: models.ProductViewAdmin, path: listPath, item: firstListItem, wantInternal: true},
+		{name: "店面视图产品列表", view: models.ProductViewStorefront, path: listPath, item: firstListItem},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router, product := newTestProductRouter(t, &config.Config{ProductView: tt.view})
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, tt.path(product), nil))
+			if w.Code != http.StatusOK {
+				t.Fatalf("status = %d: %s", w.Code, w.Body.String())
+			}
+
+			var resp struct {
+				Data json.RawMessage `json:"data"`
+			}
+			if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+				t.Fatalf("decode response: %v", err)
+			}
+			item, err := tt.item(resp.Data)
+			if err != nil {
+				t.Fatalf("decode product %s: %v", resp.Data, err)
+			}
+
+			if item["name"] != "widget" || item["price_formatted"] == nil {
+				t.Errorf("product = %v, want name and price_formatted in both views", item)
+			}
+			for _, field := range internalFields {
+				if _, ok := item[field]; ok != tt.wantInternal {
+					t.Errorf("field %s present = %v, want %v", field, ok, tt.wantInternal)
+				}
+			}
+		})
+	}
+}
+
+func productPath(p *models.Product) string { return fmt.Sprintf("/products/%d", p.ID) }
+
+func listPath(*models.Product) string { return "/products" }
+
+func productItem(data json.RawMessage) (map[string]interface{}, error) {
+	var item map[string]interface{}
+	err := json.Unmarshal(data, &item)
+	return item, err
+}
+
+func firstListItem(data json.RawMessage) (map[string]interface{}, error) {
+	var list struct {
+		Products []map[string]interface{} `json:"products"`
+	}
+	if err := json.Unmarshal(data, &list); err != nil {
+		return nil, err
+	}
+	if len(list.Products) != 1 {
+		return nil, fmt.Errorf("products = %d, want 1", len(list.Products))
+	}
+	return list.Products[0], nil
+}
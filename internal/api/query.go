@@ -0,0 +1,103 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/binary-1024/go-build-test/internal/middleware"
+	"github.com/binary-1024/go-build-test/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// parseBoolQuery 解析布尔查询参数，规则见 middleware.ParseBool
+// 参数不存在或为空时返回nil
+func parseBoolQuery(c *gin.Context, name string) (*bool, error) {
+	value, ok := c.GetQuery(name)
+	if !ok || value == "" {
+		return nil, nil
+	}
+
+	result, err := middleware.ParseBool(value)
+	if err != nil {
+		return nil, fmt.Errorf("参数%s的值无效: %s", name, value)
+	}
+	return &result, nil
+}
+
+// parseIDList 解析逗号分隔的ID列表，忽略空项
+func parseIDList(value string) ([]uint, error) {
+	var ids []uint
+	for _, part := range strings.Split(value, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		id, err := strconv.ParseUint(part, 10, 32)
+		if err != nil || id == 0 {
+			return nil, fmt.Errorf("无效的ID: %s", part)
+		}
+		ids = append(ids, uint(id))
+	}
+	return ids, nil
+}
+
+// versionETag 将版本号格式化为强ETag
+func versionETag(version int) string {
+	return strconv.Quote(strconv.Itoa(version))
+}
+
+// parseVersionETag 解析 If-Match 中的版本号，接受带引号、弱校验前缀或不带引号的写法
+func parseVersionETag(value string) (int, error) {
+	value = strings.TrimPrefix(strings.TrimSpace(value), "W/")
+	if unquoted, err := strconv.Unquote(value); err == nil {
+		value = unquoted
+	}
+	version, err := strconv.Atoi(value)
+	if err != nil || version < 1 {
+		return 0, fmt.Errorf("无效的版本号: %s", value)
+	}
+	return version, nil
+}
+
+// bindProductCursor 解析游标分页参数，失败时直接写入400响应并返回false
+func bindProductCursor(c *gin.Context, query *models.ProductQuery) bool {
+	if !query.UsesCursor() {
+		return true
+	}
+
+	var err error
+	if query.SortBy != "" || query.Order != "" {
+		err = fmt.Errorf("游标分页固定按创建时间倒序，不支持 sort_by 和 order")
+	} else if query.Cursor != "" {
+		query.After, err = models.DecodeCursor(query.Cursor)
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"message": "查询参数错误",
+			"error":   err.Error(),
+		})
+		return false
+	}
+	return true
+}
+
+// bindProductFilters 解析产品列表的布尔过滤参数，失败时直接写入400响应并返回false
+func bindProductFilters(c *gin.Context, query *models.ProductQuery) bool {
+	var err error
+	if query.IsActive, err = parseBoolQuery(c, "active"); err == nil {
+		query.InStock, err = parseBoolQuery(c, "in_stock")
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"message": "查询参数错误",
+			"error":   err.Error(),
+		})
+		return false
+	}
+	return true
+}
@@ -0,0 +1,48 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestParseBoolQuery(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	yes, no := true, false
+
+	tests := []struct {
+		name    string
+		query   string
+		want    *bool
+		wantErr bool
+	}{
+		{name: "未提供", query: "", want: nil},
+		{name: "空值", query: "active=", want: nil},
+		{name: "true", query: "active=true", want: &yes},
+		{name: "TRUE", query: "active=TRUE", want: &yes},
+		{name: "1", query: "active=1", want: &yes},
+		{name: "yes", query: "active=Yes", want: &yes},
+		{name: "false", query: "active=false", want: &no},
+		{name: "0", query: "active=0", want: &no},
+		{name: "no", query: "active=NO", want: &no},
+		{name: "无效值", query: "active=maybe", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, _ := gin.CreateTestContext(httptest.NewRecorder())
+			c.Request = httptest.NewRequest(http.MethodGet, "/products?"+tt.query, nil)
+
+			got, err := parseBoolQuery(c, "active")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseBoolQuery err = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseBoolQuery = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
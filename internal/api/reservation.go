@@ -0,0 +1,142 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/binary-1024/go-build-test/internal/models"
+	"github.com/binary-1024/go-build-test/internal/repository"
+	"github.com/binary-1024/go-build-test/internal/service"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// ReserveStock 预留产品库存，预留在有效期内未确认时自动归还
+// @Summary 预留库存
+// @Description 立即扣减库存，有效期内未确认时由后台任务归还
+// @Tags products
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Security APIKeyAuth
+// @Param id path int true "产品ID"
+// @Param request body models.ReserveStockRequest true "预留数量"
+// @Success 201 {object} api.Response{data=models.ReserveStockResponse} "预留成功"
+// @Failure 400 {object} api.ValidationErrorResponse "请求参数错误"
+// @Failure 401 {object} api.ErrorResponse "未认证或令牌无效"
+// @Failure 403 {object} api.ErrorResponse "无权访问，或缺少所需的权限（error 字段为缺少的权限）"
+// @Failure 404 {object} api.ErrorResponse "资源不存在"
+// @Failure 409 {object} api.ErrorResponse "库存不足"
+// @Failure 503 {object} api.ErrorResponse "Redis不可用，暂时无法预留"
+// @Router /products/{id}/reservations [post]
+func (h *Handler) ReserveStock(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"message": "无效的产品ID",
+		})
+		return
+	}
+
+	var req models.ReserveStockRequest
+	if !h.bindJSON(c, &req) {
+		return
+	}
+
+	ttl := h.config.StockReservationTTL
+	reservationID, err := h.productService.ReserveStock(c.Request.Context(), uint(id), req.Quantity, ttl)
+	if err != nil {
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			c.JSON(http.StatusNotFound, gin.H{
+				"success": false,
+				"message": "产品不存在",
+			})
+		case errors.Is(err, repository.ErrInsufficientStock):
+			c.JSON(http.StatusConflict, gin.H{
+				"success": false,
+				"message": err.Error(),
+			})
+		case errors.Is(err, service.ErrReservationUnavailable):
+			c.JSON(http.StatusServiceUnavailable, gin.H{
+				"success": false,
+				"message": err.Error(),
+			})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"success": false,
+				"message": "预留库存失败",
+			})
+		}
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"success": true,
+		"message": "预留库存成功",
+		"data": &models.ReserveStockResponse{
+			ReservationID: reservationID,
+			ExpiresIn:     int(ttl.Seconds()),
+		},
+	})
+}
+
+// ConfirmReservation 确认库存预留
+// @Summary 确认库存预留
+// @Tags products
+// @Produce json
+// @Security BearerAuth
+// @Security APIKeyAuth
+// @Param id path string true "预留ID" format(uuid)
+// @Success 200 {object} api.Response "预留已确认"
+// @Failure 401 {object} api.ErrorResponse "未认证或令牌无效"
+// @Failure 403 {object} api.ErrorResponse "无权访问，或缺少所需的权限（error 字段为缺少的权限）"
+// @Failure 404 {object} api.ErrorResponse "预留不存在、已到期或已被确认/取消"
+// @Router /reservations/{id}/confirm [post]
+func (h *Handler) ConfirmReservation(c *gin.Context) {
+	err := h.productService.ConfirmReservation(c.Request.Context(), c.Param("id"))
+	h.respondReservation(c, err, "确认预留失败", "预留已确认")
+}
+
+// CancelReservation 取消库存预留并归还库存
+// @Summary 取消库存预留并归还库存
+// @Tags products
+// @Produce json
+// @Security BearerAuth
+// @Security APIKeyAuth
+// @Param id path string true "预留ID" format(uuid)
+// @Success 200 {object} api.Response "预留已取消"
+// @Failure 401 {object} api.ErrorResponse "未认证或令牌无效"
+// @Failure 403 {object} api.ErrorResponse "无权访问，或缺少所需的权限（error 字段为缺少的权限）"
+// @Failure 404 {object} api.ErrorResponse "预留不存在、已到期或已被确认/取消"
+// @Router /reservations/{id} [delete]
+func (h *Handler) CancelReservation(c *gin.Context) {
+	err := h.productService.CancelReservation(c.Request.Context(), c.Param("id"))
+	h.respondReservation(c, err, "取消预留失败", "预留已取消")
+}
+
+// respondReservation 输出确认或取消预留的结果
+func (h *Handler) respondReservation(c *gin.Context, err error, failureMessage, successMessage string) {
+	if err != nil {
+		if errors.Is(err, service.ErrReservationNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{
+				"success": false,
+				"message": err.Error(),
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"message": failureMessage,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": successMessage,
+	})
+}
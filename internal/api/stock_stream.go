@@ -0,0 +1,108 @@
+package api
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// StreamingRoutes 长连接或流式输出的路由，不受请求超时限制
+var StreamingRoutes = []string{
+	"/api/v1/products/stream",
+	"/api/v1/products/:id/stream",
+	"/api/v1/products/export",
+	"/api/v1/users/export",
+}
+
+// stockHeartbeatInterval SSE心跳间隔，防止空闲连接被代理断开
+const stockHeartbeatInterval = 15 * time.Second
+
+// StreamProductStock 以SSE推送产品库存变化
+// 连接建立后先推送一次当前库存，之后每次库存变化推送一个 stock 事件
+// @Summary 以SSE推送产品库存变化
+// @Description 连接建立后先推送一次当前库存，之后每次库存变化推送一个 stock 事件；不受请求超时限制
+// @Tags products
+// @Produce text/event-stream
+// @Security BearerAuth
+// @Security APIKeyAuth
+// @Param id path int true "产品ID"
+// @Success 200 {object} models.StockEvent "SSE事件流，每个事件为 event: stock，data 为 StockEvent"
+// @Failure 401 {object} api.ErrorResponse "未认证或令牌无效"
+// @Failure 403 {object} api.ErrorResponse "无权访问，或缺少所需的权限（error 字段为缺少的权限）"
+// @Failure 404 {object} api.ErrorResponse "资源不存在"
+// @Failure 503 {object} api.ErrorResponse "Redis不可用，无法订阅库存变化"
+// @Router /products/{id}/stream [get]
+func (h *Handler) StreamProductStock(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"message": "无效的产品ID",
+		})
+		return
+	}
+
+	ctx := c.Request.Context()
+
+	// 先订阅再读取当前库存，避免漏掉两者之间的变化
+	events, unsubscribe, err := h.stockHub.Subscribe(ctx, uint(id))
+	if err != nil {
+		h.logger.Warn("订阅库存变化失败", "product_id", id, "error", err)
+		c.JSON(http.StatusServiceUnavailable, gin.H{
+			"success": false,
+			"message": "库存推送暂不可用",
+		})
+		return
+	}
+	defer unsubscribe()
+
+	current, err := h.productService.GetStock(ctx, uint(id))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{
+				"success": false,
+				"message": "产品不存在",
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"message": "获取产品库存失败",
+		})
+		return
+	}
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+	c.SSEvent("stock", current)
+	c.Writer.Flush()
+
+	heartbeat := time.NewTicker(stockHeartbeatInterval)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			// 客户端断开连接
+			return
+		case event, ok := <-events:
+			if !ok {
+				return
+			}
+			c.SSEvent("stock", event)
+		case <-heartbeat.C:
+			if _, err := io.WriteString(c.Writer, ": ping\n\n"); err != nil {
+				return
+			}
+		}
+		c.Writer.Flush()
+	}
+}
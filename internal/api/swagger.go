@@ -0,0 +1,38 @@
+package api
+
+// 以下类型只用于生成API文档，描述 gin.H 构造的统一响应格式
+
+// Response 统一响应格式，data 的类型见各接口说明
+type Response struct {
+	Success bool        `json:"success" example:"true"`
+	Message string      `json:"message"`
+	Data    interface{} `json:"data,omitempty"`
+}
+
+// ErrorResponse 错误响应
+type ErrorResponse struct {
+	Success bool   `json:"success" example:"false"`
+	Message string `json:"message"`
+	Error   string `json:"error,omitempty"`
+}
+
+// ValidationErrorResponse 请求参数错误的响应
+type ValidationErrorResponse struct {
+	Success bool   `json:"success" example:"false"`
+	Message string `json:"message"`
+	Error   string `json:"error,omitempty"`
+	// Errors 字段名到校验规则的映射，如 {"email":"required"}
+	Errors map[string]string `json:"errors,omitempty"`
+	// UnknownFields 严格模式下请求体中的未知字段
+	UnknownFields []string `json:"unknown_fields,omitempty"`
+}
+
+// ReadOnlyStatus 只读模式状态
+type ReadOnlyStatus struct {
+	Enabled bool `json:"enabled"`
+}
+
+// PurgeAccepted 后台清理任务已开始
+type PurgeAccepted struct {
+	Before string `json:"before" format:"date-time"`
+}
@@ -0,0 +1,288 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/binary-1024/go-build-test/internal/auth"
+	"github.com/binary-1024/go-build-test/internal/cache"
+	"github.com/binary-1024/go-build-test/internal/config"
+	"github.com/binary-1024/go-build-test/internal/database"
+	"github.com/binary-1024/go-build-test/internal/logger"
+	"github.com/binary-1024/go-build-test/internal/mailer"
+	"github.com/binary-1024/go-build-test/internal/middleware"
+	"github.com/binary-1024/go-build-test/internal/models"
+	"github.com/binary-1024/go-build-test/internal/repository"
+	"github.com/binary-1024/go-build-test/internal/service"
+	"github.com/binary-1024/go-build-test/internal/storage"
+
+	"github.com/gin-gonic/gin"
+)
+
+const testAdminToken = "admin-token"
+
+// testUsers 用户接口测试使用的处理器和两个已创建的用户
+type testUsers struct {
+	handler *Handler
+	alice   *models.User
+	bob     *models.User
+}
+
+func newTestUsers(t *testing.T) *testUsers {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+
+	log := logger.NewLogger("error", "json", logger.FileOutput{})
+	db, err := database.NewConnection(database.DriverSQLite, filepath.Join(t.TempDir(), "test.db"), database.PoolConfig{}, log)
+	if err != nil {
+		t.Fatalf("open database: %v", err)
+	}
+	rdb := cache.NewRedisClient("redis://"+miniredis.RunT(t).Addr(), "test", log)
+	t.Cleanup(func() { _ = rdb.Close() })
+
+	cfg := &config.Config{AdminToken: testAdminToken}
+	avatars := storage.NewLocalStorage(filepath.Join(t.TempDir(), "avatars"), "/uploads")
+	users := service.NewUserService(repository.NewUserRepository(db), repository.NewAuditRepository(db), repository.NewTransactioner(db),
+		rdb, avatars, mailer.NewNopMailer(), auth.NewJWTManager("test-secret"), service.UserOptions{CacheTTL: time.Minute}, log)
+
+	env := &testUsers{handler: &Handler{userService: users, config: cfg, cache: rdb, logger: log}}
+	for _, name := range []string{"alice", "bob"} {
+		user, err := users.CreateUser(context.Background(), &models.CreateUserRequest{
+			Username: name, Email: name + "@example.com", Password: "Passw0rd!", FullName: name,
+		})
+		if err != nil {
+			t.Fatalf("create user %s: %v", name, err)
+		}
+		if name == "alice" {
+			env.alice = user
+		} else {
+			env.bob = user
+		}
+	}
+	return env
+}
+
+// router 返回以指定用户身份访问的路由，模拟认证中间件写入的用户ID
+func (e *testUsers) router(userID uint, register func(r gin.IRoutes, h *Handler)) *gin.Engine {
+	router := gin.New()
+	routes := router.Use(func(c *gin.Context) {
+		c.Set("user_id", userID)
+		c.Request = c.Request.WithContext(auth.ContextWithUserID(c.Request.Context(), userID))
+	})
+	register(routes, e.handler)
+	return router
+}
+
+func TestGetUserAuditTrailAccess(t *testing.T) {
+	tests := []struct {
+		name       string
+		self       bool
+		adminToken string
+		want       int
+	}{
+		{name: "本人", self: true, want: http.StatusOK},
+		{name: "其他用户", want: http.StatusForbidden},
+		{name: "其他用户携带错误的管理令牌", adminToken: "wrong", want: http.StatusForbidden},
+		{name: "管理员", adminToken: testAdminToken, want: http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := newTestUsers(t)
+			updates := &models.UpdateUserRequest{FullName: "Alice Liddell"}
+			ctx := auth.ContextWithUserID(context.Background(), env.alice.ID)
+			if _, err := env.handler.userService.UpdateUser(ctx, env.alice.ID, updates); err != nil {
+				t.Fatalf("UpdateUser: %v", err)
+			}
+
+			caller := env.bob.ID
+			if tt.self {
+				caller = env.alice.ID
+			}
+			router := env.router(caller, func(r gin.IRoutes, h *Handler) {
+				r.GET("/users/:id/audit", h.GetUserAuditTrail)
+			})
+
+			req := httptest.NewRequest(http.MethodGet, fmt.Sprintf("/users/%d/audit", env.alice.ID), nil)
+			if tt.adminToken != "" {
+				req.Header.Set(middleware.AdminTokenHeader, tt.adminToken)
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			if w.Code != tt.want {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.want, w.Body.String())
+			}
+			if w.Code != http.StatusOK {
+				return
+			}
+
+			var resp struct {
+				Data models.AuditListResponse `json:"data"`
+			}
+			if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+				t.Fatalf("decode response: %v", err)
+			}
+			if len(resp.Data.Entries) != 1 {
+				t.Fatalf("entries = %+v, want 1 update", resp.Data.Entries)
+			}
+			entry := resp.Data.Entries[0]
+			if entry.Action != models.AuditActionUpdate || entry.ActorID == nil || *entry.ActorID != env.alice.ID {
+				t.Errorf("entry = %+v, want update by user %d", entry, env.alice.ID)
+			}
+		})
+	}
+}
+
+func TestUploadAvatarBodyTooLarge(t *testing.T) {
+	env := newTestUsers(t)
+	env.handler.config.AvatarMaxSize = 1 << 20
+	router := env.router(env.alice.ID, func(r gin.IRoutes, h *Handler) {
+		r.POST("/users/:id/avatar", middleware.MaxBodySize(1024, nil), h.UploadAvatar)
+	})
+
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	part, err := form.CreateFormFile("avatar", "avatar.png")
+	if err != nil {
+		t.Fatalf("create form file: %v", err)
+	}
+	_, _ = part.Write(bytes.Repeat([]byte{0}, 4096))
+	_ = form.Close()
+
+	req := httptest.NewRequest(http.MethodPost, fmt.Sprintf("/users/%d/avatar", env.alice.ID), &body)
+	req.Header.Set("Content-Type", form.FormDataContentType())
+	// 未声明长度时由 MaxBytesReader 在读取过程中拦截
+	req.ContentLength = -1
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("status = %d, want 413: %s", w.Code, w.Body.String())
+	}
+}
+
+func TestRestoreUserAccess(t *testing.T) {
+	tests := []struct {
+		name       string
+		self       bool
+		adminToken string
+		want       int
+	}{
+		{name: "本人", self: true, want: http.StatusOK},
+		{name: "其他用户", want: http.StatusForbidden},
+		{name: "管理员", adminToken: testAdminToken, want: http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := newTestUsers(t)
+			if err := env.handler.userService.DeleteUser(context.Background(), env.alice.ID); err != nil {
+				t.Fatalf("DeleteUser: %v", err)
+			}
+
+			caller := env.bob.ID
+			if tt.self {
+				caller = env.alice.ID
+			}
+			router := env.router(caller, func(r gin.IRoutes, h *Handler) {
+				r.POST("/users/:id/restore", h.RestoreUser)
+			})
+
+			req := httptest.NewRequest(http.MethodPost, fmt.Sprintf("/users/%d/restore", env.alice.ID), nil)
+			if tt.adminToken != "" {
+				req.Header.Set(middleware.AdminTokenHeader, tt.adminToken)
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			if w.Code != tt.want {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.want, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestDeleteUserSemantics(t *testing.T) {
+	tests := []struct {
+		name       string
+		idempotent bool
+		missing    bool
+		want       int
+	}{
+		{name: "严格模式删除已有用户", want: http.StatusOK},
+		{name: "严格模式删除不存在的用户", missing: true, want: http.StatusNotFound},
+		{name: "幂等模式删除已有用户", idempotent: true, want: http.StatusNoContent},
+		{name: "幂等模式删除不存在的用户", idempotent: true, missing: true, want: http.StatusNoContent},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := newTestUsers(t)
+			env.handler.config.IdempotentDelete = tt.idempotent
+			router := env.router(env.alice.ID, func(r gin.IRoutes, h *Handler) {
+				r.DELETE("/users/:id", h.DeleteUser)
+			})
+
+			id := env.alice.ID
+			if tt.missing {
+				id = 999
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(http.MethodDelete, fmt.Sprintf("/users/%d", id), nil))
+
+			if w.Code != tt.want {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.want, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestGetUserCacheHeaders(t *testing.T) {
+	tests := []struct {
+		name        string
+		environment string
+		want        []string
+		wantKey     bool
+	}{
+		{name: "开发环境", environment: "development", want: []string{"MISS", "HIT"}, wantKey: true},
+		{name: "生产环境不输出缓存键", environment: "production", want: []string{"MISS", "HIT"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := newTestUsers(t)
+			env.handler.config.Environment = tt.environment
+			router := env.router(env.alice.ID, func(r gin.IRoutes, h *Handler) {
+				r.GET("/users/:id", h.GetUser)
+			})
+
+			wantKey := ""
+			if tt.wantKey {
+				wantKey = service.UserCacheKey(env.alice.ID)
+			}
+			for i, want := range tt.want {
+				w := httptest.NewRecorder()
+				router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, fmt.Sprintf("/users/%d", env.alice.ID), nil))
+				if w.Code != http.StatusOK {
+					t.Fatalf("status = %d: %s", w.Code, w.Body.String())
+				}
+				if got := w.Header().Get("X-Cache"); got != want {
+					t.Errorf("request %d: X-Cache = %q, want %q", i+1, got, want)
+				}
+				if got := w.Header().Get("X-Cache-Key"); got != wantKey {
+					t.Errorf("request %d: X-Cache-Key = %q, want %q", i+1, got, wantKey)
+				}
+			}
+		})
+	}
+}
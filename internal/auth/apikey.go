@@ -0,0 +1,56 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"time"
+)
+
+// APIKeyPrefix API Key的固定前缀，便于在日志和代码扫描中识别泄露的密钥
+const APIKeyPrefix = "sk_"
+
+// apiKeyBytes API Key随机部分的字节数
+const apiKeyBytes = 32
+
+// apiKeyDisplayLength 用于展示和识别密钥的前缀长度（含 APIKeyPrefix）
+const apiKeyDisplayLength = len(APIKeyPrefix) + 8
+
+// APIKeyInfo API Key认证通过后的调用方信息
+type APIKeyInfo struct {
+	ID        uint       `json:"id"`
+	UserID    uint       `json:"user_id"`
+	Scopes    []string   `json:"scopes"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+}
+
+// Expired API Key在 now 时是否已过期
+func (k *APIKeyInfo) Expired(now time.Time) bool {
+	return k.ExpiresAt != nil && !now.Before(*k.ExpiresAt)
+}
+
+// GenerateAPIKey 生成新的API Key明文
+// 明文只在创建时返回给调用方一次，存储中只保存 HashAPIKey 的结果
+func GenerateAPIKey() (string, error) {
+	buf := make([]byte, apiKeyBytes)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return APIKeyPrefix + base64.RawURLEncoding.EncodeToString(buf), nil
+}
+
+// HashAPIKey 计算API Key的哈希
+// API Key本身是高熵随机值，使用SHA-256即可，不需要bcrypt这类慢哈希
+func HashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// APIKeyDisplayPrefix 返回API Key用于展示的前缀，帮助用户区分自己的多个密钥
+func APIKeyDisplayPrefix(key string) string {
+	if len(key) <= apiKeyDisplayLength {
+		return key
+	}
+	return key[:apiKeyDisplayLength]
+}
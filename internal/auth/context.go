@@ -0,0 +1,20 @@
+package auth
+
+import "context"
+
+// userIDContextKey 当前登录用户ID在 context 中的键
+type userIDContextKey struct{}
+
+// ContextWithUserID 返回携带当前登录用户ID的 context
+func ContextWithUserID(ctx context.Context, userID uint) context.Context {
+	return context.WithValue(ctx, userIDContextKey{}, userID)
+}
+
+// UserIDFromContext 从 context 中取出当前登录用户ID，未登录时返回 false
+func UserIDFromContext(ctx context.Context) (uint, bool) {
+	if ctx == nil {
+		return 0, false
+	}
+	userID, ok := ctx.Value(userIDContextKey{}).(uint)
+	return userID, ok
+}
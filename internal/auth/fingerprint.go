@@ -0,0 +1,15 @@
+package auth
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+)
+
+// FingerprintHeader 客户端提供的指纹值请求头
+const FingerprintHeader = "X-Client-Fingerprint"
+
+// Fingerprint 根据User-Agent和客户端提供的值计算客户端指纹
+func Fingerprint(userAgent, clientValue string) string {
+	sum := sha256.Sum256([]byte(userAgent + "|" + clientValue))
+	return hex.EncodeToString(sum[:])
+}
@@ -7,13 +7,41 @@ import (
 	"github.com/golang-jwt/jwt/v4"
 )
 
+// 常用token类型
+const (
+	TokenTypeAccess  = "access"
+	TokenTypeRefresh = "refresh"
+	TokenTypeReset   = "reset"
+	TokenTypeVerify  = "verify"
+)
+
+// DefaultTokenTTL 默认token有效期
+const DefaultTokenTTL = 24 * time.Hour
+
 // Claims JWT声明
 type Claims struct {
-	UserID   uint   `json:"user_id"`
-	Username string `json:"username"`
+	UserID      uint                   `json:"user_id"`
+	Username    string                 `json:"username"`
+	TokenType   string                 `json:"token_type,omitempty"`
+	Fingerprint string                 `json:"fgp,omitempty"`
+	Scopes      []string               `json:"scopes,omitempty"`
+	Extra       map[string]interface{} `json:"extra,omitempty"`
 	jwt.RegisteredClaims
 }
 
+// TokenOptions token生成选项
+type TokenOptions struct {
+	TTL       time.Duration          // 有效期，为0时使用 DefaultTokenTTL
+	TokenType string                 // token类型，如 access/refresh/reset/verify
+	Audience  []string               // 受众
+	Extra     map[string]interface{} // 额外声明
+	ID        string                 // token唯一标识（jti），为空时不设置
+	Scopes    []string               // 授权范围，访问token只能调用这些权限允许的接口
+
+	// Fingerprint 客户端指纹，非空时写入token并由认证中间件校验
+	Fingerprint string
+}
+
 // JWTManager JWT管理器
 type JWTManager struct {
 	secretKey string
@@ -28,15 +56,34 @@ func NewJWTManager(secretKey string) *JWTManager {
 
 // GenerateToken 生成JWT token
 func (j *JWTManager) GenerateToken(userID uint, username string) (string, error) {
+	return j.GenerateWithOptions(userID, username, TokenOptions{TokenType: TokenTypeAccess})
+}
+
+// GenerateWithOptions 按选项生成JWT token
+func (j *JWTManager) GenerateWithOptions(userID uint, username string, opts TokenOptions) (string, error) {
+	ttl := opts.TTL
+	if ttl <= 0 {
+		ttl = DefaultTokenTTL
+	}
+
+	now := time.Now()
 	claims := Claims{
-		UserID:   userID,
-		Username: username,
+		UserID:      userID,
+		Username:    username,
+		TokenType:   opts.TokenType,
+		Fingerprint: opts.Fingerprint,
+		Scopes:      opts.Scopes,
+		Extra:       opts.Extra,
 		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(24 * time.Hour)),
-			IssuedAt:  jwt.NewNumericDate(time.Now()),
-			NotBefore: jwt.NewNumericDate(time.Now()),
+			ExpiresAt: jwt.NewNumericDate(now.Add(ttl)),
+			IssuedAt:  jwt.NewNumericDate(now),
+			NotBefore: jwt.NewNumericDate(now),
+			ID:        opts.ID,
 		},
 	}
+	if len(opts.Audience) > 0 {
+		claims.Audience = jwt.ClaimStrings(opts.Audience)
+	}
 
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
 	return token.SignedString([]byte(j.secretKey))
@@ -0,0 +1,104 @@
+package auth
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestGenerateWithOptions(t *testing.T) {
+	manager := NewJWTManager("test-secret")
+
+	tests := []struct {
+		name    string
+		opts    TokenOptions
+		wantTTL time.Duration
+		check   func(t *testing.T, claims *Claims)
+	}{
+		{
+			name:    "默认选项",
+			wantTTL: DefaultTokenTTL,
+			check: func(t *testing.T, claims *Claims) {
+				if claims.TokenType != "" || claims.Audience != nil || claims.ID != "" || claims.Extra != nil {
+					t.Errorf("claims = %+v, want no optional claims", claims)
+				}
+			},
+		},
+		{
+			name:    "refresh token带jti",
+			opts:    TokenOptions{TTL: time.Hour, TokenType: TokenTypeRefresh, ID: "jti-1"},
+			wantTTL: time.Hour,
+			check: func(t *testing.T, claims *Claims) {
+				if claims.TokenType != TokenTypeRefresh || claims.ID != "jti-1" {
+					t.Errorf("type = %q, id = %q; want refresh, jti-1", claims.TokenType, claims.ID)
+				}
+			},
+		},
+		{
+			name:    "受众和额外声明",
+			opts:    TokenOptions{TTL: 15 * time.Minute, TokenType: TokenTypeReset, Audience: []string{"web", "app"}, Extra: map[string]interface{}{"pwd": "abc"}},
+			wantTTL: 15 * time.Minute,
+			check: func(t *testing.T, claims *Claims) {
+				if !reflect.DeepEqual([]string(claims.Audience), []string{"web", "app"}) {
+					t.Errorf("audience = %v, want [web app]", claims.Audience)
+				}
+				if claims.Extra["pwd"] != "abc" {
+					t.Errorf("extra = %v, want pwd=abc", claims.Extra)
+				}
+			},
+		},
+		{
+			name:    "权限和指纹",
+			opts:    TokenOptions{Scopes: []string{ScopeProductsRead}, Fingerprint: "fgp"},
+			wantTTL: DefaultTokenTTL,
+			check: func(t *testing.T, claims *Claims) {
+				if !reflect.DeepEqual(claims.Scopes, []string{ScopeProductsRead}) || claims.Fingerprint != "fgp" {
+					t.Errorf("scopes = %v, fingerprint = %q", claims.Scopes, claims.Fingerprint)
+				}
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			token, err := manager.GenerateWithOptions(42, "alice", tt.opts)
+			if err != nil {
+				t.Fatalf("GenerateWithOptions: %v", err)
+			}
+			claims, err := manager.ValidateToken(token)
+			if err != nil {
+				t.Fatalf("ValidateToken: %v", err)
+			}
+
+			if claims.UserID != 42 || claims.Username != "alice" {
+				t.Errorf("subject = %d/%s, want 42/alice", claims.UserID, claims.Username)
+			}
+			if ttl := claims.ExpiresAt.Sub(claims.IssuedAt.Time); ttl != tt.wantTTL {
+				t.Errorf("ttl = %v, want %v", ttl, tt.wantTTL)
+			}
+			tt.check(t, claims)
+		})
+	}
+}
+
+func TestGenerateTokenUsesDefaults(t *testing.T) {
+	manager := NewJWTManager("test-secret")
+	token, err := manager.GenerateToken(1, "bob")
+	if err != nil {
+		t.Fatalf("GenerateToken: %v", err)
+	}
+	claims, err := manager.ValidateToken(token)
+	if err != nil {
+		t.Fatalf("ValidateToken: %v", err)
+	}
+	if ttl := claims.ExpiresAt.Sub(claims.IssuedAt.Time); ttl != DefaultTokenTTL {
+		t.Errorf("ttl = %v, want %v", ttl, DefaultTokenTTL)
+	}
+	if claims.TokenType != TokenTypeAccess {
+		t.Errorf("type = %q, want %q", claims.TokenType, TokenTypeAccess)
+	}
+
+	if _, err := NewJWTManager("other-secret").ValidateToken(token); err == nil {
+		t.Error("token signed with another secret validated")
+	}
+}
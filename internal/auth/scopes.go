@@ -0,0 +1,64 @@
+package auth
+
+// 权限范围，格式为 资源:操作
+const (
+	ScopeUsersRead       = "users:read"
+	ScopeUsersWrite      = "users:write"
+	ScopeCategoriesRead  = "categories:read"
+	ScopeCategoriesWrite = "categories:write"
+	ScopeProductsRead    = "products:read"
+	ScopeProductsWrite   = "products:write"
+	// ScopeProfileWrite 修改本人的资料和头像；修改其他用户需要 users:write
+	ScopeProfileWrite = "profile:write"
+)
+
+// AllScopes 全部可用的权限范围，升级前创建的用户在迁移时被回填为全部权限
+var AllScopes = []string{
+	ScopeUsersRead,
+	ScopeUsersWrite,
+	ScopeCategoriesRead,
+	ScopeCategoriesWrite,
+	ScopeProductsRead,
+	ScopeProductsWrite,
+	ScopeProfileWrite,
+}
+
+// DefaultScopes 新用户（包括批量创建的用户）和未分配权限的用户拥有的权限：只读，以及修改本人的资料
+// 其他写权限需要管理员分配
+var DefaultScopes = []string{
+	ScopeUsersRead,
+	ScopeCategoriesRead,
+	ScopeProductsRead,
+	ScopeProfileWrite,
+}
+
+// HasScope scopes 中是否包含 scope
+func HasScope(scopes []string, scope string) bool {
+	for _, s := range scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// MissingScope 返回 requested 中第一个不在 granted 内的权限，全部包含时返回空字符串
+func MissingScope(granted, requested []string) string {
+	for _, scope := range requested {
+		if !HasScope(granted, scope) {
+			return scope
+		}
+	}
+	return ""
+}
+
+// IntersectScopes 返回 scopes 中同时包含在 granted 内的权限，保持 scopes 的顺序
+func IntersectScopes(scopes, granted []string) []string {
+	result := make([]string, 0, len(scopes))
+	for _, scope := range scopes {
+		if HasScope(granted, scope) {
+			result = append(result, scope)
+		}
+	}
+	return result
+}
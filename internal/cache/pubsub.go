@@ -0,0 +1,69 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+)
+
+// Publish 将 payload 序列化为JSON后发布到频道，序列化方式与 Set 一致
+// 频道名与键一样加上命名空间前缀
+func (r *RedisClient) Publish(ctx context.Context, channel string, payload interface{}) error {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	return r.client.Publish(ctx, r.key(channel), data).Err()
+}
+
+// Subscribe 订阅频道，返回消息通道和取消订阅函数，消息为发布时的JSON原文，可用 json.Unmarshal 解析
+// 订阅确认后才返回，Redis不可用时返回错误。调用取消订阅函数、ctx 被取消或客户端关闭后
+// 订阅连接被释放、消息通道被关闭；取消订阅函数可以重复调用，调用后不必再读取消息通道
+// 订阅连接断开时由 go-redis 自动重连并重新订阅，重连期间发布的消息会丢失
+func (r *RedisClient) Subscribe(ctx context.Context, channel string) (<-chan []byte, func(), error) {
+	pubsub := r.client.Subscribe(ctx, r.key(channel))
+	// 等待订阅确认，Redis不可用时直接返回错误
+	if _, err := pubsub.Receive(ctx); err != nil {
+		_ = pubsub.Close()
+		return nil, nil, err
+	}
+
+	done := make(chan struct{})
+	var once sync.Once
+	unsubscribe := func() {
+		once.Do(func() {
+			close(done)
+			_ = pubsub.Close()
+		})
+	}
+
+	messages := make(chan []byte)
+	go func() {
+		defer close(messages)
+		incoming := pubsub.Channel()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ctx.Done():
+				unsubscribe()
+				return
+			case msg, ok := <-incoming:
+				if !ok {
+					// 客户端已关闭
+					return
+				}
+				// 调用方不再读取时不能阻塞在发送上
+				select {
+				case messages <- []byte(msg.Payload):
+				case <-done:
+					return
+				case <-ctx.Done():
+					unsubscribe()
+					return
+				}
+			}
+		}
+	}()
+	return messages, unsubscribe, nil
+}
@@ -0,0 +1,137 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/binary-1024/go-build-test/internal/logger"
+)
+
+// redisTestURLEnv 设置后发布订阅测试连接该地址的真实Redis，如 redis://localhost:6379/15
+const redisTestURLEnv = "REDIS_TEST_URL"
+
+// newLocalRedisClient 连接本地Redis，未设置 REDIS_TEST_URL 时跳过测试
+// 每个测试使用独立的命名空间，避免与同一实例上的其他数据冲突
+func newLocalRedisClient(t *testing.T, prefix string) *RedisClient {
+	t.Helper()
+	url := os.Getenv(redisTestURLEnv)
+	if url == "" {
+		t.Skipf("未设置 %s，跳过需要本地Redis的测试", redisTestURLEnv)
+	}
+
+	rdb := NewRedisClient(url, prefix, logger.NewLogger("error", "json", logger.FileOutput{}))
+	t.Cleanup(func() { _ = rdb.Close() })
+	if err := rdb.Ping(context.Background()); err != nil {
+		t.Fatalf("ping %s: %v", url, err)
+	}
+	return rdb
+}
+
+// receive 在超时前读取一条消息，通道已关闭时 ok 为false
+func receive(t *testing.T, messages <-chan []byte) (data []byte, ok bool) {
+	t.Helper()
+	select {
+	case data, ok = <-messages:
+		return data, ok
+	case <-time.After(2 * time.Second):
+		t.Fatal("等待消息超时")
+		return nil, false
+	}
+}
+
+func TestPubSubLocalRedis(t *testing.T) {
+	type event struct {
+		ProductID uint `json:"product_id"`
+		Stock     int  `json:"stock"`
+	}
+
+	tests := []struct {
+		name string
+		run  func(t *testing.T, rdb *RedisClient, prefix string)
+	}{
+		{
+			name: "收到发布的消息",
+			run: func(t *testing.T, rdb *RedisClient, prefix string) {
+				messages, unsubscribe, err := rdb.Subscribe(context.Background(), "stock")
+				if err != nil {
+					t.Fatalf("Subscribe: %v", err)
+				}
+				defer unsubscribe()
+
+				if err := rdb.Publish(context.Background(), "stock", event{ProductID: 1, Stock: 3}); err != nil {
+					t.Fatalf("Publish: %v", err)
+				}
+				data, ok := receive(t, messages)
+				if !ok {
+					t.Fatal("消息通道已关闭")
+				}
+				var got event
+				if err := json.Unmarshal(data, &got); err != nil || got != (event{ProductID: 1, Stock: 3}) {
+					t.Errorf("message = %s, %v", data, err)
+				}
+			},
+		},
+		{
+			name: "不同命名空间互不可见",
+			run: func(t *testing.T, rdb *RedisClient, prefix string) {
+				messages, unsubscribe, err := rdb.Subscribe(context.Background(), "stock")
+				if err != nil {
+					t.Fatalf("Subscribe: %v", err)
+				}
+				defer unsubscribe()
+
+				other := newLocalRedisClient(t, prefix+"-other")
+				if err := other.Publish(context.Background(), "stock", event{ProductID: 2}); err != nil {
+					t.Fatalf("Publish: %v", err)
+				}
+				if err := rdb.Publish(context.Background(), "stock", event{ProductID: 1}); err != nil {
+					t.Fatalf("Publish: %v", err)
+				}
+				data, _ := receive(t, messages)
+				var got event
+				if err := json.Unmarshal(data, &got); err != nil || got.ProductID != 1 {
+					t.Errorf("message = %s, want product 1 only", data)
+				}
+			},
+		},
+		{
+			name: "取消订阅后关闭消息通道",
+			run: func(t *testing.T, rdb *RedisClient, prefix string) {
+				messages, unsubscribe, err := rdb.Subscribe(context.Background(), "stock")
+				if err != nil {
+					t.Fatalf("Subscribe: %v", err)
+				}
+				unsubscribe()
+				unsubscribe()
+				if _, ok := receive(t, messages); ok {
+					t.Error("取消订阅后仍收到消息")
+				}
+			},
+		},
+		{
+			name: "ctx取消后关闭消息通道",
+			run: func(t *testing.T, rdb *RedisClient, prefix string) {
+				ctx, cancel := context.WithCancel(context.Background())
+				messages, _, err := rdb.Subscribe(ctx, "stock")
+				if err != nil {
+					t.Fatalf("Subscribe: %v", err)
+				}
+				cancel()
+				if _, ok := receive(t, messages); ok {
+					t.Error("ctx取消后仍收到消息")
+				}
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			prefix := fmt.Sprintf("pubsub-test-%d", time.Now().UnixNano())
+			tt.run(t, newLocalRedisClient(t, prefix), prefix)
+		})
+	}
+}
@@ -3,21 +3,39 @@ package cache
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
 	"time"
 
+	"github.com/binary-1024/go-build-test/internal/logger"
+
 	"github.com/go-redis/redis/v8"
+	"github.com/google/uuid"
+	"golang.org/x/sync/singleflight"
 )
 
+// ErrCacheMiss 缓存中不存在该键，可以通过 errors.Is 与 redis.Nil 判断
+var ErrCacheMiss = errors.New("缓存未命中")
+
+// loadTimeout 合并加载的最长时间
+const loadTimeout = 10 * time.Second
+
 // RedisClient Redis客户端封装
 type RedisClient struct {
-	client *redis.Client
+	client    *redis.Client
+	group     singleflight.Group
+	keyPrefix string
 }
 
 // NewRedisClient 创建Redis客户端
-func NewRedisClient(redisURL string) *RedisClient {
+// 创建时不会建立连接，连接在首次使用时建立，断开后由连接池在后续命令中自动重连
+// keyPrefix 非空时所有键都会加上 "keyPrefix:" 前缀，避免多个服务共用Redis时键冲突
+func NewRedisClient(redisURL, keyPrefix string, log logger.Logger) *RedisClient {
 	opt, err := redis.ParseURL(redisURL)
 	if err != nil {
 		// 如果解析失败，使用默认配置
+		log.Warn("Redis URL解析失败，使用默认地址", "addr", "localhost:6379", "error", err)
 		opt = &redis.Options{
 			Addr: "localhost:6379",
 		}
@@ -26,12 +44,27 @@ func NewRedisClient(redisURL string) *RedisClient {
 	rdb := redis.NewClient(opt)
 
 	return &RedisClient{
-		client: rdb,
+		client:    rdb,
+		keyPrefix: keyPrefix,
+	}
+}
+
+// key 为键加上命名空间前缀
+func (r *RedisClient) key(key string) string {
+	if r.keyPrefix == "" {
+		return key
 	}
+	return r.keyPrefix + ":" + key
+}
+
+// Ping 检查Redis连接是否可用
+func (r *RedisClient) Ping(ctx context.Context) error {
+	return r.client.Ping(ctx).Err()
 }
 
 // Set 设置缓存
 func (r *RedisClient) Set(ctx context.Context, key string, value interface{}, expiration time.Duration) error {
+	key = r.key(key)
 	jsonValue, err := json.Marshal(value)
 	if err != nil {
 		return err
@@ -40,9 +73,13 @@ func (r *RedisClient) Set(ctx context.Context, key string, value interface{}, ex
 	return r.client.Set(ctx, key, jsonValue, expiration).Err()
 }
 
-// Get 获取缓存
+// Get 获取缓存，键不存在时返回 ErrCacheMiss，其他错误表示Redis不可用或数据无法解析
 func (r *RedisClient) Get(ctx context.Context, key string, dest interface{}) error {
+	key = r.key(key)
 	result, err := r.client.Get(ctx, key).Result()
+	if err == redis.Nil {
+		return fmt.Errorf("%w: %w", ErrCacheMiss, err)
+	}
 	if err != nil {
 		return err
 	}
@@ -50,13 +87,292 @@ func (r *RedisClient) Get(ctx context.Context, key string, dest interface{}) err
 	return json.Unmarshal([]byte(result), dest)
 }
 
+// MGet 批量读取缓存，返回与 keys 一一对应的JSON原文，未命中的位置为 nil
+func (r *RedisClient) MGet(ctx context.Context, keys []string) ([][]byte, error) {
+	if len(keys) == 0 {
+		return nil, nil
+	}
+
+	prefixed := make([]string, len(keys))
+	for i, key := range keys {
+		prefixed[i] = r.key(key)
+	}
+	values, err := r.client.MGet(ctx, prefixed...).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([][]byte, len(values))
+	for i, value := range values {
+		if s, ok := value.(string); ok {
+			result[i] = []byte(s)
+		}
+	}
+	return result, nil
+}
+
+// SetMany 通过管道批量写入缓存，序列化方式与 Set 一致
+func (r *RedisClient) SetMany(ctx context.Context, values map[string]interface{}, expiration time.Duration) error {
+	if len(values) == 0 {
+		return nil
+	}
+
+	pipe := r.client.Pipeline()
+	for key, value := range values {
+		jsonValue, err := json.Marshal(value)
+		if err != nil {
+			return err
+		}
+		pipe.Set(ctx, r.key(key), jsonValue, expiration)
+	}
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// GetOrSet 旁路缓存：命中时直接返回缓存数据，未命中或读取缓存出错时调用loader加载并写入缓存
+// 需要区分缓存未命中和Redis故障时，先调用 Get 再调用 Load
+func (r *RedisClient) GetOrSet(ctx context.Context, key string, dest interface{}, ttl time.Duration, loader func(ctx context.Context) (interface{}, error)) error {
+	if err := r.Get(ctx, key, dest); err == nil {
+		return nil
+	}
+	return r.Load(ctx, key, dest, ttl, loader)
+}
+
+// Load 调用loader加载数据并写入缓存
+// 同一个key的并发加载会合并为一次loader调用，避免缓存击穿
+// loader使用不继承取消信号、最长 loadTimeout 的ctx，发起加载的请求被取消时不影响其他等待者；
+// 每个调用方仍可在自己的ctx取消时提前返回
+// loader出错时不写缓存并返回该错误；写缓存失败不影响返回结果
+func (r *RedisClient) Load(ctx context.Context, key string, dest interface{}, ttl time.Duration, loader func(ctx context.Context) (interface{}, error)) error {
+	key = r.key(key)
+	ch := r.group.DoChan(key, func() (interface{}, error) {
+		loadCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), loadTimeout)
+		defer cancel()
+
+		value, err := loader(loadCtx)
+		if err != nil {
+			return nil, err
+		}
+
+		jsonValue, err := json.Marshal(value)
+		if err != nil {
+			return nil, err
+		}
+
+		_ = r.client.Set(loadCtx, key, jsonValue, ttl).Err()
+		return jsonValue, nil
+	})
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case result := <-ch:
+		if result.Err != nil {
+			return result.Err
+		}
+		return json.Unmarshal(result.Val.([]byte), dest)
+	}
+}
+
+// IncrementWindow 固定窗口计数：计数加一，首次计数时设置窗口过期时间
+// 返回当前计数和窗口剩余时间
+func (r *RedisClient) IncrementWindow(ctx context.Context, key string, window time.Duration) (int64, time.Duration, error) {
+	key = r.key(key)
+	count, err := r.client.Incr(ctx, key).Result()
+	if err != nil {
+		return 0, 0, err
+	}
+
+	ttl, err := r.client.PTTL(ctx, key).Result()
+	if err != nil {
+		return 0, 0, err
+	}
+
+	// 新窗口或过期时间丢失时重新设置，保证计数键一定会过期
+	if count == 1 || ttl < 0 {
+		if err := r.client.PExpire(ctx, key, window).Err(); err != nil {
+			return 0, 0, err
+		}
+		ttl = window
+	}
+
+	return count, ttl, nil
+}
+
+// SlidingWindowAdd 在滑动窗口中记录一次事件，返回窗口内的事件数
+// 使用有序集合按时间戳存储事件，窗口外的旧事件会被移除
+func (r *RedisClient) SlidingWindowAdd(ctx context.Context, key string, window time.Duration) (int64, error) {
+	key = r.key(key)
+	now := time.Now()
+	member := strconv.FormatInt(now.UnixNano(), 10)
+
+	pipe := r.client.TxPipeline()
+	pipe.ZRemRangeByScore(ctx, key, "-inf", strconv.FormatInt(now.Add(-window).UnixNano(), 10))
+	pipe.ZAdd(ctx, key, &redis.Z{Score: float64(now.UnixNano()), Member: member})
+	count := pipe.ZCard(ctx, key)
+	pipe.PExpire(ctx, key, window)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return 0, err
+	}
+
+	return count.Val(), nil
+}
+
+// Incr 原子地将计数器加一并返回新值，key不存在时从0开始
+func (r *RedisClient) Incr(ctx context.Context, key string) (int64, error) {
+	key = r.key(key)
+	return r.client.Incr(ctx, key).Result()
+}
+
+// unlockScript 仅当锁的值仍是自己的令牌时才删除，避免锁过期后误删其他实例持有的锁
+var unlockScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 0
+`)
+
+// Lock 尝试获取分布式锁，锁在ttl后自动过期
+// 获取成功时ok为true，调用release释放锁；锁已被其他实例持有时ok为false
+func (r *RedisClient) Lock(ctx context.Context, key string, ttl time.Duration) (release func(), ok bool, err error) {
+	key = r.key(key)
+	token := uuid.NewString()
+	ok, err = r.client.SetNX(ctx, key, token, ttl).Result()
+	if err != nil || !ok {
+		return nil, false, err
+	}
+
+	release = func() {
+		// 使用独立的上下文，调用方的上下文取消后仍能释放锁
+		_ = unlockScript.Run(context.WithoutCancel(ctx), r.client, []string{key}, token).Err()
+	}
+	return release, true, nil
+}
+
+// Increment 原子地将计数器增加by并返回新值，key不存在时从0开始
+func (r *RedisClient) Increment(ctx context.Context, key string, by int64) (int64, error) {
+	key = r.key(key)
+	return r.client.IncrBy(ctx, key, by).Result()
+}
+
+// IncrementWithTTL 原子地将计数器增加by并刷新过期时间
+func (r *RedisClient) IncrementWithTTL(ctx context.Context, key string, by int64, ttl time.Duration) (int64, error) {
+	key = r.key(key)
+	pipe := r.client.TxPipeline()
+	count := pipe.IncrBy(ctx, key, by)
+	pipe.PExpire(ctx, key, ttl)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return 0, err
+	}
+	return count.Val(), nil
+}
+
+// GetInt 读取计数器的值，key不存在时返回0
+func (r *RedisClient) GetInt(ctx context.Context, key string) (int64, error) {
+	key = r.key(key)
+	count, err := r.client.Get(ctx, key).Int64()
+	if err == redis.Nil {
+		return 0, nil
+	}
+	return count, err
+}
+
 // Delete 删除缓存
 func (r *RedisClient) Delete(ctx context.Context, key string) error {
+	key = r.key(key)
 	return r.client.Del(ctx, key).Err()
 }
 
+// AddToSet 向集合添加成员，并将集合的过期时间重置为 ttl
+func (r *RedisClient) AddToSet(ctx context.Context, key, member string, ttl time.Duration) error {
+	key = r.key(key)
+	pipe := r.client.TxPipeline()
+	pipe.SAdd(ctx, key, member)
+	pipe.Expire(ctx, key, ttl)
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// SetMembers 返回集合的全部成员，集合不存在时返回空切片
+func (r *RedisClient) SetMembers(ctx context.Context, key string) ([]string, error) {
+	return r.client.SMembers(ctx, r.key(key)).Result()
+}
+
+// deleteScanCount 按模式删除时每次 SCAN 的建议返回数量
+const deleteScanCount = 100
+
+// DeleteByPattern 删除匹配 pattern（Redis glob 语法）的所有键，返回删除的数量
+// 使用 SCAN 逐批遍历而不是 KEYS，避免在键很多时阻塞Redis；
+// 遍历结束后再分批删除，遍历中删除会使按偏移量实现游标的服务端（如 miniredis）跳过部分键
+func (r *RedisClient) DeleteByPattern(ctx context.Context, pattern string) (int, error) {
+	pattern = r.key(pattern)
+
+	var keys []string
+	var cursor uint64
+	for {
+		batch, next, err := r.client.Scan(ctx, cursor, pattern, deleteScanCount).Result()
+		if err != nil {
+			return 0, err
+		}
+		keys = append(keys, batch...)
+		cursor = next
+		if cursor == 0 {
+			break
+		}
+	}
+
+	deleted := 0
+	for start := 0; start < len(keys); start += deleteScanCount {
+		end := min(start+deleteScanCount, len(keys))
+		n, err := r.client.Del(ctx, keys[start:end]...).Result()
+		if err != nil {
+			return deleted, err
+		}
+		deleted += int(n)
+	}
+	return deleted, nil
+}
+
+// Consume 删除键并返回删除前键是否存在
+// 删除是原子的，并发调用时只有一个调用方得到 true，可用于一次性凭证
+func (r *RedisClient) Consume(ctx context.Context, key string) (bool, error) {
+	key = r.key(key)
+	n, err := r.client.Del(ctx, key).Result()
+	if err != nil {
+		return false, err
+	}
+	return n > 0, nil
+}
+
+// Schedule 将成员加入延迟队列，at 为到期时间；成员已存在时更新到期时间
+// 延迟队列使用有序集合实现，分数为到期时间的毫秒时间戳
+func (r *RedisClient) Schedule(ctx context.Context, queue, member string, at time.Time) error {
+	return r.client.ZAdd(ctx, r.key(queue), &redis.Z{Score: float64(at.UnixMilli()), Member: member}).Err()
+}
+
+// Due 返回延迟队列中到 now 为止已到期的成员，按到期时间排序，最多 limit 个
+// 返回的成员仍在队列中，处理前需要调用 Unschedule 认领
+func (r *RedisClient) Due(ctx context.Context, queue string, now time.Time, limit int64) ([]string, error) {
+	return r.client.ZRangeByScore(ctx, r.key(queue), &redis.ZRangeBy{
+		Min:   "-inf",
+		Max:   strconv.FormatInt(now.UnixMilli(), 10),
+		Count: limit,
+	}).Result()
+}
+
+// Unschedule 将成员移出延迟队列并返回移出前成员是否在队列中
+// 移除是原子的，并发调用时只有一个调用方得到 true，可用于多个实例之间认领任务
+func (r *RedisClient) Unschedule(ctx context.Context, queue, member string) (bool, error) {
+	n, err := r.client.ZRem(ctx, r.key(queue), member).Result()
+	if err != nil {
+		return false, err
+	}
+	return n > 0, nil
+}
+
 // Exists 检查键是否存在
 func (r *RedisClient) Exists(ctx context.Context, key string) bool {
+	key = r.key(key)
 	result, err := r.client.Exists(ctx, key).Result()
 	if err != nil {
 		return false
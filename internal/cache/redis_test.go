@@ -0,0 +1,349 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/binary-1024/go-build-test/internal/logger"
+)
+
+func newTestRedisClient(t *testing.T) *RedisClient {
+	t.Helper()
+	rdb := NewRedisClient("redis://"+miniredis.RunT(t).Addr(), "test", logger.NewLogger("error", "json", logger.FileOutput{}))
+	t.Cleanup(func() { _ = rdb.Close() })
+	return rdb
+}
+
+func TestLoadRunsLoaderOnceUnderConcurrency(t *testing.T) {
+	rdb := newTestRedisClient(t)
+
+	const callers = 50
+	var calls atomic.Int32
+	release := make(chan struct{})
+	loader := func(ctx context.Context) (interface{}, error) {
+		calls.Add(1)
+		<-release
+		return "value", nil
+	}
+
+	var wg sync.WaitGroup
+	errs := make(chan error, callers)
+	for i := 0; i < callers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			var got string
+			if err := rdb.Load(context.Background(), "cold", &got, time.Minute, loader); err != nil {
+				errs <- err
+				return
+			}
+			if got != "value" {
+				errs <- errors.New("got " + got)
+			}
+		}()
+	}
+
+	// 等所有调用方进入合并加载后再放行loader
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		t.Errorf("Load: %v", err)
+	}
+	if n := calls.Load(); n != 1 {
+		t.Errorf("loader calls = %d, want 1", n)
+	}
+
+	var cached string
+	if err := rdb.Get(context.Background(), "cold", &cached); err != nil || cached != "value" {
+		t.Errorf("cached = %q, %v; want value", cached, err)
+	}
+}
+
+func TestLoadIgnoresLeaderCancellation(t *testing.T) {
+	rdb := newTestRedisClient(t)
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	loaderErr := make(chan error, 1)
+	loader := func(ctx context.Context) (interface{}, error) {
+		close(started)
+		<-release
+		loaderErr <- ctx.Err()
+		if _, ok := ctx.Deadline(); !ok {
+			return nil, errors.New("loader ctx has no deadline")
+		}
+		return "value", nil
+	}
+
+	leaderCtx, cancel := context.WithCancel(context.Background())
+	leaderDone := make(chan error, 1)
+	go func() {
+		var got string
+		leaderDone <- rdb.Load(leaderCtx, "key", &got, time.Minute, loader)
+	}()
+	<-started
+
+	followerDone := make(chan error, 1)
+	var followerGot string
+	go func() {
+		followerDone <- rdb.Load(context.Background(), "key", &followerGot, time.Minute, loader)
+	}()
+
+	cancel()
+	if err := <-leaderDone; !errors.Is(err, context.Canceled) {
+		t.Errorf("leader err = %v, want context.Canceled", err)
+	}
+
+	close(release)
+	if err := <-followerDone; err != nil || followerGot != "value" {
+		t.Errorf("follower = %q, %v; want value", followerGot, err)
+	}
+	if err := <-loaderErr; err != nil {
+		t.Errorf("loader ctx err = %v, want nil", err)
+	}
+}
+
+func TestSlidingWindowAdd(t *testing.T) {
+	const window = 100 * time.Millisecond
+
+	tests := []struct {
+		name  string
+		gaps  []time.Duration
+		wants []int64
+	}{
+		{name: "窗口内累计", gaps: []time.Duration{0, 0, 0}, wants: []int64{1, 2, 3}},
+		{name: "超出窗口的事件被移除", gaps: []time.Duration{0, 0, 2 * window}, wants: []int64{1, 2, 1}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rdb := newTestRedisClient(t)
+			for i, gap := range tt.gaps {
+				time.Sleep(gap)
+				count, err := rdb.SlidingWindowAdd(context.Background(), "failures", window)
+				if err != nil {
+					t.Fatalf("SlidingWindowAdd: %v", err)
+				}
+				if count != tt.wants[i] {
+					t.Errorf("event %d count = %d, want %d", i, count, tt.wants[i])
+				}
+			}
+		})
+	}
+}
+
+func TestLockConcurrentAttempts(t *testing.T) {
+	rdb := newTestRedisClient(t)
+
+	const callers = 20
+	var acquired atomic.Int32
+	var wg sync.WaitGroup
+	start := make(chan struct{})
+	for i := 0; i < callers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			<-start
+			_, ok, err := rdb.Lock(context.Background(), "cron", time.Minute)
+			if err != nil {
+				t.Errorf("Lock: %v", err)
+				return
+			}
+			if ok {
+				acquired.Add(1)
+			}
+		}()
+	}
+	close(start)
+	wg.Wait()
+
+	if n := acquired.Load(); n != 1 {
+		t.Errorf("acquired = %d, want 1", n)
+	}
+}
+
+func TestLock(t *testing.T) {
+	const ttl = time.Second
+
+	tests := []struct {
+		name string
+		// expire 第一个持有者的锁在第二次获取前过期
+		expire bool
+		// releaseFirst 第二次获取前第一个持有者释放锁
+		releaseFirst bool
+		wantSecond   bool
+		// wantThird 第一个持有者在第二次获取后才释放，之后第三次获取的结果
+		wantThird bool
+	}{
+		{name: "锁被持有时获取失败", wantSecond: false, wantThird: true},
+		{name: "释放后可再次获取", releaseFirst: true, wantSecond: true, wantThird: false},
+		{name: "过期后旧持有者不会释放新锁", expire: true, wantSecond: true, wantThird: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mr := miniredis.RunT(t)
+			rdb := NewRedisClient("redis://"+mr.Addr(), "test", logger.NewLogger("error", "json", logger.FileOutput{}))
+			t.Cleanup(func() { _ = rdb.Close() })
+			ctx := context.Background()
+
+			release, ok, err := rdb.Lock(ctx, "cron", ttl)
+			if err != nil || !ok {
+				t.Fatalf("first Lock = %v, %v; want acquired", ok, err)
+			}
+			if tt.releaseFirst {
+				release()
+			}
+			if tt.expire {
+				mr.FastForward(2 * ttl)
+			}
+
+			_, ok, err = rdb.Lock(ctx, "cron", ttl)
+			if err != nil || ok != tt.wantSecond {
+				t.Fatalf("second Lock = %v, %v; want %v", ok, err, tt.wantSecond)
+			}
+
+			// 释放只删除自己持有的锁
+			release()
+			_, ok, err = rdb.Lock(ctx, "cron", ttl)
+			if err != nil || ok != tt.wantThird {
+				t.Errorf("third Lock = %v, %v; want %v", ok, err, tt.wantThird)
+			}
+		})
+	}
+}
+
+func TestKeyPrefix(t *testing.T) {
+	tests := []struct {
+		name    string
+		prefix  string
+		wantKey string
+	}{
+		{name: "带前缀", prefix: "orders", wantKey: "orders:user:1"},
+		{name: "不带前缀", prefix: "", wantKey: "user:1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mr := miniredis.RunT(t)
+			log := logger.NewLogger("error", "json", logger.FileOutput{})
+			rdb := NewRedisClient("redis://"+mr.Addr(), tt.prefix, log)
+			other := NewRedisClient("redis://"+mr.Addr(), "billing", log)
+			t.Cleanup(func() {
+				_ = rdb.Close()
+				_ = other.Close()
+			})
+			ctx := context.Background()
+
+			if err := rdb.Set(ctx, "user:1", "alice", time.Minute); err != nil {
+				t.Fatalf("Set: %v", err)
+			}
+			if !mr.Exists(tt.wantKey) {
+				t.Fatalf("keys = %v, want %s", mr.Keys(), tt.wantKey)
+			}
+
+			var got string
+			if err := rdb.Get(ctx, "user:1", &got); err != nil || got != "alice" {
+				t.Errorf("Get = %q, %v; want alice", got, err)
+			}
+			if !rdb.Exists(ctx, "user:1") {
+				t.Error("Exists = false, want true")
+			}
+
+			// 其他服务使用不同前缀，同名的键互不影响
+			if err := other.Get(ctx, "user:1", &got); !errors.Is(err, ErrCacheMiss) {
+				t.Errorf("other Get err = %v, want ErrCacheMiss", err)
+			}
+			if other.Exists(ctx, "user:1") {
+				t.Error("other Exists = true, want false")
+			}
+			if err := other.Delete(ctx, "user:1"); err != nil {
+				t.Fatalf("other Delete: %v", err)
+			}
+			if !mr.Exists(tt.wantKey) {
+				t.Errorf("key %s deleted through another prefix", tt.wantKey)
+			}
+
+			if err := rdb.Delete(ctx, "user:1"); err != nil {
+				t.Fatalf("Delete: %v", err)
+			}
+			if mr.Exists(tt.wantKey) {
+				t.Errorf("key %s still exists after Delete", tt.wantKey)
+			}
+		})
+	}
+}
+
+func TestDeleteByPattern(t *testing.T) {
+	tests := []struct {
+		name        string
+		keys        []string
+		pattern     string
+		wantDeleted int
+		wantKept    []string
+	}{
+		{name: "没有匹配的键", keys: []string{"user:1"}, pattern: "product:list:*", wantDeleted: 0, wantKept: []string{"user:1"}},
+		{name: "只删除匹配的键", keys: []string{"product:list:1:a", "product:list:1:b", "product:list:2:a", "product:1"}, pattern: "product:list:1:*", wantDeleted: 2, wantKept: []string{"product:list:2:a", "product:1"}},
+		{name: "超过单次SCAN数量", keys: numberedKeys("product:list:", 3*deleteScanCount), pattern: "product:list:*", wantDeleted: 3 * deleteScanCount},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mr := miniredis.RunT(t)
+			log := logger.NewLogger("error", "json", logger.FileOutput{})
+			rdb := NewRedisClient("redis://"+mr.Addr(), "test", log)
+			// 其他服务使用不同前缀的同名键，不应被删除
+			other := NewRedisClient("redis://"+mr.Addr(), "billing", log)
+			t.Cleanup(func() {
+				_ = rdb.Close()
+				_ = other.Close()
+			})
+			ctx := context.Background()
+
+			for _, key := range tt.keys {
+				if err := rdb.Set(ctx, key, 1, time.Minute); err != nil {
+					t.Fatalf("Set: %v", err)
+				}
+				if err := other.Set(ctx, key, 1, time.Minute); err != nil {
+					t.Fatalf("Set: %v", err)
+				}
+			}
+
+			deleted, err := rdb.DeleteByPattern(ctx, tt.pattern)
+			if err != nil {
+				t.Fatalf("DeleteByPattern: %v", err)
+			}
+			if deleted != tt.wantDeleted {
+				t.Errorf("deleted = %d, want %d", deleted, tt.wantDeleted)
+			}
+			for _, key := range tt.wantKept {
+				if !rdb.Exists(ctx, key) {
+					t.Errorf("key %s deleted, want kept", key)
+				}
+			}
+			for _, key := range tt.keys {
+				if !other.Exists(ctx, key) {
+					t.Errorf("key %s of another prefix deleted", key)
+				}
+			}
+		})
+	}
+}
+
+// numberedKeys 生成 n 个带序号的键
+func numberedKeys(prefix string, n int) []string {
+	keys := make([]string, n)
+	for i := range keys {
+		keys[i] = prefix + strconv.Itoa(i)
+	}
+	return keys
+}
@@ -0,0 +1,28 @@
+package cache
+
+import "context"
+
+// statusKey context 中缓存读取结果的键
+type statusKey struct{}
+
+// Status 一次请求中旁路缓存读取的结果，用于输出缓存调试响应头
+type Status struct {
+	// Recorded 是否发生过缓存读取
+	Recorded bool
+	// Hit 最后一次缓存读取是否命中
+	Hit bool
+}
+
+// WithStatus 返回记录缓存读取结果的 context，读取结果写入返回的 Status
+func WithStatus(ctx context.Context) (context.Context, *Status) {
+	status := &Status{}
+	return context.WithValue(ctx, statusKey{}, status), status
+}
+
+// RecordStatus 记录缓存读取是否命中，ctx 不是由 WithStatus 创建时不做任何操作
+func RecordStatus(ctx context.Context, hit bool) {
+	if status, ok := ctx.Value(statusKey{}).(*Status); ok {
+		status.Recorded = true
+		status.Hit = hit
+	}
+}
@@ -0,0 +1,28 @@
+package cache
+
+import "sync/atomic"
+
+// WarmupState 缓存预热状态
+type WarmupState struct {
+	warming atomic.Bool
+}
+
+// NewWarmupState 创建缓存预热状态
+func NewWarmupState() *WarmupState {
+	return &WarmupState{}
+}
+
+// Start 标记预热开始
+func (w *WarmupState) Start() {
+	w.warming.Store(true)
+}
+
+// Done 标记预热结束
+func (w *WarmupState) Done() {
+	w.warming.Store(false)
+}
+
+// InProgress 是否正在预热
+func (w *WarmupState) InProgress() bool {
+	return w.warming.Load()
+}
@@ -1,17 +1,174 @@
 package config
 
 import (
+	"errors"
+	"fmt"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 )
 
 // Config 应用配置
 type Config struct {
 	Environment string
 	Port        string
+	DBDriver    string
 	DatabaseURL string
 	RedisURL    string
 	JWTSecret   string
 	LogLevel    string
+	LogFormat   string
+	TimeFormat  string
+
+	// 日志文件输出，LogFile 为空时只输出到标准输出
+	LogFile       string
+	LogMaxSize    int // MB
+	LogMaxBackups int
+	LogMaxAge     int // 天
+	LogStdout     bool
+
+	// RedisRequired Redis是否为必需依赖：为 true 时启动时连不上Redis直接退出、健康检查返回 503，
+	// 为 false 时只记录警告，缓存降级为直接访问数据库
+	RedisRequired bool
+
+	// CacheKeyPrefix 缓存键前缀（如服务名），多个服务共用一个Redis时用于隔离键空间
+	CacheKeyPrefix string
+
+	// 缓存时间
+	UserCacheTTL    time.Duration
+	ProductCacheTTL time.Duration
+
+	// 邮箱验证：新用户会收到验证链接，RequireEmailVerification 为 true 时未验证邮箱的用户不能登录
+	RequireEmailVerification bool
+	EmailVerifyURL           string
+	EmailVerifyTTL           time.Duration
+	// 重置密码：通过邮件发送的重置链接地址和有效期
+	PasswordResetURL string
+	PasswordResetTTL time.Duration
+	// Mailer 邮件发送方式：log（写入日志）/ none（不发送）
+	Mailer string
+
+	// IdempotencyTTL 携带 Idempotency-Key 的创建请求的响应保留时间，0 表示关闭幂等键支持
+	IdempotencyTTL time.Duration
+
+	// ShutdownTimeout 优雅关闭时等待处理中请求完成的最长时间
+	ShutdownTimeout time.Duration
+	// ShutdownDrainDelay 收到退出信号后、开始关闭前的排空等待时间
+	// 期间 /readyz 返回 503 但继续处理请求，给负载均衡留出摘除实例的时间
+	ShutdownDrainDelay time.Duration
+
+	// AllowedOrigins 跨域白名单，为空时允许任意来源
+	AllowedOrigins []string
+
+	// TokenFingerprintBinding 是否将token绑定到客户端指纹（User-Agent + X-Client-Fingerprint）
+	TokenFingerprintBinding bool
+
+	// RefreshTokenTTL refresh token有效期，0 表示登录时不签发refresh token
+	RefreshTokenTTL time.Duration
+
+	// 登录接口限流：每个IP在窗口内允许的请求数，0 表示不限流
+	LoginRateLimit  int
+	LoginRateWindow time.Duration
+
+	// token校验接口限流：每个IP在窗口内允许的请求数，0 表示不限流
+	IntrospectRateLimit  int
+	IntrospectRateWindow time.Duration
+
+	// 登录失败保护：滑动窗口内失败次数达到阈值后锁定账户一段时间，0 表示不启用
+	LoginFailThreshold int
+	LoginFailWindow    time.Duration
+	LoginLockCooldown  time.Duration
+
+	// TLS，证书和私钥都配置时启用HTTPS
+	TLSCertFile     string
+	TLSKeyFile      string
+	TLSMinVersion   string
+	TLSCipherSuites []string
+
+	// 数据库连接池
+	DBMaxOpenConns    int
+	DBMaxIdleConns    int
+	DBConnMaxLifetime time.Duration
+
+	// StrictJSON 是否拒绝请求体中的未知字段（也可通过 X-Strict-JSON 请求头按请求开启）
+	StrictJSON bool
+	// RequestTimeout 单个请求的处理超时，超过时返回 504，0 表示不限制
+	RequestTimeout time.Duration
+	// MaxBodySize 请求体最大字节数，超过时返回 413，0 表示不限制（文件上传接口各自限制）
+	MaxBodySize int64
+
+	// IdempotentDelete 删除接口是否采用幂等语义（总是返回204），默认不存在时返回404
+	IdempotentDelete bool
+
+	// ProductView 产品响应视图：admin 返回完整字段，storefront 隐藏库存和内部字段
+	ProductView string
+
+	// UserBatchAtomic 批量创建用户是否全有或全无，false 时尽力写入校验通过的用户
+	UserBatchAtomic bool
+	// EmailNormalization 注册时是否按归一化邮箱（小写、去掉 +tag）判断重复
+	EmailNormalization bool
+
+	// 头像上传：本地存储目录、对外访问的URL前缀和单个文件的最大字节数
+	AvatarDir     string
+	AvatarBaseURL string
+	AvatarMaxSize int64
+
+	// 价格展示：price_formatted 字段使用的地区和默认货币
+	PriceLocale     string
+	DefaultCurrency string
+
+	// BareResponses 是否默认返回不带 {success, message, data} 外层的响应（也可用 X-Bare-Response 请求头按请求选择）
+	BareResponses bool
+
+	// ReadOnly 启动时是否处于只读模式（读接口正常，写接口返回 409），可通过管理接口在运行时切换
+	ReadOnly bool
+	// AdminToken 管理接口令牌（X-Admin-Token），为空时管理接口不可用
+	AdminToken string
+
+	// CSV导入产品：最大数据行数（不含表头）和文件最大字节数，0 表示不限制
+	ProductImportMaxRows int
+	ProductImportMaxSize int64
+
+	// 产品价格区间，0 表示不限制
+	ProductMinPrice float64
+	ProductMaxPrice float64
+
+	// StockReservationTTL 库存预留的有效期，StockReservationSweepInterval 到期预留的清理间隔，0 表示不清理
+	StockReservationTTL           time.Duration
+	StockReservationSweepInterval time.Duration
+
+	// CacheWarmingRetryAfter 缓存预热期间列表接口返回的 Retry-After 秒数，0 表示不返回
+	CacheWarmingRetryAfter int
+	// CacheWarmupEnabled 启动时是否预热产品缓存，CacheWarmupCount 为预热的产品数量（按更新时间倒序）
+	CacheWarmupEnabled bool
+	CacheWarmupCount   int
+}
+
+// DefaultJWTSecret 开发环境使用的默认JWT密钥，生产环境禁止使用
+const DefaultJWTSecret = "my-secret-key"
+
+// Validate 校验配置，返回汇总了所有问题的错误
+func (c *Config) Validate() error {
+	var errs []error
+
+	if c.Environment == "production" && (c.JWTSecret == "" || c.JWTSecret == DefaultJWTSecret) {
+		errs = append(errs, fmt.Errorf("生产环境必须通过 JWT_SECRET 设置非默认的JWT密钥"))
+	}
+
+	if port, err := strconv.Atoi(c.Port); err != nil || port < 1 || port > 65535 {
+		errs = append(errs, fmt.Errorf("PORT 必须是 1-65535 之间的端口号，当前为 %q", c.Port))
+	}
+
+	if c.DatabaseURL == "" {
+		errs = append(errs, fmt.Errorf("DATABASE_URL 不能为空"))
+	}
+
+	if c.Mailer != "log" && c.Mailer != "none" {
+		errs = append(errs, fmt.Errorf("MAILER 必须是 log 或 none，当前为 %q", c.Mailer))
+	}
+
+	return errors.Join(errs...)
 }
 
 // Load 加载配置
@@ -19,10 +176,97 @@ func Load() *Config {
 	return &Config{
 		Environment: getEnv("ENVIRONMENT", "development"),
 		Port:        getEnv("PORT", "8080"),
+		DBDriver:    getEnv("DB_DRIVER", "sqlite"),
 		DatabaseURL: getEnv("DATABASE_URL", "./microservice.db"),
 		RedisURL:    getEnv("REDIS_URL", "redis://localhost:6379"),
-		JWTSecret:   getEnv("JWT_SECRET", "my-secret-key"),
+		JWTSecret:   getEnv("JWT_SECRET", DefaultJWTSecret),
 		LogLevel:    getEnv("LOG_LEVEL", "info"),
+		LogFormat:   getEnv("LOG_FORMAT", "json"),
+		TimeFormat:  getEnv("TIME_FORMAT", "rfc3339"),
+
+		LogFile:       getEnv("LOG_FILE", ""),
+		LogMaxSize:    getEnvInt("LOG_MAX_SIZE", 100),
+		LogMaxBackups: getEnvInt("LOG_MAX_BACKUPS", 7),
+		LogMaxAge:     getEnvInt("LOG_MAX_AGE", 30),
+		LogStdout:     getEnvBool("LOG_STDOUT", true),
+
+		RedisRequired: getEnvBool("REDIS_REQUIRED", false),
+
+		CacheKeyPrefix: getEnv("CACHE_KEY_PREFIX", ""),
+
+		UserCacheTTL:    getEnvDuration("USER_CACHE_TTL", 5*time.Minute),
+		ProductCacheTTL: getEnvDuration("PRODUCT_CACHE_TTL", 10*time.Minute),
+
+		IdempotencyTTL: getEnvDuration("IDEMPOTENCY_TTL", 24*time.Hour),
+
+		RequireEmailVerification: getEnvBool("REQUIRE_EMAIL_VERIFICATION", false),
+		EmailVerifyURL:           getEnv("EMAIL_VERIFY_URL", "http://localhost:8080/verify-email"),
+		EmailVerifyTTL:           getEnvDuration("EMAIL_VERIFY_TTL", 48*time.Hour),
+		PasswordResetURL:         getEnv("PASSWORD_RESET_URL", "http://localhost:8080/reset-password"),
+		PasswordResetTTL:         getEnvDuration("PASSWORD_RESET_TTL", 30*time.Minute),
+		Mailer:                   getEnv("MAILER", "log"),
+
+		ShutdownTimeout:    getEnvDuration("SHUTDOWN_TIMEOUT", 10*time.Second),
+		ShutdownDrainDelay: getEnvDuration("SHUTDOWN_DRAIN_DELAY", 0),
+
+		AllowedOrigins: getEnvList("ALLOWED_ORIGINS"),
+
+		TokenFingerprintBinding: getEnvBool("TOKEN_FINGERPRINT_BINDING", false),
+
+		RefreshTokenTTL: getEnvDuration("REFRESH_TOKEN_TTL", 7*24*time.Hour),
+
+		LoginRateLimit:  getEnvInt("LOGIN_RATE_LIMIT", 10),
+		LoginRateWindow: getEnvDuration("LOGIN_RATE_WINDOW", time.Minute),
+
+		IntrospectRateLimit:  getEnvInt("INTROSPECT_RATE_LIMIT", 60),
+		IntrospectRateWindow: getEnvDuration("INTROSPECT_RATE_WINDOW", time.Minute),
+
+		LoginFailThreshold: getEnvInt("LOGIN_FAIL_THRESHOLD", 5),
+		LoginFailWindow:    getEnvDuration("LOGIN_FAIL_WINDOW", 15*time.Minute),
+		LoginLockCooldown:  getEnvDuration("LOGIN_LOCK_COOLDOWN", 15*time.Minute),
+
+		TLSCertFile:     getEnv("TLS_CERT_FILE", ""),
+		TLSKeyFile:      getEnv("TLS_KEY_FILE", ""),
+		TLSMinVersion:   getEnv("TLS_MIN_VERSION", "1.2"),
+		TLSCipherSuites: getEnvList("TLS_CIPHER_SUITES"),
+
+		DBMaxOpenConns:    getEnvInt("DB_MAX_OPEN_CONNS", 25),
+		DBMaxIdleConns:    getEnvInt("DB_MAX_IDLE_CONNS", 10),
+		DBConnMaxLifetime: getEnvDuration("DB_CONN_MAX_LIFETIME", 5*time.Minute),
+
+		StrictJSON:       getEnvBool("STRICT_JSON", false),
+		MaxBodySize:      int64(getEnvInt("MAX_BODY_SIZE", 1<<20)),
+		RequestTimeout:   getEnvDuration("REQUEST_TIMEOUT", 30*time.Second),
+		IdempotentDelete: getEnvBool("IDEMPOTENT_DELETE", false),
+		ProductView:      getEnv("PRODUCT_VIEW", "admin"),
+
+		UserBatchAtomic:    getEnvBool("USER_BATCH_ATOMIC", true),
+		EmailNormalization: getEnvBool("EMAIL_NORMALIZATION", false),
+
+		AvatarDir:     getEnv("AVATAR_DIR", "./uploads"),
+		AvatarBaseURL: getEnv("AVATAR_BASE_URL", "/uploads"),
+		AvatarMaxSize: int64(getEnvInt("AVATAR_MAX_SIZE", 2<<20)),
+
+		PriceLocale:     getEnv("PRICE_LOCALE", "zh-CN"),
+		DefaultCurrency: getEnv("DEFAULT_CURRENCY", "CNY"),
+
+		BareResponses: getEnvBool("BARE_RESPONSES", false),
+
+		ReadOnly:   getEnvBool("READ_ONLY", false),
+		AdminToken: getEnv("ADMIN_TOKEN", ""),
+
+		ProductImportMaxRows: getEnvInt("PRODUCT_IMPORT_MAX_ROWS", 1000),
+		ProductImportMaxSize: int64(getEnvInt("PRODUCT_IMPORT_MAX_SIZE", 10<<20)),
+
+		ProductMinPrice: getEnvFloat("PRODUCT_MIN_PRICE", 0),
+		ProductMaxPrice: getEnvFloat("PRODUCT_MAX_PRICE", 0),
+
+		StockReservationTTL:           getEnvDuration("STOCK_RESERVATION_TTL", 15*time.Minute),
+		StockReservationSweepInterval: getEnvDuration("STOCK_RESERVATION_SWEEP_INTERVAL", 30*time.Second),
+
+		CacheWarmingRetryAfter: getEnvInt("CACHE_WARMING_RETRY_AFTER", 0),
+		CacheWarmupEnabled:     getEnvBool("CACHE_WARMUP_ENABLED", false),
+		CacheWarmupCount:       getEnvInt("CACHE_WARMUP_COUNT", 100),
 	}
 }
 
@@ -32,3 +276,54 @@ func getEnv(key, defaultValue string) string {
 	}
 	return defaultValue
 }
+
+func getEnvInt(key string, defaultValue int) int {
+	if value := os.Getenv(key); value != "" {
+		if intValue, err := strconv.Atoi(value); err == nil {
+			return intValue
+		}
+	}
+	return defaultValue
+}
+
+func getEnvFloat(key string, defaultValue float64) float64 {
+	if value := os.Getenv(key); value != "" {
+		if floatValue, err := strconv.ParseFloat(value, 64); err == nil {
+			return floatValue
+		}
+	}
+	return defaultValue
+}
+
+func getEnvBool(key string, defaultValue bool) bool {
+	if value := os.Getenv(key); value != "" {
+		if boolValue, err := strconv.ParseBool(value); err == nil {
+			return boolValue
+		}
+	}
+	return defaultValue
+}
+
+func getEnvDuration(key string, defaultValue time.Duration) time.Duration {
+	if value := os.Getenv(key); value != "" {
+		if duration, err := time.ParseDuration(value); err == nil {
+			return duration
+		}
+	}
+	return defaultValue
+}
+
+func getEnvList(key string) []string {
+	value := os.Getenv(key)
+	if value == "" {
+		return nil
+	}
+
+	var list []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
@@ -1,30 +1,241 @@
 package database
 
 import (
+	"fmt"
+	"time"
+
+	"github.com/binary-1024/go-build-test/internal/auth"
+	applogger "github.com/binary-1024/go-build-test/internal/logger"
 	"github.com/binary-1024/go-build-test/internal/models"
 
+	"gorm.io/driver/mysql"
+	"gorm.io/driver/postgres"
 	"gorm.io/driver/sqlite"
 	"gorm.io/gorm"
 	"gorm.io/gorm/logger"
 )
 
+// 支持的数据库驱动
+const (
+	DriverSQLite   = "sqlite"
+	DriverPostgres = "postgres"
+	DriverMySQL    = "mysql"
+)
+
+// PoolConfig 连接池配置
+type PoolConfig struct {
+	MaxOpenConns    int
+	MaxIdleConns    int
+	ConnMaxLifetime time.Duration
+}
+
 // NewConnection 创建数据库连接
-func NewConnection(databaseURL string) (*gorm.DB, error) {
-	db, err := gorm.Open(sqlite.Open(databaseURL), &gorm.Config{
+func NewConnection(driver, databaseURL string, pool PoolConfig, log applogger.Logger) (*gorm.DB, error) {
+	dialector, err := newDialector(driver, databaseURL)
+	if err != nil {
+		return nil, err
+	}
+
+	db, err := gorm.Open(dialector, &gorm.Config{
 		Logger: logger.Default.LogMode(logger.Info),
 	})
 	if err != nil {
 		return nil, err
 	}
 
+	if err := configurePool(db, pool, log); err != nil {
+		return nil, err
+	}
+
+	if err := dropLegacyNormalizedEmailIndex(db); err != nil {
+		return nil, err
+	}
+
+	// 必须在自动迁移添加 scopes 列之前判断
+	scopesMissing := userScopesMissing(db)
+
 	// 自动迁移
 	err = db.AutoMigrate(
 		&models.User{},
+		&models.Category{},
 		&models.Product{},
+		&models.ProductImage{},
+		&models.AuditLog{},
+		&models.APIKey{},
 	)
 	if err != nil {
 		return nil, err
 	}
 
+	if err := backfillCategories(db, log); err != nil {
+		return nil, err
+	}
+
+	if scopesMissing {
+		if err := backfillUserScopes(db, log); err != nil {
+			return nil, err
+		}
+	}
+
 	return db, nil
 }
+
+// userScopesMissing 已有的 users 表是否还没有 scopes 列（升级前的版本）
+func userScopesMissing(db *gorm.DB) bool {
+	migrator := db.Migrator()
+	return migrator.HasTable(&models.User{}) && !migrator.HasColumn(&models.User{}, "scopes")
+}
+
+// backfillUserScopes 为升级前创建的用户回填全部权限，保持升级前的访问能力
+// 只在添加 scopes 列时执行一次，此后 scopes 为空的用户只拥有 auth.DefaultScopes
+func backfillUserScopes(db *gorm.DB, log applogger.Logger) error {
+	result := db.Unscoped().Model(&models.User{}).
+		Where("scopes IS NULL").
+		Update("scopes", models.StringList(auth.AllScopes))
+	if result.Error != nil {
+		return result.Error
+	}
+	log.Info("为已有用户回填权限", "count", result.RowsAffected)
+	return nil
+}
+
+// backfillCategories 将旧版产品的文本分类（products.category 列）迁移为分类实体
+// 为每个不同的分类名称创建分类，并回填产品的 category_id；已回填的产品不会重复处理
+func backfillCategories(db *gorm.DB, log applogger.Logger) error {
+	if !db.Migrator().HasColumn("products", "category") {
+		return nil
+	}
+
+	var names []string
+	err := db.Table("products").
+		Where("category_id IS NULL AND category IS NOT NULL AND category <> ''").
+		Distinct("category").
+		Pluck("category", &names).Error
+	if err != nil {
+		return err
+	}
+	if len(names) == 0 {
+		return nil
+	}
+
+	log.Info("回填产品分类", "count", len(names))
+	return db.Transaction(func(tx *gorm.DB) error {
+		for _, name := range names {
+			category := models.Category{Name: name}
+			if err := tx.Where(models.Category{Name: name}).FirstOrCreate(&category).Error; err != nil {
+				return err
+			}
+			err := tx.Table("products").
+				Where("category = ? AND category_id IS NULL", name).
+				Update("category_id", category.ID).Error
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// legacyNormalizedEmailIndex 旧版本 users.normalized_email 上的普通索引
+const legacyNormalizedEmailIndex = "idx_users_normalized_email"
+
+// dropLegacyNormalizedEmailIndex 删除旧版本的普通索引，为唯一索引做准备
+// 旧版本为所有用户保存归一化邮箱，未开启归一化时可能重复，因此同时清空该列，由 MigrateNormalizedEmails 按配置重新回填
+func dropLegacyNormalizedEmailIndex(db *gorm.DB) error {
+	migrator := db.Migrator()
+	if !migrator.HasTable(&models.User{}) || !migrator.HasIndex(&models.User{}, legacyNormalizedEmailIndex) {
+		return nil
+	}
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Migrator().DropIndex(&models.User{}, legacyNormalizedEmailIndex); err != nil {
+			return err
+		}
+		return tx.Unscoped().Model(&models.User{}).
+			Where("normalized_email IS NOT NULL").
+			Update("normalized_email", nil).Error
+	})
+}
+
+// MigrateNormalizedEmails 按邮箱归一化配置维护 users.normalized_email
+// 开启时为尚未回填的用户（包括已删除的用户）回填归一化邮箱，归一化后与已有用户重复的账户保留为空并记录警告；
+// 关闭时清空该列，使唯一索引不再限制 user+tag 形式的邮箱
+func MigrateNormalizedEmails(db *gorm.DB, enabled bool, log applogger.Logger) error {
+	if !enabled {
+		return db.Unscoped().Model(&models.User{}).
+			Where("normalized_email IS NOT NULL").
+			Update("normalized_email", nil).Error
+	}
+
+	var users []models.User
+	err := db.Unscoped().Select("id", "email").
+		Where("normalized_email IS NULL").
+		Order("id").
+		Find(&users).Error
+	if err != nil {
+		return err
+	}
+	if len(users) == 0 {
+		return nil
+	}
+
+	log.Info("回填归一化邮箱", "count", len(users))
+	skipped := 0
+	for _, user := range users {
+		normalized := models.NormalizeEmail(user.Email)
+		var count int64
+		err := db.Unscoped().Model(&models.User{}).Where("normalized_email = ?", normalized).Count(&count).Error
+		if err != nil {
+			return err
+		}
+		if count > 0 {
+			log.Warn("归一化后的邮箱与已有用户重复，跳过回填", "user_id", user.ID, "normalized_email", normalized)
+			skipped++
+			continue
+		}
+		err = db.Unscoped().Model(&models.User{}).Where("id = ?", user.ID).
+			Update("normalized_email", normalized).Error
+		if err != nil {
+			return err
+		}
+	}
+	if skipped > 0 {
+		log.Warn("部分用户的归一化邮箱重复，未参与唯一性检查", "count", skipped)
+	}
+	return nil
+}
+
+// configurePool 设置连接池参数
+func configurePool(db *gorm.DB, pool PoolConfig, log applogger.Logger) error {
+	sqlDB, err := db.DB()
+	if err != nil {
+		return err
+	}
+
+	maxIdle := pool.MaxIdleConns
+	if pool.MaxOpenConns > 0 && maxIdle > pool.MaxOpenConns {
+		log.Warn("最大空闲连接数超过最大打开连接数，已按最大打开连接数处理",
+			"max_idle_conns", maxIdle,
+			"max_open_conns", pool.MaxOpenConns,
+		)
+		maxIdle = pool.MaxOpenConns
+	}
+
+	sqlDB.SetMaxOpenConns(pool.MaxOpenConns)
+	sqlDB.SetMaxIdleConns(maxIdle)
+	sqlDB.SetConnMaxLifetime(pool.ConnMaxLifetime)
+	return nil
+}
+
+// newDialector 根据驱动名称创建GORM方言，databaseURL作为DSN
+func newDialector(driver, databaseURL string) (gorm.Dialector, error) {
+	switch driver {
+	case "", DriverSQLite:
+		return sqlite.Open(databaseURL), nil
+	case DriverPostgres:
+		return postgres.Open(databaseURL), nil
+	case DriverMySQL:
+		return mysql.Open(databaseURL), nil
+	default:
+		return nil, fmt.Errorf("不支持的数据库驱动: %s", driver)
+	}
+}
@@ -1,9 +1,14 @@
 package logger
 
 import (
+	"context"
+	"io"
 	"os"
+	"path/filepath"
+	"strconv"
 
 	"github.com/sirupsen/logrus"
+	"gopkg.in/natefinch/lumberjack.v2"
 )
 
 // Logger 日志接口
@@ -13,22 +18,66 @@ type Logger interface {
 	Warn(msg string, fields ...interface{})
 	Error(msg string, fields ...interface{})
 	Fatal(msg string, fields ...interface{})
+	// With 返回附加了固定字段的子日志器，字段同样按 key, value 成对传入
+	With(fields ...interface{}) Logger
+
+	// 以下方法从 ctx 中提取请求ID并自动附加到日志
+	DebugCtx(ctx context.Context, msg string, fields ...interface{})
+	InfoCtx(ctx context.Context, msg string, fields ...interface{})
+	WarnCtx(ctx context.Context, msg string, fields ...interface{})
+	ErrorCtx(ctx context.Context, msg string, fields ...interface{})
+}
+
+// requestIDContextKey 请求ID在 context 中的键
+type requestIDContextKey struct{}
+
+// ContextWithRequestID 返回携带请求ID的 context
+func ContextWithRequestID(ctx context.Context, requestID string) context.Context {
+	return context.WithValue(ctx, requestIDContextKey{}, requestID)
+}
+
+// RequestIDFromContext 从 context 中取出请求ID，不存在时返回空字符串
+func RequestIDFromContext(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	requestID, _ := ctx.Value(requestIDContextKey{}).(string)
+	return requestID
 }
 
 // LogrusLogger logrus日志实现
 type LogrusLogger struct {
-	logger *logrus.Logger
+	entry *logrus.Entry
+}
+
+// FileOutput 日志文件输出配置，Path 为空时只输出到标准输出
+type FileOutput struct {
+	Path       string
+	MaxSize    int  // 单个文件最大大小（MB）
+	MaxBackups int  // 保留的旧文件个数
+	MaxAge     int  // 旧文件保留天数
+	Stdout     bool // 写文件的同时是否输出到标准输出
 }
 
 // NewLogger 创建新的日志器
-func NewLogger(level string) *LogrusLogger {
+// format 为 text 时输出带颜色和时间戳的文本，便于本地开发；其他值使用JSON
+func NewLogger(level, format string, file FileOutput) *LogrusLogger {
 	logger := logrus.New()
 
 	// 设置输出格式
-	logger.SetFormatter(&logrus.JSONFormatter{})
+	logger.SetFormatter(newFormatter(format, file.Path != ""))
+
+	// 设置输出目标
+	output, err := newOutput(file)
+	if err != nil {
+		logger.SetOutput(os.Stdout)
+		logger.WithError(err).Error("日志文件初始化失败，仅输出到标准输出")
+	} else {
+		logger.SetOutput(output)
+	}
 
-	// 设置输出到标准输出
-	logger.SetOutput(os.Stdout)
+	// 写出前替换密码、token等敏感字段
+	logger.AddHook(redactHook{})
 
 	// 设置日志级别
 	switch level {
@@ -45,46 +94,150 @@ func NewLogger(level string) *LogrusLogger {
 	}
 
 	return &LogrusLogger{
-		logger: logger,
+		entry: logrus.NewEntry(logger),
 	}
 }
 
+// newFormatter 根据配置选择日志格式，写文件时文本格式不输出颜色控制符
+func newFormatter(format string, toFile bool) logrus.Formatter {
+	if format == "text" {
+		return &logrus.TextFormatter{
+			ForceColors:   !toFile,
+			FullTimestamp: true,
+		}
+	}
+	return &logrus.JSONFormatter{}
+}
+
+// newOutput 创建日志输出，配置了文件路径时按大小滚动写入文件
+func newOutput(file FileOutput) (io.Writer, error) {
+	if file.Path == "" {
+		return os.Stdout, nil
+	}
+
+	// 提前创建目录和文件，确保权限不会过宽（lumberjack滚动时沿用原文件权限）
+	if err := os.MkdirAll(filepath.Dir(file.Path), 0o750); err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(file.Path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o640)
+	if err != nil {
+		return nil, err
+	}
+	f.Close()
+
+	rotator := &lumberjack.Logger{
+		Filename:   file.Path,
+		MaxSize:    file.MaxSize,
+		MaxBackups: file.MaxBackups,
+		MaxAge:     file.MaxAge,
+	}
+	if file.Stdout {
+		return io.MultiWriter(os.Stdout, rotator), nil
+	}
+	return rotator, nil
+}
+
 // Debug 调试日志
 func (l *LogrusLogger) Debug(msg string, fields ...interface{}) {
-	l.logger.WithFields(parseFields(fields...)).Debug(msg)
+	l.entry.WithFields(parseFields(fields...)).Debug(msg)
 }
 
 // Info 信息日志
 func (l *LogrusLogger) Info(msg string, fields ...interface{}) {
-	l.logger.WithFields(parseFields(fields...)).Info(msg)
+	l.entry.WithFields(parseFields(fields...)).Info(msg)
 }
 
 // Warn 警告日志
 func (l *LogrusLogger) Warn(msg string, fields ...interface{}) {
-	l.logger.WithFields(parseFields(fields...)).Warn(msg)
+	l.entry.WithFields(parseFields(fields...)).Warn(msg)
 }
 
 // Error 错误日志
 func (l *LogrusLogger) Error(msg string, fields ...interface{}) {
-	l.logger.WithFields(parseFields(fields...)).Error(msg)
+	l.entry.WithFields(parseFields(fields...)).Error(msg)
 }
 
 // Fatal 致命错误日志
 func (l *LogrusLogger) Fatal(msg string, fields ...interface{}) {
-	l.logger.WithFields(parseFields(fields...)).Fatal(msg)
+	l.entry.WithFields(parseFields(fields...)).Fatal(msg)
+}
+
+// DebugCtx 调试日志，附加 ctx 中的请求ID
+func (l *LogrusLogger) DebugCtx(ctx context.Context, msg string, fields ...interface{}) {
+	WithRequestID(l, RequestIDFromContext(ctx)).Debug(msg, fields...)
+}
+
+// InfoCtx 信息日志，附加 ctx 中的请求ID
+func (l *LogrusLogger) InfoCtx(ctx context.Context, msg string, fields ...interface{}) {
+	WithRequestID(l, RequestIDFromContext(ctx)).Info(msg, fields...)
+}
+
+// WarnCtx 警告日志，附加 ctx 中的请求ID
+func (l *LogrusLogger) WarnCtx(ctx context.Context, msg string, fields ...interface{}) {
+	WithRequestID(l, RequestIDFromContext(ctx)).Warn(msg, fields...)
+}
+
+// ErrorCtx 错误日志，附加 ctx 中的请求ID
+func (l *LogrusLogger) ErrorCtx(ctx context.Context, msg string, fields ...interface{}) {
+	WithRequestID(l, RequestIDFromContext(ctx)).Error(msg, fields...)
+}
+
+// With 返回附加了固定字段的子日志器
+func (l *LogrusLogger) With(fields ...interface{}) Logger {
+	return &LogrusLogger{
+		entry: l.entry.WithFields(parseFields(fields...)),
+	}
 }
 
-// parseFields 解析字段
+// WithRequestID 返回携带请求ID字段的日志器
+func WithRequestID(l Logger, requestID string) Logger {
+	if requestID == "" {
+		return l
+	}
+	return l.With("request_id", requestID)
+}
+
+// 字段解析占位符，与 slog 的处理方式一致
+const (
+	// badKey 键不是字符串时，用它作为键保存该值
+	badKey = "!BADKEY"
+	// missingValue 末尾的键没有对应的值时使用的值
+	missingValue = "!MISSING"
+)
+
+// parseFields 将 key, value 成对的参数解析为日志字段
+// 非字符串的键以 !BADKEY 为键保存，末尾缺少值的键记为 !MISSING，避免数据静默丢失
 func parseFields(fields ...interface{}) logrus.Fields {
 	logrusFields := logrus.Fields{}
 
-	for i := 0; i < len(fields); i += 2 {
+	for i := 0; i < len(fields); {
+		key, ok := fields[i].(string)
+		if !ok {
+			logrusFields[uniqueKey(logrusFields, badKey)] = fields[i]
+			i++
+			continue
+		}
+
 		if i+1 < len(fields) {
-			if key, ok := fields[i].(string); ok {
-				logrusFields[key] = fields[i+1]
-			}
+			logrusFields[key] = fields[i+1]
+		} else {
+			logrusFields[key] = missingValue
 		}
+		i += 2
 	}
 
 	return logrusFields
 }
+
+// uniqueKey 多个占位键同时出现时追加序号，避免互相覆盖
+func uniqueKey(fields logrus.Fields, key string) string {
+	if _, exists := fields[key]; !exists {
+		return key
+	}
+	for n := 1; ; n++ {
+		candidate := key + strconv.Itoa(n)
+		if _, exists := fields[candidate]; !exists {
+			return candidate
+		}
+	}
+}
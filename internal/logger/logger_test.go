@@ -0,0 +1,78 @@
+package logger
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+)
+
+func TestParseFields(t *testing.T) {
+	tests := []struct {
+		name   string
+		fields []interface{}
+		want   logrus.Fields
+	}{
+		{name: "空输入", want: logrus.Fields{}},
+		{name: "成对的键值", fields: []interface{}{"user_id", 1, "path", "/"}, want: logrus.Fields{"user_id": 1, "path": "/"}},
+		{name: "奇数个参数", fields: []interface{}{"user_id", 1, "path"}, want: logrus.Fields{"user_id": 1, "path": missingValue}},
+		{name: "只有一个键", fields: []interface{}{"error"}, want: logrus.Fields{"error": missingValue}},
+		{name: "非字符串键", fields: []interface{}{42, "user_id", 1}, want: logrus.Fields{badKey: 42, "user_id": 1}},
+		{name: "多个非字符串键", fields: []interface{}{42, true, "user_id", 1}, want: logrus.Fields{badKey: 42, badKey + "1": true, "user_id": 1}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := parseFields(tt.fields...); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseFields(%v) = %v, want %v", tt.fields, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewFormatter(t *testing.T) {
+	tests := []struct {
+		name       string
+		format     string
+		toFile     bool
+		wantText   bool
+		wantColors bool
+	}{
+		{name: "默认JSON", format: "", wantText: false},
+		{name: "JSON", format: "json", wantText: false},
+		{name: "JSON写入文件", format: "json", toFile: true, wantText: false},
+		{name: "文本输出到终端", format: "text", wantText: true, wantColors: true},
+		{name: "文本写入文件时关闭颜色", format: "text", toFile: true, wantText: true, wantColors: false},
+		{name: "未知格式使用JSON", format: "yaml", wantText: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var file FileOutput
+			if tt.toFile {
+				file.Path = filepath.Join(t.TempDir(), "app.log")
+			}
+			log := NewLogger("info", tt.format, file)
+
+			switch formatter := log.entry.Logger.Formatter.(type) {
+			case *logrus.TextFormatter:
+				if !tt.wantText {
+					t.Fatalf("formatter = %T, want *logrus.JSONFormatter", formatter)
+				}
+				if formatter.ForceColors != tt.wantColors {
+					t.Errorf("ForceColors = %v, want %v", formatter.ForceColors, tt.wantColors)
+				}
+				if !formatter.FullTimestamp {
+					t.Error("FullTimestamp = false, want true")
+				}
+			case *logrus.JSONFormatter:
+				if tt.wantText {
+					t.Fatalf("formatter = %T, want *logrus.TextFormatter", formatter)
+				}
+			default:
+				t.Fatalf("unexpected formatter %T", formatter)
+			}
+		})
+	}
+}
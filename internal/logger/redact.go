@@ -0,0 +1,110 @@
+package logger
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Redacted 敏感字段被替换后的值
+const Redacted = "[REDACTED]"
+
+// sensitiveKeys 字段名（忽略大小写）包含这些片段时视为敏感字段
+var sensitiveKeys = []string{"password", "token", "authorization", "secret", "api_key", "apikey"}
+
+// nonSensitiveSuffixes 以这些后缀结尾的字段描述的是敏感值的属性而不是值本身，如 api_key_id、token_type
+var nonSensitiveSuffixes = []string{"_id", "_type", "_ttl"}
+
+// IsSensitiveKey 字段名是否为敏感字段，如 password、refresh_token、Authorization
+func IsSensitiveKey(key string) bool {
+	key = strings.ToLower(key)
+	for _, suffix := range nonSensitiveSuffixes {
+		if strings.HasSuffix(key, suffix) {
+			return false
+		}
+	}
+	for _, sensitive := range sensitiveKeys {
+		if strings.Contains(key, sensitive) {
+			return true
+		}
+	}
+	return false
+}
+
+// redactHook 在写出日志前替换敏感字段的值
+// 结构体、map 和切片类型的字段值先按JSON转换为通用结构再逐层检查，
+// 因此 json:"-" 的字段（如用户的密码哈希）不会出现在日志中，文本格式也是如此
+type redactHook struct{}
+
+// Levels 对所有级别生效
+func (redactHook) Levels() []logrus.Level {
+	return logrus.AllLevels
+}
+
+// Fire 替换 entry 中的敏感字段
+// entry.Data 可能与父日志器共享，这里替换为新的 map 而不是原地修改
+func (redactHook) Fire(entry *logrus.Entry) error {
+	data := make(logrus.Fields, len(entry.Data))
+	for key, value := range entry.Data {
+		if IsSensitiveKey(key) {
+			data[key] = Redacted
+			continue
+		}
+		data[key] = redactValue(value)
+	}
+	entry.Data = data
+	return nil
+}
+
+// redactValue 返回去掉敏感字段后的值，不需要处理的值原样返回
+func redactValue(value interface{}) interface{} {
+	switch value.(type) {
+	case nil, error, fmt.Stringer, []byte:
+		return value
+	}
+
+	v := reflect.ValueOf(value)
+	for v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			return value
+		}
+		v = v.Elem()
+	}
+	switch v.Kind() {
+	case reflect.Struct, reflect.Map, reflect.Slice, reflect.Array:
+	default:
+		return value
+	}
+
+	data, err := json.Marshal(value)
+	if err != nil {
+		return value
+	}
+	var generic interface{}
+	if err := json.Unmarshal(data, &generic); err != nil {
+		return value
+	}
+	return redactGeneric(generic)
+}
+
+// redactGeneric 逐层替换JSON通用结构中的敏感字段
+func redactGeneric(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, item := range v {
+			if IsSensitiveKey(key) {
+				v[key] = Redacted
+			} else {
+				v[key] = redactGeneric(item)
+			}
+		}
+	case []interface{}:
+		for i, item := range v {
+			v[i] = redactGeneric(item)
+		}
+	}
+	return value
+}
@@ -0,0 +1,85 @@
+package logger
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+// credentials 包含敏感字段的结构体
+type credentials struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+	Session  struct {
+		RefreshToken string `json:"refresh_token"`
+		TokenType    string `json:"token_type"`
+	} `json:"session"`
+	Keys []apiKey `json:"keys"`
+}
+
+type apiKey struct {
+	ID     uint   `json:"api_key_id"`
+	Secret string `json:"secret"`
+}
+
+func TestRedactLoggedValues(t *testing.T) {
+	creds := credentials{Username: "alice", Password: "hunter2"}
+	creds.Session.RefreshToken = "refresh-secret"
+	creds.Session.TokenType = "Bearer"
+	creds.Keys = []apiKey{{ID: 7, Secret: "key-secret"}}
+
+	tests := []struct {
+		name     string
+		format   string
+		fields   []interface{}
+		secrets  []string
+		keepText []string
+	}{
+		{
+			name:     "结构体值JSON格式",
+			format:   "json",
+			fields:   []interface{}{"request", creds},
+			secrets:  []string{"hunter2", "refresh-secret", "key-secret"},
+			keepText: []string{"alice", "Bearer", `"api_key_id":7`},
+		},
+		{
+			name:     "结构体指针文本格式",
+			format:   "text",
+			fields:   []interface{}{"request", &creds},
+			secrets:  []string{"hunter2", "refresh-secret", "key-secret"},
+			keepText: []string{"alice", "Bearer"},
+		},
+		{
+			name:     "敏感字段名",
+			format:   "json",
+			fields:   []interface{}{"password", "hunter2", "Authorization", "Bearer abc", "token_ttl", "15m"},
+			secrets:  []string{"hunter2", "Bearer abc"},
+			keepText: []string{"15m"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			log := NewLogger("info", tt.format, FileOutput{})
+			log.entry.Logger.SetOutput(&buf)
+
+			log.Info("login", tt.fields...)
+
+			out := buf.String()
+			for _, secret := range tt.secrets {
+				if strings.Contains(out, secret) {
+					t.Errorf("output contains %q: %s", secret, out)
+				}
+			}
+			for _, text := range tt.keepText {
+				if !strings.Contains(out, text) {
+					t.Errorf("output missing %q: %s", text, out)
+				}
+			}
+			if !strings.Contains(out, Redacted) {
+				t.Errorf("output missing %s: %s", Redacted, out)
+			}
+		})
+	}
+}
@@ -0,0 +1,48 @@
+package mailer
+
+import (
+	"context"
+
+	"github.com/binary-1024/go-build-test/internal/logger"
+)
+
+// Message 邮件内容
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Mailer 邮件发送接口，便于之后接入SMTP或第三方邮件服务
+type Mailer interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+// logMailer 不真正发送邮件，只把邮件内容写入日志，用于开发和测试环境
+type logMailer struct {
+	logger logger.Logger
+}
+
+// NewLogMailer 创建输出到日志的邮件发送器
+func NewLogMailer(logger logger.Logger) Mailer {
+	return &logMailer{logger: logger}
+}
+
+// Send 将邮件写入日志
+func (m *logMailer) Send(ctx context.Context, msg Message) error {
+	m.logger.InfoCtx(ctx, "发送邮件", "to", msg.To, "subject", msg.Subject, "body", msg.Body)
+	return nil
+}
+
+// nopMailer 丢弃所有邮件
+type nopMailer struct{}
+
+// NewNopMailer 创建不发送任何邮件的发送器
+func NewNopMailer() Mailer {
+	return nopMailer{}
+}
+
+// Send 直接返回
+func (nopMailer) Send(ctx context.Context, msg Message) error {
+	return nil
+}
@@ -0,0 +1,183 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// BareResponseHeader 客户端可通过该请求头选择不带 {success, message, data} 外层的响应
+const BareResponseHeader = "X-Bare-Response"
+
+// BareResponse 去掉响应外层的中间件
+// 开启时成功响应直接返回 data 中的资源（无 data 的 200 响应改为 204），
+// 错误响应改为 application/problem+json 格式；请求头 X-Bare-Response 可覆盖默认配置
+func BareResponse(enabledByDefault bool) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !bareRequested(c, enabledByDefault) {
+			c.Next()
+			return
+		}
+
+		original := c.Writer
+		writer := &envelopeWriter{ResponseWriter: original, status: http.StatusOK}
+		c.Writer = writer
+		c.Next()
+		c.Writer = original
+
+		writer.finish()
+	}
+}
+
+// bareRequested 本次请求是否需要去掉响应外层，请求头缺失或无效时使用默认配置
+func bareRequested(c *gin.Context, enabledByDefault bool) bool {
+	if bare, err := ParseBool(c.GetHeader(BareResponseHeader)); err == nil {
+		return bare
+	}
+	return enabledByDefault
+}
+
+// ParseBool 不区分大小写地解析 true/false/1/0/yes/no，查询参数和请求头中的布尔值统一按该规则解析
+func ParseBool(value string) (bool, error) {
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case "true", "1", "yes":
+		return true, nil
+	case "false", "0", "no":
+		return false, nil
+	}
+	return false, fmt.Errorf("无效的布尔值: %q", value)
+}
+
+// envelopeWriter 缓存JSON响应以便改写外层，非JSON响应（如NDJSON流）直接透传
+type envelopeWriter struct {
+	gin.ResponseWriter
+	status      int
+	body        bytes.Buffer
+	passthrough bool
+	decided     bool
+}
+
+// decide 首次写入时根据 Content-Type 决定是否透传
+func (w *envelopeWriter) decide() {
+	if w.decided {
+		return
+	}
+	w.decided = true
+	contentType := w.ResponseWriter.Header().Get("Content-Type")
+	if !strings.HasPrefix(contentType, "application/json") {
+		w.passthrough = true
+		w.ResponseWriter.WriteHeader(w.status)
+	}
+}
+
+func (w *envelopeWriter) WriteHeader(code int) {
+	if w.passthrough {
+		w.ResponseWriter.WriteHeader(code)
+		return
+	}
+	w.status = code
+}
+
+func (w *envelopeWriter) WriteHeaderNow() {
+	if w.passthrough {
+		w.ResponseWriter.WriteHeaderNow()
+	}
+}
+
+func (w *envelopeWriter) Write(data []byte) (int, error) {
+	w.decide()
+	if w.passthrough {
+		return w.ResponseWriter.Write(data)
+	}
+	return w.body.Write(data)
+}
+
+func (w *envelopeWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+func (w *envelopeWriter) Status() int {
+	if w.passthrough {
+		return w.ResponseWriter.Status()
+	}
+	return w.status
+}
+
+func (w *envelopeWriter) Written() bool {
+	return w.decided || w.ResponseWriter.Written()
+}
+
+func (w *envelopeWriter) Flush() {
+	w.decide()
+	if w.passthrough {
+		w.ResponseWriter.Flush()
+	}
+}
+
+// finish 改写缓存的响应并写出
+func (w *envelopeWriter) finish() {
+	if w.passthrough {
+		return
+	}
+
+	out := w.ResponseWriter
+	if w.body.Len() == 0 {
+		out.WriteHeader(w.status)
+		out.WriteHeaderNow()
+		return
+	}
+
+	var envelope map[string]json.RawMessage
+	if err := json.Unmarshal(w.body.Bytes(), &envelope); err != nil || envelope["success"] == nil {
+		// 不是统一响应格式，原样输出
+		out.WriteHeader(w.status)
+		_, _ = out.Write(w.body.Bytes())
+		return
+	}
+
+	if w.status < http.StatusBadRequest {
+		data, ok := envelope["data"]
+		if !ok {
+			if w.status == http.StatusOK {
+				w.status = http.StatusNoContent
+			}
+			out.Header().Del("Content-Type")
+			out.WriteHeader(w.status)
+			out.WriteHeaderNow()
+			return
+		}
+		out.WriteHeader(w.status)
+		_, _ = out.Write(data)
+		return
+	}
+
+	// 错误响应使用 RFC 7807 problem details
+	problem := map[string]interface{}{
+		"type":   "about:blank",
+		"status": w.status,
+	}
+	if message, ok := envelope["message"]; ok {
+		problem["title"] = message
+	} else {
+		problem["title"] = http.StatusText(w.status)
+	}
+	if detail, ok := envelope["error"]; ok {
+		problem["detail"] = detail
+	}
+	if errs, ok := envelope["errors"]; ok {
+		problem["errors"] = errs
+	}
+	body, err := json.Marshal(problem)
+	if err != nil {
+		out.WriteHeader(w.status)
+		_, _ = out.Write(w.body.Bytes())
+		return
+	}
+	out.Header().Set("Content-Type", "application/problem+json")
+	out.WriteHeader(w.status)
+	_, _ = out.Write(body)
+}
@@ -0,0 +1,230 @@
+package middleware
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/binary-1024/go-build-test/internal/cache"
+
+	"github.com/gin-gonic/gin"
+)
+
+// IdempotencyKeyHeader 客户端用于标识一次逻辑请求的请求头，重试时携带相同的值
+const IdempotencyKeyHeader = "Idempotency-Key"
+
+// IdempotentReplayedHeader 响应来自幂等缓存时设置该响应头
+const IdempotentReplayedHeader = "Idempotent-Replayed"
+
+// maxIdempotencyKeyLength 幂等键的最大长度
+const maxIdempotencyKeyLength = 255
+
+// idempotencyLockTTL 处理中标记的过期时间，防止进程崩溃后幂等键永久处于处理中
+const idempotencyLockTTL = time.Minute
+
+// idempotencyPollInterval 等待相同幂等键的请求处理完成时，检查其结果的间隔
+const idempotencyPollInterval = 50 * time.Millisecond
+
+// idempotentResponse 缓存的响应
+type idempotentResponse struct {
+	BodyHash    string `json:"body_hash"`
+	Status      int    `json:"status"`
+	ContentType string `json:"content_type"`
+	Location    string `json:"location,omitempty"`
+	Body        []byte `json:"body"`
+}
+
+// Idempotency 幂等键中间件
+// 请求携带 Idempotency-Key 时，在ttl内相同键的重复请求直接返回第一次的响应，不再执行处理器；
+// 5xx 响应不缓存，客户端可以用同一个键重试。
+// 相同键的请求正在处理中时等待其完成并返回相同的响应；等待超过处理中标记的有效期或请求被取消时返回 409。
+// 幂等键按请求方法、路由和登录用户隔离，并绑定请求体的哈希：相同键、不同请求体的请求返回 422；
+// 未登录的请求（如注册）无法区分客户端，请求体哈希同时作为键的一部分，避免不同客户端的相同键互相冲突。
+// Redis不可用时放行请求
+func Idempotency(rdb *cache.RedisClient, ttl time.Duration) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		idempotencyKey := c.GetHeader(IdempotencyKeyHeader)
+		if rdb == nil || ttl <= 0 || idempotencyKey == "" {
+			c.Next()
+			return
+		}
+
+		if len(idempotencyKey) > maxIdempotencyKeyLength {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"success": false,
+				"message": fmt.Sprintf("%s 不能超过%d个字符", IdempotencyKeyHeader, maxIdempotencyKeyLength),
+			})
+			c.Abort()
+			return
+		}
+
+		body, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			var maxBytesErr *http.MaxBytesError
+			if errors.As(err, &maxBytesErr) {
+				c.JSON(http.StatusRequestEntityTooLarge, gin.H{
+					"success": false,
+					"message": fmt.Sprintf("请求体不能超过%d字节", maxBytesErr.Limit),
+				})
+			} else {
+				c.JSON(http.StatusBadRequest, gin.H{
+					"success": false,
+					"message": "读取请求体失败",
+				})
+			}
+			c.Abort()
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+		sum := sha256.Sum256(body)
+		bodyHash := hex.EncodeToString(sum[:])
+
+		ctx := c.Request.Context()
+		userID := c.GetUint("user_id")
+		key := fmt.Sprintf("idempotency:%s:%s:%d:%s", c.Request.Method, GetRoute(c), userID, idempotencyKey)
+		if userID == 0 {
+			key += ":" + bodyHash
+		}
+
+		replayed, ok := replayIdempotent(c, rdb, key, bodyHash)
+		if !ok {
+			c.Next()
+			return
+		}
+		if replayed {
+			return
+		}
+
+		release, acquired, err := rdb.Lock(ctx, key+":lock", idempotencyLockTTL)
+		if err != nil {
+			c.Next()
+			return
+		}
+		if !acquired {
+			// 相同键的请求正在处理中：等待其写入响应后重放；
+			// 如果它失败（5xx不缓存）而释放了锁，则由当前请求接着处理
+			release, acquired, err = waitIdempotent(c, rdb, key, bodyHash)
+			if err != nil {
+				c.Next()
+				return
+			}
+			if !acquired {
+				if !c.IsAborted() {
+					c.JSON(http.StatusConflict, gin.H{
+						"success": false,
+						"message": "相同幂等键的请求正在处理中，请稍后重试",
+					})
+					c.Abort()
+				}
+				return
+			}
+		}
+		defer release()
+
+		// 获取锁之前，上一个请求可能刚好处理完成
+		if replayed, ok := replayIdempotent(c, rdb, key, bodyHash); ok && replayed {
+			return
+		}
+
+		writer := &recordingWriter{ResponseWriter: c.Writer}
+		c.Writer = writer
+		c.Next()
+		c.Writer = writer.ResponseWriter
+
+		if writer.Status() >= http.StatusInternalServerError {
+			return
+		}
+		_ = rdb.Set(ctx, key, &idempotentResponse{
+			BodyHash:    bodyHash,
+			Status:      writer.Status(),
+			ContentType: writer.Header().Get("Content-Type"),
+			Location:    writer.Header().Get("Location"),
+			Body:        writer.body.Bytes(),
+		}, ttl)
+	}
+}
+
+// waitIdempotent 等待持有锁的请求处理完成
+// 对方写入响应时直接重放并中止请求（acquired 为 false 且请求已中止）；对方未写入响应就释放了锁时，
+// 由当前请求获取锁（acquired 为 true）。等待超过锁的有效期或请求被取消时 acquired 为 false 且请求未中止
+func waitIdempotent(c *gin.Context, rdb *cache.RedisClient, key, bodyHash string) (release func(), acquired bool, err error) {
+	ctx := c.Request.Context()
+	deadline := time.NewTimer(idempotencyLockTTL)
+	defer deadline.Stop()
+	ticker := time.NewTicker(idempotencyPollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil, false, nil
+		case <-deadline.C:
+			return nil, false, nil
+		case <-ticker.C:
+		}
+
+		replayed, ok := replayIdempotent(c, rdb, key, bodyHash)
+		if !ok {
+			return nil, false, errors.New("Redis不可用")
+		}
+		if replayed {
+			return nil, false, nil
+		}
+
+		release, acquired, err = rdb.Lock(ctx, key+":lock", idempotencyLockTTL)
+		if err != nil || acquired {
+			return release, acquired, err
+		}
+	}
+}
+
+// replayIdempotent 存在缓存的响应时直接写出并中止请求；缓存的响应属于不同的请求体时返回 422
+// replayed 表示已写出响应；ok 为 false 表示Redis不可用
+func replayIdempotent(c *gin.Context, rdb *cache.RedisClient, key, bodyHash string) (replayed, ok bool) {
+	var stored idempotentResponse
+	err := rdb.Get(c.Request.Context(), key, &stored)
+	if errors.Is(err, cache.ErrCacheMiss) {
+		return false, true
+	}
+	if err != nil {
+		return false, false
+	}
+
+	if stored.BodyHash != bodyHash {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"success": false,
+			"message": fmt.Sprintf("%s 已用于内容不同的请求", IdempotencyKeyHeader),
+		})
+		c.Abort()
+		return true, true
+	}
+
+	c.Header(IdempotentReplayedHeader, "true")
+	if stored.Location != "" {
+		c.Header("Location", stored.Location)
+	}
+	c.Data(stored.Status, stored.ContentType, stored.Body)
+	c.Abort()
+	return true, true
+}
+
+// recordingWriter 在写出响应的同时记录响应体
+type recordingWriter struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *recordingWriter) Write(data []byte) (int, error) {
+	w.body.Write(data)
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *recordingWriter) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
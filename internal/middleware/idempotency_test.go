@@ -0,0 +1,130 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/binary-1024/go-build-test/internal/cache"
+	"github.com/binary-1024/go-build-test/internal/logger"
+
+	"github.com/gin-gonic/gin"
+)
+
+func newTestRedis(t *testing.T) *cache.RedisClient {
+	t.Helper()
+	mr := miniredis.RunT(t)
+	rdb := cache.NewRedisClient("redis://"+mr.Addr(), "test", logger.NewLogger("error", "json", logger.FileOutput{}))
+	t.Cleanup(func() { _ = rdb.Close() })
+	return rdb
+}
+
+func TestIdempotencyConcurrentDuplicates(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	var created int32
+	router := gin.New()
+	router.POST("/orders", Idempotency(newTestRedis(t), time.Hour), func(c *gin.Context) {
+		n := atomic.AddInt32(&created, 1)
+		time.Sleep(200 * time.Millisecond)
+		c.JSON(http.StatusCreated, gin.H{"success": true, "data": gin.H{"id": n}})
+	})
+
+	const clients = 2
+	responses := make([]*httptest.ResponseRecorder, clients)
+	var wg sync.WaitGroup
+	for i := 0; i < clients; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			req := httptest.NewRequest(http.MethodPost, "/orders", strings.NewReader(`{"sku":"a"}`))
+			req.Header.Set(IdempotencyKeyHeader, "order-1")
+			responses[i] = httptest.NewRecorder()
+			router.ServeHTTP(responses[i], req)
+		}(i)
+	}
+	wg.Wait()
+
+	if created != 1 {
+		t.Fatalf("handler ran %d times, want 1", created)
+	}
+	replayed := 0
+	for i, w := range responses {
+		if w.Code != http.StatusCreated {
+			t.Fatalf("response %d: status %d, want %d (body %s)", i, w.Code, http.StatusCreated, w.Body.String())
+		}
+		if w.Body.String() != responses[0].Body.String() {
+			t.Fatalf("response bodies differ: %s vs %s", w.Body.String(), responses[0].Body.String())
+		}
+		if w.Header().Get(IdempotentReplayedHeader) == "true" {
+			replayed++
+		}
+	}
+	if replayed != clients-1 {
+		t.Fatalf("%d responses replayed, want %d", replayed, clients-1)
+	}
+}
+
+func TestIdempotency(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name       string
+		key        string
+		userID     uint
+		bodies     [2]string
+		status     int
+		wantCalls  int32
+		wantReplay bool
+		wantSecond int
+	}{
+		{name: "无幂等键每次都执行", key: "", userID: 1, status: http.StatusCreated, wantCalls: 2, wantSecond: http.StatusCreated},
+		{name: "相同幂等键重放第一次的响应", key: "k1", userID: 1, status: http.StatusCreated, wantCalls: 1, wantReplay: true, wantSecond: http.StatusCreated},
+		{name: "4xx响应同样重放", key: "k2", userID: 1, status: http.StatusBadRequest, wantCalls: 1, wantReplay: true, wantSecond: http.StatusBadRequest},
+		{name: "5xx响应不缓存", key: "k3", userID: 1, status: http.StatusInternalServerError, wantCalls: 2, wantSecond: http.StatusInternalServerError},
+		{name: "幂等键过长", key: strings.Repeat("x", maxIdempotencyKeyLength+1), userID: 1, status: http.StatusCreated, wantCalls: 0, wantSecond: http.StatusBadRequest},
+		{name: "相同幂等键不同请求体", key: "k4", userID: 1, bodies: [2]string{`{"a":1}`, `{"a":2}`}, status: http.StatusCreated, wantCalls: 1, wantSecond: http.StatusUnprocessableEntity},
+		{name: "未登录时相同幂等键相同请求体重放", key: "k5", bodies: [2]string{`{"a":1}`, `{"a":1}`}, status: http.StatusCreated, wantCalls: 1, wantReplay: true, wantSecond: http.StatusCreated},
+		{name: "未登录时相同幂等键不同请求体互不影响", key: "k6", bodies: [2]string{`{"a":1}`, `{"a":2}`}, status: http.StatusCreated, wantCalls: 2, wantSecond: http.StatusCreated},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var calls int32
+			router := gin.New()
+			router.POST("/orders", func(c *gin.Context) {
+				if tt.userID != 0 {
+					c.Set("user_id", tt.userID)
+				}
+			}, Idempotency(newTestRedis(t), time.Hour), func(c *gin.Context) {
+				atomic.AddInt32(&calls, 1)
+				c.JSON(tt.status, gin.H{"success": tt.status < 400})
+			})
+
+			var last *httptest.ResponseRecorder
+			for _, body := range tt.bodies {
+				req := httptest.NewRequest(http.MethodPost, "/orders", strings.NewReader(body))
+				if tt.key != "" {
+					req.Header.Set(IdempotencyKeyHeader, tt.key)
+				}
+				last = httptest.NewRecorder()
+				router.ServeHTTP(last, req)
+			}
+
+			if calls != tt.wantCalls {
+				t.Errorf("handler ran %d times, want %d", calls, tt.wantCalls)
+			}
+			if last.Code != tt.wantSecond {
+				t.Errorf("second status = %d, want %d", last.Code, tt.wantSecond)
+			}
+			if got := last.Header().Get(IdempotentReplayedHeader) == "true"; got != tt.wantReplay {
+				t.Errorf("replayed = %v, want %v", got, tt.wantReplay)
+			}
+		})
+	}
+}
@@ -1,16 +1,142 @@
 package middleware
 
 import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"math"
 	"net/http"
+	"net/url"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/binary-1024/go-build-test/internal/auth"
+	"github.com/binary-1024/go-build-test/internal/cache"
 	"github.com/binary-1024/go-build-test/internal/logger"
+	"github.com/binary-1024/go-build-test/internal/server"
 
 	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
 )
 
+const (
+	// RequestIDHeader 请求ID头
+	RequestIDHeader = "X-Request-ID"
+	// RequestIDKey 请求ID在gin上下文中的键
+	RequestIDKey = "request_id"
+	// RouteKey 路由模板在gin上下文中的键
+	RouteKey = "route"
+	// UnmatchedRoute 未匹配任何路由时使用的标签
+	UnmatchedRoute = "unmatched"
+	// AdminTokenHeader 管理接口令牌头
+	AdminTokenHeader = "X-Admin-Token"
+	// APIVersionKey 请求的API版本在gin上下文中的键
+	APIVersionKey = "api_version"
+	// vendorMediaPrefix 带版本的媒体类型前缀，完整形式为 application/vnd.myapp.v1+json
+	vendorMediaPrefix = "application/vnd.myapp.v"
+	// vendorMediaSuffix 带版本的媒体类型后缀
+	vendorMediaSuffix = "+json"
+)
+
+// RequestID 请求ID中间件
+// 优先使用请求头中的 X-Request-ID，否则生成新的UUID
+func RequestID() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		requestID := c.GetHeader(RequestIDHeader)
+		if requestID == "" {
+			requestID = uuid.NewString()
+		}
+
+		c.Set(RequestIDKey, requestID)
+		c.Header(RequestIDHeader, requestID)
+		// 同时写入请求的 context，供 logger.InfoCtx 等方法提取
+		c.Request = c.Request.WithContext(logger.ContextWithRequestID(c.Request.Context(), requestID))
+		c.Next()
+	}
+}
+
+// GetRequestID 获取当前请求ID
+func GetRequestID(c *gin.Context) string {
+	return c.GetString(RequestIDKey)
+}
+
+// Route 记录请求匹配到的路由模板（如 /api/v1/products/:id），用于日志和指标
+func Route() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Set(RouteKey, routePattern(c))
+		c.Next()
+	}
+}
+
+// GetRoute 获取当前请求的路由模板，未匹配时返回 UnmatchedRoute
+func GetRoute(c *gin.Context) string {
+	if route := c.GetString(RouteKey); route != "" {
+		return route
+	}
+	return routePattern(c)
+}
+
+func routePattern(c *gin.Context) string {
+	if route := c.FullPath(); route != "" {
+		return route
+	}
+	return UnmatchedRoute
+}
+
+// APIVersion 根据 Accept 头（application/vnd.myapp.v1+json）确定请求的API版本
+// 未指定版本时使用 defaultVersion，指定了不支持的版本时返回 406
+func APIVersion(defaultVersion int, supported ...int) gin.HandlerFunc {
+	allowed := make(map[int]bool, len(supported))
+	for _, v := range supported {
+		allowed[v] = true
+	}
+
+	return func(c *gin.Context) {
+		version, ok := parseAPIVersion(c.GetHeader("Accept"))
+		if !ok {
+			version = defaultVersion
+		}
+
+		if !allowed[version] {
+			c.JSON(http.StatusNotAcceptable, gin.H{
+				"success": false,
+				"message": "不支持的API版本",
+				"error":   fmt.Sprintf("支持的版本: %v", supported),
+			})
+			c.Abort()
+			return
+		}
+
+		c.Set(APIVersionKey, version)
+		c.Next()
+	}
+}
+
+// GetAPIVersion 获取当前请求的API版本，未经过 APIVersion 中间件时返回 0
+func GetAPIVersion(c *gin.Context) int {
+	return c.GetInt(APIVersionKey)
+}
+
+// parseAPIVersion 从 Accept 头中解析第一个带版本的媒体类型
+func parseAPIVersion(accept string) (int, bool) {
+	for _, mediaRange := range strings.Split(accept, ",") {
+		mediaType := strings.TrimSpace(strings.SplitN(mediaRange, ";", 2)[0])
+		if !strings.HasPrefix(mediaType, vendorMediaPrefix) || !strings.HasSuffix(mediaType, vendorMediaSuffix) {
+			continue
+		}
+		raw := strings.TrimSuffix(strings.TrimPrefix(mediaType, vendorMediaPrefix), vendorMediaSuffix)
+		version, err := strconv.Atoi(raw)
+		if err != nil || version <= 0 {
+			// 格式错误的版本号按不支持处理
+			return -1, true
+		}
+		return version, true
+	}
+	return 0, false
+}
+
 // Logger 日志中间件
 func Logger(logger logger.Logger) gin.HandlerFunc {
 	return func(c *gin.Context) {
@@ -26,19 +152,38 @@ func Logger(logger logger.Logger) gin.HandlerFunc {
 		statusCode := c.Writer.Status()
 
 		if raw != "" {
-			path = path + "?" + raw
+			path = path + "?" + redactQuery(raw)
 		}
 
 		logger.Info("HTTP请求",
 			"method", method,
 			"path", path,
+			"route", GetRoute(c),
 			"status", statusCode,
 			"latency", latency,
 			"ip", clientIP,
+			"request_id", GetRequestID(c),
 		)
 	}
 }
 
+// redactQuery 替换查询参数中的敏感值，如验证链接中的 token
+func redactQuery(raw string) string {
+	// 解析出错时 values 仍包含能解析的部分，格式错误的片段在重新编码时被丢弃
+	values, _ := url.ParseQuery(raw)
+	redacted := false
+	for key := range values {
+		if logger.IsSensitiveKey(key) {
+			values[key] = []string{logger.Redacted}
+			redacted = true
+		}
+	}
+	if !redacted {
+		return raw
+	}
+	return values.Encode()
+}
+
 // Recovery 恢复中间件
 func Recovery(logger logger.Logger) gin.HandlerFunc {
 	return func(c *gin.Context) {
@@ -57,9 +202,22 @@ func Recovery(logger logger.Logger) gin.HandlerFunc {
 }
 
 // CORS 跨域中间件
-func CORS() gin.HandlerFunc {
+// allowedOrigins 为空时允许任意来源（*），否则仅回显白名单中的 Origin
+func CORS(allowedOrigins []string) gin.HandlerFunc {
+	allowed := make(map[string]bool, len(allowedOrigins))
+	for _, origin := range allowedOrigins {
+		allowed[origin] = true
+	}
+
 	return func(c *gin.Context) {
-		c.Header("Access-Control-Allow-Origin", "*")
+		if len(allowed) == 0 {
+			c.Header("Access-Control-Allow-Origin", "*")
+		} else {
+			c.Header("Vary", "Origin")
+			if origin := c.GetHeader("Origin"); allowed[origin] {
+				c.Header("Access-Control-Allow-Origin", origin)
+			}
+		}
 		c.Header("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
 		c.Header("Access-Control-Allow-Headers", "Origin, Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization")
 
@@ -72,8 +230,227 @@ func CORS() gin.HandlerFunc {
 	}
 }
 
+// MaxBodySize 请求体大小限制中间件，超过 limit 字节时返回 413
+// 声明了 Content-Length 的请求直接拒绝，其余请求在读取超限时由绑定逻辑返回 413
+// routeLimits 按路由模板单独设置限制（如头像、CSV导入等上传接口），不大于0表示不限制
+func MaxBodySize(limit int64, routeLimits map[string]int64) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		limit := limit
+		if routeLimit, ok := routeLimits[GetRoute(c)]; ok {
+			limit = routeLimit
+		}
+		if limit <= 0 || c.Request.Body == nil {
+			c.Next()
+			return
+		}
+
+		if c.Request.ContentLength > limit {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{
+				"success": false,
+				"message": fmt.Sprintf("请求体不能超过%d字节", limit),
+			})
+			c.Abort()
+			return
+		}
+
+		c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, limit)
+		c.Next()
+	}
+}
+
+// Timeout 请求超时中间件，为请求上下文设置截止时间
+// 截止时间之后处理器返回的 5xx 响应（通常是被中断的数据库或Redis调用）会被丢弃并改为返回 504；
+// 处理器在截止时间之后仍正常完成时保留其响应
+// 数据库和Redis调用使用请求上下文，超时后会被及时中断
+// exempt 为不设置截止时间的路由模板（如长连接的SSE推送）
+func Timeout(d time.Duration, exempt ...string) gin.HandlerFunc {
+	skip := make(map[string]bool, len(exempt))
+	for _, route := range exempt {
+		skip[route] = true
+	}
+
+	return func(c *gin.Context) {
+		if d <= 0 || skip[GetRoute(c)] {
+			c.Next()
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(c.Request.Context(), d)
+		defer cancel()
+		c.Request = c.Request.WithContext(ctx)
+
+		tw := &timeoutWriter{ResponseWriter: c.Writer, ctx: ctx}
+		c.Writer = tw
+		c.Next()
+		c.Writer = tw.ResponseWriter
+
+		if tw.timedOut {
+			c.JSON(http.StatusGatewayTimeout, gin.H{
+				"success": false,
+				"message": "请求处理超时",
+			})
+		}
+	}
+}
+
+// timeoutWriter 截止时间之后丢弃处理器的 5xx 输出，由 Timeout 统一返回 504
+type timeoutWriter struct {
+	gin.ResponseWriter
+	ctx      context.Context
+	timedOut bool
+}
+
+// expired 截止时间已过、响应尚未开始写出且状态码为 5xx
+func (w *timeoutWriter) expired(code int) bool {
+	if !w.timedOut && code >= http.StatusInternalServerError && !w.ResponseWriter.Written() && errors.Is(w.ctx.Err(), context.DeadlineExceeded) {
+		w.timedOut = true
+	}
+	return w.timedOut
+}
+
+func (w *timeoutWriter) WriteHeader(code int) {
+	if w.expired(code) {
+		return
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *timeoutWriter) WriteHeaderNow() {
+	if w.expired(w.ResponseWriter.Status()) {
+		return
+	}
+	w.ResponseWriter.WriteHeaderNow()
+}
+
+func (w *timeoutWriter) Write(data []byte) (int, error) {
+	if w.expired(w.ResponseWriter.Status()) {
+		return len(data), nil
+	}
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *timeoutWriter) WriteString(s string) (int, error) {
+	if w.expired(w.ResponseWriter.Status()) {
+		return len(s), nil
+	}
+	return w.ResponseWriter.WriteString(s)
+}
+
+// CacheWarming 缓存预热提示中间件
+// 预热期间仍正常处理请求，但通过响应头提示客户端响应可能较慢
+func CacheWarming(state *cache.WarmupState, retryAfter int) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if state != nil && state.InProgress() {
+			c.Header("X-Cache-Warming", "true")
+			if retryAfter > 0 {
+				c.Header("Retry-After", strconv.Itoa(retryAfter))
+			}
+		}
+		c.Next()
+	}
+}
+
+// ReadOnly 只读模式中间件，开启时放行读请求，写请求返回 409
+// exempt 为不受限制的路由模板（如登录、切换只读模式的管理接口）
+func ReadOnly(mode *server.ReadOnlyMode, exempt ...string) gin.HandlerFunc {
+	skip := make(map[string]bool, len(exempt))
+	for _, route := range exempt {
+		skip[route] = true
+	}
+
+	return func(c *gin.Context) {
+		if mode == nil || !mode.Enabled() || skip[GetRoute(c)] {
+			c.Next()
+			return
+		}
+
+		switch c.Request.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+			c.Next()
+			return
+		}
+
+		c.JSON(http.StatusConflict, gin.H{
+			"success": false,
+			"message": "服务处于只读模式，暂不支持写操作",
+		})
+		c.Abort()
+	}
+}
+
+// AdminToken 管理接口认证中间件，校验 X-Admin-Token 请求头
+// 未配置管理令牌时管理接口不可用，返回 404
+func AdminToken(token string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if token == "" {
+			c.JSON(http.StatusNotFound, gin.H{
+				"success": false,
+				"message": "接口不存在",
+			})
+			c.Abort()
+			return
+		}
+
+		if !HasAdminToken(c, token) {
+			c.JSON(http.StatusForbidden, gin.H{
+				"success": false,
+				"message": "无权访问管理接口",
+			})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// HasAdminToken 请求是否携带了正确的管理令牌，未配置管理令牌时总是返回false
+// 用于普通接口中仅限管理员的操作（如彻底删除）
+func HasAdminToken(c *gin.Context, token string) bool {
+	if token == "" {
+		return false
+	}
+	provided := c.GetHeader(AdminTokenHeader)
+	return subtle.ConstantTimeCompare([]byte(provided), []byte(token)) == 1
+}
+
+// RateLimit 基于Redis的固定窗口限流中间件，按客户端IP和路由计数
+// Redis不可用时放行请求，避免缓存故障导致接口不可用
+func RateLimit(rdb *cache.RedisClient, limit int, window time.Duration) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if rdb == nil || limit <= 0 {
+			c.Next()
+			return
+		}
+
+		key := fmt.Sprintf("ratelimit:%s:%s", GetRoute(c), c.ClientIP())
+		count, ttl, err := rdb.IncrementWindow(c.Request.Context(), key, window)
+		if err != nil {
+			c.Next()
+			return
+		}
+
+		if count > int64(limit) {
+			retryAfter := int(math.Ceil(ttl.Seconds()))
+			if retryAfter < 1 {
+				retryAfter = 1
+			}
+			c.Header("Retry-After", strconv.Itoa(retryAfter))
+			c.JSON(http.StatusTooManyRequests, gin.H{
+				"success": false,
+				"message": "请求过于频繁，请稍后再试",
+			})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
+
 // Auth JWT认证中间件
-func Auth(jwtManager *auth.JWTManager) gin.HandlerFunc {
+// bindFingerprint 为true时要求token中的客户端指纹与当前请求一致
+func Auth(jwtManager *auth.JWTManager, bindFingerprint bool) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		authHeader := c.GetHeader("Authorization")
 		if authHeader == "" {
@@ -95,7 +472,11 @@ func Auth(jwtManager *auth.JWTManager) gin.HandlerFunc {
 			return
 		}
 
+		// 只接受访问token，验证邮箱等用途的token不能用来调用接口
 		claims, err := jwtManager.ValidateToken(tokenString)
+		if err == nil && claims.TokenType != "" && claims.TokenType != auth.TokenTypeAccess {
+			err = errors.New("token类型不是访问token")
+		}
 		if err != nil {
 			c.JSON(http.StatusUnauthorized, gin.H{
 				"success": false,
@@ -105,8 +486,118 @@ func Auth(jwtManager *auth.JWTManager) gin.HandlerFunc {
 			return
 		}
 
+		if bindFingerprint {
+			fingerprint := auth.Fingerprint(c.GetHeader("User-Agent"), c.GetHeader(auth.FingerprintHeader))
+			if claims.Fingerprint != fingerprint {
+				c.JSON(http.StatusUnauthorized, gin.H{
+					"success": false,
+					"message": "token与客户端不匹配",
+				})
+				c.Abort()
+				return
+			}
+		}
+
 		c.Set("user_id", claims.UserID)
 		c.Set("username", claims.Username)
+		c.Set("scopes", claims.Scopes)
+		// 同时写入请求的 context，服务层据此记录操作人
+		c.Request = c.Request.WithContext(auth.ContextWithUserID(c.Request.Context(), claims.UserID))
+		c.Next()
+	}
+}
+
+// RequireScope 权限检查中间件，需放在认证中间件之后
+// JWT和API Key认证都会在gin上下文中写入 scopes，缺少所需权限时返回 403 并指明缺少的权限
+func RequireScope(scope string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !auth.HasScope(c.GetStringSlice("scopes"), scope) {
+			c.JSON(http.StatusForbidden, gin.H{
+				"success": false,
+				"message": fmt.Sprintf("缺少所需的权限：%s", scope),
+				"error":   scope,
+			})
+			c.Abort()
+			return
+		}
 		c.Next()
 	}
 }
+
+// RequireScopeForUser 按路径参数 :id 指定的用户检查权限，需放在认证中间件之后
+// 操作本人时具有 selfScope 或 anyScope 之一即可，操作其他用户时必须具有 anyScope
+func RequireScopeForUser(selfScope, anyScope string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		scopes := c.GetStringSlice("scopes")
+		if auth.HasScope(scopes, anyScope) {
+			c.Next()
+			return
+		}
+
+		if id, err := strconv.ParseUint(c.Param("id"), 10, 32); err == nil && uint(id) == c.GetUint("user_id") && auth.HasScope(scopes, selfScope) {
+			c.Next()
+			return
+		}
+
+		c.JSON(http.StatusForbidden, gin.H{
+			"success": false,
+			"message": fmt.Sprintf("缺少所需的权限：%s", anyScope),
+			"error":   anyScope,
+		})
+		c.Abort()
+	}
+}
+
+// APIKeyHeader 携带API Key的请求头
+const APIKeyHeader = "X-API-Key"
+
+// APIKeyAuthenticator 校验API Key，密钥无效时返回错误
+type APIKeyAuthenticator interface {
+	AuthenticateAPIKey(ctx context.Context, key string) (*auth.APIKeyInfo, error)
+}
+
+// APIKeyAuth API Key认证中间件，校验 X-API-Key 请求头
+// 认证通过后以密钥所属用户的身份处理请求，并在gin上下文中记录 api_key_id 和 scopes
+func APIKeyAuth(keys APIKeyAuthenticator) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := c.GetHeader(APIKeyHeader)
+		if key == "" {
+			c.JSON(http.StatusUnauthorized, gin.H{
+				"success": false,
+				"message": "缺少API Key",
+			})
+			c.Abort()
+			return
+		}
+
+		info, err := keys.AuthenticateAPIKey(c.Request.Context(), key)
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{
+				"success": false,
+				"message": "无效的API Key",
+			})
+			c.Abort()
+			return
+		}
+
+		c.Set("user_id", info.UserID)
+		c.Set("api_key_id", info.ID)
+		c.Set("scopes", info.Scopes)
+		c.Request = c.Request.WithContext(auth.ContextWithUserID(c.Request.Context(), info.UserID))
+		c.Next()
+	}
+}
+
+// AuthOrAPIKey 同时接受JWT和API Key的认证中间件
+// 请求携带 X-API-Key 时按API Key认证，否则按 Authorization 头中的JWT认证
+func AuthOrAPIKey(jwtManager *auth.JWTManager, bindFingerprint bool, keys APIKeyAuthenticator) gin.HandlerFunc {
+	jwtAuth := Auth(jwtManager, bindFingerprint)
+	apiKeyAuth := APIKeyAuth(keys)
+	return func(c *gin.Context) {
+		if c.GetHeader(APIKeyHeader) != "" {
+			apiKeyAuth(c)
+			return
+		}
+		jwtAuth(c)
+	}
+}
@@ -0,0 +1,528 @@
+package middleware
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/binary-1024/go-build-test/internal/auth"
+	"github.com/binary-1024/go-build-test/internal/cache"
+	"github.com/binary-1024/go-build-test/internal/logger"
+	"github.com/binary-1024/go-build-test/internal/server"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestMaxBodySize(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	router := gin.New()
+	router.Use(Route())
+	router.Use(MaxBodySize(16, map[string]int64{"/upload": 64}))
+	read := func(c *gin.Context) {
+		if _, err := io.ReadAll(c.Request.Body); err != nil {
+			var maxBytesErr *http.MaxBytesError
+			if errors.As(err, &maxBytesErr) {
+				c.Status(http.StatusRequestEntityTooLarge)
+				return
+			}
+			c.Status(http.StatusBadRequest)
+			return
+		}
+		c.Status(http.StatusOK)
+	}
+	router.POST("/users", read)
+	router.POST("/upload", read)
+
+	tests := []struct {
+		name        string
+		path        string
+		contentType string
+		body        string
+		chunked     bool
+		want        int
+	}{
+		{name: "未超限", path: "/users", contentType: "application/json", body: `{"a":1}`, want: http.StatusOK},
+		{name: "声明长度超限", path: "/users", contentType: "application/json", body: strings.Repeat("a", 17), want: http.StatusRequestEntityTooLarge},
+		{name: "未声明长度读取时超限", path: "/users", contentType: "application/json", body: strings.Repeat("a", 17), chunked: true, want: http.StatusRequestEntityTooLarge},
+		{name: "multipart同样受限", path: "/users", contentType: "multipart/form-data; boundary=x", body: strings.Repeat("a", 17), want: http.StatusRequestEntityTooLarge},
+		{name: "上传路由使用单独的限制", path: "/upload", contentType: "multipart/form-data; boundary=x", body: strings.Repeat("a", 64), want: http.StatusOK},
+		{name: "上传路由超过单独的限制", path: "/upload", contentType: "multipart/form-data; boundary=x", body: strings.Repeat("a", 65), chunked: true, want: http.StatusRequestEntityTooLarge},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, tt.path, strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", tt.contentType)
+			if tt.chunked {
+				req.ContentLength = -1
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			if w.Code != tt.want {
+				t.Errorf("status = %d, want %d", w.Code, tt.want)
+			}
+		})
+	}
+}
+
+func TestTimeout(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	const deadline = 20 * time.Millisecond
+	router := gin.New()
+	router.Use(Route())
+	router.Use(Timeout(deadline, "/stream"))
+	router.GET("/fast", func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"success": true})
+	})
+	router.GET("/slow-ok", func(c *gin.Context) {
+		time.Sleep(2 * deadline)
+		c.JSON(http.StatusOK, gin.H{"success": true})
+	})
+	router.GET("/slow-fail", func(c *gin.Context) {
+		<-c.Request.Context().Done()
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false})
+	})
+	router.GET("/stream", func(c *gin.Context) {
+		if _, ok := c.Request.Context().Deadline(); ok {
+			c.Status(http.StatusInternalServerError)
+			return
+		}
+		time.Sleep(2 * deadline)
+		c.String(http.StatusOK, "data")
+	})
+
+	tests := []struct {
+		name string
+		path string
+		want int
+	}{
+		{name: "截止时间之前完成", path: "/fast", want: http.StatusOK},
+		{name: "截止时间之后正常完成保留响应", path: "/slow-ok", want: http.StatusOK},
+		{name: "被截止时间中断返回504", path: "/slow-fail", want: http.StatusGatewayTimeout},
+		{name: "豁免路由不设置截止时间", path: "/stream", want: http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, tt.path, nil))
+
+			if w.Code != tt.want {
+				t.Errorf("status = %d, want %d (body %s)", w.Code, tt.want, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestRequireScopeForUser(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name   string
+		userID uint
+		scopes []string
+		path   string
+		want   int
+	}{
+		{name: "本人且有本人权限", userID: 1, scopes: []string{"profile:write"}, path: "/users/1", want: http.StatusOK},
+		{name: "其他用户只有本人权限", userID: 1, scopes: []string{"profile:write"}, path: "/users/2", want: http.StatusForbidden},
+		{name: "其他用户且有全部用户权限", userID: 1, scopes: []string{"users:write"}, path: "/users/2", want: http.StatusOK},
+		{name: "本人但没有权限", userID: 1, scopes: []string{"users:read"}, path: "/users/1", want: http.StatusForbidden},
+		{name: "无效的用户ID", userID: 1, scopes: []string{"profile:write"}, path: "/users/abc", want: http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router := gin.New()
+			router.PUT("/users/:id", func(c *gin.Context) {
+				c.Set("user_id", tt.userID)
+				c.Set("scopes", tt.scopes)
+			}, RequireScopeForUser("profile:write", "users:write"), func(c *gin.Context) {
+				c.Status(http.StatusOK)
+			})
+
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(http.MethodPut, tt.path, nil))
+
+			if w.Code != tt.want {
+				t.Errorf("status = %d, want %d", w.Code, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseBool(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    bool
+		wantErr bool
+	}{
+		{value: "true", want: true},
+		{value: "TRUE", want: true},
+		{value: "1", want: true},
+		{value: "Yes", want: true},
+		{value: "false"},
+		{value: "0"},
+		{value: "no"},
+		{value: " no "},
+		{value: "", wantErr: true},
+		{value: "on", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			got, err := ParseBool(tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseBool(%q) err = %v, wantErr %v", tt.value, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("ParseBool(%q) = %v, want %v", tt.value, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBareRequested(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name     string
+		header   string
+		fallback bool
+		want     bool
+	}{
+		{name: "未设置使用默认值", fallback: true, want: true},
+		{name: "yes开启", header: "yes", want: true},
+		{name: "no关闭", header: "no", fallback: true, want: false},
+		{name: "无效值使用默认值", header: "maybe", fallback: true, want: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, _ := gin.CreateTestContext(httptest.NewRecorder())
+			c.Request = httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.header != "" {
+				c.Request.Header.Set(BareResponseHeader, tt.header)
+			}
+			if got := bareRequested(c, tt.fallback); got != tt.want {
+				t.Errorf("bareRequested = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRedactQuery(t *testing.T) {
+	tests := []struct {
+		name string
+		raw  string
+		want string
+	}{
+		{name: "无敏感参数原样返回", raw: "page=2&b=1&a=3", want: "page=2&b=1&a=3"},
+		{name: "替换token", raw: "token=abc&page=1", want: "page=1&token=%5BREDACTED%5D"},
+		{name: "忽略大小写", raw: "Access_Token=abc", want: "Access_Token=%5BREDACTED%5D"},
+		{name: "属性字段不替换", raw: "token_type=bearer", want: "token_type=bearer"},
+		{name: "丢弃格式错误的片段", raw: "password=p&bad=%zz", want: "password=%5BREDACTED%5D"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := redactQuery(tt.raw); got != tt.want {
+				t.Errorf("redactQuery(%q) = %q, want %q", tt.raw, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCacheWarming(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name           string
+		retryAfter     int
+		done           bool
+		wantWarming    string
+		wantRetryAfter string
+	}{
+		{name: "预热中", retryAfter: 5, wantWarming: "true", wantRetryAfter: "5"},
+		{name: "预热中不设置Retry-After", wantWarming: "true"},
+		{name: "预热完成后不再提示", retryAfter: 5, done: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			state := cache.NewWarmupState()
+			state.Start()
+			if tt.done {
+				state.Done()
+			}
+
+			router := gin.New()
+			router.GET("/products", CacheWarming(state, tt.retryAfter), func(c *gin.Context) {
+				c.Status(http.StatusOK)
+			})
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/products", nil))
+
+			if w.Code != http.StatusOK {
+				t.Fatalf("status = %d, want 200", w.Code)
+			}
+			if got := w.Header().Get("X-Cache-Warming"); got != tt.wantWarming {
+				t.Errorf("X-Cache-Warming = %q, want %q", got, tt.wantWarming)
+			}
+			if got := w.Header().Get("Retry-After"); got != tt.wantRetryAfter {
+				t.Errorf("Retry-After = %q, want %q", got, tt.wantRetryAfter)
+			}
+		})
+	}
+}
+
+// recordingLogger 记录 Info 日志字段的日志器
+type recordingLogger struct {
+	logger.Logger
+	fields map[string]interface{}
+}
+
+func (l *recordingLogger) Info(msg string, fields ...interface{}) {
+	l.fields = make(map[string]interface{})
+	for i := 0; i+1 < len(fields); i += 2 {
+		l.fields[fields[i].(string)] = fields[i+1]
+	}
+}
+
+func TestLoggerRecordsRouteTemplate(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name      string
+		target    string
+		wantRoute string
+		wantPath  string
+	}{
+		{name: "带参数的路由", target: "/api/v1/products/42", wantRoute: "/api/v1/products/:id", wantPath: "/api/v1/products/42"},
+		{name: "静态路由", target: "/api/v1/products", wantRoute: "/api/v1/products", wantPath: "/api/v1/products"},
+		{name: "未匹配的路由", target: "/nope/1", wantRoute: UnmatchedRoute, wantPath: "/nope/1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			log := &recordingLogger{}
+			router := gin.New()
+			router.Use(Route(), Logger(log))
+			router.GET("/api/v1/products", func(c *gin.Context) { c.Status(http.StatusOK) })
+			router.GET("/api/v1/products/:id", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+			router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, tt.target, nil))
+
+			if got := log.fields["route"]; got != tt.wantRoute {
+				t.Errorf("route = %v, want %q", got, tt.wantRoute)
+			}
+			if got := log.fields["path"]; got != tt.wantPath {
+				t.Errorf("path = %v, want %q", got, tt.wantPath)
+			}
+		})
+	}
+}
+
+func TestAuthFingerprintBinding(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	jwtManager := auth.NewJWTManager("test-secret")
+	bound := auth.Fingerprint("test-agent", "device-1")
+
+	tests := []struct {
+		name        string
+		bind        bool
+		fingerprint string
+		userAgent   string
+		clientValue string
+		want        int
+	}{
+		{name: "指纹一致", bind: true, fingerprint: bound, userAgent: "test-agent", clientValue: "device-1", want: http.StatusOK},
+		{name: "客户端值不一致", bind: true, fingerprint: bound, userAgent: "test-agent", clientValue: "device-2", want: http.StatusUnauthorized},
+		{name: "User-Agent不一致", bind: true, fingerprint: bound, userAgent: "other-agent", clientValue: "device-1", want: http.StatusUnauthorized},
+		{name: "token未绑定指纹", bind: true, userAgent: "test-agent", clientValue: "device-1", want: http.StatusUnauthorized},
+		{name: "未开启绑定时忽略指纹", fingerprint: bound, userAgent: "other-agent", want: http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			token, err := jwtManager.GenerateWithOptions(1, "alice", auth.TokenOptions{TokenType: auth.TokenTypeAccess, Fingerprint: tt.fingerprint})
+			if err != nil {
+				t.Fatalf("GenerateWithOptions: %v", err)
+			}
+
+			router := gin.New()
+			router.GET("/me", Auth(jwtManager, tt.bind), func(c *gin.Context) { c.Status(http.StatusOK) })
+			req := httptest.NewRequest(http.MethodGet, "/me", nil)
+			req.Header.Set("Authorization", "Bearer "+token)
+			req.Header.Set("User-Agent", tt.userAgent)
+			if tt.clientValue != "" {
+				req.Header.Set(auth.FingerprintHeader, tt.clientValue)
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			if w.Code != tt.want {
+				t.Errorf("status = %d, want %d: %s", w.Code, tt.want, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestAPIVersion(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	router := gin.New()
+	router.Use(APIVersion(1, 1, 2))
+	router.GET("/ping", func(c *gin.Context) {
+		c.String(http.StatusOK, strconv.Itoa(GetAPIVersion(c)))
+	})
+
+	tests := []struct {
+		name        string
+		accept      string
+		wantStatus  int
+		wantVersion string
+	}{
+		{name: "未指定Accept使用默认版本", wantStatus: http.StatusOK, wantVersion: "1"},
+		{name: "普通JSON使用默认版本", accept: "application/json", wantStatus: http.StatusOK, wantVersion: "1"},
+		{name: "支持的版本", accept: "application/vnd.myapp.v2+json", wantStatus: http.StatusOK, wantVersion: "2"},
+		{name: "多个媒体类型取带版本的一项", accept: "text/html, application/vnd.myapp.v2+json; q=0.9", wantStatus: http.StatusOK, wantVersion: "2"},
+		{name: "不支持的版本", accept: "application/vnd.myapp.v3+json", wantStatus: http.StatusNotAcceptable},
+		{name: "格式错误的版本", accept: "application/vnd.myapp.vx+json", wantStatus: http.StatusNotAcceptable},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/ping", nil)
+			if tt.accept != "" {
+				req.Header.Set("Accept", tt.accept)
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.wantStatus, w.Body.String())
+			}
+			if tt.wantVersion != "" && w.Body.String() != tt.wantVersion {
+				t.Errorf("version = %s, want %s", w.Body.String(), tt.wantVersion)
+			}
+		})
+	}
+}
+
+func TestReadOnly(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	mode := server.NewReadOnlyMode(false)
+	router := gin.New()
+	router.Use(Route())
+	router.Use(ReadOnly(mode, "/admin/read-only"))
+	ok := func(c *gin.Context) { c.Status(http.StatusOK) }
+	router.GET("/products", ok)
+	router.HEAD("/products", ok)
+	router.POST("/products", ok)
+	router.DELETE("/products/:id", ok)
+	router.PUT("/admin/read-only", ok)
+
+	tests := []struct {
+		name    string
+		enabled bool
+		method  string
+		path    string
+		want    int
+	}{
+		{name: "关闭时允许写", method: http.MethodPost, path: "/products", want: http.StatusOK},
+		{name: "开启时允许读", enabled: true, method: http.MethodGet, path: "/products", want: http.StatusOK},
+		{name: "开启时允许HEAD", enabled: true, method: http.MethodHead, path: "/products", want: http.StatusOK},
+		{name: "开启时拒绝创建", enabled: true, method: http.MethodPost, path: "/products", want: http.StatusConflict},
+		{name: "开启时拒绝删除", enabled: true, method: http.MethodDelete, path: "/products/1", want: http.StatusConflict},
+		{name: "豁免的路由不受限制", enabled: true, method: http.MethodPut, path: "/admin/read-only", want: http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// 同一个开关在运行时切换，中间件无需重建
+			mode.Set(tt.enabled)
+
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(tt.method, tt.path, nil))
+
+			if w.Code != tt.want {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.want, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestBareResponse(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	newRouter := func(enabledByDefault bool) *gin.Engine {
+		router := gin.New()
+		router.Use(BareResponse(enabledByDefault))
+		router.GET("/item", func(c *gin.Context) {
+			c.JSON(http.StatusOK, gin.H{"success": true, "message": "获取成功", "data": gin.H{"id": 1}})
+		})
+		router.DELETE("/item", func(c *gin.Context) {
+			c.JSON(http.StatusOK, gin.H{"success": true, "message": "删除成功"})
+		})
+		router.GET("/missing", func(c *gin.Context) {
+			c.JSON(http.StatusNotFound, gin.H{"success": false, "message": "不存在", "error": "record not found"})
+		})
+		return router
+	}
+
+	tests := []struct {
+		name            string
+		enabled         bool
+		header          string
+		method          string
+		path            string
+		wantStatus      int
+		wantContentType string
+		wantBody        string
+	}{
+		{name: "默认保留外层", method: http.MethodGet, path: "/item", wantStatus: http.StatusOK, wantContentType: "application/json",
+			wantBody: `{"data":{"id":1},"message":"获取成功","success":true}`},
+		{name: "请求头开启时直接返回资源", header: "true", method: http.MethodGet, path: "/item", wantStatus: http.StatusOK, wantContentType: "application/json",
+			wantBody: `{"id":1}`},
+		{name: "配置开启时直接返回资源", enabled: true, method: http.MethodGet, path: "/item", wantStatus: http.StatusOK, wantContentType: "application/json",
+			wantBody: `{"id":1}`},
+		{name: "请求头可关闭配置的默认值", enabled: true, header: "false", method: http.MethodGet, path: "/item", wantStatus: http.StatusOK, wantContentType: "application/json",
+			wantBody: `{"data":{"id":1},"message":"获取成功","success":true}`},
+		{name: "无数据的成功响应改为204", enabled: true, method: http.MethodDelete, path: "/item", wantStatus: http.StatusNoContent},
+		{name: "默认的错误响应", method: http.MethodGet, path: "/missing", wantStatus: http.StatusNotFound, wantContentType: "application/json",
+			wantBody: `{"error":"record not found","message":"不存在","success":false}`},
+		{name: "错误响应改为problem格式", enabled: true, method: http.MethodGet, path: "/missing", wantStatus: http.StatusNotFound, wantContentType: "application/problem+json",
+			wantBody: `{"detail":"record not found","status":404,"title":"不存在","type":"about:blank"}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, nil)
+			if tt.header != "" {
+				req.Header.Set(BareResponseHeader, tt.header)
+			}
+			w := httptest.NewRecorder()
+			newRouter(tt.enabled).ServeHTTP(w, req)
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.wantStatus, w.Body.String())
+			}
+			if contentType := w.Header().Get("Content-Type"); !strings.HasPrefix(contentType, tt.wantContentType) || (tt.wantContentType == "" && contentType != "") {
+				t.Errorf("Content-Type = %q, want %q", contentType, tt.wantContentType)
+			}
+			if body := strings.TrimSpace(w.Body.String()); body != tt.wantBody {
+				t.Errorf("body = %s, want %s", body, tt.wantBody)
+			}
+		})
+	}
+}
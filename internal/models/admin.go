@@ -0,0 +1,20 @@
+package models
+
+import "time"
+
+// ReadOnlyRequest 切换只读模式请求
+type ReadOnlyRequest struct {
+	Enabled *bool `json:"enabled" binding:"required"`
+}
+
+// PurgeRequest 彻底删除软删除记录的请求
+type PurgeRequest struct {
+	OlderThanDays int `json:"older_than_days" binding:"required,min=1"`
+}
+
+// PurgeResponse 彻底删除的结果，Before 之前删除的记录已被清除
+type PurgeResponse struct {
+	Before   time.Time `json:"before"`
+	Users    int64     `json:"users"`
+	Products int64     `json:"products"`
+}
@@ -0,0 +1,68 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// APIKey 机器对机器调用使用的API Key，只保存密钥的哈希
+type APIKey struct {
+	ID        uint       `json:"id" gorm:"primaryKey"`
+	UserID    uint       `json:"user_id" gorm:"index;not null"`
+	Name      string     `json:"name" gorm:"not null"`
+	Prefix    string     `json:"prefix" gorm:"not null"`
+	KeyHash   string     `json:"-" gorm:"uniqueIndex;size:64;not null"`
+	Scopes    StringList `json:"scopes" gorm:"type:text"`
+	ExpiresAt *time.Time `json:"expires_at"`
+	RevokedAt *time.Time `json:"revoked_at"`
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+}
+
+// APIKeyResponse 接口响应中的API Key，时间字段按配置格式输出
+type APIKeyResponse APIKey
+
+// ToResponse 转换为响应中使用的API Key
+func (k *APIKey) ToResponse() APIKeyResponse {
+	return APIKeyResponse(*k)
+}
+
+// ToAPIKeyResponses 批量转换为响应中使用的API Key
+func ToAPIKeyResponses(keys []*APIKey) []APIKeyResponse {
+	result := make([]APIKeyResponse, len(keys))
+	for i, key := range keys {
+		result[i] = key.ToResponse()
+	}
+	return result
+}
+
+// MarshalJSON 按配置的时间格式输出时间字段
+func (k APIKeyResponse) MarshalJSON() ([]byte, error) {
+	type alias APIKey
+	return json.Marshal(&struct {
+		alias
+		ExpiresAt *JSONTime `json:"expires_at"`
+		RevokedAt *JSONTime `json:"revoked_at"`
+		CreatedAt JSONTime  `json:"created_at"`
+		UpdatedAt JSONTime  `json:"updated_at"`
+	}{
+		alias:     alias(k),
+		ExpiresAt: (*JSONTime)(k.ExpiresAt),
+		RevokedAt: (*JSONTime)(k.RevokedAt),
+		CreatedAt: JSONTime(k.CreatedAt),
+		UpdatedAt: JSONTime(k.UpdatedAt),
+	})
+}
+
+// CreateAPIKeyRequest 创建API Key请求
+type CreateAPIKeyRequest struct {
+	Name      string     `json:"name" binding:"required,max=64"`
+	Scopes    []string   `json:"scopes" binding:"omitempty,dive,required,max=64"`
+	ExpiresAt *time.Time `json:"expires_at"`
+}
+
+// CreateAPIKeyResponse 创建API Key响应，明文密钥只在此时返回一次
+type CreateAPIKeyResponse struct {
+	APIKey APIKeyResponse `json:"api_key"`
+	Key    string         `json:"key"`
+}
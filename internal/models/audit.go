@@ -0,0 +1,33 @@
+package models
+
+import "time"
+
+// 审计操作类型
+const (
+	AuditActionUpdate  = "update"
+	AuditActionDelete  = "delete"
+	AuditActionRestore = "restore"
+	AuditActionPurge   = "purge"
+)
+
+// 审计实体类型
+const (
+	AuditEntityUser = "user"
+)
+
+// AuditLog 审计日志模型
+type AuditLog struct {
+	ID         uint      `json:"id" gorm:"primaryKey"`
+	EntityType string    `json:"entity_type" gorm:"index:idx_audit_entity;not null"`
+	EntityID   uint      `json:"entity_id" gorm:"index:idx_audit_entity;not null"`
+	Action     string    `json:"action" gorm:"not null"`
+	ActorID    *uint     `json:"actor_id"`
+	Changes    string    `json:"changes"`
+	CreatedAt  time.Time `json:"created_at" gorm:"index"`
+}
+
+// AuditListResponse 审计日志列表响应
+type AuditListResponse struct {
+	Entries []*AuditLog `json:"entries"`
+	Pagination
+}
@@ -0,0 +1,59 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Category 产品分类
+type Category struct {
+	ID          uint      `json:"id" gorm:"primaryKey"`
+	Name        string    `json:"name" gorm:"uniqueIndex;not null"`
+	Description string    `json:"description"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// CategoryResponse 接口响应中的分类，时间字段按配置格式输出
+// Category 本身的JSON格式用于缓存，保留完整精度的标准时间
+type CategoryResponse Category
+
+// ToResponse 转换为响应中使用的分类
+func (c *Category) ToResponse() CategoryResponse {
+	return CategoryResponse(*c)
+}
+
+// ToCategoryResponses 批量转换为响应中使用的分类
+func ToCategoryResponses(categories []*Category) []CategoryResponse {
+	result := make([]CategoryResponse, len(categories))
+	for i, category := range categories {
+		result[i] = category.ToResponse()
+	}
+	return result
+}
+
+// MarshalJSON 按配置的时间格式输出时间字段
+func (c CategoryResponse) MarshalJSON() ([]byte, error) {
+	type alias Category
+	return json.Marshal(&struct {
+		alias
+		CreatedAt JSONTime `json:"created_at"`
+		UpdatedAt JSONTime `json:"updated_at"`
+	}{
+		alias:     alias(c),
+		CreatedAt: JSONTime(c.CreatedAt),
+		UpdatedAt: JSONTime(c.UpdatedAt),
+	})
+}
+
+// CreateCategoryRequest 创建分类请求
+type CreateCategoryRequest struct {
+	Name        string `json:"name" binding:"required,max=64"`
+	Description string `json:"description"`
+}
+
+// UpdateCategoryRequest 更新分类请求
+type UpdateCategoryRequest struct {
+	Name        string `json:"name" binding:"omitempty,max=64"`
+	Description string `json:"description"`
+}
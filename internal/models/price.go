@@ -0,0 +1,115 @@
+package models
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"sync/atomic"
+)
+
+// 默认的价格格式
+const (
+	DefaultPriceLocale = "zh-CN"
+	DefaultCurrency    = "CNY"
+)
+
+// priceLocale 地区的数字格式
+type priceLocale struct {
+	thousands   string
+	decimal     string
+	symbolAfter bool // 货币符号是否放在数字之后（以空格分隔）
+}
+
+var priceLocales = map[string]priceLocale{
+	"zh-CN": {thousands: ",", decimal: "."},
+	"en-US": {thousands: ",", decimal: "."},
+	"en-GB": {thousands: ",", decimal: "."},
+	"ja-JP": {thousands: ",", decimal: "."},
+	"de-DE": {thousands: ".", decimal: ",", symbolAfter: true},
+	"fr-FR": {thousands: " ", decimal: ",", symbolAfter: true},
+}
+
+// priceCurrency 货币符号和小数位数
+type priceCurrency struct {
+	symbol   string
+	decimals int
+}
+
+var priceCurrencies = map[string]priceCurrency{
+	"CNY": {symbol: "¥", decimals: 2},
+	"USD": {symbol: "$", decimals: 2},
+	"EUR": {symbol: "€", decimals: 2},
+	"GBP": {symbol: "£", decimals: 2},
+	"JPY": {symbol: "¥", decimals: 0},
+}
+
+// priceFormat 当前生效的价格格式
+type priceFormat struct {
+	locale   priceLocale
+	currency priceCurrency
+}
+
+var currentPriceFormat atomic.Value
+
+func init() {
+	currentPriceFormat.Store(priceFormat{
+		locale:   priceLocales[DefaultPriceLocale],
+		currency: priceCurrencies[DefaultCurrency],
+	})
+}
+
+// SetPriceFormat 设置响应中 price_formatted 字段使用的地区和货币
+// 不支持的地区或货币返回错误，并保持原有格式
+func SetPriceFormat(locale, currency string) error {
+	l, ok := priceLocales[locale]
+	if !ok {
+		return fmt.Errorf("不支持的地区: %s", locale)
+	}
+	c, ok := priceCurrencies[strings.ToUpper(currency)]
+	if !ok {
+		return fmt.Errorf("不支持的货币: %s", currency)
+	}
+	currentPriceFormat.Store(priceFormat{locale: l, currency: c})
+	return nil
+}
+
+// FormatPrice 按配置的地区和货币格式化价格，例如 ¥1,234.50 或 1.234,50 €
+func FormatPrice(price float64) string {
+	format := currentPriceFormat.Load().(priceFormat)
+	return format.format(price)
+}
+
+func (f priceFormat) format(price float64) string {
+	negative := price < 0
+	raw := strconv.FormatFloat(math.Abs(price), 'f', f.currency.decimals, 64)
+
+	intPart, fracPart := raw, ""
+	if dot := strings.IndexByte(raw, '.'); dot >= 0 {
+		intPart, fracPart = raw[:dot], raw[dot+1:]
+	}
+
+	// 从右往左每三位插入千位分隔符
+	var b strings.Builder
+	for i, digit := range intPart {
+		if i > 0 && (len(intPart)-i)%3 == 0 {
+			b.WriteString(f.locale.thousands)
+		}
+		b.WriteRune(digit)
+	}
+	number := b.String()
+	if fracPart != "" {
+		number += f.locale.decimal + fracPart
+	}
+
+	var result string
+	if f.locale.symbolAfter {
+		result = number + " " + f.currency.symbol
+	} else {
+		result = f.currency.symbol + number
+	}
+	if negative {
+		result = "-" + result
+	}
+	return result
+}
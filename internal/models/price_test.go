@@ -0,0 +1,59 @@
+package models
+
+import "testing"
+
+func TestFormatPrice(t *testing.T) {
+	tests := []struct {
+		name     string
+		locale   string
+		currency string
+		price    float64
+		want     string
+	}{
+		{name: "zh-CN 人民币", locale: "zh-CN", currency: "CNY", price: 1234.5, want: "¥1,234.50"},
+		{name: "zh-CN 小于一千", locale: "zh-CN", currency: "CNY", price: 99.99, want: "¥99.99"},
+		{name: "en-US 美元", locale: "en-US", currency: "USD", price: 1234567.891, want: "$1,234,567.89"},
+		{name: "en-US 货币代码不区分大小写", locale: "en-US", currency: "usd", price: 0.5, want: "$0.50"},
+		{name: "en-US 负数", locale: "en-US", currency: "USD", price: -1234.5, want: "-$1,234.50"},
+		{name: "de-DE 欧元", locale: "de-DE", currency: "EUR", price: 1234567.5, want: "1.234.567,50 €"},
+		{name: "de-DE 整千", locale: "de-DE", currency: "EUR", price: 1000, want: "1.000,00 €"},
+		{name: "ja-JP 日元没有小数", locale: "ja-JP", currency: "JPY", price: 1234.6, want: "¥1,235"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Cleanup(func() { _ = SetPriceFormat(DefaultPriceLocale, DefaultCurrency) })
+			if err := SetPriceFormat(tt.locale, tt.currency); err != nil {
+				t.Fatalf("SetPriceFormat(%s, %s): %v", tt.locale, tt.currency, err)
+			}
+			if got := FormatPrice(tt.price); got != tt.want {
+				t.Errorf("FormatPrice(%v) = %q, want %q", tt.price, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSetPriceFormatUnsupported(t *testing.T) {
+	tests := []struct {
+		name     string
+		locale   string
+		currency string
+	}{
+		{name: "未知地区", locale: "xx-XX", currency: "USD"},
+		{name: "未知货币", locale: "en-US", currency: "XYZ"},
+		{name: "空地区", locale: "", currency: "CNY"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Cleanup(func() { _ = SetPriceFormat(DefaultPriceLocale, DefaultCurrency) })
+			if err := SetPriceFormat(tt.locale, tt.currency); err == nil {
+				t.Fatalf("SetPriceFormat(%q, %q) succeeded, want error", tt.locale, tt.currency)
+			}
+			// 设置失败时保持原有格式
+			if got := FormatPrice(1234.5); got != "¥1,234.50" {
+				t.Errorf("FormatPrice after failed set = %q, want ¥1,234.50", got)
+			}
+		})
+	}
+}
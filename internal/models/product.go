@@ -1,10 +1,16 @@
 package models
 
 import (
+	"encoding/json"
+	"strings"
 	"time"
+
 	"gorm.io/gorm"
 )
 
+// MaxQueryCategories 多分类查询允许的最大分类数
+const MaxQueryCategories = 20
+
 // Product 产品模型
 type Product struct {
 	ID          uint           `json:"id" gorm:"primaryKey"`
@@ -12,46 +18,298 @@ type Product struct {
 	Description string         `json:"description"`
 	Price       float64        `json:"price" gorm:"not null"`
 	Stock       int            `json:"stock" gorm:"default:0"`
-	Category    string         `json:"category"`
+	CategoryID  *uint          `json:"category_id" gorm:"index"`
+	Category    *Category      `json:"category,omitempty"`
 	IsActive    bool           `json:"is_active" gorm:"default:true"`
+	Images      []ProductImage `json:"images,omitempty" gorm:"foreignKey:ProductID"`
+	Version     int            `json:"version" gorm:"not null;default:1"`
+	CreatedBy   *uint          `json:"created_by"`
+	UpdatedBy   *uint          `json:"updated_by"`
 	CreatedAt   time.Time      `json:"created_at"`
 	UpdatedAt   time.Time      `json:"updated_at"`
 	DeletedAt   gorm.DeletedAt `json:"-" gorm:"index"`
 }
 
+// ProductImage 产品图片
+type ProductImage struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	ProductID uint      `json:"product_id" gorm:"index;not null"`
+	URL       string    `json:"url" gorm:"not null"`
+	SortOrder int       `json:"sort_order" gorm:"default:0"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// AddProductImageRequest 添加产品图片请求
+type AddProductImageRequest struct {
+	URL       string `json:"url" binding:"required,url"`
+	SortOrder int    `json:"sort_order" binding:"min=0"`
+}
+
+// ProductResponse 接口响应中的产品，时间字段按配置格式输出并附带格式化后的价格
+// Product 本身的JSON格式用于缓存，保留完整精度的标准时间，响应中的产品统一转换为该类型
+type ProductResponse Product
+
+// ToResponse 转换为响应中使用的产品
+func (p *Product) ToResponse() ProductResponse {
+	return ProductResponse(*p)
+}
+
+// ToProductResponses 批量转换为响应中使用的产品
+func ToProductResponses(products []*Product) []ProductResponse {
+	result := make([]ProductResponse, len(products))
+	for i, product := range products {
+		result[i] = product.ToResponse()
+	}
+	return result
+}
+
+// MarshalJSON 按配置的时间格式输出时间字段，并附带格式化后的价格
+func (p ProductResponse) MarshalJSON() ([]byte, error) {
+	type alias Product
+	return json.Marshal(&struct {
+		alias
+		Category       *CategoryResponse `json:"category,omitempty"`
+		PriceFormatted string            `json:"price_formatted"`
+		CreatedAt      JSONTime          `json:"created_at"`
+		UpdatedAt      JSONTime          `json:"updated_at"`
+	}{
+		alias:          alias(p),
+		Category:       (*CategoryResponse)(p.Category),
+		PriceFormatted: FormatPrice(p.Price),
+		CreatedAt:      JSONTime(p.CreatedAt),
+		UpdatedAt:      JSONTime(p.UpdatedAt),
+	})
+}
+
+// 产品响应视图
+const (
+	ProductViewAdmin      = "admin"
+	ProductViewStorefront = "storefront"
+)
+
+// StorefrontProduct 面向店面的产品视图，不包含库存、上下架状态和内部时间戳
+type StorefrontProduct struct {
+	ID             uint    `json:"id"`
+	Name           string  `json:"name"`
+	Description    string  `json:"description"`
+	Price          float64 `json:"price"`
+	PriceFormatted string  `json:"price_formatted"`
+	Category       string  `json:"category"`
+}
+
+// ToStorefront 转换为店面视图
+func (p *Product) ToStorefront() StorefrontProduct {
+	return StorefrontProduct{
+		ID:             p.ID,
+		Name:           p.Name,
+		Description:    p.Description,
+		Price:          p.Price,
+		PriceFormatted: FormatPrice(p.Price),
+		Category:       p.CategoryName(),
+	}
+}
+
+// CategoryName 返回已加载的分类名称，未加载或未分类时返回空字符串
+func (p *Product) CategoryName() string {
+	if p.Category == nil {
+		return ""
+	}
+	return p.Category.Name
+}
+
 // CreateProductRequest 创建产品请求
 type CreateProductRequest struct {
 	Name        string  `json:"name" binding:"required"`
 	Description string  `json:"description"`
 	Price       float64 `json:"price" binding:"required,min=0"`
 	Stock       int     `json:"stock" binding:"min=0"`
-	Category    string  `json:"category"`
+	CategoryID  uint    `json:"category_id" binding:"required"`
 }
 
 // UpdateProductRequest 更新产品请求
 type UpdateProductRequest struct {
-	Name        string  `json:"name"`
-	Description string  `json:"description"`
+	Name        string   `json:"name"`
+	Description string   `json:"description"`
 	Price       *float64 `json:"price" binding:"omitempty,min=0"`
-	Stock       *int    `json:"stock" binding:"omitempty,min=0"`
-	Category    string  `json:"category"`
-	IsActive    *bool   `json:"is_active"`
+	Stock       *int     `json:"stock" binding:"omitempty,min=0"`
+	CategoryID  *uint    `json:"category_id"`
+	IsActive    *bool    `json:"is_active"`
+	// Version 客户端读取到的产品版本，必须提供（或使用 If-Match 请求头），版本不一致时返回 409
+	Version *int `json:"version" binding:"omitempty,min=1"`
+}
+
+// StockEvent 产品库存变化事件，通过Redis频道广播并以SSE推送给订阅的客户端
+type StockEvent struct {
+	ProductID uint     `json:"product_id"`
+	Stock     int      `json:"stock"`
+	UpdatedAt JSONTime `json:"updated_at"`
+}
+
+// PurchaseRequest 购买产品请求
+type PurchaseRequest struct {
+	Quantity int `json:"quantity" binding:"required,min=1"`
+}
+
+// ReserveStockRequest 预留库存请求
+type ReserveStockRequest struct {
+	Quantity int `json:"quantity" binding:"required,min=1"`
+}
+
+// ReserveStockResponse 预留库存响应，ExpiresIn 为预留的有效秒数
+type ReserveStockResponse struct {
+	ReservationID string `json:"reservation_id"`
+	ExpiresIn     int    `json:"expires_in"`
+}
+
+// ImportRowError CSV导入中某一行的错误，Line 为文件中的行号（表头为第1行）
+type ImportRowError struct {
+	Line  int    `json:"line"`
+	Error string `json:"error"`
+}
+
+// ProductImportResponse CSV导入产品的结果
+type ProductImportResponse struct {
+	Imported int              `json:"imported"`
+	Errors   []ImportRowError `json:"errors"`
+}
+
+// ProductViewsResponse 产品浏览次数响应
+type ProductViewsResponse struct {
+	ProductID uint  `json:"product_id"`
+	Views     int64 `json:"views"`
 }
 
 // ProductQuery 产品查询参数
 type ProductQuery struct {
-	Page     int     `form:"page,default=1" binding:"min=1"`
-	Limit    int     `form:"limit,default=10" binding:"min=1,max=100"`
-	Category string  `form:"category"`
-	MinPrice float64 `form:"min_price" binding:"min=0"`
-	MaxPrice float64 `form:"max_price" binding:"min=0"`
-	Search   string  `form:"search"`
+	Page       int     `form:"page,default=1" binding:"min=1"`
+	Limit      int     `form:"limit,default=10" binding:"min=1,max=100"`
+	Category   string  `form:"category"`
+	Categories string  `form:"categories"`
+	CategoryID uint    `form:"category_id"`
+	MinPrice   float64 `form:"min_price" binding:"min=0"`
+	MaxPrice   float64 `form:"max_price" binding:"min=0"`
+	Search     string  `form:"search"`
+	SortBy     string  `form:"sort_by" binding:"omitempty,oneof=name price created_at stock"`
+	Order      string  `form:"order" binding:"omitempty,oneof=asc desc ASC DESC"`
+	IsActive   *bool   `form:"-"`
+	InStock    *bool   `form:"-"`
+	// Pagination 为 cursor 时使用游标分页（按创建时间倒序），忽略 page，不支持 sort_by 和 order
+	Pagination string  `form:"pagination" binding:"omitempty,oneof=offset cursor"`
+	Cursor     string  `form:"cursor"`
+	After      *Cursor `form:"-"`
+}
+
+// UsesCursor 是否使用游标分页，传入 cursor 时也视为游标分页
+func (q *ProductQuery) UsesCursor() bool {
+	return q.Pagination == PaginationCursor || q.Cursor != ""
+}
+
+// productSortColumns 允许排序的列，防止通过排序字段注入SQL
+var productSortColumns = map[string]bool{
+	"name":       true,
+	"price":      true,
+	"created_at": true,
+	"stock":      true,
+}
+
+// OrderClause 返回排序子句，非白名单字段回退到默认的 created_at DESC
+func (q *ProductQuery) OrderClause() string {
+	column := q.SortBy
+	if !productSortColumns[column] {
+		return "created_at DESC"
+	}
+
+	direction := "ASC"
+	if strings.EqualFold(q.Order, "desc") {
+		direction = "DESC"
+	}
+	return column + " " + direction
+}
+
+// CategoryList 解析逗号分隔的分类列表，忽略空值
+func (q *ProductQuery) CategoryList() []string {
+	if q.Categories == "" {
+		return nil
+	}
+
+	var categories []string
+	for _, category := range strings.Split(q.Categories, ",") {
+		if category = strings.TrimSpace(category); category != "" {
+			categories = append(categories, category)
+		}
+	}
+	return categories
+}
+
+// StorefrontProductListResponse 店面视图的产品列表响应
+type StorefrontProductListResponse struct {
+	Products []StorefrontProduct `json:"products"`
+	Pagination
 }
 
 // ProductListResponse 产品列表响应
 type ProductListResponse struct {
-	Products []Product `json:"products"`
-	Total    int64     `json:"total"`
-	Page     int       `json:"page"`
-	Limit    int       `json:"limit"`
+	Products []ProductResponse `json:"products"`
+	Pagination
+}
+
+// ProductCursorListResponse 游标分页的产品列表响应
+type ProductCursorListResponse struct {
+	Products []ProductResponse `json:"products"`
+	CursorPagination
+}
+
+// StorefrontProductCursorListResponse 店面视图的游标分页产品列表响应
+type StorefrontProductCursorListResponse struct {
+	Products []StorefrontProduct `json:"products"`
+	CursorPagination
+}
+
+// ToStorefront 转换为店面视图的列表响应
+func (r *ProductCursorListResponse) ToStorefront() *StorefrontProductCursorListResponse {
+	products := make([]StorefrontProduct, len(r.Products))
+	for i := range r.Products {
+		products[i] = (*Product)(&r.Products[i]).ToStorefront()
+	}
+	return &StorefrontProductCursorListResponse{
+		Products:         products,
+		CursorPagination: r.CursorPagination,
+	}
+}
+
+// ToStorefront 转换为店面视图的列表响应
+func (r *ProductListResponse) ToStorefront() *StorefrontProductListResponse {
+	products := make([]StorefrontProduct, len(r.Products))
+	for i := range r.Products {
+		products[i] = (*Product)(&r.Products[i]).ToStorefront()
+	}
+	return &StorefrontProductListResponse{
+		Products:   products,
+		Pagination: r.Pagination,
+	}
+}
+
+// ProductBatchResponse 按ID批量获取产品的响应，products 按请求中ID的顺序排列
+type ProductBatchResponse struct {
+	Products []ProductResponse `json:"products"`
+	// Missing 不存在的产品ID
+	Missing []uint `json:"missing"`
+}
+
+// StorefrontProductBatchResponse 店面视图的批量获取产品响应
+type StorefrontProductBatchResponse struct {
+	Products []StorefrontProduct `json:"products"`
+	Missing  []uint              `json:"missing"`
+}
+
+// ToStorefront 转换为店面视图的批量获取响应
+func (r *ProductBatchResponse) ToStorefront() *StorefrontProductBatchResponse {
+	products := make([]StorefrontProduct, len(r.Products))
+	for i := range r.Products {
+		products[i] = (*Product)(&r.Products[i]).ToStorefront()
+	}
+	return &StorefrontProductBatchResponse{
+		Products: products,
+		Missing:  r.Missing,
+	}
 }
@@ -0,0 +1,102 @@
+package models
+
+import (
+	"bytes"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// 预置的时间格式
+const (
+	TimeFormatRFC3339 = "rfc3339"
+	TimeFormatUnix    = "unix"
+	TimeFormatUnixMs  = "unix_ms"
+)
+
+var timeFormat atomic.Value
+
+func init() {
+	timeFormat.Store(TimeFormatRFC3339)
+}
+
+// SetTimeFormat 设置响应中时间字段的格式
+// 支持 rfc3339、unix、unix_ms，其他值按Go时间布局字符串处理
+func SetTimeFormat(format string) {
+	if format == "" {
+		format = TimeFormatRFC3339
+	}
+	timeFormat.Store(format)
+}
+
+// GetTimeFormat 获取当前时间格式
+func GetTimeFormat() string {
+	return timeFormat.Load().(string)
+}
+
+// JSONTime 按配置格式序列化的时间
+type JSONTime time.Time
+
+// MarshalJSON 按配置格式序列化
+func (t JSONTime) MarshalJSON() ([]byte, error) {
+	tm := time.Time(t)
+	switch format := GetTimeFormat(); strings.ToLower(format) {
+	case TimeFormatRFC3339:
+		return tm.MarshalJSON()
+	case TimeFormatUnix:
+		return []byte(strconv.FormatInt(tm.Unix(), 10)), nil
+	case TimeFormatUnixMs:
+		return []byte(strconv.FormatInt(tm.UnixMilli(), 10)), nil
+	default:
+		return []byte(strconv.Quote(tm.Format(format))), nil
+	}
+}
+
+// UnmarshalJSON 兼容解析各种配置格式，保证按配置格式发布的事件可以正常读回
+func (t *JSONTime) UnmarshalJSON(data []byte) error {
+	if bytes.Equal(data, []byte("null")) {
+		return nil
+	}
+
+	// 数字形式的Unix时间戳
+	if len(data) > 0 && data[0] != '"' {
+		value, err := strconv.ParseInt(string(data), 10, 64)
+		if err != nil {
+			return err
+		}
+		if strings.ToLower(GetTimeFormat()) == TimeFormatUnixMs {
+			*t = JSONTime(time.UnixMilli(value))
+		} else {
+			*t = JSONTime(time.Unix(value, 0))
+		}
+		return nil
+	}
+
+	str, err := strconv.Unquote(string(data))
+	if err != nil {
+		return err
+	}
+
+	layouts := []string{time.RFC3339Nano}
+	if format := GetTimeFormat(); !isPresetTimeFormat(format) {
+		layouts = append([]string{format}, layouts...)
+	}
+
+	for _, layout := range layouts {
+		var parsed time.Time
+		if parsed, err = time.Parse(layout, str); err == nil {
+			*t = JSONTime(parsed)
+			return nil
+		}
+	}
+	return err
+}
+
+func isPresetTimeFormat(format string) bool {
+	switch strings.ToLower(format) {
+	case TimeFormatRFC3339, TimeFormatUnix, TimeFormatUnixMs:
+		return true
+	}
+	return false
+}
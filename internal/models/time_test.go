@@ -0,0 +1,100 @@
+package models
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestJSONTimeFormat(t *testing.T) {
+	tm := time.Date(2024, 3, 5, 8, 30, 15, 123456789, time.UTC)
+
+	tests := []struct {
+		format string
+		want   string
+	}{
+		{format: "", want: `"2024-03-05T08:30:15.123456789Z"`},
+		{format: TimeFormatRFC3339, want: `"2024-03-05T08:30:15.123456789Z"`},
+		{format: TimeFormatUnix, want: `1709627415`},
+		{format: "UNIX_MS", want: `1709627415123`},
+		{format: "2006-01-02 15:04:05", want: `"2024-03-05 08:30:15"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			SetTimeFormat(tt.format)
+			t.Cleanup(func() { SetTimeFormat(TimeFormatRFC3339) })
+
+			data, err := json.Marshal(JSONTime(tm))
+			if err != nil {
+				t.Fatalf("Marshal: %v", err)
+			}
+			if string(data) != tt.want {
+				t.Errorf("Marshal = %s, want %s", data, tt.want)
+			}
+
+			// 按同一格式输出的值可以读回，精度受格式限制
+			var parsed JSONTime
+			if err := json.Unmarshal(data, &parsed); err != nil {
+				t.Fatalf("Unmarshal %s: %v", data, err)
+			}
+			if got := time.Time(parsed); got.Unix() != tm.Unix() {
+				t.Errorf("Unmarshal = %v, want %v", got, tm)
+			}
+		})
+	}
+}
+
+func TestResponseTypesUseTimeFormat(t *testing.T) {
+	SetTimeFormat(TimeFormatUnix)
+	t.Cleanup(func() { SetTimeFormat(TimeFormatRFC3339) })
+
+	tm := time.Date(2024, 3, 5, 8, 30, 15, 0, time.UTC)
+	tests := []struct {
+		name  string
+		value interface{}
+	}{
+		{name: "用户", value: (&User{CreatedAt: tm, UpdatedAt: tm}).ToPublic()},
+		{name: "产品", value: (&Product{CreatedAt: tm, UpdatedAt: tm}).ToResponse()},
+		{name: "分类", value: (&Category{CreatedAt: tm, UpdatedAt: tm}).ToResponse()},
+		{name: "API Key", value: (&APIKey{CreatedAt: tm, UpdatedAt: tm}).ToResponse()},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := json.Marshal(tt.value)
+			if err != nil {
+				t.Fatalf("Marshal: %v", err)
+			}
+			var resp struct {
+				CreatedAt int64 `json:"created_at"`
+				UpdatedAt int64 `json:"updated_at"`
+			}
+			if err := json.Unmarshal(data, &resp); err != nil {
+				t.Fatalf("Unmarshal %s: %v", data, err)
+			}
+			if resp.CreatedAt != tm.Unix() || resp.UpdatedAt != tm.Unix() {
+				t.Errorf("times = %d/%d, want %d: %s", resp.CreatedAt, resp.UpdatedAt, tm.Unix(), data)
+			}
+		})
+	}
+}
+
+func TestModelsKeepStandardTimeJSON(t *testing.T) {
+	SetTimeFormat(TimeFormatUnix)
+	t.Cleanup(func() { SetTimeFormat(TimeFormatRFC3339) })
+
+	// 模型本身用于缓存，不受响应时间格式影响
+	tm := time.Date(2024, 3, 5, 8, 30, 15, 123456789, time.UTC)
+	data, err := json.Marshal(&Product{CreatedAt: tm})
+	if err != nil {
+		t.Fatalf("Marshal: %v", err)
+	}
+	var product Product
+	if err := json.Unmarshal(data, &product); err != nil {
+		t.Fatalf("Unmarshal %s: %v", data, err)
+	}
+	if !product.CreatedAt.Equal(tm) {
+		t.Errorf("created_at = %v, want %v", product.CreatedAt, tm)
+	}
+}
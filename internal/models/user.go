@@ -1,7 +1,9 @@
 package models
 
 import (
+	"strings"
 	"time"
+	"unicode"
 
 	"golang.org/x/crypto/bcrypt"
 	"gorm.io/gorm"
@@ -12,38 +14,212 @@ type User struct {
 	ID        uint      `json:"id" gorm:"primaryKey"`
 	Username  string    `json:"username" gorm:"uniqueIndex;not null"`
 	Email     string    `json:"email" gorm:"uniqueIndex;not null"`
+	// NormalizedEmail 归一化后的邮箱，只在开启邮箱归一化时保存，由唯一索引保证不重复
+	NormalizedEmail *string `json:"-" gorm:"uniqueIndex:uidx_users_normalized_email"`
 	Password  string    `json:"-" gorm:"not null"`
 	FullName  string    `json:"full_name"`
+	AvatarURL string    `json:"avatar_url"`
 	IsActive  bool      `json:"is_active" gorm:"default:true"`
+	// EmailVerified 是否已通过验证邮件确认邮箱
+	EmailVerified bool  `json:"email_verified" gorm:"not null;default:false"`
+	// Scopes 分配给用户的权限范围，为空（null）时只拥有默认权限
+	Scopes    StringList `json:"scopes" gorm:"type:text"`
+	// CreatedBy/UpdatedBy 创建和最后修改该用户的登录用户ID，公开注册时为空
+	CreatedBy *uint     `json:"created_by"`
+	UpdatedBy *uint     `json:"updated_by"`
 	CreatedAt time.Time `json:"created_at"`
 	UpdatedAt time.Time `json:"updated_at"`
 	DeletedAt gorm.DeletedAt `json:"-" gorm:"index"`
 }
 
+// UserPublic 接口响应中的用户，不包含密码哈希和创建人、修改人等内部审计字段
+// User 本身的JSON格式用于缓存，响应中的用户统一转换为该类型
+type UserPublic struct {
+	ID            uint       `json:"id"`
+	Username      string     `json:"username"`
+	Email         string     `json:"email"`
+	FullName      string     `json:"full_name"`
+	AvatarURL     string     `json:"avatar_url"`
+	IsActive      bool       `json:"is_active"`
+	EmailVerified bool       `json:"email_verified"`
+	Scopes        StringList `json:"scopes"`
+	CreatedAt     JSONTime   `json:"created_at"`
+	UpdatedAt     JSONTime   `json:"updated_at"`
+}
+
+// ToPublic 转换为响应中使用的用户
+func (u *User) ToPublic() UserPublic {
+	return UserPublic{
+		ID:            u.ID,
+		Username:      u.Username,
+		Email:         u.Email,
+		FullName:      u.FullName,
+		AvatarURL:     u.AvatarURL,
+		IsActive:      u.IsActive,
+		EmailVerified: u.EmailVerified,
+		Scopes:        u.Scopes,
+		CreatedAt:     JSONTime(u.CreatedAt),
+		UpdatedAt:     JSONTime(u.UpdatedAt),
+	}
+}
+
+// ToPublicUsers 批量转换为响应中使用的用户
+func ToPublicUsers(users []*User) []UserPublic {
+	result := make([]UserPublic, len(users))
+	for i, user := range users {
+		result[i] = user.ToPublic()
+	}
+	return result
+}
+
 // CreateUserRequest 创建用户请求
 type CreateUserRequest struct {
-	Username string `json:"username" binding:"required,min=3,max=50"`
+	Username string `json:"username" binding:"required,min=3,max=32"`
 	Email    string `json:"email" binding:"required,email"`
-	Password string `json:"password" binding:"required,min=6"`
+	Password string `json:"password" binding:"required,password"`
 	FullName string `json:"full_name" binding:"required"`
 }
 
+// BatchCreateUsersRequest 批量创建用户请求
+type BatchCreateUsersRequest struct {
+	Users []*CreateUserRequest `json:"users" binding:"required,min=1,max=100"`
+}
+
+// BatchRowError 批量操作中单行的错误
+type BatchRowError struct {
+	Index  int               `json:"index"`
+	Error  string            `json:"error"`
+	Fields map[string]string `json:"fields,omitempty"`
+}
+
+// BatchCreateUsersResponse 批量创建用户响应
+type BatchCreateUsersResponse struct {
+	Created []UserPublic    `json:"created"`
+	Errors  []BatchRowError `json:"errors"`
+}
+
 // UpdateUserRequest 更新用户请求
 type UpdateUserRequest struct {
 	FullName string `json:"full_name"`
 	IsActive *bool  `json:"is_active"`
 }
 
+// UserListResponse 用户列表响应
+type UserListResponse struct {
+	Users []UserPublic `json:"users"`
+	Pagination
+}
+
 // LoginRequest 登录请求
 type LoginRequest struct {
 	Username string `json:"username" binding:"required"`
 	Password string `json:"password" binding:"required"`
+	// Scopes 申请的权限范围，必须是用户权限的子集；为空时签发用户的全部权限，可用于申请只读token
+	Scopes []string `json:"scopes" binding:"omitempty,dive,required"`
+
+	// Fingerprint 客户端指纹，由处理器根据请求头填充
+	Fingerprint string `json:"-"`
+}
+
+// IntrospectRequest token校验请求
+type IntrospectRequest struct {
+	Token string `json:"token" binding:"required"`
+
+	// Fingerprint 客户端指纹，由处理器根据请求头填充
+	Fingerprint string `json:"-"`
+}
+
+// VerifyEmailRequest 邮箱验证请求
+type VerifyEmailRequest struct {
+	Token string `json:"token" binding:"required"`
+}
+
+// ForgotPasswordRequest 申请重置密码请求
+type ForgotPasswordRequest struct {
+	Email string `json:"email" binding:"required,email"`
+}
+
+// ResetPasswordRequest 重置密码请求
+type ResetPasswordRequest struct {
+	Token    string `json:"token" binding:"required"`
+	Password string `json:"password" binding:"required,password"`
+}
+
+// IntrospectResponse token校验响应
+type IntrospectResponse struct {
+	Active    bool   `json:"active"`
+	UserID    uint   `json:"user_id"`
+	Username  string `json:"username"`
+	TokenType string `json:"token_type,omitempty"`
+	// Scope 空格分隔的授权范围
+	Scope     string `json:"scope,omitempty"`
+	ExpiresAt int64  `json:"exp,omitempty"`
+	IssuedAt  int64  `json:"iat,omitempty"`
+}
+
+// SetUserScopesRequest 设置用户权限请求，scopes 为 null 时恢复为默认权限
+type SetUserScopesRequest struct {
+	Scopes []string `json:"scopes" binding:"omitempty,dive,required"`
 }
 
 // LoginResponse 登录响应
 type LoginResponse struct {
 	Token string `json:"token"`
-	User  User   `json:"user"`
+	// RefreshToken 用于换取新的访问token，每个只能使用一次；未启用时为空
+	RefreshToken string     `json:"refresh_token,omitempty"`
+	User         UserPublic `json:"user"`
+}
+
+// RefreshTokenRequest 刷新token请求
+type RefreshTokenRequest struct {
+	RefreshToken string `json:"refresh_token" binding:"required"`
+
+	// Fingerprint 客户端指纹，由处理器根据请求头填充
+	Fingerprint string `json:"-"`
+}
+
+// NormalizeEmail 归一化邮箱：转为小写并去掉本地部分的 +tag 后缀
+// 例如 User+news@Gmail.com 归一化为 user@gmail.com
+func NormalizeEmail(email string) string {
+	email = strings.ToLower(strings.TrimSpace(email))
+	at := strings.LastIndex(email, "@")
+	if at < 0 {
+		return email
+	}
+	local, domain := email[:at], email[at:]
+	if plus := strings.Index(local, "+"); plus >= 0 {
+		local = local[:plus]
+	}
+	return local + domain
+}
+
+// AvatarContentTypes 允许上传的头像类型及对应的文件扩展名
+var AvatarContentTypes = map[string]string{
+	"image/jpeg": ".jpg",
+	"image/png":  ".png",
+	"image/gif":  ".gif",
+	"image/webp": ".webp",
+}
+
+// PasswordMinLength 密码最小长度
+const PasswordMinLength = 8
+
+// IsStrongPassword 检查密码强度：至少 PasswordMinLength 位，且同时包含字母和数字
+func IsStrongPassword(password string) bool {
+	if len([]rune(password)) < PasswordMinLength {
+		return false
+	}
+
+	var hasLetter, hasDigit bool
+	for _, r := range password {
+		switch {
+		case unicode.IsLetter(r):
+			hasLetter = true
+		case unicode.IsDigit(r):
+			hasDigit = true
+		}
+	}
+	return hasLetter && hasDigit
 }
 
 // HashPassword 加密密码
@@ -0,0 +1,26 @@
+package models
+
+import "testing"
+
+func TestNormalizeEmail(t *testing.T) {
+	tests := []struct {
+		email string
+		want  string
+	}{
+		{email: "user@example.com", want: "user@example.com"},
+		{email: "User@Example.COM", want: "user@example.com"},
+		{email: "user+tag@gmail.com", want: "user@gmail.com"},
+		{email: "user+a+b@gmail.com", want: "user@gmail.com"},
+		{email: "  user+news@example.com ", want: "user@example.com"},
+		{email: "first.last@example.com", want: "first.last@example.com"},
+		{email: "not-an-email", want: "not-an-email"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.email, func(t *testing.T) {
+			if got := NormalizeEmail(tt.email); got != tt.want {
+				t.Errorf("NormalizeEmail(%q) = %q, want %q", tt.email, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,45 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/binary-1024/go-build-test/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// APIKeyRepository API Key仓库接口
+type APIKeyRepository interface {
+	Create(ctx context.Context, key *models.APIKey) error
+	GetByID(ctx context.Context, id uint) (*models.APIKey, error)
+	GetByHash(ctx context.Context, hash string) (*models.APIKey, error)
+	ListByUser(ctx context.Context, userID uint) ([]*models.APIKey, error)
+	Update(ctx context.Context, id uint, updates map[string]interface{}) error
+}
+
+// apiKeyRepository API Key仓库实现
+type apiKeyRepository struct {
+	Base[models.APIKey]
+}
+
+// NewAPIKeyRepository 创建API Key仓库
+func NewAPIKeyRepository(db *gorm.DB) APIKeyRepository {
+	return &apiKeyRepository{Base: NewBase[models.APIKey](db)}
+}
+
+// GetByHash 根据密钥哈希获取API Key
+func (r *apiKeyRepository) GetByHash(ctx context.Context, hash string) (*models.APIKey, error) {
+	var key models.APIKey
+	err := r.db.WithContext(ctx).Where("key_hash = ?", hash).First(&key).Error
+	if err != nil {
+		return nil, err
+	}
+	return &key, nil
+}
+
+// ListByUser 获取用户的全部API Key（包括已撤销的），按创建时间倒序
+func (r *apiKeyRepository) ListByUser(ctx context.Context, userID uint) ([]*models.APIKey, error) {
+	var keys []*models.APIKey
+	err := r.db.WithContext(ctx).Where("user_id = ?", userID).Order("created_at DESC, id DESC").Find(&keys).Error
+	return keys, err
+}
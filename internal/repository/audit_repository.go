@@ -0,0 +1,49 @@
+package repository
+
+import (
+	"context"
+	"github.com/binary-1024/go-build-test/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// AuditRepository 审计日志仓库接口
+type AuditRepository interface {
+	Create(ctx context.Context, entry *models.AuditLog) error
+	ListByEntity(ctx context.Context, entityType string, entityID uint, offset, limit int) ([]*models.AuditLog, int64, error)
+}
+
+// auditRepository 审计日志仓库实现
+type auditRepository struct {
+	db *gorm.DB
+}
+
+// NewAuditRepository 创建审计日志仓库
+func NewAuditRepository(db *gorm.DB) AuditRepository {
+	return &auditRepository{db: db}
+}
+
+// Create 记录审计日志
+func (r *auditRepository) Create(ctx context.Context, entry *models.AuditLog) error {
+	return r.db.WithContext(ctx).Create(entry).Error
+}
+
+// ListByEntity 按实体获取审计日志，按时间倒序
+func (r *auditRepository) ListByEntity(ctx context.Context, entityType string, entityID uint, offset, limit int) ([]*models.AuditLog, int64, error) {
+	var entries []*models.AuditLog
+	var total int64
+
+	db := r.db.WithContext(ctx).Model(&models.AuditLog{}).Where("entity_type = ? AND entity_id = ?", entityType, entityID)
+
+	err := db.Count(&total).Error
+	if err != nil {
+		return nil, 0, err
+	}
+
+	err = db.Order("created_at DESC").Order("id DESC").Offset(offset).Limit(limit).Find(&entries).Error
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return entries, total, nil
+}
@@ -0,0 +1,60 @@
+package repository
+
+import (
+	"context"
+
+	"gorm.io/gorm"
+)
+
+// Base 通用的增删改查实现，T 为模型类型，按主键 id 操作
+// 具体仓库嵌入 Base 后只需实现特有的查询，需要不同行为时（如预加载关联、版本检查）定义同名方法覆盖即可
+type Base[T any] struct {
+	db *gorm.DB
+}
+
+// NewBase 创建通用仓库
+func NewBase[T any](db *gorm.DB) Base[T] {
+	return Base[T]{db: db}
+}
+
+// Create 创建记录
+func (r Base[T]) Create(ctx context.Context, model *T) error {
+	return r.db.WithContext(ctx).Create(model).Error
+}
+
+// GetByID 根据ID获取记录，不存在时返回 gorm.ErrRecordNotFound
+func (r Base[T]) GetByID(ctx context.Context, id uint) (*T, error) {
+	var model T
+	err := r.db.WithContext(ctx).First(&model, id).Error
+	if err != nil {
+		return nil, err
+	}
+	return &model, nil
+}
+
+// Exists 是否存在满足条件的记录，只查询一行且不读取字段
+func (r Base[T]) Exists(ctx context.Context, query interface{}, args ...interface{}) (bool, error) {
+	var found []int
+	err := r.db.WithContext(ctx).Model(new(T)).Select("1").Where(query, args...).Limit(1).Find(&found).Error
+	if err != nil {
+		return false, err
+	}
+	return len(found) > 0, nil
+}
+
+// Update 按ID更新指定字段
+func (r Base[T]) Update(ctx context.Context, id uint, updates map[string]interface{}) error {
+	return r.db.WithContext(ctx).Model(new(T)).Where("id = ?", id).Updates(updates).Error
+}
+
+// Delete 删除记录，记录不存在时返回 gorm.ErrRecordNotFound
+func (r Base[T]) Delete(ctx context.Context, id uint) error {
+	result := r.db.WithContext(ctx).Delete(new(T), id)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
@@ -0,0 +1,75 @@
+package repository
+
+import (
+	"context"
+	"errors"
+
+	"github.com/binary-1024/go-build-test/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// ErrCategoryInUse 分类下仍有产品
+var ErrCategoryInUse = errors.New("分类下仍有产品")
+
+// CategoryRepository 分类仓库接口
+type CategoryRepository interface {
+	Create(ctx context.Context, category *models.Category) error
+	GetByID(ctx context.Context, id uint) (*models.Category, error)
+	GetByName(ctx context.Context, name string) (*models.Category, error)
+	Update(ctx context.Context, id uint, updates map[string]interface{}) error
+	Delete(ctx context.Context, id uint) error
+	List(ctx context.Context) ([]*models.Category, error)
+}
+
+// categoryRepository 分类仓库实现
+type categoryRepository struct {
+	Base[models.Category]
+}
+
+// NewCategoryRepository 创建分类仓库
+func NewCategoryRepository(db *gorm.DB) CategoryRepository {
+	return &categoryRepository{Base: NewBase[models.Category](db)}
+}
+
+// GetByName 根据名称获取分类
+func (r *categoryRepository) GetByName(ctx context.Context, name string) (*models.Category, error) {
+	var category models.Category
+	err := r.db.WithContext(ctx).Where("name = ?", name).First(&category).Error
+	if err != nil {
+		return nil, err
+	}
+	return &category, nil
+}
+
+// Delete 删除分类，仍有产品引用时返回 ErrCategoryInUse，不存在时返回 gorm.ErrRecordNotFound
+func (r *categoryRepository) Delete(ctx context.Context, id uint) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var count int64
+		if err := tx.Model(&models.Product{}).Where("category_id = ?", id).Count(&count).Error; err != nil {
+			return err
+		}
+		if count > 0 {
+			return ErrCategoryInUse
+		}
+
+		result := tx.Delete(&models.Category{}, id)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		return nil
+	})
+}
+
+// List 获取全部分类，按名称排序
+func (r *categoryRepository) List(ctx context.Context) ([]*models.Category, error) {
+	var categories []*models.Category
+	err := r.db.WithContext(ctx).Order("name ASC").Find(&categories).Error
+	if err != nil {
+		return nil, err
+	}
+	return categories, nil
+}
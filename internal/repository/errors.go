@@ -0,0 +1,45 @@
+package repository
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// uniqueViolationPatterns 各数据库唯一约束冲突的错误信息，捕获冲突的列或索引名
+var uniqueViolationPatterns = []*regexp.Regexp{
+	// sqlite: UNIQUE constraint failed: users.username
+	regexp.MustCompile(`UNIQUE constraint failed: ([\w.]+)`),
+	// postgres: duplicate key value violates unique constraint "idx_users_username"
+	regexp.MustCompile(`duplicate key value violates unique constraint "([^"]+)"`),
+	// mysql: Duplicate entry 'bob' for key 'users.idx_users_username'
+	regexp.MustCompile(`Duplicate entry '.*' for key '([^']+)'`),
+}
+
+// IsUniqueViolation 判断错误是否为唯一约束冲突
+// column 非空时还要求冲突发生在该列上（按 gorm 默认的 idx_<表>_<列> 索引命名匹配）
+func IsUniqueViolation(err error, column string) bool {
+	if err == nil {
+		return false
+	}
+	if errors.Is(err, gorm.ErrDuplicatedKey) {
+		// 已被驱动转换的错误不再包含列信息
+		return column == ""
+	}
+
+	msg := err.Error()
+	for _, pattern := range uniqueViolationPatterns {
+		match := pattern.FindStringSubmatch(msg)
+		if match == nil {
+			continue
+		}
+		if column == "" {
+			return true
+		}
+		target := match[1]
+		return strings.HasSuffix(target, "."+column) || strings.HasSuffix(target, "_"+column)
+	}
+	return false
+}
@@ -1,6 +1,10 @@
 package repository
 
 import (
+	"context"
+	"errors"
+	"time"
+
 	"github.com/binary-1024/go-build-test/internal/models"
 
 	"gorm.io/gorm"
@@ -8,58 +12,328 @@ import (
 
 // ProductRepository 产品仓库接口
 type ProductRepository interface {
-	Create(product *models.Product) error
-	GetByID(id uint) (*models.Product, error)
-	Update(id uint, updates map[string]interface{}) error
-	Delete(id uint) error
-	List(query *models.ProductQuery) ([]*models.Product, int64, error)
+	Create(ctx context.Context, product *models.Product) error
+	CreateBatch(ctx context.Context, products []*models.Product) error
+	GetByID(ctx context.Context, id uint) (*models.Product, error)
+	GetByIDs(ctx context.Context, ids []uint) ([]*models.Product, error)
+	Update(ctx context.Context, id uint, version int, updates map[string]interface{}) error
+	Delete(ctx context.Context, id uint) error
+	List(ctx context.Context, query *models.ProductQuery) ([]*models.Product, int64, error)
+	ListByCursor(ctx context.Context, query *models.ProductQuery, limit int) ([]*models.Product, error)
+	ListRecentlyUpdated(ctx context.Context, limit int) ([]*models.Product, error)
+	DecrementStock(ctx context.Context, id uint, qty int) error
+	IncrementStock(ctx context.Context, id uint, qty int) error
+	Stream(ctx context.Context, query *models.ProductQuery, fn func(*models.Product) error) error
+	ForEach(ctx context.Context, query *models.ProductQuery, batchSize int, fn func(*models.Product) error) error
+	AddImage(ctx context.Context, image *models.ProductImage) error
+	RemoveImage(ctx context.Context, productID, imageID uint) error
+	ListImages(ctx context.Context, productID uint) ([]models.ProductImage, error)
+	PurgeDeletedBefore(ctx context.Context, before time.Time, batchSize int) (int64, error)
 }
 
+// ErrInsufficientStock 库存不足
+var ErrInsufficientStock = errors.New("库存不足")
+
+// ErrConflict 产品已被其他请求修改，版本号不一致
+var ErrConflict = errors.New("产品已被修改，请获取最新版本后重试")
+
+// DefaultBatchSize ForEach 未指定批大小时的默认值
+const DefaultBatchSize = 500
+
+// maxIDsPerQuery 单条 IN 查询最多包含的ID数，低于SQLite旧版本默认的999个绑定变量上限
+const maxIDsPerQuery = 500
+
 // productRepository 产品仓库实现
 type productRepository struct {
-	db *gorm.DB
+	Base[models.Product]
 }
 
 // NewProductRepository 创建产品仓库
 func NewProductRepository(db *gorm.DB) ProductRepository {
-	return &productRepository{db: db}
+	return &productRepository{Base: NewBase[models.Product](db)}
 }
 
-// Create 创建产品
-func (r *productRepository) Create(product *models.Product) error {
-	return r.db.Create(product).Error
+// CreateBatch 在同一事务中批量创建产品，任意一条失败则全部回滚
+func (r *productRepository) CreateBatch(ctx context.Context, products []*models.Product) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		return tx.CreateInBatches(products, DefaultBatchSize).Error
+	})
 }
 
-// GetByID 根据ID获取产品
-func (r *productRepository) GetByID(id uint) (*models.Product, error) {
+// GetByID 根据ID获取产品，同时加载产品图片
+func (r *productRepository) GetByID(ctx context.Context, id uint) (*models.Product, error) {
 	var product models.Product
-	err := r.db.First(&product, id).Error
+	err := r.db.WithContext(ctx).Preload("Category").Preload("Images", func(db *gorm.DB) *gorm.DB {
+		return db.Order("sort_order ASC").Order("id ASC")
+	}).First(&product, id).Error
 	if err != nil {
 		return nil, err
 	}
 	return &product, nil
 }
 
-// Update 更新产品
-func (r *productRepository) Update(id uint, updates map[string]interface{}) error {
-	return r.db.Model(&models.Product{}).Where("id = ?", id).Updates(updates).Error
+// GetByIDs 根据ID批量获取产品，与 GetByID 一样加载分类和图片，不存在的ID被忽略
+// ID较多时分批查询，避免超出数据库单条语句的绑定变量上限
+func (r *productRepository) GetByIDs(ctx context.Context, ids []uint) ([]*models.Product, error) {
+	products := make([]*models.Product, 0, len(ids))
+	for start := 0; start < len(ids); start += maxIDsPerQuery {
+		end := start + maxIDsPerQuery
+		if end > len(ids) {
+			end = len(ids)
+		}
+
+		var batch []*models.Product
+		err := r.db.WithContext(ctx).Preload("Category").Preload("Images", func(db *gorm.DB) *gorm.DB {
+			return db.Order("sort_order ASC").Order("id ASC")
+		}).Where("id IN ?", ids[start:end]).Find(&batch).Error
+		if err != nil {
+			return nil, err
+		}
+		products = append(products, batch...)
+	}
+	return products, nil
+}
+
+// Update 更新产品并递增版本号
+// version 大于0时只有当前版本一致才会更新，否则返回 ErrConflict；为0时不检查版本
+func (r *productRepository) Update(ctx context.Context, id uint, version int, updates map[string]interface{}) error {
+	db := r.db.WithContext(ctx).Model(&models.Product{}).Where("id = ?", id)
+	if version > 0 {
+		db = db.Where("version = ?", version)
+	}
+
+	updates["version"] = gorm.Expr("version + 1")
+	result := db.Updates(updates)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		if version > 0 {
+			return ErrConflict
+		}
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// DecrementStock 原子扣减库存，库存不足时返回 ErrInsufficientStock
+func (r *productRepository) DecrementStock(ctx context.Context, id uint, qty int) error {
+	// 单条UPDATE语句完成检查和扣减，避免并发下的超卖
+	result := r.db.WithContext(ctx).Model(&models.Product{}).
+		Where("id = ? AND stock >= ?", id, qty).
+		UpdateColumns(map[string]interface{}{
+			"stock":   gorm.Expr("stock - ?", qty),
+			"version": gorm.Expr("version + 1"),
+		})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrInsufficientStock
+	}
+	return nil
 }
 
-// Delete 删除产品
-func (r *productRepository) Delete(id uint) error {
-	return r.db.Delete(&models.Product{}, id).Error
+// IncrementStock 原子增加库存（如归还预留），产品不存在时返回 gorm.ErrRecordNotFound
+func (r *productRepository) IncrementStock(ctx context.Context, id uint, qty int) error {
+	result := r.db.WithContext(ctx).Model(&models.Product{}).
+		Where("id = ?", id).
+		UpdateColumns(map[string]interface{}{
+			"stock":   gorm.Expr("stock + ?", qty),
+			"version": gorm.Expr("version + 1"),
+		})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
 }
 
 // List 获取产品列表
-func (r *productRepository) List(query *models.ProductQuery) ([]*models.Product, int64, error) {
+func (r *productRepository) List(ctx context.Context, query *models.ProductQuery) ([]*models.Product, int64, error) {
 	var products []*models.Product
 	var total int64
 
-	db := r.db.Model(&models.Product{})
+	db := r.applyFilters(r.db.WithContext(ctx).Model(&models.Product{}), query)
+
+	// 获取总数
+	err := db.Count(&total).Error
+	if err != nil {
+		return nil, 0, err
+	}
+
+	// 分页查询，追加 id 作为稳定排序的兜底，避免翻页时出现重复或遗漏
+	offset := (query.Page - 1) * query.Limit
+	err = db.Preload("Category").Offset(offset).Limit(query.Limit).Order(query.OrderClause()).Order("id ASC").Find(&products).Error
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return products, total, nil
+}
+
+// ListByCursor 游标分页获取产品，按创建时间倒序，返回 query.After 之后的最多 limit 条
+func (r *productRepository) ListByCursor(ctx context.Context, query *models.ProductQuery, limit int) ([]*models.Product, error) {
+	db := r.applyFilters(r.db.WithContext(ctx).Model(&models.Product{}), query)
+	if query.After != nil {
+		db = db.Where("(created_at, id) < (?, ?)", query.After.CreatedAt, query.After.ID)
+	}
+
+	var products []*models.Product
+	err := db.Preload("Category").Order("created_at DESC").Order("id DESC").Limit(limit).Find(&products).Error
+	if err != nil {
+		return nil, err
+	}
+	return products, nil
+}
+
+// ListRecentlyUpdated 获取最近更新的 limit 个产品，与 GetByID 一样加载分类和图片
+func (r *productRepository) ListRecentlyUpdated(ctx context.Context, limit int) ([]*models.Product, error) {
+	var products []*models.Product
+	err := r.db.WithContext(ctx).Preload("Category").Preload("Images", func(db *gorm.DB) *gorm.DB {
+		return db.Order("sort_order ASC").Order("id ASC")
+	}).Order("updated_at DESC").Order("id DESC").Limit(limit).Find(&products).Error
+	if err != nil {
+		return nil, err
+	}
+	return products, nil
+}
 
-	// 添加搜索条件
+// Stream 逐行遍历符合条件的产品，不做分页，内存占用与表大小无关
+// 逐行扫描无法 Preload，分类在遍历前一次查出后按 category_id 填充
+func (r *productRepository) Stream(ctx context.Context, query *models.ProductQuery, fn func(*models.Product) error) error {
+	var categoryList []*models.Category
+	if err := r.db.WithContext(ctx).Find(&categoryList).Error; err != nil {
+		return err
+	}
+	categories := make(map[uint]*models.Category, len(categoryList))
+	for _, category := range categoryList {
+		categories[category.ID] = category
+	}
+
+	db := r.applyFilters(r.db.WithContext(ctx).Model(&models.Product{}), query)
+
+	rows, err := db.Order(query.OrderClause()).Order("id ASC").Rows()
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var product models.Product
+		if err := r.db.ScanRows(rows, &product); err != nil {
+			return err
+		}
+		if product.CategoryID != nil {
+			product.Category = categories[*product.CategoryID]
+		}
+		if err := fn(&product); err != nil {
+			return err
+		}
+	}
+
+	return rows.Err()
+}
+
+// ForEach 按主键分批遍历符合条件的产品，fn 返回错误时立即停止并返回该错误
+// 每批只在内存中保留 batchSize 条记录，适用于缓存预热、导出等批处理任务
+func (r *productRepository) ForEach(ctx context.Context, query *models.ProductQuery, batchSize int, fn func(*models.Product) error) error {
+	if batchSize <= 0 {
+		batchSize = DefaultBatchSize
+	}
+
+	var batch []*models.Product
+	db := r.applyFilters(r.db.WithContext(ctx).Model(&models.Product{}), query)
+	result := db.FindInBatches(&batch, batchSize, func(tx *gorm.DB, _ int) error {
+		for _, product := range batch {
+			if err := fn(product); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	return result.Error
+}
+
+// AddImage 添加产品图片
+func (r *productRepository) AddImage(ctx context.Context, image *models.ProductImage) error {
+	return r.db.WithContext(ctx).Create(image).Error
+}
+
+// RemoveImage 删除产品图片，图片不存在或不属于该产品时返回 gorm.ErrRecordNotFound
+func (r *productRepository) RemoveImage(ctx context.Context, productID, imageID uint) error {
+	result := r.db.WithContext(ctx).Where("product_id = ?", productID).Delete(&models.ProductImage{}, imageID)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// ListImages 获取产品图片，按 sort_order 排序
+func (r *productRepository) ListImages(ctx context.Context, productID uint) ([]models.ProductImage, error) {
+	var images []models.ProductImage
+	err := r.db.WithContext(ctx).Where("product_id = ?", productID).Order("sort_order ASC").Order("id ASC").Find(&images).Error
+	if err != nil {
+		return nil, err
+	}
+	return images, nil
+}
+
+// PurgeDeletedBefore 分批彻底删除 before 之前软删除的产品及其图片，返回删除的产品数
+// 每批一个短事务，避免长时间锁表；ctx 取消时在当前批次完成后停止
+func (r *productRepository) PurgeDeletedBefore(ctx context.Context, before time.Time, batchSize int) (int64, error) {
+	if batchSize <= 0 {
+		batchSize = DefaultBatchSize
+	}
+
+	var total int64
+	for {
+		if err := ctx.Err(); err != nil {
+			return total, err
+		}
+
+		var ids []uint
+		err := r.db.WithContext(ctx).Unscoped().Model(&models.Product{}).
+			Where("deleted_at IS NOT NULL AND deleted_at < ?", before).
+			Limit(batchSize).Pluck("id", &ids).Error
+		if err != nil {
+			return total, err
+		}
+		if len(ids) == 0 {
+			return total, nil
+		}
+
+		err = r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+			if err := tx.Where("product_id IN ?", ids).Delete(&models.ProductImage{}).Error; err != nil {
+				return err
+			}
+			return tx.Unscoped().Delete(&models.Product{}, ids).Error
+		})
+		if err != nil {
+			return total, err
+		}
+		total += int64(len(ids))
+	}
+}
+
+// applyFilters 添加产品查询的过滤条件
+func (r *productRepository) applyFilters(db *gorm.DB, query *models.ProductQuery) *gorm.DB {
+	// 分类参数按名称过滤，通过子查询转换为分类ID
 	if query.Category != "" {
-		db = db.Where("category = ?", query.Category)
+		db = db.Where("category_id IN (?)", r.db.Model(&models.Category{}).Select("id").Where("name = ?", query.Category))
+	}
+
+	if categories := query.CategoryList(); len(categories) > 0 {
+		db = db.Where("category_id IN (?)", r.db.Model(&models.Category{}).Select("id").Where("name IN ?", categories))
+	}
+
+	if query.CategoryID > 0 {
+		db = db.Where("category_id = ?", query.CategoryID)
 	}
 
 	if query.MinPrice > 0 {
@@ -74,18 +348,17 @@ func (r *productRepository) List(query *models.ProductQuery) ([]*models.Product,
 		db = db.Where("name LIKE ? OR description LIKE ?", "%"+query.Search+"%", "%"+query.Search+"%")
 	}
 
-	// 获取总数
-	err := db.Count(&total).Error
-	if err != nil {
-		return nil, 0, err
+	if query.IsActive != nil {
+		db = db.Where("is_active = ?", *query.IsActive)
 	}
 
-	// 分页查询
-	offset := (query.Page - 1) * query.Limit
-	err = db.Offset(offset).Limit(query.Limit).Order("created_at DESC").Find(&products).Error
-	if err != nil {
-		return nil, 0, err
+	if query.InStock != nil {
+		if *query.InStock {
+			db = db.Where("stock > 0")
+		} else {
+			db = db.Where("stock <= 0")
+		}
 	}
 
-	return products, total, nil
+	return db
 }
@@ -0,0 +1,80 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"github.com/binary-1024/go-build-test/internal/database"
+	"github.com/binary-1024/go-build-test/internal/logger"
+	"github.com/binary-1024/go-build-test/internal/models"
+)
+
+func newTestProductRepository(t *testing.T, count int) ProductRepository {
+	t.Helper()
+	log := logger.NewLogger("error", "json", logger.FileOutput{})
+	db, err := database.NewConnection(database.DriverSQLite, filepath.Join(t.TempDir(), "test.db"), database.PoolConfig{}, log)
+	if err != nil {
+		t.Fatalf("open database: %v", err)
+	}
+
+	repo := NewProductRepository(db)
+	products := make([]*models.Product, count)
+	for i := range products {
+		products[i] = &models.Product{Name: fmt.Sprintf("item-%02d", i), Price: float64(i + 1), Stock: i % 2, IsActive: true, Version: 1}
+	}
+	if err := repo.CreateBatch(context.Background(), products); err != nil {
+		t.Fatalf("CreateBatch: %v", err)
+	}
+	return repo
+}
+
+func TestForEach(t *testing.T) {
+	errStop := errors.New("stop")
+
+	tests := []struct {
+		name      string
+		query     models.ProductQuery
+		batchSize int
+		stopAt    int
+		wantCount int
+		wantErr   error
+	}{
+		{name: "分批遍历全部产品", batchSize: 5, wantCount: 23},
+		{name: "批大小大于总数", batchSize: 100, wantCount: 23},
+		{name: "未指定批大小使用默认值", wantCount: 23},
+		{name: "应用过滤条件", query: models.ProductQuery{MinPrice: 11}, batchSize: 5, wantCount: 13},
+		{name: "回调出错时停止", batchSize: 5, stopAt: 7, wantCount: 7, wantErr: errStop},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := newTestProductRepository(t, 23)
+
+			seen := make(map[uint]bool)
+			var lastID uint
+			err := repo.ForEach(context.Background(), &tt.query, tt.batchSize, func(p *models.Product) error {
+				if seen[p.ID] {
+					t.Errorf("product %d visited twice", p.ID)
+				}
+				if p.ID <= lastID {
+					t.Errorf("product %d visited after %d", p.ID, lastID)
+				}
+				seen[p.ID], lastID = true, p.ID
+				if len(seen) == tt.stopAt {
+					return errStop
+				}
+				return nil
+			})
+
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("ForEach err = %v, want %v", err, tt.wantErr)
+			}
+			if len(seen) != tt.wantCount {
+				t.Errorf("visited %d products, want %d", len(seen), tt.wantCount)
+			}
+		})
+	}
+}
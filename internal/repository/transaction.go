@@ -0,0 +1,27 @@
+package repository
+
+import (
+	"context"
+
+	"gorm.io/gorm"
+)
+
+// Transactioner 事务执行器，fn 返回错误时回滚，否则提交
+type Transactioner interface {
+	Transaction(ctx context.Context, fn func(tx *gorm.DB) error) error
+}
+
+// transactioner 基于 gorm 的事务执行器
+type transactioner struct {
+	db *gorm.DB
+}
+
+// NewTransactioner 创建事务执行器
+func NewTransactioner(db *gorm.DB) Transactioner {
+	return &transactioner{db: db}
+}
+
+// Transaction 在事务中执行 fn，事务内的查询使用 ctx，取消时回滚
+func (t *transactioner) Transaction(ctx context.Context, fn func(tx *gorm.DB) error) error {
+	return t.db.WithContext(ctx).Transaction(fn)
+}
@@ -1,6 +1,10 @@
 package repository
 
 import (
+	"context"
+	"strings"
+	"time"
+
 	"github.com/binary-1024/go-build-test/internal/models"
 
 	"gorm.io/gorm"
@@ -8,84 +12,258 @@ import (
 
 // UserRepository 用户仓库接口
 type UserRepository interface {
-	Create(user *models.User) error
-	GetByID(id uint) (*models.User, error)
-	GetByUsername(username string) (*models.User, error)
-	GetByEmail(email string) (*models.User, error)
-	Update(id uint, updates map[string]interface{}) error
-	Delete(id uint) error
-	List(offset, limit int) ([]*models.User, int64, error)
+	Create(ctx context.Context, user *models.User) error
+	CreateBatch(ctx context.Context, users []*models.User) error
+	GetByID(ctx context.Context, id uint) (*models.User, error)
+	GetByUsername(ctx context.Context, username string) (*models.User, error)
+	GetByEmail(ctx context.Context, email string) (*models.User, error)
+	GetByNormalizedEmail(ctx context.Context, email string) (*models.User, error)
+	ExistsByUsername(ctx context.Context, username string) (bool, error)
+	ExistsByEmail(ctx context.Context, email string) (bool, error)
+	ExistsByNormalizedEmail(ctx context.Context, email string) (bool, error)
+	Update(ctx context.Context, id uint, updates map[string]interface{}) error
+	Delete(ctx context.Context, id uint) error
+	Restore(ctx context.Context, id uint) error
+	Purge(ctx context.Context, id uint) (*models.User, error)
+	PurgeDeletedBefore(ctx context.Context, before time.Time, batchSize int, purged func([]*models.User)) (int64, error)
+	List(ctx context.Context, offset, limit int) ([]*models.User, int64, error)
+	Search(ctx context.Context, keyword string, offset, limit int) ([]*models.User, int64, error)
+	Stream(ctx context.Context, keyword string, fn func(*models.User) error) error
+	WithTx(tx *gorm.DB) UserRepository
 }
 
 // userRepository 用户仓库实现
 type userRepository struct {
-	db *gorm.DB
+	Base[models.User]
 }
 
 // NewUserRepository 创建用户仓库
 func NewUserRepository(db *gorm.DB) UserRepository {
-	return &userRepository{db: db}
+	return &userRepository{Base: NewBase[models.User](db)}
+}
+
+// WithTx 返回绑定到指定事务的仓库
+func (r *userRepository) WithTx(tx *gorm.DB) UserRepository {
+	return &userRepository{Base: NewBase[models.User](tx)}
 }
 
-// Create 创建用户
-func (r *userRepository) Create(user *models.User) error {
-	return r.db.Create(user).Error
+// CreateBatch 在同一事务中批量创建用户，任意一条失败则全部回滚
+func (r *userRepository) CreateBatch(ctx context.Context, users []*models.User) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		for _, user := range users {
+			if err := tx.Create(user).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
 }
 
-// GetByID 根据ID获取用户
-func (r *userRepository) GetByID(id uint) (*models.User, error) {
+// GetByUsername 根据用户名获取用户，不包含已删除的用户
+func (r *userRepository) GetByUsername(ctx context.Context, username string) (*models.User, error) {
 	var user models.User
-	err := r.db.First(&user, id).Error
+	err := r.db.WithContext(ctx).Where("username = ?", username).First(&user).Error
 	if err != nil {
 		return nil, err
 	}
 	return &user, nil
 }
 
-// GetByUsername 根据用户名获取用户
-func (r *userRepository) GetByUsername(username string) (*models.User, error) {
+// GetByEmail 根据邮箱获取用户，不包含已删除的用户
+func (r *userRepository) GetByEmail(ctx context.Context, email string) (*models.User, error) {
 	var user models.User
-	err := r.db.Where("username = ?", username).First(&user).Error
+	err := r.db.WithContext(ctx).Where("email = ?", email).First(&user).Error
 	if err != nil {
 		return nil, err
 	}
 	return &user, nil
 }
 
-// GetByEmail 根据邮箱获取用户
-func (r *userRepository) GetByEmail(email string) (*models.User, error) {
+// GetByNormalizedEmail 根据归一化后的邮箱获取用户，不包含已删除的用户
+func (r *userRepository) GetByNormalizedEmail(ctx context.Context, email string) (*models.User, error) {
 	var user models.User
-	err := r.db.Where("email = ?", email).First(&user).Error
+	err := r.db.WithContext(ctx).Where("normalized_email = ?", email).First(&user).Error
 	if err != nil {
 		return nil, err
 	}
 	return &user, nil
 }
 
-// Update 更新用户
-func (r *userRepository) Update(id uint, updates map[string]interface{}) error {
-	return r.db.Model(&models.User{}).Where("id = ?", id).Updates(updates).Error
+// ExistsByUsername 用户名是否已被占用，不包含已删除的用户
+func (r *userRepository) ExistsByUsername(ctx context.Context, username string) (bool, error) {
+	return r.Exists(ctx, "username = ?", username)
+}
+
+// ExistsByEmail 邮箱是否已被占用，不包含已删除的用户
+func (r *userRepository) ExistsByEmail(ctx context.Context, email string) (bool, error) {
+	return r.Exists(ctx, "email = ?", email)
+}
+
+// ExistsByNormalizedEmail 归一化后的邮箱是否已被占用，不包含已删除的用户
+func (r *userRepository) ExistsByNormalizedEmail(ctx context.Context, email string) (bool, error) {
+	return r.Exists(ctx, "normalized_email = ?", email)
+}
+
+// Restore 恢复软删除的用户，用户不存在或未被删除时返回 gorm.ErrRecordNotFound
+func (r *userRepository) Restore(ctx context.Context, id uint) error {
+	result := r.db.WithContext(ctx).Unscoped().Model(&models.User{}).
+		Where("id = ? AND deleted_at IS NOT NULL", id).
+		Update("deleted_at", nil)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// Purge 彻底删除用户（包括已软删除的用户）及其审计记录和API Key，返回删除前的用户，用户不存在时返回 gorm.ErrRecordNotFound
+// 审计记录中可能包含个人信息，一并删除；头像等外部文件由调用方清理
+func (r *userRepository) Purge(ctx context.Context, id uint) (*models.User, error) {
+	var user models.User
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Unscoped().First(&user, id).Error; err != nil {
+			return err
+		}
+		if err := tx.Unscoped().Delete(&models.User{}, id).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("user_id = ?", id).Delete(&models.APIKey{}).Error; err != nil {
+			return err
+		}
+		return tx.Where("entity_type = ? AND entity_id = ?", models.AuditEntityUser, id).Delete(&models.AuditLog{}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
 }
 
-// Delete 删除用户
-func (r *userRepository) Delete(id uint) error {
-	return r.db.Delete(&models.User{}, id).Error
+// PurgeDeletedBefore 分批彻底删除 before 之前软删除的用户及其审计记录和API Key，返回删除的用户数
+// 每批一个短事务，避免长时间锁表；ctx 取消时在当前批次完成后停止。
+// purged 不为 nil 时在每批提交后以该批用户（只包含 id 和 avatar_url）调用，用于清理头像等外部文件
+func (r *userRepository) PurgeDeletedBefore(ctx context.Context, before time.Time, batchSize int, purged func([]*models.User)) (int64, error) {
+	if batchSize <= 0 {
+		batchSize = DefaultBatchSize
+	}
+
+	var total int64
+	for {
+		if err := ctx.Err(); err != nil {
+			return total, err
+		}
+
+		var users []*models.User
+		err := r.db.WithContext(ctx).Unscoped().Select("id", "avatar_url").
+			Where("deleted_at IS NOT NULL AND deleted_at < ?", before).
+			Limit(batchSize).Find(&users).Error
+		if err != nil {
+			return total, err
+		}
+		if len(users) == 0 {
+			return total, nil
+		}
+		ids := make([]uint, len(users))
+		for i, user := range users {
+			ids[i] = user.ID
+		}
+
+		err = r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+			if err := tx.Where("entity_type = ? AND entity_id IN ?", models.AuditEntityUser, ids).Delete(&models.AuditLog{}).Error; err != nil {
+				return err
+			}
+			if err := tx.Where("user_id IN ?", ids).Delete(&models.APIKey{}).Error; err != nil {
+				return err
+			}
+			return tx.Unscoped().Delete(&models.User{}, ids).Error
+		})
+		if err != nil {
+			return total, err
+		}
+		total += int64(len(ids))
+		if purged != nil {
+			purged(users)
+		}
+	}
 }
 
 // List 获取用户列表
-func (r *userRepository) List(offset, limit int) ([]*models.User, int64, error) {
+func (r *userRepository) List(ctx context.Context, offset, limit int) ([]*models.User, int64, error) {
 	var users []*models.User
 	var total int64
 
-	err := r.db.Model(&models.User{}).Count(&total).Error
+	err := r.db.WithContext(ctx).Model(&models.User{}).Count(&total).Error
 	if err != nil {
 		return nil, 0, err
 	}
 
-	err = r.db.Offset(offset).Limit(limit).Find(&users).Error
+	err = r.db.WithContext(ctx).Offset(offset).Limit(limit).Order("id ASC").Find(&users).Error
 	if err != nil {
 		return nil, 0, err
 	}
 
 	return users, total, nil
 }
+
+// Search 按用户名、邮箱、姓名模糊搜索用户
+func (r *userRepository) Search(ctx context.Context, keyword string, offset, limit int) ([]*models.User, int64, error) {
+	var users []*models.User
+	var total int64
+
+	pattern := "%" + escapeLike(keyword) + "%"
+	db := r.db.WithContext(ctx).Model(&models.User{}).Where(
+		`username LIKE ? ESCAPE '!' OR email LIKE ? ESCAPE '!' OR full_name LIKE ? ESCAPE '!'`,
+		pattern, pattern, pattern,
+	)
+
+	err := db.Count(&total).Error
+	if err != nil {
+		return nil, 0, err
+	}
+
+	err = db.Offset(offset).Limit(limit).Order("id ASC").Find(&users).Error
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return users, total, nil
+}
+
+// Stream 逐行遍历用户，keyword 非空时按 Search 的规则过滤，内存占用与表大小无关
+func (r *userRepository) Stream(ctx context.Context, keyword string, fn func(*models.User) error) error {
+	db := r.db.WithContext(ctx).Model(&models.User{})
+	if keyword != "" {
+		pattern := "%" + escapeLike(keyword) + "%"
+		db = db.Where(
+			`username LIKE ? ESCAPE '!' OR email LIKE ? ESCAPE '!' OR full_name LIKE ? ESCAPE '!'`,
+			pattern, pattern, pattern,
+		)
+	}
+
+	rows, err := db.Order("id ASC").Rows()
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var user models.User
+		if err := r.db.ScanRows(rows, &user); err != nil {
+			return err
+		}
+		if err := fn(&user); err != nil {
+			return err
+		}
+	}
+
+	return rows.Err()
+}
+
+// escapeLike 转义LIKE中的通配符，使关键字按字面匹配
+// 使用 ! 作为转义符，避免反斜杠在MySQL字符串中的特殊含义
+func escapeLike(s string) string {
+	return likeEscaper.Replace(s)
+}
+
+var likeEscaper = strings.NewReplacer("!", "!!", "%", "!%", "_", "!_")
@@ -0,0 +1,23 @@
+package server
+
+import "sync/atomic"
+
+// Readiness 服务就绪状态，关闭前先标记为排空，让负载均衡摘除实例
+type Readiness struct {
+	draining atomic.Bool
+}
+
+// NewReadiness 创建就绪状态
+func NewReadiness() *Readiness {
+	return &Readiness{}
+}
+
+// StartDraining 标记开始排空，此后就绪检查返回未就绪
+func (r *Readiness) StartDraining() {
+	r.draining.Store(true)
+}
+
+// Ready 是否就绪
+func (r *Readiness) Ready() bool {
+	return !r.draining.Load()
+}
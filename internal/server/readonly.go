@@ -0,0 +1,26 @@
+package server
+
+import "sync/atomic"
+
+// ReadOnlyMode 只读模式开关，开启时服务正常提供读接口但拒绝写操作
+// 与维护模式不同，只读模式下服务仍然就绪，适用于数据迁移期间
+type ReadOnlyMode struct {
+	enabled atomic.Bool
+}
+
+// NewReadOnlyMode 创建只读模式开关
+func NewReadOnlyMode(enabled bool) *ReadOnlyMode {
+	m := &ReadOnlyMode{}
+	m.enabled.Store(enabled)
+	return m
+}
+
+// Set 开启或关闭只读模式
+func (m *ReadOnlyMode) Set(enabled bool) {
+	m.enabled.Store(enabled)
+}
+
+// Enabled 是否处于只读模式
+func (m *ReadOnlyMode) Enabled() bool {
+	return m.enabled.Load()
+}
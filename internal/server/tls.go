@@ -0,0 +1,73 @@
+package server
+
+import (
+	"crypto/tls"
+	"fmt"
+	"strings"
+)
+
+// DefaultCipherSuites 默认的TLS 1.2加密套件（仅保留支持前向保密的AEAD套件）
+// TLS 1.3的套件由Go标准库固定管理，无需配置
+var DefaultCipherSuites = []uint16{
+	tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384,
+	tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384,
+	tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256,
+	tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,
+	tls.TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305_SHA256,
+	tls.TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305_SHA256,
+}
+
+// NewTLSConfig 根据最低版本和加密套件名称创建TLS配置
+// minVersion 支持 "1.2"、"1.3"，为空时默认TLS 1.2；cipherSuites 为空时使用 DefaultCipherSuites
+func NewTLSConfig(minVersion string, cipherSuites []string) (*tls.Config, error) {
+	version, err := parseTLSVersion(minVersion)
+	if err != nil {
+		return nil, err
+	}
+
+	suites := DefaultCipherSuites
+	if len(cipherSuites) > 0 {
+		suites, err = parseCipherSuites(cipherSuites)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return &tls.Config{
+		MinVersion:   version,
+		CipherSuites: suites,
+	}, nil
+}
+
+func parseTLSVersion(version string) (uint16, error) {
+	switch strings.TrimSpace(version) {
+	case "", "1.2":
+		return tls.VersionTLS12, nil
+	case "1.3":
+		return tls.VersionTLS13, nil
+	default:
+		return 0, fmt.Errorf("不支持的TLS最低版本: %s", version)
+	}
+}
+
+func parseCipherSuites(names []string) ([]uint16, error) {
+	// 只允许选择标准库认为安全的套件
+	available := make(map[string]uint16)
+	for _, suite := range tls.CipherSuites() {
+		available[suite.Name] = suite.ID
+	}
+
+	suites := make([]uint16, 0, len(names))
+	for _, name := range names {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		id, ok := available[name]
+		if !ok {
+			return nil, fmt.Errorf("不支持的加密套件: %s", name)
+		}
+		suites = append(suites, id)
+	}
+	return suites, nil
+}
@@ -0,0 +1,90 @@
+package server
+
+import (
+	"crypto/tls"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestNewTLSConfig(t *testing.T) {
+	tests := []struct {
+		name         string
+		minVersion   string
+		cipherSuites []string
+		wantVersion  uint16
+		wantSuites   []uint16
+		wantErr      bool
+	}{
+		{name: "默认TLS 1.2", wantVersion: tls.VersionTLS12, wantSuites: DefaultCipherSuites},
+		{name: "TLS 1.3", minVersion: "1.3", wantVersion: tls.VersionTLS13, wantSuites: DefaultCipherSuites},
+		{
+			name:         "自定义加密套件",
+			minVersion:   "1.2",
+			cipherSuites: []string{"TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256", " "},
+			wantVersion:  tls.VersionTLS12,
+			wantSuites:   []uint16{tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256},
+		},
+		{name: "不支持的版本", minVersion: "1.0", wantErr: true},
+		{name: "不安全的加密套件", cipherSuites: []string{"TLS_RSA_WITH_RC4_128_SHA"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg, err := NewTLSConfig(tt.minVersion, tt.cipherSuites)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("NewTLSConfig err = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if cfg.MinVersion != tt.wantVersion {
+				t.Errorf("MinVersion = %x, want %x", cfg.MinVersion, tt.wantVersion)
+			}
+			if !reflect.DeepEqual(cfg.CipherSuites, tt.wantSuites) {
+				t.Errorf("CipherSuites = %v, want %v", cfg.CipherSuites, tt.wantSuites)
+			}
+		})
+	}
+}
+
+func TestTLSConfigRejectsOldClients(t *testing.T) {
+	cfg, err := NewTLSConfig("", nil)
+	if err != nil {
+		t.Fatalf("NewTLSConfig: %v", err)
+	}
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	srv.TLS = cfg
+	srv.StartTLS()
+	defer srv.Close()
+
+	tests := []struct {
+		name       string
+		maxVersion uint16
+		wantErr    bool
+	}{
+		{name: "TLS 1.0", maxVersion: tls.VersionTLS10, wantErr: true},
+		{name: "TLS 1.1", maxVersion: tls.VersionTLS11, wantErr: true},
+		{name: "TLS 1.2", maxVersion: tls.VersionTLS12},
+		{name: "TLS 1.3", maxVersion: tls.VersionTLS13},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := srv.Client()
+			transport := client.Transport.(*http.Transport).Clone()
+			transport.TLSClientConfig.MinVersion = tls.VersionTLS10
+			transport.TLSClientConfig.MaxVersion = tt.maxVersion
+			client.Transport = transport
+
+			resp, err := client.Get(srv.URL)
+			if err == nil {
+				resp.Body.Close()
+			}
+			if (err != nil) != tt.wantErr {
+				t.Errorf("handshake err = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,27 @@
+package service
+
+import (
+	"context"
+
+	"github.com/binary-1024/go-build-test/internal/auth"
+)
+
+// actorID 执行当前操作的登录用户ID，匿名请求（如公开注册）返回nil
+func actorID(ctx context.Context) *uint {
+	if userID, ok := auth.UserIDFromContext(ctx); ok {
+		return &userID
+	}
+	return nil
+}
+
+// withUpdatedBy 返回附带 updated_by 的更新数据副本，不修改传入的 updates（审计记录只包含业务字段）
+func withUpdatedBy(ctx context.Context, updates map[string]interface{}) map[string]interface{} {
+	result := make(map[string]interface{}, len(updates)+1)
+	for field, value := range updates {
+		result[field] = value
+	}
+	if actor := actorID(ctx); actor != nil {
+		result["updated_by"] = *actor
+	}
+	return result
+}
@@ -0,0 +1,195 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/binary-1024/go-build-test/internal/auth"
+	"github.com/binary-1024/go-build-test/internal/cache"
+	"github.com/binary-1024/go-build-test/internal/logger"
+	"github.com/binary-1024/go-build-test/internal/models"
+	"github.com/binary-1024/go-build-test/internal/repository"
+
+	"gorm.io/gorm"
+)
+
+// apiKeyCacheTTL API Key认证信息的缓存时间
+// 撤销时会主动删除缓存；所属用户被禁用时，最长在该时间后才会生效
+const apiKeyCacheTTL = 5 * time.Minute
+
+// API Key相关错误
+var (
+	// ErrInvalidAPIKey API Key不存在、已撤销、已过期或所属用户已禁用
+	ErrInvalidAPIKey = errors.New("无效的API Key")
+	// ErrInvalidAPIKeyExpiry 过期时间早于当前时间
+	ErrInvalidAPIKeyExpiry = errors.New("过期时间必须晚于当前时间")
+)
+
+// APIKeyService API Key服务接口
+type APIKeyService interface {
+	CreateAPIKey(ctx context.Context, userID uint, req *models.CreateAPIKeyRequest) (*models.CreateAPIKeyResponse, error)
+	ListAPIKeys(ctx context.Context, userID uint) ([]*models.APIKey, error)
+	RevokeAPIKey(ctx context.Context, userID, id uint) error
+	AuthenticateAPIKey(ctx context.Context, key string) (*auth.APIKeyInfo, error)
+}
+
+// apiKeyService API Key服务实现
+type apiKeyService struct {
+	repo     repository.APIKeyRepository
+	userRepo repository.UserRepository
+	cache    *cache.RedisClient
+	logger   logger.Logger
+}
+
+// NewAPIKeyService 创建API Key服务
+func NewAPIKeyService(repo repository.APIKeyRepository, userRepo repository.UserRepository, cache *cache.RedisClient, logger logger.Logger) APIKeyService {
+	return &apiKeyService{
+		repo:     repo,
+		userRepo: userRepo,
+		cache:    cache,
+		logger:   logger,
+	}
+}
+
+// APIKeyCacheKey API Key认证信息的缓存键，按密钥哈希索引
+func APIKeyCacheKey(hash string) string {
+	return fmt.Sprintf("api_key:%s", hash)
+}
+
+// CreateAPIKey 为用户创建API Key，返回的明文密钥之后无法再次获取
+func (s *apiKeyService) CreateAPIKey(ctx context.Context, userID uint, req *models.CreateAPIKeyRequest) (*models.CreateAPIKeyResponse, error) {
+	log := s.logger.With("user_id", userID)
+	log.Info("创建API Key", "name", req.Name)
+
+	if req.ExpiresAt != nil && !req.ExpiresAt.After(time.Now()) {
+		return nil, ErrInvalidAPIKeyExpiry
+	}
+
+	key, err := auth.GenerateAPIKey()
+	if err != nil {
+		log.Error("生成API Key失败", "error", err)
+		return nil, err
+	}
+
+	// API Key的权限不能超过所属用户的权限，未指定时使用用户的全部权限
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		log.Error("获取用户失败", "error", err)
+		return nil, err
+	}
+	scopes := grantedScopes(user)
+	if len(req.Scopes) > 0 {
+		if missing := auth.MissingScope(scopes, req.Scopes); missing != "" {
+			return nil, fmt.Errorf("%w：%s", ErrScopeNotAllowed, missing)
+		}
+		scopes = req.Scopes
+	}
+	apiKey := &models.APIKey{
+		UserID:    userID,
+		Name:      req.Name,
+		Prefix:    auth.APIKeyDisplayPrefix(key),
+		KeyHash:   auth.HashAPIKey(key),
+		Scopes:    scopes,
+		ExpiresAt: req.ExpiresAt,
+	}
+	if err := s.repo.Create(ctx, apiKey); err != nil {
+		log.Error("保存API Key失败", "error", err)
+		return nil, err
+	}
+
+	log.Info("API Key创建成功", "api_key_id", apiKey.ID)
+	return &models.CreateAPIKeyResponse{APIKey: apiKey.ToResponse(), Key: key}, nil
+}
+
+// ListAPIKeys 获取用户的全部API Key
+func (s *apiKeyService) ListAPIKeys(ctx context.Context, userID uint) ([]*models.APIKey, error) {
+	keys, err := s.repo.ListByUser(ctx, userID)
+	if err != nil {
+		s.logger.Error("获取API Key列表失败", "user_id", userID, "error", err)
+		return nil, err
+	}
+	return keys, nil
+}
+
+// RevokeAPIKey 撤销用户的API Key，重复撤销视为成功
+// 不属于该用户的API Key按不存在处理，返回 gorm.ErrRecordNotFound
+func (s *apiKeyService) RevokeAPIKey(ctx context.Context, userID, id uint) error {
+	log := s.logger.With("user_id", userID)
+
+	apiKey, err := s.repo.GetByID(ctx, id)
+	if err != nil {
+		return err
+	}
+	if apiKey.UserID != userID {
+		return gorm.ErrRecordNotFound
+	}
+	if apiKey.RevokedAt != nil {
+		return nil
+	}
+
+	if err := s.repo.Update(ctx, id, map[string]interface{}{"revoked_at": time.Now()}); err != nil {
+		log.Error("撤销API Key失败", "api_key_id", id, "error", err)
+		return err
+	}
+	if err := s.cache.Delete(context.WithoutCancel(ctx), APIKeyCacheKey(apiKey.KeyHash)); err != nil {
+		log.Warn("删除API Key缓存失败", "api_key_id", id, "error", err)
+	}
+
+	log.Info("API Key已撤销", "api_key_id", id)
+	return nil
+}
+
+// AuthenticateAPIKey 校验API Key并返回调用方信息
+// 只缓存有效的密钥，不存在的密钥每次都会查询数据库
+func (s *apiKeyService) AuthenticateAPIKey(ctx context.Context, key string) (*auth.APIKeyInfo, error) {
+	hash := auth.HashAPIKey(key)
+	var info auth.APIKeyInfo
+	err := getOrLoad(ctx, s.cache, s.logger, APIKeyCacheKey(hash), &info, apiKeyCacheTTL, func(ctx context.Context) (interface{}, error) {
+		return s.loadAPIKey(ctx, hash)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if info.Expired(time.Now()) {
+		return nil, ErrInvalidAPIKey
+	}
+	return &info, nil
+}
+
+// loadAPIKey 从数据库加载有效的API Key
+func (s *apiKeyService) loadAPIKey(ctx context.Context, hash string) (*auth.APIKeyInfo, error) {
+	apiKey, err := s.repo.GetByHash(ctx, hash)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrInvalidAPIKey
+		}
+		s.logger.Error("获取API Key失败", "error", err)
+		return nil, err
+	}
+	if apiKey.RevokedAt != nil {
+		return nil, ErrInvalidAPIKey
+	}
+
+	// 所属用户被删除或禁用后，其API Key随之失效
+	user, err := s.userRepo.GetByID(ctx, apiKey.UserID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrInvalidAPIKey
+		}
+		s.logger.Error("获取API Key所属用户失败", "api_key_id", apiKey.ID, "error", err)
+		return nil, err
+	}
+	if !user.IsActive {
+		return nil, ErrInvalidAPIKey
+	}
+
+	// 所属用户的权限被收回后，API Key随之失去对应的权限
+	return &auth.APIKeyInfo{
+		ID:        apiKey.ID,
+		UserID:    apiKey.UserID,
+		Scopes:    auth.IntersectScopes(apiKey.Scopes, grantedScopes(user)),
+		ExpiresAt: apiKey.ExpiresAt,
+	}, nil
+}
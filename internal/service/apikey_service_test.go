@@ -0,0 +1,51 @@
+package service
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/binary-1024/go-build-test/internal/auth"
+	"github.com/binary-1024/go-build-test/internal/models"
+	"github.com/binary-1024/go-build-test/internal/repository"
+)
+
+func TestAuthenticateAPIKeyIntersectsUserScopes(t *testing.T) {
+	tests := []struct {
+		name       string
+		keyScopes  []string
+		userScopes []string
+		want       []string
+	}{
+		{name: "用户权限未变", keyScopes: []string{auth.ScopeProductsRead, auth.ScopeProductsWrite}, userScopes: nil, want: []string{auth.ScopeProductsRead, auth.ScopeProductsWrite}},
+		{name: "用户被收回写权限", keyScopes: []string{auth.ScopeProductsRead, auth.ScopeProductsWrite}, userScopes: []string{auth.ScopeProductsRead}, want: []string{auth.ScopeProductsRead}},
+		{name: "用户被收回全部权限", keyScopes: []string{auth.ScopeProductsRead}, userScopes: []string{}, want: []string{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := newTestEnv(t)
+			user := env.createUser(t, "alice", testPassword)
+			svc := NewAPIKeyService(repository.NewAPIKeyRepository(env.db), repository.NewUserRepository(env.db), env.cache, env.logger)
+			ctx := context.Background()
+
+			created, err := svc.CreateAPIKey(ctx, user.ID, &models.CreateAPIKeyRequest{Name: "ci", Scopes: tt.keyScopes})
+			if err != nil {
+				t.Fatalf("CreateAPIKey: %v", err)
+			}
+			if tt.userScopes != nil {
+				if err := env.db.Model(user).Update("scopes", models.StringList(tt.userScopes)).Error; err != nil {
+					t.Fatalf("update scopes: %v", err)
+				}
+			}
+
+			info, err := svc.AuthenticateAPIKey(ctx, created.Key)
+			if err != nil {
+				t.Fatalf("AuthenticateAPIKey: %v", err)
+			}
+			if !reflect.DeepEqual(info.Scopes, tt.want) {
+				t.Errorf("scopes = %v, want %v", info.Scopes, tt.want)
+			}
+		})
+	}
+}
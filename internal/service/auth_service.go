@@ -1,81 +1,592 @@
 package service
 
 import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"fmt"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/binary-1024/go-build-test/internal/auth"
+	"github.com/binary-1024/go-build-test/internal/cache"
 	"github.com/binary-1024/go-build-test/internal/logger"
+	"github.com/binary-1024/go-build-test/internal/mailer"
 	"github.com/binary-1024/go-build-test/internal/models"
 	"github.com/binary-1024/go-build-test/internal/repository"
 
+	"github.com/google/uuid"
+	"golang.org/x/crypto/bcrypt"
 	"gorm.io/gorm"
 )
 
 // AuthService 认证服务接口
 type AuthService interface {
-	Login(req *models.LoginRequest) (*models.LoginResponse, error)
-	ValidateToken(token string) (*auth.Claims, error)
+	Login(ctx context.Context, req *models.LoginRequest) (*models.LoginResponse, error)
+	Refresh(ctx context.Context, req *models.RefreshTokenRequest) (*models.LoginResponse, error)
+	VerifyEmail(ctx context.Context, token string) (*models.User, error)
+	ForgotPassword(ctx context.Context, email string) error
+	ResetPassword(ctx context.Context, token, password string) error
+	Introspect(ctx context.Context, req *models.IntrospectRequest) (*models.IntrospectResponse, error)
+}
+
+// 认证相关错误
+var (
+	// ErrEmailNotVerified 要求邮箱验证时，未验证邮箱的用户不能登录
+	ErrEmailNotVerified = errors.New("邮箱尚未验证")
+	// ErrInvalidVerifyToken 邮箱验证token无效、已过期或邮箱已变更
+	ErrInvalidVerifyToken = errors.New("验证链接无效或已过期")
+	// ErrInvalidResetToken 重置密码token无效、已过期或已被使用
+	ErrInvalidResetToken = errors.New("重置链接无效或已过期")
+	// ErrInvalidRefreshToken refresh token无效、已过期或已被撤销
+	ErrInvalidRefreshToken = errors.New("refresh token无效或已过期")
+	// ErrRefreshTokenReused 已使用过的refresh token被再次使用，视为泄露，同一登录会话签发的token全部撤销
+	ErrRefreshTokenReused = errors.New("refresh token已被使用，请重新登录")
+	// ErrScopeNotAllowed 申请的权限超出用户被授予的权限
+	ErrScopeNotAllowed = errors.New("申请的权限超出授权范围")
+)
+
+// refreshFamilyClaim refresh token中记录所属token族的声明
+// 同一次登录及其后续刷新签发的refresh token属于同一个族，检测到重复使用时整族撤销；
+// 每个用户的token族记录在 user_refresh_families:<id> 集合中，重置密码时撤销该用户的全部token族
+const refreshFamilyClaim = "family"
+
+// resetPasswordClaim 重置密码token中记录当前密码指纹的声明，密码修改后旧token随之失效
+const resetPasswordClaim = "pwd"
+
+// dummyPasswordHash 用户不存在时用于比对的密码哈希，与真实密码使用相同的cost
+// 首次使用时生成，避免在启动时付出一次bcrypt的开销
+var dummyPasswordHash = sync.OnceValue(func() []byte {
+	hash, _ := bcrypt.GenerateFromPassword([]byte("dummy-password"), bcrypt.DefaultCost)
+	return hash
+})
+
+// LoginProtection 登录失败保护配置
+type LoginProtection struct {
+	Threshold int           // 窗口内允许的失败次数，0 表示不启用
+	Window    time.Duration // 统计失败次数的滑动窗口
+	Cooldown  time.Duration // 达到阈值后账户锁定的时长
+}
+
+// AuthOptions 认证服务的可选行为
+type AuthOptions struct {
+	// RequireVerifiedEmail 为true时未验证邮箱的用户不能登录
+	RequireVerifiedEmail bool
+	// ResetURL 重置密码链接的地址，token 作为查询参数附加在后面
+	ResetURL string
+	// ResetTokenTTL 重置密码token的有效期
+	ResetTokenTTL time.Duration
+	// RefreshTokenTTL refresh token的有效期，为0时登录不签发refresh token
+	RefreshTokenTTL time.Duration
+	// NormalizeEmail 是否按归一化后的邮箱查找用户，与 UserOptions.NormalizeEmail 保持一致
+	NormalizeEmail bool
 }
 
 // authService 认证服务实现
 type authService struct {
 	userRepo   repository.UserRepository
 	jwtManager *auth.JWTManager
+	cache      *cache.RedisClient
+	mailer     mailer.Mailer
+	protection LoginProtection
+	options    AuthOptions
 	logger     logger.Logger
+
+	// comparePassword 比对密码哈希，测试中可替换以确认每条登录路径都执行了比对
+	comparePassword func(hash, password []byte) error
 }
 
 // NewAuthService 创建认证服务
-func NewAuthService(userRepo repository.UserRepository, jwtManager *auth.JWTManager, logger logger.Logger) AuthService {
+func NewAuthService(userRepo repository.UserRepository, jwtManager *auth.JWTManager, cache *cache.RedisClient, mailer mailer.Mailer, protection LoginProtection, options AuthOptions, logger logger.Logger) AuthService {
 	return &authService{
 		userRepo:   userRepo,
 		jwtManager: jwtManager,
+		cache:      cache,
+		mailer:     mailer,
+		protection: protection,
+		options:    options,
 		logger:     logger,
+
+		comparePassword: bcrypt.CompareHashAndPassword,
 	}
 }
 
 // Login 用户登录
-func (s *authService) Login(req *models.LoginRequest) (*models.LoginResponse, error) {
+func (s *authService) Login(ctx context.Context, req *models.LoginRequest) (*models.LoginResponse, error) {
 	s.logger.Info("用户登录", "username", req.Username)
 
+	// 检查账户是否因连续登录失败被锁定
+	// 不存在的用户名同样记录失败并锁定，锁定提示不能用于判断用户名是否存在
+	if s.isLocked(ctx, req.Username) {
+		s.logger.Warn("账户已锁定", "username", req.Username)
+		return nil, fmt.Errorf("账户已锁定")
+	}
+
 	// 根据用户名获取用户
-	user, err := s.userRepo.GetByUsername(req.Username)
+	user, err := s.userRepo.GetByUsername(ctx, req.Username)
 	if err != nil {
-		if err == gorm.ErrRecordNotFound {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
 			s.logger.Warn("用户不存在", "username", req.Username)
+			// 用户不存在时同样执行一次bcrypt比对，使响应时间与密码错误时接近，
+			// 避免通过响应时间判断用户名是否存在
+			_ = s.comparePassword(dummyPasswordHash(), []byte(req.Password))
+			s.recordFailure(ctx, req.Username)
 			return nil, fmt.Errorf("用户名或密码错误")
 		}
 		s.logger.Error("获取用户失败", "error", err)
 		return nil, err
 	}
 
-	// 检查用户是否激活
+	// 先验证密码，密码错误时无论账户是否禁用都返回相同的错误
+	if err := s.comparePassword([]byte(user.Password), []byte(req.Password)); err != nil {
+		s.logger.Warn("密码错误", "username", req.Username)
+		s.recordFailure(ctx, req.Username)
+		return nil, fmt.Errorf("用户名或密码错误")
+	}
+
+	// 密码正确后再检查账户是否禁用，避免泄露账户是否存在
 	if !user.IsActive {
 		s.logger.Warn("用户已禁用", "username", req.Username)
 		return nil, fmt.Errorf("用户已被禁用")
 	}
 
-	// 验证密码
-	if err := user.CheckPassword(req.Password); err != nil {
-		s.logger.Warn("密码错误", "username", req.Username)
-		return nil, fmt.Errorf("用户名或密码错误")
+	// 密码正确后再检查邮箱验证，避免泄露账户是否存在
+	if s.options.RequireVerifiedEmail && !user.EmailVerified {
+		s.logger.Warn("邮箱未验证", "username", req.Username)
+		return nil, ErrEmailNotVerified
+	}
+
+	// 申请了权限时只签发申请的部分，例如只读token
+	scopes := grantedScopes(user)
+	if len(req.Scopes) > 0 {
+		if missing := auth.MissingScope(scopes, req.Scopes); missing != "" {
+			s.logger.Warn("申请的权限超出授权范围", "username", req.Username, "scope", missing)
+			return nil, fmt.Errorf("%w：%s", ErrScopeNotAllowed, missing)
+		}
+		scopes = req.Scopes
 	}
 
 	// 生成JWT token
-	token, err := s.jwtManager.GenerateToken(user.ID, user.Username)
+	token, err := s.jwtManager.GenerateWithOptions(user.ID, user.Username, auth.TokenOptions{
+		TokenType:   auth.TokenTypeAccess,
+		Fingerprint: req.Fingerprint,
+		Scopes:      scopes,
+	})
 	if err != nil {
 		s.logger.Error("生成token失败", "error", err)
 		return nil, err
 	}
 
+	s.clearFailures(ctx, req.Username)
+
+	// refresh token不可用时仍然允许登录，客户端在访问token过期后重新登录
+	var refreshToken string
+	if s.options.RefreshTokenTTL > 0 {
+		refreshToken, err = s.issueRefreshToken(ctx, user, uuid.NewString(), req.Fingerprint, scopes)
+		if err != nil {
+			s.logger.Warn("签发refresh token失败", "user_id", user.ID, "error", err)
+		}
+	}
+
 	s.logger.Info("用户登录成功", "user_id", user.ID)
 
 	return &models.LoginResponse{
-		Token: token,
-		User:  *user,
+		Token:        token,
+		RefreshToken: refreshToken,
+		User:         user.ToPublic(),
+	}, nil
+}
+
+// Refresh 使用refresh token换取新的访问token和refresh token
+// 每个refresh token只能使用一次；已使用的token再次出现说明可能已泄露，
+// 此时撤销其所属的整个token族，持有同族最新token的一方也需要重新登录
+func (s *authService) Refresh(ctx context.Context, req *models.RefreshTokenRequest) (*models.LoginResponse, error) {
+	if s.options.RefreshTokenTTL <= 0 {
+		return nil, ErrInvalidRefreshToken
+	}
+
+	claims, err := s.jwtManager.ValidateToken(req.RefreshToken)
+	if err != nil || claims.TokenType != auth.TokenTypeRefresh || claims.ID == "" {
+		return nil, ErrInvalidRefreshToken
+	}
+	family, _ := claims.Extra[refreshFamilyClaim].(string)
+	if family == "" || claims.Fingerprint != req.Fingerprint {
+		return nil, ErrInvalidRefreshToken
+	}
+	log := s.logger.With("user_id", claims.UserID)
+
+	consumed, err := s.cache.Consume(ctx, refreshTokenKey(claims.ID))
+	if err != nil {
+		log.Error("校验refresh token失败", "error", err)
+		return nil, err
+	}
+	if !consumed {
+		// 撤销不随请求取消而中断，否则攻击者可以通过主动断开保留token族
+		if err := s.cache.Delete(context.WithoutCancel(ctx), refreshFamilyKey(family)); err != nil {
+			log.Error("撤销token族失败", "family", family, "error", err)
+			return nil, err
+		}
+		log.Warn("检测到refresh token重复使用，已撤销整个token族", "family", family)
+		return nil, ErrRefreshTokenReused
+	}
+	if !s.cache.Exists(ctx, refreshFamilyKey(family)) {
+		log.Warn("refresh token所属的token族已撤销", "family", family)
+		return nil, ErrInvalidRefreshToken
+	}
+
+	user, err := s.userRepo.GetByID(ctx, claims.UserID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrInvalidRefreshToken
+		}
+		log.Error("获取用户失败", "error", err)
+		return nil, err
+	}
+	if !user.IsActive {
+		return nil, ErrInvalidRefreshToken
+	}
+
+	// 沿用登录时申请的权限，期间被收回的权限不再签发
+	scopes := auth.IntersectScopes(claims.Scopes, grantedScopes(user))
+	token, err := s.jwtManager.GenerateWithOptions(user.ID, user.Username, auth.TokenOptions{
+		TokenType:   auth.TokenTypeAccess,
+		Fingerprint: req.Fingerprint,
+		Scopes:      scopes,
+	})
+	if err != nil {
+		log.Error("生成token失败", "error", err)
+		return nil, err
+	}
+	refreshToken, err := s.issueRefreshToken(ctx, user, family, req.Fingerprint, scopes)
+	if err != nil {
+		log.Error("签发refresh token失败", "error", err)
+		return nil, err
+	}
+
+	log.Info("刷新token成功")
+	return &models.LoginResponse{
+		Token:        token,
+		RefreshToken: refreshToken,
+		User:         user.ToPublic(),
 	}, nil
 }
 
-// ValidateToken 验证token
-func (s *authService) ValidateToken(token string) (*auth.Claims, error) {
-	return s.jwtManager.ValidateToken(token)
+// issueRefreshToken 签发属于 family 的refresh token，并在Redis中登记为未使用
+func (s *authService) issueRefreshToken(ctx context.Context, user *models.User, family, fingerprint string, scopes []string) (string, error) {
+	jti := uuid.NewString()
+	token, err := s.jwtManager.GenerateWithOptions(user.ID, user.Username, auth.TokenOptions{
+		TTL:         s.options.RefreshTokenTTL,
+		TokenType:   auth.TokenTypeRefresh,
+		ID:          jti,
+		Extra:       map[string]interface{}{refreshFamilyClaim: family},
+		Fingerprint: fingerprint,
+		Scopes:      scopes,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	// 每次签发都延长token族的有效期，使其不早于族内最新的token过期
+	ctx = context.WithoutCancel(ctx)
+	if err := s.cache.Set(ctx, refreshFamilyKey(family), user.ID, s.options.RefreshTokenTTL); err != nil {
+		return "", err
+	}
+	if err := s.cache.Set(ctx, refreshTokenKey(jti), family, s.options.RefreshTokenTTL); err != nil {
+		return "", err
+	}
+	if err := s.cache.AddToSet(ctx, userRefreshFamiliesKey(user.ID), family, s.options.RefreshTokenTTL); err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// revokeRefreshFamilies 撤销用户的全部token族，已签发的refresh token都不能再使用
+func (s *authService) revokeRefreshFamilies(ctx context.Context, userID uint) error {
+	families, err := s.cache.SetMembers(ctx, userRefreshFamiliesKey(userID))
+	if err != nil {
+		return err
+	}
+	for _, family := range families {
+		if err := s.cache.Delete(ctx, refreshFamilyKey(family)); err != nil {
+			return err
+		}
+	}
+	return s.cache.Delete(ctx, userRefreshFamiliesKey(userID))
+}
+
+// VerifyEmail 校验邮箱验证token并将用户标记为已验证
+// 重复验证同一个有效链接视为成功
+func (s *authService) VerifyEmail(ctx context.Context, token string) (*models.User, error) {
+	claims, err := s.jwtManager.ValidateToken(token)
+	if err != nil || claims.TokenType != auth.TokenTypeVerify {
+		return nil, ErrInvalidVerifyToken
+	}
+	log := s.logger.With("user_id", claims.UserID)
+
+	user, err := s.userRepo.GetByID(ctx, claims.UserID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrInvalidVerifyToken
+		}
+		log.Error("获取用户失败", "error", err)
+		return nil, err
+	}
+
+	// 邮箱变更后旧邮箱的验证链接不再有效
+	if email, _ := claims.Extra[verifyEmailClaim].(string); email != user.Email {
+		log.Warn("验证token中的邮箱与当前邮箱不一致")
+		return nil, ErrInvalidVerifyToken
+	}
+
+	if user.EmailVerified {
+		return user, nil
+	}
+	if err := s.userRepo.Update(ctx, user.ID, map[string]interface{}{"email_verified": true}); err != nil {
+		log.Error("更新邮箱验证状态失败", "error", err)
+		return nil, err
+	}
+	if err := s.cache.Delete(context.WithoutCancel(ctx), UserCacheKey(user.ID)); err != nil {
+		log.Warn("删除用户缓存失败", "error", err)
+	}
+
+	user.EmailVerified = true
+	log.Info("邮箱验证成功")
+	return user, nil
+}
+
+// ForgotPassword 向邮箱对应的用户发送重置密码链接
+// 邮件在后台发送，邮箱是否存在的响应时间相同，避免通过响应时间探测已注册的邮箱
+func (s *authService) ForgotPassword(ctx context.Context, email string) error {
+	user, err := s.findUserByEmail(ctx, email)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			s.logger.Info("申请重置密码的邮箱不存在")
+			return nil
+		}
+		s.logger.Error("获取用户失败", "error", err)
+		return err
+	}
+	log := s.logger.With("user_id", user.ID)
+	if !user.IsActive {
+		log.Warn("已禁用的用户申请重置密码")
+		return nil
+	}
+
+	token, err := s.jwtManager.GenerateWithOptions(user.ID, user.Username, auth.TokenOptions{
+		TTL:       s.options.ResetTokenTTL,
+		TokenType: auth.TokenTypeReset,
+		Extra:     map[string]interface{}{resetPasswordClaim: passwordFingerprint(user.Password)},
+	})
+	if err != nil {
+		log.Error("生成重置密码token失败", "error", err)
+		return err
+	}
+
+	msg := mailer.Message{
+		To:      user.Email,
+		Subject: "重置您的密码",
+		Body:    fmt.Sprintf("%s，您好：\n\n请打开以下链接重置密码，链接在%s内有效：\n%s?token=%s\n\n如果这不是您本人的操作，请忽略本邮件。\n", user.Username, s.options.ResetTokenTTL, s.options.ResetURL, url.QueryEscape(token)),
+	}
+	go func() {
+		// 与邮箱不存在时的响应保持一致，发送失败只记录日志
+		if err := s.mailer.Send(context.WithoutCancel(ctx), msg); err != nil {
+			log.Warn("发送重置密码邮件失败", "error", err)
+			return
+		}
+		log.Info("已发送重置密码邮件")
+	}()
+	return nil
+}
+
+// findUserByEmail 根据邮箱查找用户，开启邮箱归一化时 user+tag@example.com 可以找到 user@example.com 的账户
+// 归一化后与其他账户重复、未回填归一化邮箱的旧账户仍按原始邮箱查找
+func (s *authService) findUserByEmail(ctx context.Context, email string) (*models.User, error) {
+	if !s.options.NormalizeEmail {
+		return s.userRepo.GetByEmail(ctx, email)
+	}
+	user, err := s.userRepo.GetByNormalizedEmail(ctx, models.NormalizeEmail(email))
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return s.userRepo.GetByEmail(ctx, email)
+	}
+	return user, err
+}
+
+// ResetPassword 校验重置密码token并设置新密码，同时撤销该用户已签发的全部refresh token
+// token 中记录了签发时的密码指纹，密码修改后同一个链接不能再次使用
+func (s *authService) ResetPassword(ctx context.Context, token, password string) error {
+	claims, err := s.jwtManager.ValidateToken(token)
+	if err != nil || claims.TokenType != auth.TokenTypeReset {
+		return ErrInvalidResetToken
+	}
+	log := s.logger.With("user_id", claims.UserID)
+
+	user, err := s.userRepo.GetByID(ctx, claims.UserID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrInvalidResetToken
+		}
+		log.Error("获取用户失败", "error", err)
+		return err
+	}
+	if !user.IsActive {
+		return ErrInvalidResetToken
+	}
+	if fingerprint, _ := claims.Extra[resetPasswordClaim].(string); fingerprint != passwordFingerprint(user.Password) {
+		log.Warn("重置密码token已使用或密码已变更")
+		return ErrInvalidResetToken
+	}
+
+	user.Password = password
+	if err := user.HashPassword(); err != nil {
+		log.Error("密码加密失败", "error", err)
+		return err
+	}
+	if err := s.userRepo.Update(ctx, user.ID, map[string]interface{}{"password": user.Password}); err != nil {
+		log.Error("更新密码失败", "error", err)
+		return err
+	}
+
+	// 密码可能已经泄露，撤销之前登录签发的全部refresh token
+	ctx = context.WithoutCancel(ctx)
+	if err := s.revokeRefreshFamilies(ctx, user.ID); err != nil {
+		log.Error("撤销refresh token失败", "error", err)
+	}
+
+	// 重置密码后解除因连续登录失败导致的锁定
+	for _, key := range []string{UserCacheKey(user.ID), loginFailKey(user.Username), loginLockKey(user.Username)} {
+		if err := s.cache.Delete(ctx, key); err != nil {
+			log.Warn("删除缓存失败", "key", key, "error", err)
+		}
+	}
+
+	log.Info("密码重置成功")
+	return nil
+}
+
+// Introspect 校验token并返回其中的声明
+// 签名无效、已过期、与客户端指纹不匹配、refresh token已使用或已撤销、用户已删除或已禁用时返回 Active 为false的结果；
+// 只有查询用户失败时返回错误
+func (s *authService) Introspect(ctx context.Context, req *models.IntrospectRequest) (*models.IntrospectResponse, error) {
+	inactive := &models.IntrospectResponse{Active: false}
+
+	claims, err := s.jwtManager.ValidateToken(req.Token)
+	if err != nil || claims.Fingerprint != req.Fingerprint {
+		return inactive, nil
+	}
+
+	// refresh token须未被使用且所属token族未被撤销
+	if claims.TokenType == auth.TokenTypeRefresh {
+		family, _ := claims.Extra[refreshFamilyClaim].(string)
+		if family == "" || claims.ID == "" ||
+			!s.cache.Exists(ctx, refreshTokenKey(claims.ID)) || !s.cache.Exists(ctx, refreshFamilyKey(family)) {
+			return inactive, nil
+		}
+	}
+
+	user, err := s.userRepo.GetByID(ctx, claims.UserID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return inactive, nil
+		}
+		s.logger.Error("获取用户失败", "user_id", claims.UserID, "error", err)
+		return nil, err
+	}
+	if !user.IsActive {
+		return inactive, nil
+	}
+
+	resp := &models.IntrospectResponse{
+		Active:    true,
+		UserID:    claims.UserID,
+		Username:  claims.Username,
+		TokenType: claims.TokenType,
+		Scope:     strings.Join(claims.Scopes, " "),
+	}
+	if claims.ExpiresAt != nil {
+		resp.ExpiresAt = claims.ExpiresAt.Unix()
+	}
+	if claims.IssuedAt != nil {
+		resp.IssuedAt = claims.IssuedAt.Unix()
+	}
+	return resp, nil
+}
+
+// isLocked 账户是否处于锁定冷却期
+func (s *authService) isLocked(ctx context.Context, username string) bool {
+	if s.protection.Threshold <= 0 {
+		return false
+	}
+	return s.cache.Exists(ctx, loginLockKey(username))
+}
+
+// recordFailure 记录一次登录失败，滑动窗口内失败次数达到阈值时锁定账户
+// 失败记录不随请求取消而丢失，否则客户端可以通过主动断开绕过锁定
+func (s *authService) recordFailure(ctx context.Context, username string) {
+	if s.protection.Threshold <= 0 {
+		return
+	}
+
+	ctx = context.WithoutCancel(ctx)
+	count, err := s.cache.SlidingWindowAdd(ctx, loginFailKey(username), s.protection.Window)
+	if err != nil {
+		s.logger.Warn("记录登录失败次数失败", "username", username, "error", err)
+		return
+	}
+
+	if count >= int64(s.protection.Threshold) {
+		if err := s.cache.Set(ctx, loginLockKey(username), true, s.protection.Cooldown); err != nil {
+			s.logger.Warn("锁定账户失败", "username", username, "error", err)
+			return
+		}
+		s.logger.Warn("登录失败次数过多，账户已锁定", "username", username, "cooldown", s.protection.Cooldown)
+	}
+}
+
+// clearFailures 登录成功后清除失败记录
+func (s *authService) clearFailures(ctx context.Context, username string) {
+	if s.protection.Threshold <= 0 {
+		return
+	}
+
+	if err := s.cache.Delete(ctx, loginFailKey(username)); err != nil {
+		s.logger.Warn("清除登录失败次数失败", "username", username, "error", err)
+	}
+}
+
+// grantedScopes 用户被授予的权限，未分配时只拥有默认权限
+// 升级前创建的用户在迁移时已回填为全部权限，见 database.NewConnection
+func grantedScopes(user *models.User) []string {
+	if user.Scopes == nil {
+		return auth.DefaultScopes
+	}
+	return []string(user.Scopes)
+}
+
+// passwordFingerprint 密码哈希的摘要，用于在token中标识签发时的密码而不暴露哈希本身
+func passwordFingerprint(hash string) string {
+	sum := sha256.Sum256([]byte(hash))
+	return hex.EncodeToString(sum[:8])
+}
+
+func refreshTokenKey(jti string) string {
+	return fmt.Sprintf("refresh_token:%s", jti)
+}
+
+func refreshFamilyKey(family string) string {
+	return fmt.Sprintf("refresh_family:%s", family)
+}
+
+func userRefreshFamiliesKey(userID uint) string {
+	return fmt.Sprintf("user_refresh_families:%d", userID)
+}
+
+func loginFailKey(username string) string {
+	return fmt.Sprintf("login_fail:%s", username)
+}
+
+func loginLockKey(username string) string {
+	return fmt.Sprintf("login_lock:%s", username)
 }
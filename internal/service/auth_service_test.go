@@ -0,0 +1,554 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/binary-1024/go-build-test/internal/auth"
+	"github.com/binary-1024/go-build-test/internal/mailer"
+	"github.com/binary-1024/go-build-test/internal/models"
+	"github.com/binary-1024/go-build-test/internal/repository"
+
+	"github.com/golang-jwt/jwt/v4"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const testPassword = "Passw0rd!x"
+
+func newTestAuthService(env *testEnv) (*authService, *auth.JWTManager) {
+	jwtManager := auth.NewJWTManager("test-secret")
+	svc := NewAuthService(repository.NewUserRepository(env.db), jwtManager, env.cache, mailer.NewNopMailer(), LoginProtection{}, AuthOptions{
+		ResetTokenTTL:   time.Hour,
+		RefreshTokenTTL: time.Hour,
+	}, env.logger)
+	return svc.(*authService), jwtManager
+}
+
+func TestRefreshTokenRotation(t *testing.T) {
+	tests := []struct {
+		name string
+		// replay 在第一次刷新之后执行，返回最后一次刷新的错误
+		replay  func(ctx context.Context, svc *authService, first, second string) error
+		wantErr error
+	}{
+		{
+			name: "使用最新的token刷新",
+			replay: func(ctx context.Context, svc *authService, first, second string) error {
+				_, err := svc.Refresh(ctx, &models.RefreshTokenRequest{RefreshToken: second})
+				return err
+			},
+		},
+		{
+			name: "重复使用已使用的token",
+			replay: func(ctx context.Context, svc *authService, first, second string) error {
+				_, err := svc.Refresh(ctx, &models.RefreshTokenRequest{RefreshToken: first})
+				return err
+			},
+			wantErr: ErrRefreshTokenReused,
+		},
+		{
+			name: "检测到重复使用后同族最新的token失效",
+			replay: func(ctx context.Context, svc *authService, first, second string) error {
+				_, _ = svc.Refresh(ctx, &models.RefreshTokenRequest{RefreshToken: first})
+				_, err := svc.Refresh(ctx, &models.RefreshTokenRequest{RefreshToken: second})
+				return err
+			},
+			wantErr: ErrInvalidRefreshToken,
+		},
+		{
+			name: "指纹不一致",
+			replay: func(ctx context.Context, svc *authService, first, second string) error {
+				_, err := svc.Refresh(ctx, &models.RefreshTokenRequest{RefreshToken: second, Fingerprint: "other-device"})
+				return err
+			},
+			wantErr: ErrInvalidRefreshToken,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := newTestEnv(t)
+			env.createUser(t, "alice", testPassword)
+			svc, _ := newTestAuthService(env)
+			ctx := context.Background()
+
+			login, err := svc.Login(ctx, &models.LoginRequest{Username: "alice", Password: testPassword})
+			if err != nil {
+				t.Fatalf("Login: %v", err)
+			}
+			refreshed, err := svc.Refresh(ctx, &models.RefreshTokenRequest{RefreshToken: login.RefreshToken})
+			if err != nil {
+				t.Fatalf("Refresh: %v", err)
+			}
+
+			if err := tt.replay(ctx, svc, login.RefreshToken, refreshed.RefreshToken); !errors.Is(err, tt.wantErr) {
+				t.Errorf("err = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestResetPasswordRevokesRefreshTokens(t *testing.T) {
+	env := newTestEnv(t)
+	user := env.createUser(t, "alice", testPassword)
+	svc, jwtManager := newTestAuthService(env)
+	ctx := context.Background()
+
+	// 两个设备分别登录，各自属于一个token族
+	var refreshTokens []string
+	for _, device := range []string{"phone", "laptop"} {
+		login, err := svc.Login(ctx, &models.LoginRequest{Username: "alice", Password: testPassword, Fingerprint: device})
+		if err != nil {
+			t.Fatalf("Login(%s): %v", device, err)
+		}
+		refreshTokens = append(refreshTokens, login.RefreshToken)
+	}
+
+	resetToken, err := jwtManager.GenerateWithOptions(user.ID, user.Username, auth.TokenOptions{
+		TTL:       time.Hour,
+		TokenType: auth.TokenTypeReset,
+		Extra:     map[string]interface{}{resetPasswordClaim: passwordFingerprint(user.Password)},
+	})
+	if err != nil {
+		t.Fatalf("generate reset token: %v", err)
+	}
+	if err := svc.ResetPassword(ctx, resetToken, "N3wPassw0rd!"); err != nil {
+		t.Fatalf("ResetPassword: %v", err)
+	}
+
+	for i, device := range []string{"phone", "laptop"} {
+		_, err := svc.Refresh(ctx, &models.RefreshTokenRequest{RefreshToken: refreshTokens[i], Fingerprint: device})
+		if !errors.Is(err, ErrInvalidRefreshToken) {
+			t.Errorf("Refresh(%s) after reset: err = %v, want %v", device, err, ErrInvalidRefreshToken)
+		}
+	}
+	if env.cache.Exists(ctx, userRefreshFamiliesKey(user.ID)) {
+		t.Error("refresh family set kept after reset")
+	}
+}
+
+func TestIntrospect(t *testing.T) {
+	tests := []struct {
+		name string
+		// token 准备测试数据并返回待校验的token
+		token       func(t *testing.T, env *testEnv, svc *authService, jwtManager *auth.JWTManager, user *models.User) string
+		fingerprint string
+		wantActive  bool
+		wantScope   string
+	}{
+		{
+			name: "有效的访问token",
+			token: func(t *testing.T, env *testEnv, svc *authService, jwtManager *auth.JWTManager, user *models.User) string {
+				return login(t, svc).Token
+			},
+			wantActive: true,
+			wantScope:  "users:read products:read",
+		},
+		{
+			name: "格式错误的token",
+			token: func(t *testing.T, env *testEnv, svc *authService, jwtManager *auth.JWTManager, user *models.User) string {
+				return "not-a-token"
+			},
+		},
+		{
+			name: "过期的token",
+			token: func(t *testing.T, env *testEnv, svc *authService, jwtManager *auth.JWTManager, user *models.User) string {
+				claims := auth.Claims{
+					UserID:           user.ID,
+					Username:         user.Username,
+					TokenType:        auth.TokenTypeAccess,
+					RegisteredClaims: jwt.RegisteredClaims{ExpiresAt: jwt.NewNumericDate(time.Now().Add(-time.Minute))},
+				}
+				token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte("test-secret"))
+				if err != nil {
+					t.Fatalf("sign token: %v", err)
+				}
+				return token
+			},
+		},
+		{
+			name: "未使用的refresh token",
+			token: func(t *testing.T, env *testEnv, svc *authService, jwtManager *auth.JWTManager, user *models.User) string {
+				return login(t, svc).RefreshToken
+			},
+			wantActive: true,
+			wantScope:  "users:read products:read",
+		},
+		{
+			name: "已使用的refresh token",
+			token: func(t *testing.T, env *testEnv, svc *authService, jwtManager *auth.JWTManager, user *models.User) string {
+				refreshToken := login(t, svc).RefreshToken
+				if _, err := svc.Refresh(context.Background(), &models.RefreshTokenRequest{RefreshToken: refreshToken}); err != nil {
+					t.Fatalf("Refresh: %v", err)
+				}
+				return refreshToken
+			},
+		},
+		{
+			name: "token族已撤销",
+			token: func(t *testing.T, env *testEnv, svc *authService, jwtManager *auth.JWTManager, user *models.User) string {
+				refreshToken := login(t, svc).RefreshToken
+				if err := svc.revokeRefreshFamilies(context.Background(), user.ID); err != nil {
+					t.Fatalf("revokeRefreshFamilies: %v", err)
+				}
+				return refreshToken
+			},
+		},
+		{
+			name: "用户已禁用",
+			token: func(t *testing.T, env *testEnv, svc *authService, jwtManager *auth.JWTManager, user *models.User) string {
+				token := login(t, svc).Token
+				if err := env.db.Model(user).Update("is_active", false).Error; err != nil {
+					t.Fatalf("deactivate user: %v", err)
+				}
+				return token
+			},
+		},
+		{
+			name: "用户已删除",
+			token: func(t *testing.T, env *testEnv, svc *authService, jwtManager *auth.JWTManager, user *models.User) string {
+				token := login(t, svc).Token
+				if err := env.db.Delete(user).Error; err != nil {
+					t.Fatalf("delete user: %v", err)
+				}
+				return token
+			},
+		},
+		{
+			name: "客户端指纹不一致",
+			token: func(t *testing.T, env *testEnv, svc *authService, jwtManager *auth.JWTManager, user *models.User) string {
+				token, err := jwtManager.GenerateWithOptions(user.ID, user.Username, auth.TokenOptions{TokenType: auth.TokenTypeAccess, Fingerprint: "device-a"})
+				if err != nil {
+					t.Fatalf("GenerateWithOptions: %v", err)
+				}
+				return token
+			},
+			fingerprint: "device-b",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := newTestEnv(t)
+			svc, jwtManager := newTestAuthService(env)
+			user := env.createUser(t, "alice", testPassword)
+
+			resp, err := svc.Introspect(context.Background(), &models.IntrospectRequest{
+				Token:       tt.token(t, env, svc, jwtManager, user),
+				Fingerprint: tt.fingerprint,
+			})
+			if err != nil {
+				t.Fatalf("Introspect: %v", err)
+			}
+			if resp.Active != tt.wantActive {
+				t.Fatalf("active = %v, want %v", resp.Active, tt.wantActive)
+			}
+			if resp.Active && (resp.UserID != user.ID || resp.Scope != tt.wantScope) {
+				t.Errorf("user_id = %d, scope = %q, want %d, %q", resp.UserID, resp.Scope, user.ID, tt.wantScope)
+			}
+		})
+	}
+}
+
+// login 以测试用户登录，申请 users:read 和 products:read 权限
+func login(t *testing.T, svc *authService) *models.LoginResponse {
+	t.Helper()
+	resp, err := svc.Login(context.Background(), &models.LoginRequest{
+		Username: "alice",
+		Password: testPassword,
+		Scopes:   []string{auth.ScopeUsersRead, auth.ScopeProductsRead},
+	})
+	if err != nil {
+		t.Fatalf("Login: %v", err)
+	}
+	return resp
+}
+
+// blockingMailer 在 release 关闭前阻塞发送，发送的邮件写入 sent
+type blockingMailer struct {
+	release chan struct{}
+	sent    chan mailer.Message
+}
+
+func (m *blockingMailer) Send(ctx context.Context, msg mailer.Message) error {
+	<-m.release
+	m.sent <- msg
+	return nil
+}
+
+func TestForgotPassword(t *testing.T) {
+	tests := []struct {
+		name      string
+		normalize bool
+		email     string
+		wantMail  bool
+	}{
+		{name: "原始邮箱", email: "alice@example.com", wantMail: true},
+		{name: "开启归一化时使用+tag邮箱", normalize: true, email: "Alice+reset@example.com", wantMail: true},
+		{name: "未开启归一化时使用+tag邮箱", email: "Alice+reset@example.com", wantMail: false},
+		{name: "邮箱不存在", normalize: true, email: "nobody@example.com", wantMail: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := newTestEnv(t)
+			user := env.createUser(t, "alice", testPassword)
+			if tt.normalize {
+				normalized := models.NormalizeEmail(user.Email)
+				if err := env.db.Model(user).Update("normalized_email", normalized).Error; err != nil {
+					t.Fatalf("set normalized email: %v", err)
+				}
+			}
+			mail := &blockingMailer{release: make(chan struct{}), sent: make(chan mailer.Message, 1)}
+			svc := NewAuthService(repository.NewUserRepository(env.db), auth.NewJWTManager("test-secret"), env.cache, mail, LoginProtection{}, AuthOptions{
+				ResetTokenTTL:  time.Hour,
+				NormalizeEmail: tt.normalize,
+			}, env.logger)
+
+			// 邮件在后台发送，发送阻塞时接口仍立即返回
+			done := make(chan error, 1)
+			go func() { done <- svc.ForgotPassword(context.Background(), tt.email) }()
+			select {
+			case err := <-done:
+				if err != nil {
+					t.Fatalf("ForgotPassword: %v", err)
+				}
+			case <-time.After(time.Second):
+				t.Fatal("ForgotPassword blocked on sending mail")
+			}
+			close(mail.release)
+
+			select {
+			case msg := <-mail.sent:
+				if !tt.wantMail {
+					t.Fatalf("unexpected mail to %s", msg.To)
+				}
+				if msg.To != user.Email {
+					t.Errorf("mail to = %s, want %s", msg.To, user.Email)
+				}
+			case <-time.After(200 * time.Millisecond):
+				if tt.wantMail {
+					t.Fatal("reset mail was not sent")
+				}
+			}
+		})
+	}
+}
+
+func TestLoginAlwaysComparesPassword(t *testing.T) {
+	const genericError = "用户名或密码错误"
+
+	tests := []struct {
+		name      string
+		username  string
+		password  string
+		disabled  bool
+		wantMsg   string
+		wantDummy bool
+	}{
+		{name: "用户不存在", username: "nobody", password: testPassword, wantMsg: genericError, wantDummy: true},
+		{name: "密码错误", username: "alice", password: "wrong-password", wantMsg: genericError},
+		{name: "禁用用户密码错误", username: "alice", password: "wrong-password", disabled: true, wantMsg: genericError},
+		{name: "禁用用户密码正确", username: "alice", password: testPassword, disabled: true, wantMsg: "用户已被禁用"},
+		{name: "登录成功", username: "alice", password: testPassword},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := newTestEnv(t)
+			user := env.createUser(t, "alice", testPassword)
+			if tt.disabled {
+				if err := env.db.Model(user).Update("is_active", false).Error; err != nil {
+					t.Fatalf("disable user: %v", err)
+				}
+			}
+			svc, _ := newTestAuthService(env)
+
+			var hashes [][]byte
+			svc.comparePassword = func(hash, password []byte) error {
+				hashes = append(hashes, hash)
+				return bcrypt.CompareHashAndPassword(hash, password)
+			}
+
+			_, err := svc.Login(context.Background(), &models.LoginRequest{Username: tt.username, Password: tt.password})
+			switch {
+			case tt.wantMsg == "" && err != nil:
+				t.Fatalf("Login err = %v, want nil", err)
+			case tt.wantMsg != "" && (err == nil || err.Error() != tt.wantMsg):
+				t.Fatalf("Login err = %v, want %q", err, tt.wantMsg)
+			}
+			if len(hashes) != 1 {
+				t.Fatalf("comparisons = %d, want 1", len(hashes))
+			}
+			if dummy := bytes.Equal(hashes[0], dummyPasswordHash()); dummy != tt.wantDummy {
+				t.Errorf("compared against dummy hash = %v, want %v", dummy, tt.wantDummy)
+			}
+		})
+	}
+}
+
+// wrappedNotFoundRepo 像带错误包装的仓库一样，将查询错误包装后返回
+type wrappedNotFoundRepo struct {
+	repository.UserRepository
+}
+
+func (r wrappedNotFoundRepo) wrap(user *models.User, err error) (*models.User, error) {
+	if err != nil {
+		return nil, fmt.Errorf("user repository: %w", err)
+	}
+	return user, nil
+}
+
+func (r wrappedNotFoundRepo) GetByID(ctx context.Context, id uint) (*models.User, error) {
+	return r.wrap(r.UserRepository.GetByID(ctx, id))
+}
+
+func (r wrappedNotFoundRepo) GetByUsername(ctx context.Context, username string) (*models.User, error) {
+	return r.wrap(r.UserRepository.GetByUsername(ctx, username))
+}
+
+func (r wrappedNotFoundRepo) GetByEmail(ctx context.Context, email string) (*models.User, error) {
+	return r.wrap(r.UserRepository.GetByEmail(ctx, email))
+}
+
+func TestWrappedRecordNotFound(t *testing.T) {
+	const missingUserID = 999
+
+	tests := []struct {
+		name    string
+		call    func(svc *authService, jwtManager *auth.JWTManager) error
+		wantErr error
+		wantMsg string
+	}{
+		{
+			name: "登录不存在的用户",
+			call: func(svc *authService, _ *auth.JWTManager) error {
+				_, err := svc.Login(context.Background(), &models.LoginRequest{Username: "nobody", Password: testPassword})
+				return err
+			},
+			wantMsg: "用户名或密码错误",
+		},
+		{
+			name: "验证不存在用户的邮箱",
+			call: func(svc *authService, jwtManager *auth.JWTManager) error {
+				token, _ := jwtManager.GenerateWithOptions(missingUserID, "nobody", auth.TokenOptions{TokenType: auth.TokenTypeVerify})
+				_, err := svc.VerifyEmail(context.Background(), token)
+				return err
+			},
+			wantErr: ErrInvalidVerifyToken,
+		},
+		{
+			name: "重置不存在用户的密码",
+			call: func(svc *authService, jwtManager *auth.JWTManager) error {
+				token, _ := jwtManager.GenerateWithOptions(missingUserID, "nobody", auth.TokenOptions{TokenType: auth.TokenTypeReset})
+				return svc.ResetPassword(context.Background(), token, testPassword)
+			},
+			wantErr: ErrInvalidResetToken,
+		},
+		{
+			name: "为不存在的邮箱申请重置密码",
+			call: func(svc *authService, _ *auth.JWTManager) error {
+				return svc.ForgotPassword(context.Background(), "nobody@example.com")
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := newTestEnv(t)
+			svc, jwtManager := newTestAuthService(env)
+			svc.userRepo = wrappedNotFoundRepo{svc.userRepo}
+
+			err := tt.call(svc, jwtManager)
+			switch {
+			case tt.wantMsg != "":
+				if err == nil || err.Error() != tt.wantMsg {
+					t.Errorf("err = %v, want %q", err, tt.wantMsg)
+				}
+			case tt.wantErr != nil:
+				if !errors.Is(err, tt.wantErr) {
+					t.Errorf("err = %v, want %v", err, tt.wantErr)
+				}
+			case err != nil:
+				t.Errorf("err = %v, want nil", err)
+			}
+		})
+	}
+}
+
+// loginStep 锁定测试中的一次登录，wrong 为true时使用错误密码，wait 为登录前等待的时间
+type loginStep struct {
+	wrong bool
+	wait  time.Duration
+}
+
+func TestLoginLockoutSlidingWindow(t *testing.T) {
+	const window = 150 * time.Millisecond
+
+	tests := []struct {
+		name       string
+		username   string
+		steps      []loginStep
+		wantLocked bool
+	}{
+		{
+			name:       "窗口内达到阈值后锁定",
+			steps:      []loginStep{{wrong: true}, {wrong: true}, {wrong: true}},
+			wantLocked: true,
+		},
+		{
+			name:       "不存在的用户名同样锁定",
+			username:   "nobody",
+			steps:      []loginStep{{wrong: true}, {wrong: true}, {wrong: true}},
+			wantLocked: true,
+		},
+		{
+			name:  "窗口外的失败不计入",
+			steps: []loginStep{{wrong: true}, {wrong: true}, {wrong: true, wait: 2 * window}},
+		},
+		{
+			name:  "登录成功后清除失败记录",
+			steps: []loginStep{{wrong: true}, {wrong: true}, {}, {wrong: true}, {wrong: true}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := newTestEnv(t)
+			env.createUser(t, "alice", testPassword)
+			svc, _ := newTestAuthService(env)
+			svc.protection = LoginProtection{Threshold: 3, Window: window, Cooldown: time.Minute}
+			// 跳过bcrypt，使几次登录都落在窗口内
+			svc.comparePassword = func(_, password []byte) error {
+				if string(password) != testPassword {
+					return bcrypt.ErrMismatchedHashAndPassword
+				}
+				return nil
+			}
+
+			username := tt.username
+			if username == "" {
+				username = "alice"
+			}
+
+			ctx := context.Background()
+			for _, step := range tt.steps {
+				time.Sleep(step.wait)
+				password := testPassword
+				if step.wrong {
+					password = "wrong-password"
+				}
+				_, _ = svc.Login(ctx, &models.LoginRequest{Username: username, Password: password})
+			}
+
+			_, err := svc.Login(ctx, &models.LoginRequest{Username: username, Password: testPassword})
+			if locked := err != nil && err.Error() == "账户已锁定"; locked != tt.wantLocked {
+				t.Errorf("locked = %v (err %v), want %v", locked, err, tt.wantLocked)
+			}
+		})
+	}
+}
@@ -0,0 +1,24 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/binary-1024/go-build-test/internal/cache"
+	"github.com/binary-1024/go-build-test/internal/logger"
+)
+
+// getOrLoad 旁路缓存读取：命中时直接返回，未命中时通过loader加载并写入缓存
+// Redis出错时记录警告后同样回退到loader，避免缓存故障被静默吞掉。是否命中通过 cache.RecordStatus 记录到 ctx
+func getOrLoad(ctx context.Context, c *cache.RedisClient, log logger.Logger, key string, dest interface{}, ttl time.Duration, loader func(ctx context.Context) (interface{}, error)) error {
+	err := c.Get(ctx, key, dest)
+	cache.RecordStatus(ctx, err == nil)
+	if err == nil {
+		return nil
+	}
+	if !errors.Is(err, cache.ErrCacheMiss) {
+		log.Warn("读取缓存失败，回退到数据库", "key", key, "error", err)
+	}
+	return c.Load(ctx, key, dest, ttl, loader)
+}
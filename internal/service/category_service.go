@@ -0,0 +1,140 @@
+package service
+
+import (
+	"context"
+	"errors"
+
+	"github.com/binary-1024/go-build-test/internal/logger"
+	"github.com/binary-1024/go-build-test/internal/models"
+	"github.com/binary-1024/go-build-test/internal/repository"
+
+	"gorm.io/gorm"
+)
+
+// ErrCategoryExists 分类名称已被其他分类使用
+var ErrCategoryExists = errors.New("分类已存在")
+
+// CategoryService 分类服务接口
+type CategoryService interface {
+	CreateCategory(ctx context.Context, req *models.CreateCategoryRequest) (*models.Category, error)
+	GetCategory(ctx context.Context, id uint) (*models.Category, error)
+	UpdateCategory(ctx context.Context, id uint, req *models.UpdateCategoryRequest) (*models.Category, error)
+	DeleteCategory(ctx context.Context, id uint) error
+	ListCategories(ctx context.Context) ([]*models.Category, error)
+}
+
+// categoryService 分类服务实现
+type categoryService struct {
+	repo     repository.CategoryRepository
+	products ProductService
+	logger   logger.Logger
+}
+
+// NewCategoryService 创建分类服务
+// products 用于在分类变更后使内嵌了分类信息的产品缓存失效
+func NewCategoryService(repo repository.CategoryRepository, products ProductService, logger logger.Logger) CategoryService {
+	return &categoryService{
+		repo:     repo,
+		products: products,
+		logger:   logger,
+	}
+}
+
+// CreateCategory 创建分类
+func (s *categoryService) CreateCategory(ctx context.Context, req *models.CreateCategoryRequest) (*models.Category, error) {
+	s.logger.Info("创建分类", "name", req.Name)
+
+	if err := s.checkNameAvailable(ctx, req.Name, 0); err != nil {
+		return nil, err
+	}
+
+	category := &models.Category{
+		Name:        req.Name,
+		Description: req.Description,
+	}
+	if err := s.repo.Create(ctx, category); err != nil {
+		if repository.IsUniqueViolation(err, "name") {
+			return nil, ErrCategoryExists
+		}
+		s.logger.Error("创建分类失败", "error", err)
+		return nil, err
+	}
+
+	s.logger.Info("分类创建成功", "category_id", category.ID)
+	return category, nil
+}
+
+// GetCategory 获取分类
+func (s *categoryService) GetCategory(ctx context.Context, id uint) (*models.Category, error) {
+	return s.repo.GetByID(ctx, id)
+}
+
+// UpdateCategory 更新分类，同时使该分类下的产品缓存和产品列表缓存失效
+func (s *categoryService) UpdateCategory(ctx context.Context, id uint, req *models.UpdateCategoryRequest) (*models.Category, error) {
+	log := s.logger.With("category_id", id)
+	log.Info("更新分类")
+
+	if _, err := s.repo.GetByID(ctx, id); err != nil {
+		log.Error("分类不存在", "error", err)
+		return nil, err
+	}
+
+	updates := make(map[string]interface{})
+	if req.Name != "" {
+		if err := s.checkNameAvailable(ctx, req.Name, id); err != nil {
+			return nil, err
+		}
+		updates["name"] = req.Name
+	}
+	if req.Description != "" {
+		updates["description"] = req.Description
+	}
+
+	if err := s.repo.Update(ctx, id, updates); err != nil {
+		if repository.IsUniqueViolation(err, "name") {
+			return nil, ErrCategoryExists
+		}
+		log.Error("更新分类失败", "error", err)
+		return nil, err
+	}
+
+	// 分类已经更新，缓存失效失败只会让产品在缓存过期前显示旧的分类
+	_ = s.products.InvalidateCategory(ctx, id)
+
+	return s.repo.GetByID(ctx, id)
+}
+
+// DeleteCategory 删除分类，分类下仍有产品时拒绝删除
+func (s *categoryService) DeleteCategory(ctx context.Context, id uint) error {
+	log := s.logger.With("category_id", id)
+	log.Info("删除分类")
+
+	if err := s.repo.Delete(ctx, id); err != nil {
+		log.Warn("删除分类失败", "error", err)
+		return err
+	}
+	return nil
+}
+
+// ListCategories 获取全部分类
+func (s *categoryService) ListCategories(ctx context.Context) ([]*models.Category, error) {
+	categories, err := s.repo.List(ctx)
+	if err != nil {
+		s.logger.Error("获取分类列表失败", "error", err)
+		return nil, err
+	}
+	return categories, nil
+}
+
+// checkNameAvailable 检查分类名称是否已被其他分类使用
+func (s *categoryService) checkNameAvailable(ctx context.Context, name string, selfID uint) error {
+	existing, err := s.repo.GetByName(ctx, name)
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		s.logger.Error("检查分类名称失败", "error", err)
+		return err
+	}
+	if existing != nil && existing.ID != selfID {
+		return ErrCategoryExists
+	}
+	return nil
+}
@@ -0,0 +1,100 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/binary-1024/go-build-test/internal/models"
+	"github.com/binary-1024/go-build-test/internal/repository"
+)
+
+func TestCategoryServiceNameConflicts(t *testing.T) {
+	tests := []struct {
+		name    string
+		run     func(ctx context.Context, svc CategoryService, existing *models.Category) error
+		wantErr error
+	}{
+		{
+			name: "创建重名分类",
+			run: func(ctx context.Context, svc CategoryService, existing *models.Category) error {
+				_, err := svc.CreateCategory(ctx, &models.CreateCategoryRequest{Name: existing.Name})
+				return err
+			},
+			wantErr: ErrCategoryExists,
+		},
+		{
+			name: "改为其他分类的名称",
+			run: func(ctx context.Context, svc CategoryService, existing *models.Category) error {
+				other, err := svc.CreateCategory(ctx, &models.CreateCategoryRequest{Name: "图书"})
+				if err != nil {
+					return err
+				}
+				_, err = svc.UpdateCategory(ctx, other.ID, &models.UpdateCategoryRequest{Name: existing.Name})
+				return err
+			},
+			wantErr: ErrCategoryExists,
+		},
+		{
+			name: "保留自己的名称",
+			run: func(ctx context.Context, svc CategoryService, existing *models.Category) error {
+				_, err := svc.UpdateCategory(ctx, existing.ID, &models.UpdateCategoryRequest{Name: existing.Name, Description: "updated"})
+				return err
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := newTestEnv(t)
+			products := NewProductService(repository.NewProductRepository(env.db), repository.NewCategoryRepository(env.db), env.cache, time.Minute, PricePolicy{}, env.logger)
+			svc := NewCategoryService(repository.NewCategoryRepository(env.db), products, env.logger)
+			ctx := context.Background()
+
+			existing, err := svc.CreateCategory(ctx, &models.CreateCategoryRequest{Name: "书籍"})
+			if err != nil {
+				t.Fatalf("CreateCategory: %v", err)
+			}
+			if err := tt.run(ctx, svc, existing); !errors.Is(err, tt.wantErr) {
+				t.Errorf("err = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestUpdateCategoryInvalidatesProductCaches(t *testing.T) {
+	env := newTestEnv(t)
+	product := env.createProduct(t, "widget", 10)
+	products := NewProductService(repository.NewProductRepository(env.db), repository.NewCategoryRepository(env.db), env.cache, time.Minute, PricePolicy{}, env.logger)
+	svc := NewCategoryService(repository.NewCategoryRepository(env.db), products, env.logger)
+	ctx := context.Background()
+
+	// 读取一次，使产品和列表进入缓存
+	if _, err := products.GetProduct(ctx, product.ID); err != nil {
+		t.Fatalf("GetProduct: %v", err)
+	}
+	if _, err := products.ListProducts(ctx, &models.ProductQuery{Page: 1, Limit: 10}); err != nil {
+		t.Fatalf("ListProducts: %v", err)
+	}
+
+	if _, err := svc.UpdateCategory(ctx, *product.CategoryID, &models.UpdateCategoryRequest{Name: "renamed"}); err != nil {
+		t.Fatalf("UpdateCategory: %v", err)
+	}
+
+	got, err := products.GetProduct(ctx, product.ID)
+	if err != nil {
+		t.Fatalf("GetProduct: %v", err)
+	}
+	if got.Category == nil || got.Category.Name != "renamed" {
+		t.Errorf("cached product category = %+v, want renamed", got.Category)
+	}
+
+	list, err := products.ListProducts(ctx, &models.ProductQuery{Page: 1, Limit: 10})
+	if err != nil {
+		t.Fatalf("ListProducts: %v", err)
+	}
+	if len(list.Products) != 1 || list.Products[0].Category == nil || list.Products[0].Category.Name != "renamed" {
+		t.Errorf("cached list = %+v, want category renamed", list.Products)
+	}
+}
@@ -2,97 +2,334 @@ package service
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/binary-1024/go-build-test/internal/cache"
 	"github.com/binary-1024/go-build-test/internal/logger"
 	"github.com/binary-1024/go-build-test/internal/models"
 	"github.com/binary-1024/go-build-test/internal/repository"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
 )
 
 // ProductService 产品服务接口
 type ProductService interface {
-	CreateProduct(req *models.CreateProductRequest) (*models.Product, error)
-	GetProduct(id uint) (*models.Product, error)
-	UpdateProduct(id uint, req *models.UpdateProductRequest) (*models.Product, error)
-	DeleteProduct(id uint) error
-	ListProducts(query *models.ProductQuery) (*models.ProductListResponse, error)
+	CreateProduct(ctx context.Context, req *models.CreateProductRequest) (*models.Product, error)
+	GetProduct(ctx context.Context, id uint) (*models.Product, error)
+	GetProductsByIDs(ctx context.Context, ids []uint) (map[uint]*models.Product, error)
+	GetProductViews(ctx context.Context, id uint) (*models.ProductViewsResponse, error)
+	UpdateProduct(ctx context.Context, id uint, req *models.UpdateProductRequest) (*models.Product, error)
+	DeleteProduct(ctx context.Context, id uint) error
+	ListProducts(ctx context.Context, query *models.ProductQuery) (*models.ProductListResponse, error)
+	ListProductsByCursor(ctx context.Context, query *models.ProductQuery) (*models.ProductCursorListResponse, error)
+	DecrementStock(ctx context.Context, id uint, qty int) error
+	ReserveStock(ctx context.Context, productID uint, qty int, ttl time.Duration) (string, error)
+	ConfirmReservation(ctx context.Context, reservationID string) error
+	CancelReservation(ctx context.Context, reservationID string) error
+	SweepExpiredReservations(ctx context.Context) (int, error)
+	GetStock(ctx context.Context, id uint) (*models.StockEvent, error)
+	StreamProducts(ctx context.Context, query *models.ProductQuery, fn func(*models.Product) error) error
+	AddImage(ctx context.Context, id uint, req *models.AddProductImageRequest) (*models.ProductImage, error)
+	RemoveImage(ctx context.Context, id, imageID uint) error
+	ImportProducts(ctx context.Context, r io.Reader, maxRows int) (*models.ProductImportResponse, error)
+	PurgeDeleted(ctx context.Context, before time.Time) (int64, error)
+	WarmCache(ctx context.Context, count int) (int, error)
+	InvalidateCategory(ctx context.Context, categoryID uint) error
+}
+
+// productImportColumns CSV导入必须包含的列，表头不区分大小写、顺序不限
+var productImportColumns = []string{"name", "description", "price", "stock", "category"}
+
+// ProductCacheKey 产品缓存键
+func ProductCacheKey(id uint) string {
+	return fmt.Sprintf("product:%d", id)
+}
+
+// 产品列表缓存
+// 列表缓存以较短的过期时间换取数据库负载的降低：产品的增删改会递增列表缓存代数，使所有列表缓存立即失效，
+// 但不经过产品服务的变更（如直接修改数据库）最多在 productListTTL 内不可见
+const (
+	productListGenerationKey = "product:list:gen"
+	productListTTL           = 30 * time.Second
+)
+
+// productListCacheKey 根据缓存代数和规范化后的查询参数生成列表缓存键，语义相同的查询命中同一个键
+func productListCacheKey(generation int64, query *models.ProductQuery) string {
+	categories := query.CategoryList()
+	sort.Strings(categories)
+
+	normalized, _ := json.Marshal(struct {
+		Page       int
+		Limit      int
+		Category   string
+		Categories []string
+		CategoryID uint
+		MinPrice   float64
+		MaxPrice   float64
+		Search     string
+		Order      string
+		IsActive   *bool
+		InStock    *bool
+	}{
+		Page:       query.Page,
+		Limit:      query.Limit,
+		Category:   query.Category,
+		Categories: categories,
+		CategoryID: query.CategoryID,
+		MinPrice:   query.MinPrice,
+		MaxPrice:   query.MaxPrice,
+		Search:     query.Search,
+		Order:      query.OrderClause(),
+		IsActive:   query.IsActive,
+		InStock:    query.InStock,
+	})
+
+	sum := sha256.Sum256(normalized)
+	return fmt.Sprintf("product:list:%d:%s", generation, hex.EncodeToString(sum[:]))
+}
+
+// productListCachePattern 匹配某一代全部产品列表缓存的模式
+func productListCachePattern(generation int64) string {
+	return fmt.Sprintf("product:list:%d:*", generation)
+}
+
+// StockReservationKey 库存预留的键
+func StockReservationKey(id string) string {
+	return "stock_reservation:" + id
+}
+
+// 库存预留
+// 预留记录保存在 stock_reservation:<id>，同时以到期时间加入延迟队列，到期后由后台清理归还库存。
+// 预留记录的过期时间比预留本身多 stockReservationGrace，保证清理任务在到期后仍能读到预留的数量；
+// 归还失败的预留在 stockReservationRetryDelay 后重新清理，预留记录只在库存归还成功后删除
+const (
+	stockReservationQueue      = "stock_reservations"
+	stockReservationGrace      = time.Hour
+	stockReservationRetryDelay = time.Minute
+	stockReservationSweepBatch = 100
+)
+
+var (
+	// ErrReservationNotFound 预留不存在、已到期或已被确认/取消
+	ErrReservationNotFound = errors.New("库存预留不存在或已过期")
+	// ErrReservationUnavailable Redis不可用，无法记录预留；此时拒绝预留，避免扣减的库存无法归还
+	ErrReservationUnavailable = errors.New("库存预留暂不可用，请稍后重试")
+)
+
+// stockReservation Redis中保存的库存预留
+type stockReservation struct {
+	ProductID uint      `json:"product_id"`
+	Quantity  int       `json:"quantity"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// cachedProductList 缓存的产品列表分页
+type cachedProductList struct {
+	Products []*models.Product `json:"products"`
+	Total    int64             `json:"total"`
+}
+
+// ProductViewsKey 产品浏览次数计数器的键
+func ProductViewsKey(id uint) string {
+	return fmt.Sprintf("product:views:%d", id)
+}
+
+// PricePolicy 产品价格区间策略，0 表示不限制
+type PricePolicy struct {
+	Min float64
+	Max float64
+}
+
+// Check 检查价格是否在允许区间内
+func (p PricePolicy) Check(price float64) error {
+	if p.Min > 0 && price < p.Min {
+		return fmt.Errorf("产品价格不能低于%.2f", p.Min)
+	}
+	if p.Max > 0 && price > p.Max {
+		return fmt.Errorf("产品价格不能高于%.2f", p.Max)
+	}
+	return nil
 }
 
 // productService 产品服务实现
 type productService struct {
-	repo   repository.ProductRepository
-	cache  *cache.RedisClient
-	logger logger.Logger
+	repo        repository.ProductRepository
+	categories  repository.CategoryRepository
+	cache       *cache.RedisClient
+	cacheTTL    time.Duration
+	pricePolicy PricePolicy
+	logger      logger.Logger
 }
 
 // NewProductService 创建产品服务
-func NewProductService(repo repository.ProductRepository, cache *cache.RedisClient, logger logger.Logger) ProductService {
+// cacheTTL 为单个产品的缓存时间
+func NewProductService(repo repository.ProductRepository, categories repository.CategoryRepository, cache *cache.RedisClient, cacheTTL time.Duration, pricePolicy PricePolicy, logger logger.Logger) ProductService {
 	return &productService{
-		repo:   repo,
-		cache:  cache,
-		logger: logger,
+		repo:        repo,
+		categories:  categories,
+		cache:       cache,
+		cacheTTL:    cacheTTL,
+		pricePolicy: pricePolicy,
+		logger:      logger,
 	}
 }
 
 // CreateProduct 创建产品
-func (s *productService) CreateProduct(req *models.CreateProductRequest) (*models.Product, error) {
+func (s *productService) CreateProduct(ctx context.Context, req *models.CreateProductRequest) (*models.Product, error) {
 	s.logger.Info("创建产品", "name", req.Name)
 
+	if err := s.pricePolicy.Check(req.Price); err != nil {
+		s.logger.Warn("产品价格超出允许范围", "name", req.Name, "price", req.Price)
+		return nil, err
+	}
+
+	category, err := s.checkCategory(ctx, req.CategoryID)
+	if err != nil {
+		return nil, err
+	}
+
 	product := &models.Product{
 		Name:        req.Name,
 		Description: req.Description,
 		Price:       req.Price,
 		Stock:       req.Stock,
-		Category:    req.Category,
+		CategoryID:  &category.ID,
+		Category:    category,
 		IsActive:    true,
+		CreatedBy:   actorID(ctx),
+		UpdatedBy:   actorID(ctx),
 	}
 
-	if err := s.repo.Create(product); err != nil {
+	if err := s.repo.Create(ctx, product); err != nil {
 		s.logger.Error("创建产品失败", "error", err)
 		return nil, err
 	}
 
+	s.invalidateProductLists(ctx)
+
 	s.logger.Info("产品创建成功", "product_id", product.ID)
 	return product, nil
 }
 
 // GetProduct 获取产品
-func (s *productService) GetProduct(id uint) (*models.Product, error) {
-	// 先从缓存获取
-	cacheKey := fmt.Sprintf("product:%d", id)
-	var cachedProduct models.Product
+func (s *productService) GetProduct(ctx context.Context, id uint) (*models.Product, error) {
+	cacheKey := ProductCacheKey(id)
+	var product models.Product
 
-	ctx := context.Background()
-	if err := s.cache.Get(ctx, cacheKey, &cachedProduct); err == nil {
-		s.logger.Debug("从缓存获取产品", "product_id", id)
-		return &cachedProduct, nil
+	err := getOrLoad(ctx, s.cache, s.logger, cacheKey, &product, s.cacheTTL, func(ctx context.Context) (interface{}, error) {
+		return s.repo.GetByID(ctx, id)
+	})
+	if err != nil {
+		s.logger.Error("获取产品失败", "product_id", id, "error", err)
+		return nil, err
 	}
 
-	// 从数据库获取
-	product, err := s.repo.GetByID(id)
+	// 浏览计数只记在Redis中，计数失败不影响获取产品
+	if _, err := s.cache.Increment(ctx, ProductViewsKey(id), 1); err != nil {
+		s.logger.Warn("记录产品浏览次数失败", "product_id", id, "error", err)
+	}
+
+	return &product, nil
+}
+
+// GetProductsByIDs 批量获取产品，返回以ID为键的映射，不存在的产品不在结果中
+// 先一次读取全部缓存，未命中的产品再一次查询数据库并回填缓存；不计入浏览次数
+func (s *productService) GetProductsByIDs(ctx context.Context, ids []uint) (map[uint]*models.Product, error) {
+	products := make(map[uint]*models.Product, len(ids))
+
+	unique := make([]uint, 0, len(ids))
+	keys := make([]string, 0, len(ids))
+	seen := make(map[uint]bool, len(ids))
+	for _, id := range ids {
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+		unique = append(unique, id)
+		keys = append(keys, ProductCacheKey(id))
+	}
+	if len(unique) == 0 {
+		return products, nil
+	}
+
+	var missing []uint
+	cached, err := s.cache.MGet(ctx, keys)
+	cacheAvailable := err == nil
 	if err != nil {
-		s.logger.Error("获取产品失败", "product_id", id, "error", err)
+		s.logger.Warn("批量读取产品缓存失败，回退到数据库", "error", err)
+		missing = unique
+	}
+	for i, data := range cached {
+		if data == nil {
+			missing = append(missing, unique[i])
+			continue
+		}
+		var product models.Product
+		if err := json.Unmarshal(data, &product); err != nil {
+			s.logger.Warn("解析产品缓存失败", "product_id", unique[i], "error", err)
+			missing = append(missing, unique[i])
+			continue
+		}
+		products[unique[i]] = &product
+	}
+	if len(missing) == 0 {
+		return products, nil
+	}
+
+	loaded, err := s.repo.GetByIDs(ctx, missing)
+	if err != nil {
+		s.logger.Error("批量获取产品失败", "count", len(missing), "error", err)
 		return nil, err
 	}
+	values := make(map[string]interface{}, len(loaded))
+	for _, product := range loaded {
+		products[product.ID] = product
+		values[ProductCacheKey(product.ID)] = product
+	}
+	if cacheAvailable {
+		if err := s.cache.SetMany(ctx, values, s.cacheTTL); err != nil {
+			s.logger.Warn("回填产品缓存失败", "error", err)
+		}
+	}
+
+	return products, nil
+}
 
-	// 缓存产品信息
-	if err := s.cache.Set(ctx, cacheKey, product, 10*time.Minute); err != nil {
-		s.logger.Warn("缓存产品信息失败", "product_id", id, "error", err)
+// GetProductViews 获取产品的累计浏览次数
+func (s *productService) GetProductViews(ctx context.Context, id uint) (*models.ProductViewsResponse, error) {
+	if _, err := s.repo.GetByID(ctx, id); err != nil {
+		return nil, err
 	}
 
-	return product, nil
+	views, err := s.cache.GetInt(ctx, ProductViewsKey(id))
+	if err != nil {
+		s.logger.Error("获取产品浏览次数失败", "product_id", id, "error", err)
+		return nil, err
+	}
+
+	return &models.ProductViewsResponse{ProductID: id, Views: views}, nil
 }
 
 // UpdateProduct 更新产品
-func (s *productService) UpdateProduct(id uint, req *models.UpdateProductRequest) (*models.Product, error) {
-	s.logger.Info("更新产品", "product_id", id)
+func (s *productService) UpdateProduct(ctx context.Context, id uint, req *models.UpdateProductRequest) (*models.Product, error) {
+	log := s.logger.With("product_id", id)
+	log.Info("更新产品")
 
 	// 检查产品是否存在
-	_, err := s.repo.GetByID(id)
+	_, err := s.repo.GetByID(ctx, id)
 	if err != nil {
-		s.logger.Error("产品不存在", "product_id", id, "error", err)
+		log.Error("产品不存在", "error", err)
 		return nil, err
 	}
 
@@ -105,66 +342,636 @@ func (s *productService) UpdateProduct(id uint, req *models.UpdateProductRequest
 		updates["description"] = req.Description
 	}
 	if req.Price != nil {
+		if err := s.pricePolicy.Check(*req.Price); err != nil {
+			log.Warn("产品价格超出允许范围", "price", *req.Price)
+			return nil, err
+		}
 		updates["price"] = *req.Price
 	}
 	if req.Stock != nil {
 		updates["stock"] = *req.Stock
 	}
-	if req.Category != "" {
-		updates["category"] = req.Category
+	if req.CategoryID != nil {
+		if _, err := s.checkCategory(ctx, *req.CategoryID); err != nil {
+			return nil, err
+		}
+		updates["category_id"] = *req.CategoryID
 	}
 	if req.IsActive != nil {
 		updates["is_active"] = *req.IsActive
 	}
 
+	version := 0
+	if req.Version != nil {
+		version = *req.Version
+	}
+
 	// 更新产品
-	if err := s.repo.Update(id, updates); err != nil {
-		s.logger.Error("更新产品失败", "product_id", id, "error", err)
+	if err := s.repo.Update(ctx, id, version, withUpdatedBy(ctx, updates)); err != nil {
+		if errors.Is(err, repository.ErrConflict) {
+			log.Warn("产品版本冲突", "version", version)
+			return nil, err
+		}
+		log.Error("更新产品失败", "error", err)
 		return nil, err
 	}
 
 	// 删除缓存
-	cacheKey := fmt.Sprintf("product:%d", id)
-	ctx := context.Background()
-	if err := s.cache.Delete(ctx, cacheKey); err != nil {
-		s.logger.Warn("删除产品缓存失败", "product_id", id, "error", err)
-	}
+	s.invalidateProduct(ctx, id)
 
 	// 返回更新后的产品
-	return s.repo.GetByID(id)
+	product, err := s.repo.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if req.Stock != nil {
+		s.publishStock(ctx, product)
+	}
+	return product, nil
 }
 
 // DeleteProduct 删除产品
-func (s *productService) DeleteProduct(id uint) error {
-	s.logger.Info("删除产品", "product_id", id)
+func (s *productService) DeleteProduct(ctx context.Context, id uint) error {
+	log := s.logger.With("product_id", id)
+	log.Info("删除产品")
 
-	if err := s.repo.Delete(id); err != nil {
-		s.logger.Error("删除产品失败", "product_id", id, "error", err)
+	if err := s.repo.Delete(ctx, id); err != nil {
+		log.Error("删除产品失败", "error", err)
 		return err
 	}
 
 	// 删除缓存
-	cacheKey := fmt.Sprintf("product:%d", id)
-	ctx := context.Background()
-	if err := s.cache.Delete(ctx, cacheKey); err != nil {
-		s.logger.Warn("删除产品缓存失败", "product_id", id, "error", err)
+	s.invalidateProduct(ctx, id)
+
+	return nil
+}
+
+// DecrementStock 扣减产品库存
+func (s *productService) DecrementStock(ctx context.Context, id uint, qty int) error {
+	log := s.logger.With("product_id", id)
+	log.Info("扣减库存", "quantity", qty)
+
+	// 检查产品是否存在
+	if _, err := s.repo.GetByID(ctx, id); err != nil {
+		log.Error("产品不存在", "error", err)
+		return err
+	}
+
+	if err := s.repo.DecrementStock(ctx, id, qty); err != nil {
+		log.Warn("扣减库存失败", "quantity", qty, "error", err)
+		return err
+	}
+
+	s.stockChanged(ctx, id)
+	return nil
+}
+
+// ReserveStock 预留库存：立即扣减库存并在Redis中记录预留，返回预留ID
+// 预留在ttl内未确认或取消时，由 SweepExpiredReservations 归还库存
+func (s *productService) ReserveStock(ctx context.Context, productID uint, qty int, ttl time.Duration) (string, error) {
+	log := s.logger.With("product_id", productID)
+
+	// 检查产品是否存在
+	if _, err := s.repo.GetByID(ctx, productID); err != nil {
+		log.Error("产品不存在", "error", err)
+		return "", err
+	}
+
+	// 预留依赖Redis记录和到期归还，扣减库存之前确认Redis可用
+	if err := s.cache.Ping(ctx); err != nil {
+		log.Error("Redis不可用，拒绝预留库存", "error", err)
+		return "", ErrReservationUnavailable
+	}
+
+	if err := s.repo.DecrementStock(ctx, productID, qty); err != nil {
+		log.Warn("预留库存失败", "quantity", qty, "error", err)
+		return "", err
+	}
+
+	// 库存已经扣减，后续步骤不受请求取消影响，失败时归还库存
+	bg := context.WithoutCancel(ctx)
+	id := uuid.NewString()
+	reservation := &stockReservation{
+		ProductID: productID,
+		Quantity:  qty,
+		ExpiresAt: time.Now().Add(ttl),
+	}
+	err := s.cache.Set(bg, StockReservationKey(id), reservation, ttl+stockReservationGrace)
+	if err == nil {
+		err = s.cache.Schedule(bg, stockReservationQueue, id, reservation.ExpiresAt)
+	}
+	if err != nil {
+		log.Error("记录库存预留失败，归还库存", "quantity", qty, "error", err)
+		_ = s.cache.Delete(bg, StockReservationKey(id))
+		_ = s.releaseReservation(bg, id, reservation)
+		return "", ErrReservationUnavailable
+	}
+
+	s.stockChanged(bg, productID)
+	log.Info("预留库存成功", "reservation_id", id, "quantity", qty, "ttl", ttl)
+	return id, nil
+}
+
+// ConfirmReservation 确认预留，库存不再归还；预留不存在或已到期时返回 ErrReservationNotFound
+func (s *productService) ConfirmReservation(ctx context.Context, reservationID string) error {
+	reservation, err := s.claimReservation(ctx, reservationID)
+	if err != nil {
+		return err
+	}
+	_ = s.cache.Delete(context.WithoutCancel(ctx), StockReservationKey(reservationID))
+	s.logger.Info("确认库存预留", "reservation_id", reservationID, "product_id", reservation.ProductID, "quantity", reservation.Quantity)
+	return nil
+}
+
+// CancelReservation 取消预留并归还库存；预留不存在或已到期时返回 ErrReservationNotFound
+// 归还失败时预留重新放回到期队列，可以重试取消，否则到期后由清理任务归还
+func (s *productService) CancelReservation(ctx context.Context, reservationID string) error {
+	reservation, err := s.claimReservation(ctx, reservationID)
+	if err != nil {
+		return err
+	}
+	bg := context.WithoutCancel(ctx)
+	if err := s.releaseReservation(bg, reservationID, reservation); err != nil {
+		s.requeueReservation(bg, reservationID, reservation, reservation.ExpiresAt)
+		return err
+	}
+	_ = s.cache.Delete(bg, StockReservationKey(reservationID))
+	s.logger.Info("取消库存预留", "reservation_id", reservationID, "product_id", reservation.ProductID, "quantity", reservation.Quantity)
+	return nil
+}
+
+// SweepExpiredReservations 归还已到期且未确认的预留库存，返回归还的预留数
+// 多个实例可以同时清理，每个预留只会被其中一个实例处理；归还失败的预留稍后重试
+func (s *productService) SweepExpiredReservations(ctx context.Context) (int, error) {
+	ids, err := s.cache.Due(ctx, stockReservationQueue, time.Now(), stockReservationSweepBatch)
+	if err != nil {
+		return 0, err
+	}
+
+	released := 0
+	for _, id := range ids {
+		if ctx.Err() != nil {
+			break
+		}
+		claimed, err := s.cache.Unschedule(ctx, stockReservationQueue, id)
+		if err != nil {
+			return released, err
+		}
+		if !claimed {
+			// 已被确认、取消或由其他实例处理
+			continue
+		}
+
+		// 已经移出队列，后续步骤不受停止清理影响，否则预留既不在队列中也没有归还
+		bg := context.WithoutCancel(ctx)
+		var reservation stockReservation
+		if err := s.cache.Get(bg, StockReservationKey(id), &reservation); err != nil {
+			if errors.Is(err, cache.ErrCacheMiss) {
+				s.logger.Error("到期的库存预留记录已不存在，库存未归还", "reservation_id", id)
+				continue
+			}
+			s.logger.Error("读取到期的库存预留失败，稍后重试", "reservation_id", id, "error", err)
+			_ = s.cache.Schedule(bg, stockReservationQueue, id, time.Now().Add(stockReservationRetryDelay))
+			continue
+		}
+
+		if err := s.releaseReservation(bg, id, &reservation); err != nil {
+			s.requeueReservation(bg, id, &reservation, time.Now().Add(stockReservationRetryDelay))
+			continue
+		}
+		_ = s.cache.Delete(bg, StockReservationKey(id))
+		s.logger.Info("库存预留已到期，归还库存", "reservation_id", id, "product_id", reservation.ProductID, "quantity", reservation.Quantity)
+		released++
+	}
+	return released, nil
+}
+
+// claimReservation 读取未到期的预留并将其移出到期队列
+// 移出队列是确认、取消和到期清理之间唯一的互斥点，只有成功移出的一方可以处理该预留；
+// 预留记录由调用方在处理完成后删除
+func (s *productService) claimReservation(ctx context.Context, id string) (*stockReservation, error) {
+	var reservation stockReservation
+	if err := s.cache.Get(ctx, StockReservationKey(id), &reservation); err != nil {
+		if errors.Is(err, cache.ErrCacheMiss) {
+			return nil, ErrReservationNotFound
+		}
+		s.logger.Error("读取库存预留失败", "reservation_id", id, "error", err)
+		return nil, err
+	}
+	if !time.Now().Before(reservation.ExpiresAt) {
+		return nil, ErrReservationNotFound
+	}
+
+	claimed, err := s.cache.Unschedule(ctx, stockReservationQueue, id)
+	if err != nil {
+		s.logger.Error("认领库存预留失败", "reservation_id", id, "error", err)
+		return nil, err
 	}
+	if !claimed {
+		return nil, ErrReservationNotFound
+	}
+	return &reservation, nil
+}
 
+// requeueReservation 归还失败后将预留重新放回到期队列，at 之后由清理任务重试
+// 同时延长预留记录的过期时间，保证重试时仍能读到预留的数量
+func (s *productService) requeueReservation(ctx context.Context, id string, reservation *stockReservation, at time.Time) {
+	err := s.cache.Set(ctx, StockReservationKey(id), reservation, time.Until(at)+stockReservationGrace)
+	if err == nil {
+		err = s.cache.Schedule(ctx, stockReservationQueue, id, at)
+	}
+	if err != nil {
+		s.logger.Error("库存预留重新入队失败，库存未归还", "reservation_id", id, "product_id", reservation.ProductID, "quantity", reservation.Quantity, "error", err)
+	}
+}
+
+// releaseReservation 归还预留的库存
+func (s *productService) releaseReservation(ctx context.Context, id string, reservation *stockReservation) error {
+	if err := s.repo.IncrementStock(ctx, reservation.ProductID, reservation.Quantity); err != nil {
+		s.logger.Error("归还预留库存失败", "reservation_id", id, "product_id", reservation.ProductID, "quantity", reservation.Quantity, "error", err)
+		return err
+	}
+	s.stockChanged(ctx, reservation.ProductID)
 	return nil
 }
 
-// ListProducts 获取产品列表
-func (s *productService) ListProducts(query *models.ProductQuery) (*models.ProductListResponse, error) {
-	products, total, err := s.repo.List(query)
+// stockChanged 库存变化后清除缓存并广播最新库存
+func (s *productService) stockChanged(ctx context.Context, id uint) {
+	s.invalidateProduct(ctx, id)
+
+	// 库存已经修改，读取最新库存失败只影响实时推送
+	if product, err := s.repo.GetByID(ctx, id); err != nil {
+		s.logger.Warn("读取最新库存失败", "product_id", id, "error", err)
+	} else {
+		s.publishStock(ctx, product)
+	}
+}
+
+// GetStock 获取产品当前库存，直接读取数据库，不计入浏览次数
+func (s *productService) GetStock(ctx context.Context, id uint) (*models.StockEvent, error) {
+	product, err := s.repo.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return stockEvent(product), nil
+}
+
+// publishStock 广播产品的最新库存，发布失败只记录警告
+func (s *productService) publishStock(ctx context.Context, product *models.Product) {
+	if err := s.cache.Publish(context.WithoutCancel(ctx), StockChannel, stockEvent(product)); err != nil {
+		s.logger.Warn("发布库存变化事件失败", "product_id", product.ID, "error", err)
+	}
+}
+
+func stockEvent(product *models.Product) *models.StockEvent {
+	return &models.StockEvent{
+		ProductID: product.ID,
+		Stock:     product.Stock,
+		UpdatedAt: models.JSONTime(product.UpdatedAt),
+	}
+}
+
+// ListProducts 获取产品列表，结果会短暂缓存
+func (s *productService) ListProducts(ctx context.Context, query *models.ProductQuery) (*models.ProductListResponse, error) {
+	var generation int64
+	if err := s.cache.Get(ctx, productListGenerationKey, &generation); err != nil {
+		if !errors.Is(err, cache.ErrCacheMiss) {
+			s.logger.Warn("读取产品列表缓存代数失败", "error", err)
+		}
+		generation = 0
+	}
+
+	var list cachedProductList
+	err := getOrLoad(ctx, s.cache, s.logger, productListCacheKey(generation, query), &list, productListTTL, func(ctx context.Context) (interface{}, error) {
+		products, total, err := s.repo.List(ctx, query)
+		if err != nil {
+			return nil, err
+		}
+		return &cachedProductList{Products: products, Total: total}, nil
+	})
 	if err != nil {
 		s.logger.Error("获取产品列表失败", "error", err)
 		return nil, err
 	}
 
 	return &models.ProductListResponse{
-		Products: make([]models.Product, len(products)),
-		Total:    total,
-		Page:     query.Page,
-		Limit:    query.Limit,
+		Products:   models.ToProductResponses(list.Products),
+		Pagination: models.NewPagination(list.Total, query.Page, query.Limit),
 	}, nil
 }
+
+// ListProductsByCursor 游标分页获取产品列表，不统计总数，结果不缓存
+func (s *productService) ListProductsByCursor(ctx context.Context, query *models.ProductQuery) (*models.ProductCursorListResponse, error) {
+	// 多取一条用于判断是否还有下一页
+	products, err := s.repo.ListByCursor(ctx, query, query.Limit+1)
+	if err != nil {
+		s.logger.Error("获取产品列表失败", "error", err)
+		return nil, err
+	}
+
+	resp := &models.ProductCursorListResponse{
+		CursorPagination: models.CursorPagination{Limit: query.Limit},
+	}
+	if len(products) > query.Limit {
+		products = products[:query.Limit]
+		last := products[len(products)-1]
+		resp.HasNext = true
+		resp.NextCursor = models.Cursor{CreatedAt: last.CreatedAt, ID: last.ID}.Encode()
+	}
+
+	resp.Products = models.ToProductResponses(products)
+	return resp, nil
+}
+
+// StreamProducts 逐个遍历符合条件的产品
+func (s *productService) StreamProducts(ctx context.Context, query *models.ProductQuery, fn func(*models.Product) error) error {
+	if err := s.repo.Stream(ctx, query, fn); err != nil {
+		s.logger.Error("遍历产品失败", "error", err)
+		return err
+	}
+	return nil
+}
+
+// AddImage 为产品添加图片
+func (s *productService) AddImage(ctx context.Context, id uint, req *models.AddProductImageRequest) (*models.ProductImage, error) {
+	log := s.logger.With("product_id", id)
+
+	if _, err := s.repo.GetByID(ctx, id); err != nil {
+		log.Error("产品不存在", "error", err)
+		return nil, err
+	}
+
+	image := &models.ProductImage{
+		ProductID: id,
+		URL:       req.URL,
+		SortOrder: req.SortOrder,
+	}
+	if err := s.repo.AddImage(ctx, image); err != nil {
+		log.Error("添加产品图片失败", "error", err)
+		return nil, err
+	}
+
+	s.invalidateProduct(ctx, id)
+
+	log.Info("产品图片添加成功", "image_id", image.ID)
+	return image, nil
+}
+
+// RemoveImage 删除产品图片
+func (s *productService) RemoveImage(ctx context.Context, id, imageID uint) error {
+	log := s.logger.With("product_id", id)
+
+	if err := s.repo.RemoveImage(ctx, id, imageID); err != nil {
+		log.Error("删除产品图片失败", "image_id", imageID, "error", err)
+		return err
+	}
+
+	s.invalidateProduct(ctx, id)
+
+	log.Info("产品图片删除成功", "image_id", imageID)
+	return nil
+}
+
+// ImportProducts 逐行解析CSV并导入产品，校验通过的行在同一事务中写入
+// 校验失败的行记录在返回的错误报告中；数据行超过 maxRows（>0 时）则整体拒绝
+func (s *productService) ImportProducts(ctx context.Context, r io.Reader, maxRows int) (*models.ProductImportResponse, error) {
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err == io.EOF {
+		return nil, fmt.Errorf("CSV文件为空")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("读取CSV表头失败: %w", err)
+	}
+	columns, err := parseImportHeader(header)
+	if err != nil {
+		return nil, err
+	}
+	reader.FieldsPerRecord = len(header)
+
+	resp := &models.ProductImportResponse{Errors: []models.ImportRowError{}}
+	categories := make(map[string]*models.Category)
+	var products []*models.Product
+
+	for rows := 1; ; rows++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if maxRows > 0 && rows > maxRows {
+			return nil, fmt.Errorf("CSV数据行不能超过%d行", maxRows)
+		}
+
+		var parseErr *csv.ParseError
+		if errors.As(err, &parseErr) {
+			resp.Errors = append(resp.Errors, models.ImportRowError{Line: parseErr.StartLine, Error: "CSV格式错误: " + parseErr.Err.Error()})
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		line, _ := reader.FieldPos(0)
+		product, err := s.parseImportRow(ctx, record, columns, categories)
+		if err != nil {
+			var rowErr *importRowError
+			if !errors.As(err, &rowErr) {
+				return nil, err
+			}
+			resp.Errors = append(resp.Errors, models.ImportRowError{Line: line, Error: rowErr.Error()})
+			continue
+		}
+		products = append(products, product)
+	}
+
+	if len(products) > 0 {
+		if err := s.repo.CreateBatch(ctx, products); err != nil {
+			s.logger.Error("导入产品失败", "error", err)
+			return nil, err
+		}
+		s.invalidateProductLists(ctx)
+	}
+	resp.Imported = len(products)
+
+	s.logger.Info("产品导入完成", "imported", resp.Imported, "failed", len(resp.Errors))
+	return resp, nil
+}
+
+// importRowError 单行数据校验失败，只跳过该行，不中断导入
+type importRowError struct {
+	msg string
+}
+
+func (e *importRowError) Error() string {
+	return e.msg
+}
+
+func rowErrorf(format string, args ...interface{}) error {
+	return &importRowError{msg: fmt.Sprintf(format, args...)}
+}
+
+// parseImportHeader 返回各列在记录中的位置，缺少必需列时返回错误
+func parseImportHeader(header []string) (map[string]int, error) {
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		// Excel 导出的UTF-8 CSV以BOM开头
+		name = strings.TrimPrefix(name, "\ufeff")
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+
+	var missing []string
+	for _, name := range productImportColumns {
+		if _, ok := columns[name]; !ok {
+			missing = append(missing, name)
+		}
+	}
+	if len(missing) > 0 {
+		return nil, fmt.Errorf("CSV缺少列: %s", strings.Join(missing, ", "))
+	}
+	return columns, nil
+}
+
+// parseImportRow 校验一行数据并构建产品，categories 缓存已查询过的分类（不存在的分类为nil）
+// 数据错误返回 *importRowError，查询分类失败等其他错误会中断导入
+func (s *productService) parseImportRow(ctx context.Context, record []string, columns map[string]int, categories map[string]*models.Category) (*models.Product, error) {
+	field := func(name string) string {
+		return strings.TrimSpace(record[columns[name]])
+	}
+
+	name := field("name")
+	if name == "" {
+		return nil, rowErrorf("产品名称不能为空")
+	}
+
+	price, err := strconv.ParseFloat(field("price"), 64)
+	if err != nil || price < 0 {
+		return nil, rowErrorf("价格必须是非负数字: %q", field("price"))
+	}
+	if err := s.pricePolicy.Check(price); err != nil {
+		return nil, rowErrorf("%s", err.Error())
+	}
+
+	stock := 0
+	if v := field("stock"); v != "" {
+		stock, err = strconv.Atoi(v)
+		if err != nil || stock < 0 {
+			return nil, rowErrorf("库存必须是非负整数: %q", v)
+		}
+	}
+
+	categoryName := field("category")
+	if categoryName == "" {
+		return nil, rowErrorf("分类不能为空")
+	}
+	category, ok := categories[categoryName]
+	if !ok {
+		category, err = s.categories.GetByName(ctx, categoryName)
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			s.logger.Error("获取分类失败", "category", categoryName, "error", err)
+			return nil, err
+		}
+		categories[categoryName] = category
+	}
+	if category == nil {
+		return nil, rowErrorf("分类不存在: %s", categoryName)
+	}
+
+	return &models.Product{
+		Name:        name,
+		Description: field("description"),
+		Price:       price,
+		Stock:       stock,
+		CategoryID:  &category.ID,
+		IsActive:    true,
+		CreatedBy:   actorID(ctx),
+		UpdatedBy:   actorID(ctx),
+	}, nil
+}
+
+// PurgeDeleted 彻底删除 before 之前软删除的产品，返回删除的数量
+func (s *productService) PurgeDeleted(ctx context.Context, before time.Time) (int64, error) {
+	count, err := s.repo.PurgeDeletedBefore(ctx, before, repository.DefaultBatchSize)
+	if err != nil {
+		s.logger.Error("清理已删除产品失败", "before", before, "purged", count, "error", err)
+		return count, err
+	}
+	s.logger.Info("清理已删除产品完成", "before", before, "purged", count)
+	return count, nil
+}
+
+// WarmCache 将最近更新的 count 个产品写入缓存，返回写入的数量
+// 写入失败说明Redis不可用，直接停止并返回已写入的数量
+func (s *productService) WarmCache(ctx context.Context, count int) (int, error) {
+	products, err := s.repo.ListRecentlyUpdated(ctx, count)
+	if err != nil {
+		s.logger.Error("读取预热产品失败", "error", err)
+		return 0, err
+	}
+
+	warmed := 0
+	for _, product := range products {
+		if err := s.cache.Set(ctx, ProductCacheKey(product.ID), product, s.cacheTTL); err != nil {
+			s.logger.Warn("写入产品缓存失败，停止预热", "product_id", product.ID, "warmed", warmed, "error", err)
+			return warmed, err
+		}
+		warmed++
+	}
+	return warmed, nil
+}
+
+// checkCategory 检查分类是否存在
+func (s *productService) checkCategory(ctx context.Context, id uint) (*models.Category, error) {
+	category, err := s.categories.GetByID(ctx, id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf("分类不存在")
+		}
+		s.logger.Error("获取分类失败", "category_id", id, "error", err)
+		return nil, err
+	}
+	return category, nil
+}
+
+// invalidateProduct 删除单个产品的缓存以及所有产品列表缓存
+// 数据已经写入，即使请求被取消也要完成失效，因此不继承请求的取消信号
+func (s *productService) invalidateProduct(ctx context.Context, id uint) {
+	if err := s.cache.Delete(context.WithoutCancel(ctx), ProductCacheKey(id)); err != nil {
+		s.logger.Warn("删除产品缓存失败", "product_id", id, "error", err)
+	}
+	s.invalidateProductLists(ctx)
+}
+
+// InvalidateCategory 分类变更后删除该分类下所有产品的缓存以及所有产品列表缓存
+// 产品缓存中内嵌了分类信息，分类改名后需要重新加载
+func (s *productService) InvalidateCategory(ctx context.Context, categoryID uint) error {
+	bg := context.WithoutCancel(ctx)
+	defer s.invalidateProductLists(bg)
+
+	err := s.repo.ForEach(bg, &models.ProductQuery{CategoryID: categoryID}, 0, func(product *models.Product) error {
+		if err := s.cache.Delete(bg, ProductCacheKey(product.ID)); err != nil {
+			s.logger.Warn("删除产品缓存失败", "product_id", product.ID, "error", err)
+		}
+		return nil
+	})
+	if err != nil {
+		s.logger.Error("删除分类下的产品缓存失败", "category_id", categoryID, "error", err)
+	}
+	return err
+}
+
+// invalidateProductLists 递增列表缓存代数，使所有产品列表缓存失效，产品的增删改都可能影响任意一页列表
+// 只按模式删除旧缓存时，删除前已开始的查询仍会写回旧数据；递增代数后这些写入落在不再读取的旧代数下。
+// 旧代数的缓存随后按模式删除以立即释放内存，删除失败时由过期时间清理
+func (s *productService) invalidateProductLists(ctx context.Context) {
+	ctx = context.WithoutCancel(ctx)
+	generation, err := s.cache.Incr(ctx, productListGenerationKey)
+	if err != nil {
+		s.logger.Warn("使产品列表缓存失效失败", "error", err)
+		return
+	}
+	if _, err := s.cache.DeleteByPattern(ctx, productListCachePattern(generation-1)); err != nil {
+		s.logger.Warn("删除旧的产品列表缓存失败", "generation", generation-1, "error", err)
+	}
+}
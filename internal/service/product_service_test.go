@@ -0,0 +1,525 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/binary-1024/go-build-test/internal/auth"
+	"github.com/binary-1024/go-build-test/internal/cache"
+	"github.com/binary-1024/go-build-test/internal/database"
+	"github.com/binary-1024/go-build-test/internal/logger"
+	"github.com/binary-1024/go-build-test/internal/models"
+	"github.com/binary-1024/go-build-test/internal/repository"
+
+	"gorm.io/gorm"
+)
+
+// testEnv 服务测试使用的SQLite数据库和内存Redis
+type testEnv struct {
+	db     *gorm.DB
+	redis  *miniredis.Miniredis
+	cache  *cache.RedisClient
+	logger logger.Logger
+}
+
+func newTestEnv(t *testing.T) *testEnv {
+	t.Helper()
+	log := logger.NewLogger("error", "json", logger.FileOutput{})
+	db, err := database.NewConnection(database.DriverSQLite, filepath.Join(t.TempDir(), "test.db"), database.PoolConfig{}, log)
+	if err != nil {
+		t.Fatalf("open database: %v", err)
+	}
+	mr := miniredis.RunT(t)
+	rdb := cache.NewRedisClient("redis://"+mr.Addr(), "test", log)
+	t.Cleanup(func() { _ = rdb.Close() })
+	return &testEnv{db: db, redis: mr, cache: rdb, logger: log}
+}
+
+// createProduct 直接写入数据库创建一个产品
+func (e *testEnv) createProduct(t *testing.T, name string, stock int) *models.Product {
+	t.Helper()
+	category := &models.Category{Name: name + "-category"}
+	if err := e.db.Create(category).Error; err != nil {
+		t.Fatalf("create category: %v", err)
+	}
+	product := &models.Product{Name: name, Price: 10, Stock: stock, CategoryID: &category.ID, IsActive: true, Version: 1}
+	if err := e.db.Create(product).Error; err != nil {
+		t.Fatalf("create product: %v", err)
+	}
+	return product
+}
+
+// createUser 直接写入数据库创建一个已激活、拥有全部权限的用户
+func (e *testEnv) createUser(t *testing.T, username, password string) *models.User {
+	t.Helper()
+	user := &models.User{Username: username, Email: username + "@example.com", Password: password, FullName: username, IsActive: true,
+		Scopes: models.StringList(auth.AllScopes)}
+	if err := user.HashPassword(); err != nil {
+		t.Fatalf("hash password: %v", err)
+	}
+	if err := e.db.Create(user).Error; err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+	return user
+}
+
+func (e *testEnv) stock(t *testing.T, id uint) int {
+	t.Helper()
+	var product models.Product
+	if err := e.db.First(&product, id).Error; err != nil {
+		t.Fatalf("get product: %v", err)
+	}
+	return product.Stock
+}
+
+// flakyStockRepo 归还库存失败指定次数的产品仓库
+type flakyStockRepo struct {
+	repository.ProductRepository
+	failures int
+}
+
+func (r *flakyStockRepo) IncrementStock(ctx context.Context, id uint, qty int) error {
+	if r.failures > 0 {
+		r.failures--
+		return errors.New("database unavailable")
+	}
+	return r.ProductRepository.IncrementStock(ctx, id, qty)
+}
+
+func TestReservationLifecycle(t *testing.T) {
+	tests := []struct {
+		name      string
+		settle    func(ctx context.Context, svc ProductService, id string) error
+		wantStock int
+		wantErr   error
+	}{
+		{
+			name:      "确认后不归还",
+			settle:    func(ctx context.Context, svc ProductService, id string) error { return svc.ConfirmReservation(ctx, id) },
+			wantStock: 7,
+		},
+		{
+			name:      "取消后归还",
+			settle:    func(ctx context.Context, svc ProductService, id string) error { return svc.CancelReservation(ctx, id) },
+			wantStock: 10,
+		},
+		{
+			name: "重复确认返回不存在",
+			settle: func(ctx context.Context, svc ProductService, id string) error {
+				if err := svc.ConfirmReservation(ctx, id); err != nil {
+					return err
+				}
+				return svc.CancelReservation(ctx, id)
+			},
+			wantStock: 7,
+			wantErr:   ErrReservationNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := newTestEnv(t)
+			product := env.createProduct(t, "widget", 10)
+			svc := NewProductService(repository.NewProductRepository(env.db), repository.NewCategoryRepository(env.db), env.cache, time.Minute, PricePolicy{}, env.logger)
+			ctx := context.Background()
+
+			id, err := svc.ReserveStock(ctx, product.ID, 3, time.Minute)
+			if err != nil {
+				t.Fatalf("ReserveStock: %v", err)
+			}
+			if got := env.stock(t, product.ID); got != 7 {
+				t.Fatalf("stock after reserve = %d, want 7", got)
+			}
+
+			if err := tt.settle(ctx, svc, id); !errors.Is(err, tt.wantErr) {
+				t.Fatalf("err = %v, want %v", err, tt.wantErr)
+			}
+			if got := env.stock(t, product.ID); got != tt.wantStock {
+				t.Errorf("stock = %d, want %d", got, tt.wantStock)
+			}
+		})
+	}
+}
+
+func TestSweepExpiredReservationsRetriesFailedRelease(t *testing.T) {
+	env := newTestEnv(t)
+	product := env.createProduct(t, "widget", 10)
+	repo := &flakyStockRepo{ProductRepository: repository.NewProductRepository(env.db), failures: 1}
+	svc := NewProductService(repo, repository.NewCategoryRepository(env.db), env.cache, time.Minute, PricePolicy{}, env.logger).(*productService)
+	ctx := context.Background()
+
+	id, err := svc.ReserveStock(ctx, product.ID, 4, time.Millisecond)
+	if err != nil {
+		t.Fatalf("ReserveStock: %v", err)
+	}
+	time.Sleep(5 * time.Millisecond)
+
+	// 第一次归还失败：预留重新入队，记录保留
+	if released, err := svc.SweepExpiredReservations(ctx); err != nil || released != 0 {
+		t.Fatalf("first sweep = %d, %v; want 0, nil", released, err)
+	}
+	if !env.cache.Exists(ctx, StockReservationKey(id)) {
+		t.Fatal("reservation record deleted after failed release")
+	}
+	if got := env.stock(t, product.ID); got != 6 {
+		t.Fatalf("stock after failed release = %d, want 6", got)
+	}
+
+	// 重试时间到达后归还成功
+	due, err := env.cache.Due(ctx, stockReservationQueue, time.Now().Add(stockReservationRetryDelay), 10)
+	if err != nil || len(due) != 1 || due[0] != id {
+		t.Fatalf("requeued = %v, %v; want [%s]", due, err, id)
+	}
+	if err := env.cache.Schedule(ctx, stockReservationQueue, id, time.Now()); err != nil {
+		t.Fatalf("Schedule: %v", err)
+	}
+	if released, err := svc.SweepExpiredReservations(ctx); err != nil || released != 1 {
+		t.Fatalf("second sweep = %d, %v; want 1, nil", released, err)
+	}
+	if got := env.stock(t, product.ID); got != 10 {
+		t.Errorf("stock after retry = %d, want 10", got)
+	}
+	if env.cache.Exists(ctx, StockReservationKey(id)) {
+		t.Error("reservation record kept after successful release")
+	}
+}
+
+func TestReserveStockRefusedWithoutRedis(t *testing.T) {
+	env := newTestEnv(t)
+	product := env.createProduct(t, "widget", 10)
+	svc := NewProductService(repository.NewProductRepository(env.db), repository.NewCategoryRepository(env.db), env.cache, time.Minute, PricePolicy{}, env.logger)
+	env.redis.Close()
+
+	if _, err := svc.ReserveStock(context.Background(), product.ID, 3, time.Minute); !errors.Is(err, ErrReservationUnavailable) {
+		t.Fatalf("err = %v, want %v", err, ErrReservationUnavailable)
+	}
+	if got := env.stock(t, product.ID); got != 10 {
+		t.Errorf("stock = %d, want 10", got)
+	}
+}
+
+func TestProductListCacheKey(t *testing.T) {
+	tests := []struct {
+		name      string
+		a, b      models.ProductQuery
+		genA      int64
+		genB      int64
+		wantEqual bool
+	}{
+		{name: "相同查询", a: models.ProductQuery{Page: 1, Limit: 10}, b: models.ProductQuery{Page: 1, Limit: 10}, wantEqual: true},
+		{name: "分类顺序不同", a: models.ProductQuery{Page: 1, Limit: 10, Categories: "a,b"}, b: models.ProductQuery{Page: 1, Limit: 10, Categories: "b,a"}, wantEqual: true},
+		{name: "排序方向大小写不同", a: models.ProductQuery{Page: 1, Limit: 10, SortBy: "price", Order: "desc"}, b: models.ProductQuery{Page: 1, Limit: 10, SortBy: "price", Order: "DESC"}, wantEqual: true},
+		{name: "页码不同", a: models.ProductQuery{Page: 1, Limit: 10}, b: models.ProductQuery{Page: 2, Limit: 10}},
+		{name: "代数不同", a: models.ProductQuery{Page: 1, Limit: 10}, b: models.ProductQuery{Page: 1, Limit: 10}, genB: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, b := productListCacheKey(tt.genA, &tt.a), productListCacheKey(tt.genB, &tt.b)
+			if (a == b) != tt.wantEqual {
+				t.Errorf("keys %q and %q: equal = %v, want %v", a, b, a == b, tt.wantEqual)
+			}
+		})
+	}
+}
+
+func TestProductListCacheInvalidatedOnWrite(t *testing.T) {
+	env := newTestEnv(t)
+	existing := env.createProduct(t, "widget", 10)
+	svc := NewProductService(repository.NewProductRepository(env.db), repository.NewCategoryRepository(env.db), env.cache, time.Minute, PricePolicy{}, env.logger)
+	ctx := context.Background()
+	query := &models.ProductQuery{Page: 1, Limit: 10}
+
+	list, err := svc.ListProducts(ctx, query)
+	if err != nil || list.Pagination.Total != 1 {
+		t.Fatalf("ListProducts = %+v, %v; want 1 product", list, err)
+	}
+
+	if _, err := svc.CreateProduct(ctx, &models.CreateProductRequest{Name: "gadget", Price: 5, CategoryID: *existing.CategoryID}); err != nil {
+		t.Fatalf("CreateProduct: %v", err)
+	}
+
+	list, err = svc.ListProducts(ctx, query)
+	if err != nil {
+		t.Fatalf("ListProducts: %v", err)
+	}
+	if list.Pagination.Total != 2 {
+		t.Errorf("total after create = %d, want 2 (stale list cache)", list.Pagination.Total)
+	}
+	// 旧代数的列表缓存已被删除，不必等到过期
+	for _, key := range env.redis.Keys() {
+		if strings.HasPrefix(key, "test:product:list:0:") {
+			t.Errorf("stale list cache %s not deleted", key)
+		}
+	}
+}
+
+func TestProductCacheKeepsFullPrecisionTimes(t *testing.T) {
+	models.SetTimeFormat(models.TimeFormatUnix)
+	t.Cleanup(func() { models.SetTimeFormat(models.TimeFormatRFC3339) })
+
+	env := newTestEnv(t)
+	created := env.createProduct(t, "widget", 5)
+	products := NewProductService(repository.NewProductRepository(env.db), repository.NewCategoryRepository(env.db), env.cache, time.Minute, PricePolicy{}, env.logger)
+	ctx := context.Background()
+
+	loaded, err := products.GetProduct(ctx, created.ID)
+	if err != nil {
+		t.Fatalf("GetProduct: %v", err)
+	}
+	cached, err := products.GetProduct(ctx, created.ID)
+	if err != nil {
+		t.Fatalf("GetProduct (cached): %v", err)
+	}
+	if !cached.CreatedAt.Equal(loaded.CreatedAt) || cached.CreatedAt.Nanosecond() == 0 {
+		t.Errorf("cached created_at = %v, want %v", cached.CreatedAt, loaded.CreatedAt)
+	}
+
+	// 响应中的时间仍按配置格式输出
+	body, err := json.Marshal(cached.ToResponse())
+	if err != nil {
+		t.Fatalf("marshal response: %v", err)
+	}
+	var resp struct {
+		CreatedAt int64 `json:"created_at"`
+	}
+	if err := json.Unmarshal(body, &resp); err != nil {
+		t.Fatalf("unmarshal response %s: %v", body, err)
+	}
+	if resp.CreatedAt != loaded.CreatedAt.Unix() {
+		t.Errorf("response created_at = %d, want %d", resp.CreatedAt, loaded.CreatedAt.Unix())
+	}
+}
+
+func TestGetProductsByIDs(t *testing.T) {
+	env := newTestEnv(t)
+	repo := repository.NewProductRepository(env.db)
+	products := NewProductService(repo, repository.NewCategoryRepository(env.db), env.cache, time.Minute, PricePolicy{}, env.logger)
+	ctx := context.Background()
+
+	cachedProduct := env.createProduct(t, "cached", 1)
+	uncached := env.createProduct(t, "uncached", 2)
+	if _, err := products.GetProduct(ctx, cachedProduct.ID); err != nil {
+		t.Fatalf("GetProduct: %v", err)
+	}
+	// 直接修改数据库，只有命中缓存的产品仍返回旧名称
+	if err := env.db.Model(&models.Product{}).Where("id = ?", cachedProduct.ID).Update("name", "renamed").Error; err != nil {
+		t.Fatalf("rename product: %v", err)
+	}
+
+	bulk := make([]*models.Product, 1200)
+	for i := range bulk {
+		bulk[i] = &models.Product{Name: fmt.Sprintf("bulk-%d", i), Price: 1, IsActive: true, Version: 1}
+	}
+	if err := repo.CreateBatch(ctx, bulk); err != nil {
+		t.Fatalf("CreateBatch: %v", err)
+	}
+	bulkIDs := make([]uint, len(bulk))
+	for i, product := range bulk {
+		bulkIDs[i] = product.ID
+	}
+
+	tests := []struct {
+		name      string
+		ids       []uint
+		wantNames map[uint]string
+		wantLen   int
+	}{
+		{name: "空列表", wantLen: 0},
+		{
+			name:      "缓存命中与未命中混合",
+			ids:       []uint{cachedProduct.ID, uncached.ID, 99999, uncached.ID},
+			wantNames: map[uint]string{cachedProduct.ID: "cached", uncached.ID: "uncached"},
+			wantLen:   2,
+		},
+		{name: "超过单次查询的ID数时分批查询", ids: bulkIDs, wantLen: len(bulkIDs)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := products.GetProductsByIDs(ctx, tt.ids)
+			if err != nil {
+				t.Fatalf("GetProductsByIDs: %v", err)
+			}
+			if len(got) != tt.wantLen {
+				t.Fatalf("len = %d, want %d", len(got), tt.wantLen)
+			}
+			for id, name := range tt.wantNames {
+				if got[id] == nil || got[id].Name != name {
+					t.Errorf("product %d = %+v, want name %q", id, got[id], name)
+				}
+			}
+		})
+	}
+
+	// 未命中的产品已回填缓存
+	if !env.redis.Exists("test:" + ProductCacheKey(uncached.ID)) {
+		t.Errorf("product %d not cached after lookup", uncached.ID)
+	}
+}
+
+func TestListProductsByCategories(t *testing.T) {
+	env := newTestEnv(t)
+	products := NewProductService(repository.NewProductRepository(env.db), repository.NewCategoryRepository(env.db), env.cache, time.Minute, PricePolicy{}, env.logger)
+	for _, name := range []string{"book", "pen", "lamp"} {
+		env.createProduct(t, name, 1)
+	}
+
+	tests := []struct {
+		name      string
+		query     models.ProductQuery
+		wantTotal int64
+	}{
+		{name: "两个分类", query: models.ProductQuery{Categories: "book-category,pen-category"}, wantTotal: 2},
+		{name: "忽略空项和空格", query: models.ProductQuery{Categories: " lamp-category, ,"}, wantTotal: 1},
+		{name: "与单个分类参数同时使用", query: models.ProductQuery{Category: "book-category", Categories: "book-category,pen-category"}, wantTotal: 1},
+		{name: "不存在的分类", query: models.ProductQuery{Categories: "missing"}, wantTotal: 0},
+		{name: "不过滤", query: models.ProductQuery{}, wantTotal: 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.query.Page, tt.query.Limit = 1, 10
+			resp, err := products.ListProducts(context.Background(), &tt.query)
+			if err != nil {
+				t.Fatalf("ListProducts: %v", err)
+			}
+			if resp.Total != tt.wantTotal || int64(len(resp.Products)) != tt.wantTotal {
+				t.Errorf("total = %d, products = %d; want %d", resp.Total, len(resp.Products), tt.wantTotal)
+			}
+		})
+	}
+}
+
+func TestListProductsStablePagination(t *testing.T) {
+	env := newTestEnv(t)
+	repo := repository.NewProductRepository(env.db)
+	products := NewProductService(repo, repository.NewCategoryRepository(env.db), env.cache, time.Minute, PricePolicy{}, env.logger)
+	ctx := context.Background()
+
+	// 价格和创建时间都相同，只能依靠 id 区分顺序
+	createdAt := time.Now().Truncate(time.Second)
+	batch := make([]*models.Product, 7)
+	for i := range batch {
+		batch[i] = &models.Product{Name: fmt.Sprintf("same-%d", i), Price: 9.9, Stock: 1, IsActive: true, Version: 1, CreatedAt: createdAt}
+	}
+	if err := repo.CreateBatch(ctx, batch); err != nil {
+		t.Fatalf("CreateBatch: %v", err)
+	}
+
+	tests := []struct {
+		name  string
+		query models.ProductQuery
+	}{
+		{name: "按价格升序", query: models.ProductQuery{SortBy: "price"}},
+		{name: "按价格降序", query: models.ProductQuery{SortBy: "price", Order: "desc"}},
+		{name: "默认按创建时间", query: models.ProductQuery{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			seen := make(map[uint]bool)
+			for page := 1; page <= 3; page++ {
+				query := tt.query
+				query.Page, query.Limit = page, 3
+				resp, err := products.ListProducts(ctx, &query)
+				if err != nil {
+					t.Fatalf("ListProducts page %d: %v", page, err)
+				}
+				for _, product := range resp.Products {
+					if seen[product.ID] {
+						t.Errorf("product %d returned on more than one page", product.ID)
+					}
+					seen[product.ID] = true
+				}
+			}
+			if len(seen) != len(batch) {
+				t.Errorf("saw %d products across pages, want %d", len(seen), len(batch))
+			}
+		})
+	}
+}
+
+func TestListProductsReturnsStoredValues(t *testing.T) {
+	env := newTestEnv(t)
+	products := NewProductService(repository.NewProductRepository(env.db), repository.NewCategoryRepository(env.db), env.cache, time.Minute, PricePolicy{}, env.logger)
+	seeded := []*models.Product{
+		{Name: "Go语言编程", Price: 59.9, Stock: 3, IsActive: true, Version: 1},
+		{Name: "机械键盘", Price: 299, Stock: 8, IsActive: true, Version: 1},
+	}
+	for _, product := range seeded {
+		if err := env.db.Create(product).Error; err != nil {
+			t.Fatalf("create product: %v", err)
+		}
+	}
+
+	tests := []struct {
+		name string
+	}{
+		{name: "读取数据库"},
+		{name: "读取缓存"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := products.ListProducts(context.Background(), &models.ProductQuery{Page: 1, Limit: 10, SortBy: "price"})
+			if err != nil {
+				t.Fatalf("ListProducts: %v", err)
+			}
+			if len(resp.Products) != len(seeded) {
+				t.Fatalf("products = %d, want %d", len(resp.Products), len(seeded))
+			}
+			for i, want := range seeded {
+				got := resp.Products[i]
+				if got.ID != want.ID || got.Name != want.Name || got.Price != want.Price || got.Stock != want.Stock {
+					t.Errorf("product %d = %+v, want %s %.2f x%d", i, got, want.Name, want.Price, want.Stock)
+				}
+			}
+		})
+	}
+}
+
+func TestPricePolicy(t *testing.T) {
+	policy := PricePolicy{Min: 1, Max: 1000}
+
+	tests := []struct {
+		name    string
+		price   float64
+		wantErr string
+	}{
+		{name: "低于下限", price: 0.5, wantErr: "产品价格不能低于1.00"},
+		{name: "等于下限", price: 1},
+		{name: "区间内", price: 99.9},
+		{name: "等于上限", price: 1000},
+		{name: "高于上限", price: 1000.01, wantErr: "产品价格不能高于1000.00"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := newTestEnv(t)
+			products := NewProductService(repository.NewProductRepository(env.db), repository.NewCategoryRepository(env.db), env.cache, time.Minute, policy, env.logger)
+			existing := env.createProduct(t, "widget", 1)
+			ctx := context.Background()
+
+			_, createErr := products.CreateProduct(ctx, &models.CreateProductRequest{Name: "new", Price: tt.price, CategoryID: *existing.CategoryID})
+			price := tt.price
+			_, updateErr := products.UpdateProduct(ctx, existing.ID, &models.UpdateProductRequest{Price: &price})
+
+			for op, err := range map[string]error{"CreateProduct": createErr, "UpdateProduct": updateErr} {
+				switch {
+				case tt.wantErr == "" && err != nil:
+					t.Errorf("%s err = %v, want nil", op, err)
+				case tt.wantErr != "" && (err == nil || err.Error() != tt.wantErr):
+					t.Errorf("%s err = %v, want %q", op, err, tt.wantErr)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,149 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+
+	"github.com/binary-1024/go-build-test/internal/cache"
+	"github.com/binary-1024/go-build-test/internal/logger"
+	"github.com/binary-1024/go-build-test/internal/models"
+)
+
+// StockChannel 产品库存变化事件的Redis频道，所有产品共用一个频道
+const StockChannel = "product:stock"
+
+// stockSubscriberBuffer 每个订阅者的事件缓冲，订阅者消费过慢、缓冲已满时丢弃新事件
+const stockSubscriberBuffer = 16
+
+// StockHub 库存变化事件的进程内分发
+// 同一实例上的所有订阅者共用一个Redis订阅：第一个订阅者到来时订阅频道，最后一个离开时取消订阅
+type StockHub struct {
+	cache  *cache.RedisClient
+	logger logger.Logger
+
+	mu          sync.Mutex
+	subscribers map[uint]map[chan models.StockEvent]struct{}
+	count       int
+	unsubscribe func()
+	// messages 当前Redis订阅的消息通道，用于区分已被替换的旧订阅
+	messages <-chan []byte
+}
+
+// NewStockHub 创建库存事件分发器
+func NewStockHub(cache *cache.RedisClient, logger logger.Logger) *StockHub {
+	return &StockHub{
+		cache:       cache,
+		logger:      logger,
+		subscribers: make(map[uint]map[chan models.StockEvent]struct{}),
+	}
+}
+
+// Subscribe 订阅产品的库存变化事件，返回事件通道和取消订阅函数
+// 取消订阅后事件通道被关闭，取消函数可以重复调用
+func (h *StockHub) Subscribe(ctx context.Context, productID uint) (<-chan models.StockEvent, func(), error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.count == 0 {
+		// Redis订阅由所有订阅者共用，不能随第一个订阅者的请求结束而取消
+		messages, unsubscribe, err := h.cache.Subscribe(context.WithoutCancel(ctx), StockChannel)
+		if err != nil {
+			return nil, nil, err
+		}
+		h.unsubscribe = unsubscribe
+		h.messages = messages
+		go h.dispatch(messages)
+	}
+
+	events := make(chan models.StockEvent, stockSubscriberBuffer)
+	if h.subscribers[productID] == nil {
+		h.subscribers[productID] = make(map[chan models.StockEvent]struct{})
+	}
+	h.subscribers[productID][events] = struct{}{}
+	h.count++
+
+	var once sync.Once
+	return events, func() {
+		once.Do(func() { h.remove(productID, events) })
+	}, nil
+}
+
+// Close 关闭所有订阅者的事件通道并取消Redis订阅，用于服务关闭时结束所有SSE连接
+// 关闭后仍可重新订阅
+func (h *StockHub) Close() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.closeAllLocked()
+}
+
+// remove 移除订阅者，最后一个订阅者离开时取消Redis订阅
+// 订阅者已被 closeAllLocked 移除时不做任何操作
+func (h *StockHub) remove(productID uint, events chan models.StockEvent) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if _, ok := h.subscribers[productID][events]; !ok {
+		return
+	}
+	delete(h.subscribers[productID], events)
+	if len(h.subscribers[productID]) == 0 {
+		delete(h.subscribers, productID)
+	}
+	close(events)
+
+	h.count--
+	if h.count == 0 {
+		h.unsubscribe()
+		h.unsubscribe = nil
+		h.messages = nil
+	}
+}
+
+// closeAllLocked 关闭并移除所有订阅者，取消Redis订阅，调用方须持有 h.mu
+func (h *StockHub) closeAllLocked() {
+	for _, subscribers := range h.subscribers {
+		for events := range subscribers {
+			close(events)
+		}
+	}
+	h.subscribers = make(map[uint]map[chan models.StockEvent]struct{})
+	h.count = 0
+	if h.unsubscribe != nil {
+		h.unsubscribe()
+		h.unsubscribe = nil
+	}
+	h.messages = nil
+}
+
+// dispatch 将Redis消息分发给对应产品的订阅者，Redis订阅取消后退出
+// Redis订阅意外结束（如客户端被关闭）时关闭所有订阅者的事件通道，下一个订阅者会重新订阅
+func (h *StockHub) dispatch(messages <-chan []byte) {
+	defer func() {
+		h.mu.Lock()
+		defer h.mu.Unlock()
+		// 最后一个订阅者离开或 Close 导致的正常结束，订阅可能已被新的订阅替换
+		if h.messages == messages {
+			h.logger.Warn("库存事件订阅已断开，关闭所有订阅者")
+			h.closeAllLocked()
+		}
+	}()
+
+	for data := range messages {
+		var event models.StockEvent
+		if err := json.Unmarshal(data, &event); err != nil {
+			h.logger.Warn("解析库存事件失败", "error", err)
+			continue
+		}
+
+		h.mu.Lock()
+		for events := range h.subscribers[event.ProductID] {
+			select {
+			case events <- event:
+			default:
+				h.logger.Warn("库存事件订阅者消费过慢，丢弃事件", "product_id", event.ProductID)
+			}
+		}
+		h.mu.Unlock()
+	}
+}
@@ -0,0 +1,88 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/binary-1024/go-build-test/internal/models"
+)
+
+// waitClosed 等待事件通道被关闭
+func waitClosed(t *testing.T, events <-chan models.StockEvent) {
+	t.Helper()
+	timeout := time.After(2 * time.Second)
+	for {
+		select {
+		case _, ok := <-events:
+			if !ok {
+				return
+			}
+		case <-timeout:
+			t.Fatal("event channel not closed")
+		}
+	}
+}
+
+func TestStockHubCloseEndsSubscribers(t *testing.T) {
+	env := newTestEnv(t)
+	hub := NewStockHub(env.cache, env.logger)
+
+	first, unsubscribeFirst, err := hub.Subscribe(context.Background(), 1)
+	if err != nil {
+		t.Fatalf("Subscribe: %v", err)
+	}
+	second, unsubscribeSecond, err := hub.Subscribe(context.Background(), 2)
+	if err != nil {
+		t.Fatalf("Subscribe: %v", err)
+	}
+
+	hub.Close()
+	waitClosed(t, first)
+	waitClosed(t, second)
+
+	// 关闭后的取消订阅函数不能重复关闭通道
+	unsubscribeFirst()
+	unsubscribeSecond()
+
+	// 关闭后可以重新订阅并收到事件
+	events, unsubscribe, err := hub.Subscribe(context.Background(), 1)
+	if err != nil {
+		t.Fatalf("Subscribe after Close: %v", err)
+	}
+	defer unsubscribe()
+	if err := env.cache.Publish(context.Background(), StockChannel, models.StockEvent{ProductID: 1, Stock: 5}); err != nil {
+		t.Fatalf("Publish: %v", err)
+	}
+	select {
+	case event := <-events:
+		if event.Stock != 5 {
+			t.Errorf("stock = %d, want 5", event.Stock)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("no event after resubscribe")
+	}
+}
+
+func TestStockHubClosesSubscribersWhenRedisSubscriptionEnds(t *testing.T) {
+	env := newTestEnv(t)
+	hub := NewStockHub(env.cache, env.logger)
+
+	events, unsubscribe, err := hub.Subscribe(context.Background(), 1)
+	if err != nil {
+		t.Fatalf("Subscribe: %v", err)
+	}
+
+	// 关闭Redis客户端会结束订阅并关闭消息通道
+	if err := env.cache.Close(); err != nil {
+		t.Fatalf("Close redis: %v", err)
+	}
+	waitClosed(t, events)
+	unsubscribe()
+
+	hub.mu.Lock()
+	defer hub.mu.Unlock()
+	if hub.count != 0 || len(hub.subscribers) != 0 {
+		t.Errorf("count = %d, subscribers = %d, want 0", hub.count, len(hub.subscribers))
+	}
+}
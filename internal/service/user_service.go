@@ -2,73 +2,332 @@ package service
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"net/url"
 	"time"
 
+	"github.com/binary-1024/go-build-test/internal/auth"
 	"github.com/binary-1024/go-build-test/internal/cache"
 	"github.com/binary-1024/go-build-test/internal/logger"
+	"github.com/binary-1024/go-build-test/internal/mailer"
 	"github.com/binary-1024/go-build-test/internal/models"
 	"github.com/binary-1024/go-build-test/internal/repository"
+	"github.com/binary-1024/go-build-test/internal/storage"
 
+	"github.com/google/uuid"
 	"gorm.io/gorm"
 )
 
 // UserService 用户服务接口
 type UserService interface {
-	CreateUser(req *models.CreateUserRequest) (*models.User, error)
-	GetUser(id uint) (*models.User, error)
-	UpdateUser(id uint, req *models.UpdateUserRequest) (*models.User, error)
-	DeleteUser(id uint) error
-	ListUsers(page, limit int) ([]*models.User, int64, error)
+	CreateUser(ctx context.Context, req *models.CreateUserRequest) (*models.User, error)
+	CreateUsers(ctx context.Context, reqs []*models.CreateUserRequest) ([]*models.User, []error)
+	GetUser(ctx context.Context, id uint) (*models.User, error)
+	UpdateUser(ctx context.Context, id uint, req *models.UpdateUserRequest) (*models.User, error)
+	DeleteUser(ctx context.Context, id uint) error
+	RestoreUser(ctx context.Context, id uint) (*models.User, error)
+	PurgeUser(ctx context.Context, id uint) error
+	PurgeDeleted(ctx context.Context, before time.Time) (int64, error)
+	ListUsers(ctx context.Context, page, limit int) ([]*models.User, int64, error)
+	SearchUsers(ctx context.Context, query string, page, limit int) ([]*models.User, int64, error)
+	StreamUsers(ctx context.Context, query string, fn func(*models.User) error) error
+	GetAuditTrail(ctx context.Context, id uint, page, limit int) (*models.AuditListResponse, error)
+	UpdateAvatar(ctx context.Context, id uint, contentType string, r io.Reader) (*models.User, error)
+	SetScopes(ctx context.Context, id uint, scopes []string) (*models.User, error)
+}
+
+// ErrUnknownScope 分配了不存在的权限
+var ErrUnknownScope = errors.New("未知的权限")
+
+// UserCacheKey 用户缓存键
+func UserCacheKey(id uint) string {
+	return fmt.Sprintf("user:%d", id)
+}
+
+// UserOptions 用户服务的可选行为
+type UserOptions struct {
+	// BatchAtomic 批量创建是否采用全有或全无的事务语义
+	BatchAtomic bool
+	// NormalizeEmail 是否按归一化后的邮箱（小写、去掉 +tag）检查唯一性
+	NormalizeEmail bool
+	// CacheTTL 单个用户的缓存时间
+	CacheTTL time.Duration
+	// VerifyURL 邮箱验证链接的地址，验证token以 token 查询参数附加在后面
+	VerifyURL string
+	// VerifyTokenTTL 邮箱验证token的有效期
+	VerifyTokenTTL time.Duration
+}
+
+// verifyEmailClaim 验证token中记录邮箱的额外声明，邮箱变更后旧的验证链接随之失效
+const verifyEmailClaim = "email"
+
+// 用户列表缓存
+// 列表缓存键包含一个代数，需要整体失效时只需递增代数，无需逐个删除分页缓存
+const (
+	userListGenerationKey = "users:list:gen"
+	userListTTL           = time.Minute
+)
+
+// userListFields 会影响列表成员或排序的字段，更新这些字段时需要使列表缓存失效
+// 其他字段（如 full_name）的更新只删除单个用户缓存，列表中的值最多在 userListTTL 内保持旧值
+var userListFields = map[string]bool{
+	"username":  true,
+	"email":     true,
+	"is_active": true,
+}
+
+// cachedUserList 缓存的用户列表分页
+type cachedUserList struct {
+	Users []*models.User `json:"users"`
+	Total int64          `json:"total"`
 }
 
 // userService 用户服务实现
 type userService struct {
-	repo   repository.UserRepository
-	cache  *cache.RedisClient
-	logger logger.Logger
+	repo      repository.UserRepository
+	auditRepo repository.AuditRepository
+	tx        repository.Transactioner
+	cache     *cache.RedisClient
+	avatars   storage.Storage
+	mailer    mailer.Mailer
+	tokens    *auth.JWTManager
+	options   UserOptions
+	logger    logger.Logger
 }
 
 // NewUserService 创建用户服务
-func NewUserService(repo repository.UserRepository, cache *cache.RedisClient, logger logger.Logger) UserService {
+// mailer 和 tokens 用于在创建用户后发送邮箱验证链接
+func NewUserService(repo repository.UserRepository, auditRepo repository.AuditRepository, tx repository.Transactioner, cache *cache.RedisClient, avatars storage.Storage, mailer mailer.Mailer, tokens *auth.JWTManager, options UserOptions, logger logger.Logger) UserService {
 	return &userService{
-		repo:   repo,
-		cache:  cache,
-		logger: logger,
+		repo:      repo,
+		auditRepo: auditRepo,
+		tx:        tx,
+		cache:     cache,
+		avatars:   avatars,
+		mailer:    mailer,
+		tokens:    tokens,
+		options:   options,
+		logger:    logger,
 	}
 }
 
-// CreateUser 创建用户
-func (s *userService) CreateUser(req *models.CreateUserRequest) (*models.User, error) {
+// CreateUser 创建用户（自助注册）
+func (s *userService) CreateUser(ctx context.Context, req *models.CreateUserRequest) (*models.User, error) {
 	s.logger.Info("创建用户", "username", req.Username)
 
+	user, err := s.buildUser(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.createUser(ctx, user); err != nil {
+		s.logger.Error("创建用户失败", "error", err)
+		return nil, err
+	}
+
+	s.invalidateUserList(ctx)
+	s.sendVerification(ctx, user)
+
+	s.logger.Info("用户创建成功", "user_id", user.ID)
+	return user, nil
+}
+
+// CreateUsers 批量创建用户，返回的错误切片与请求一一对应（成功的位置为nil）
+// 全有或全无模式下任意一条失败都不会写入任何用户；尽力模式下只写入校验通过的用户
+func (s *userService) CreateUsers(ctx context.Context, reqs []*models.CreateUserRequest) ([]*models.User, []error) {
+	s.logger.Info("批量创建用户", "count", len(reqs), "atomic", s.options.BatchAtomic)
+
+	errs := make([]error, len(reqs))
+	users := make([]*models.User, len(reqs))
+	failed := false
+
+	seenUsernames := make(map[string]bool)
+	seenEmails := make(map[string]bool)
+	for i, req := range reqs {
+		// 批次内部的重复也视为冲突
+		if seenUsernames[req.Username] {
+			errs[i], failed = fmt.Errorf("用户名已存在"), true
+			continue
+		}
+		emailKey := s.emailKey(req.Email)
+		if seenEmails[emailKey] {
+			errs[i], failed = fmt.Errorf("邮箱已存在"), true
+			continue
+		}
+		seenUsernames[req.Username] = true
+		seenEmails[emailKey] = true
+
+		// 提前检查唯一性以便逐行报告，最终仍以唯一索引为准
+		if err := s.checkUnique(ctx, s.repo, req.Username, req.Email); err != nil {
+			errs[i], failed = err, true
+			continue
+		}
+		user, err := s.buildUser(ctx, req)
+		if err != nil {
+			errs[i], failed = err, true
+			continue
+		}
+		users[i] = user
+	}
+
+	if s.options.BatchAtomic {
+		if failed {
+			return nil, errs
+		}
+		if err := s.repo.CreateBatch(ctx, users); err != nil {
+			if repository.IsUniqueViolation(err, "") {
+				err = s.duplicateError(err)
+			}
+			s.logger.Error("批量创建用户失败", "error", err)
+			for i := range errs {
+				errs[i] = err
+			}
+			return nil, errs
+		}
+		s.invalidateUserList(ctx)
+		for _, user := range users {
+			s.sendVerification(ctx, user)
+		}
+		s.logger.Info("批量创建用户成功", "count", len(users))
+		return users, errs
+	}
+
+	created := make([]*models.User, 0, len(users))
+	for i, user := range users {
+		if user == nil {
+			continue
+		}
+		if err := s.createUser(ctx, user); err != nil {
+			s.logger.Error("创建用户失败", "username", user.Username, "error", err)
+			errs[i] = err
+			continue
+		}
+		created = append(created, user)
+		s.sendVerification(ctx, user)
+	}
+
+	if len(created) > 0 {
+		s.invalidateUserList(ctx)
+	}
+
+	s.logger.Info("批量创建用户完成", "created", len(created), "total", len(reqs))
+	return created, errs
+}
+
+// sendVerification 向新用户发送邮箱验证链接，发送失败只记录日志，用户可以之后重新验证
+func (s *userService) sendVerification(ctx context.Context, user *models.User) {
+	log := s.logger.With("user_id", user.ID)
+
+	token, err := s.tokens.GenerateWithOptions(user.ID, user.Username, auth.TokenOptions{
+		TTL:       s.options.VerifyTokenTTL,
+		TokenType: auth.TokenTypeVerify,
+		Extra:     map[string]interface{}{verifyEmailClaim: user.Email},
+	})
+	if err != nil {
+		log.Error("生成邮箱验证token失败", "error", err)
+		return
+	}
+
+	err = s.mailer.Send(context.WithoutCancel(ctx), mailer.Message{
+		To:      user.Email,
+		Subject: "请验证您的邮箱",
+		Body:    fmt.Sprintf("%s，您好：\n\n请打开以下链接完成邮箱验证：\n%s?token=%s\n", user.Username, s.options.VerifyURL, url.QueryEscape(token)),
+	})
+	if err != nil {
+		log.Warn("发送邮箱验证邮件失败", "error", err)
+	}
+}
+
+// createUser 在事务中检查唯一性并写入用户
+// 并发请求可能同时通过检查，此时由唯一索引兜底，冲突被转换为"已存在"错误
+func (s *userService) createUser(ctx context.Context, user *models.User) error {
+	err := s.tx.Transaction(ctx, func(tx *gorm.DB) error {
+		repo := s.repo.WithTx(tx)
+		if err := s.checkUnique(ctx, repo, user.Username, user.Email); err != nil {
+			return err
+		}
+		return repo.Create(ctx, user)
+	})
+	if repository.IsUniqueViolation(err, "") {
+		return s.duplicateError(err)
+	}
+	return err
+}
+
+// checkUnique 检查用户名和邮箱是否已被占用
+func (s *userService) checkUnique(ctx context.Context, repo repository.UserRepository, username, email string) error {
 	// 检查用户名是否已存在
-	existingUser, err := s.repo.GetByUsername(req.Username)
-	if err != nil && err != gorm.ErrRecordNotFound {
+	exists, err := repo.ExistsByUsername(ctx, username)
+	if err != nil {
 		s.logger.Error("检查用户名失败", "error", err)
-		return nil, err
+		return err
 	}
-	if existingUser != nil {
-		return nil, fmt.Errorf("用户名已存在")
+	if exists {
+		return fmt.Errorf("用户名已存在")
 	}
 
-	// 检查邮箱是否已存在
-	existingUser, err = s.repo.GetByEmail(req.Email)
-	if err != nil && err != gorm.ErrRecordNotFound {
+	// 检查邮箱是否已存在，开启归一化时 user+tag@example.com 与 user@example.com 视为同一邮箱
+	if s.options.NormalizeEmail {
+		exists, err = repo.ExistsByNormalizedEmail(ctx, models.NormalizeEmail(email))
+	} else {
+		exists, err = repo.ExistsByEmail(ctx, email)
+	}
+	if err != nil {
 		s.logger.Error("检查邮箱失败", "error", err)
-		return nil, err
+		return err
+	}
+	if exists {
+		return fmt.Errorf("邮箱已存在")
 	}
-	if existingUser != nil {
-		return nil, fmt.Errorf("邮箱已存在")
+
+	return nil
+}
+
+// duplicateError 将唯一约束冲突转换为友好的错误信息
+func (s *userService) duplicateError(err error) error {
+	switch {
+	case repository.IsUniqueViolation(err, "username"):
+		return fmt.Errorf("用户名已存在")
+	case repository.IsUniqueViolation(err, "email"):
+		return fmt.Errorf("邮箱已存在")
+	default:
+		return fmt.Errorf("用户名或邮箱已存在")
+	}
+}
+
+// emailKey 返回用于比较邮箱是否重复的键
+func (s *userService) emailKey(email string) string {
+	if s.options.NormalizeEmail {
+		return models.NormalizeEmail(email)
+	}
+	return email
+}
+
+// normalizedEmail 返回需要保存的归一化邮箱，未开启归一化时为空
+func (s *userService) normalizedEmail(email string) *string {
+	if !s.options.NormalizeEmail {
+		return nil
 	}
+	normalized := models.NormalizeEmail(email)
+	return &normalized
+}
 
-	// 创建用户
+// buildUser 根据请求构建加密密码后的用户，新用户只拥有 auth.DefaultScopes 中的权限
+func (s *userService) buildUser(ctx context.Context, req *models.CreateUserRequest) (*models.User, error) {
+	actor := actorID(ctx)
 	user := &models.User{
-		Username: req.Username,
-		Email:    req.Email,
-		Password: req.Password,
-		FullName: req.FullName,
-		IsActive: true,
+		Username:        req.Username,
+		Email:           req.Email,
+		Password:        req.Password,
+		NormalizedEmail: s.normalizedEmail(req.Email),
+		FullName:        req.FullName,
+		IsActive:        true,
+		Scopes:          models.StringList(auth.DefaultScopes),
+		CreatedBy:       actor,
+		UpdatedBy:       actor,
 	}
 
 	// 加密密码
@@ -77,50 +336,34 @@ func (s *userService) CreateUser(req *models.CreateUserRequest) (*models.User, e
 		return nil, err
 	}
 
-	if err := s.repo.Create(user); err != nil {
-		s.logger.Error("创建用户失败", "error", err)
-		return nil, err
-	}
-
-	s.logger.Info("用户创建成功", "user_id", user.ID)
 	return user, nil
 }
 
 // GetUser 获取用户
-func (s *userService) GetUser(id uint) (*models.User, error) {
-	// 先从缓存获取
-	cacheKey := fmt.Sprintf("user:%d", id)
-	var cachedUser models.User
-
-	ctx := context.Background()
-	if err := s.cache.Get(ctx, cacheKey, &cachedUser); err == nil {
-		s.logger.Debug("从缓存获取用户", "user_id", id)
-		return &cachedUser, nil
-	}
+func (s *userService) GetUser(ctx context.Context, id uint) (*models.User, error) {
+	cacheKey := UserCacheKey(id)
+	var user models.User
 
-	// 从数据库获取
-	user, err := s.repo.GetByID(id)
+	err := getOrLoad(ctx, s.cache, s.logger, cacheKey, &user, s.options.CacheTTL, func(ctx context.Context) (interface{}, error) {
+		return s.repo.GetByID(ctx, id)
+	})
 	if err != nil {
 		s.logger.Error("获取用户失败", "user_id", id, "error", err)
 		return nil, err
 	}
 
-	// 缓存用户信息
-	if err := s.cache.Set(ctx, cacheKey, user, 5*time.Minute); err != nil {
-		s.logger.Warn("缓存用户信息失败", "user_id", id, "error", err)
-	}
-
-	return user, nil
+	return &user, nil
 }
 
 // UpdateUser 更新用户
-func (s *userService) UpdateUser(id uint, req *models.UpdateUserRequest) (*models.User, error) {
-	s.logger.Info("更新用户", "user_id", id)
+func (s *userService) UpdateUser(ctx context.Context, id uint, req *models.UpdateUserRequest) (*models.User, error) {
+	log := s.logger.With("user_id", id)
+	log.Info("更新用户")
 
 	// 检查用户是否存在
-	_, err := s.repo.GetByID(id)
+	_, err := s.repo.GetByID(ctx, id)
 	if err != nil {
-		s.logger.Error("用户不存在", "user_id", id, "error", err)
+		log.Error("用户不存在", "error", err)
 		return nil, err
 	}
 
@@ -134,43 +377,295 @@ func (s *userService) UpdateUser(id uint, req *models.UpdateUserRequest) (*model
 	}
 
 	// 更新用户
-	if err := s.repo.Update(id, updates); err != nil {
-		s.logger.Error("更新用户失败", "user_id", id, "error", err)
+	if err := s.repo.Update(ctx, id, withUpdatedBy(ctx, updates)); err != nil {
+		log.Error("更新用户失败", "error", err)
 		return nil, err
 	}
 
+	s.recordAudit(ctx, id, models.AuditActionUpdate, updates)
+
 	// 删除缓存
-	cacheKey := fmt.Sprintf("user:%d", id)
-	ctx := context.Background()
-	if err := s.cache.Delete(ctx, cacheKey); err != nil {
-		s.logger.Warn("删除用户缓存失败", "user_id", id, "error", err)
+	cacheKey := UserCacheKey(id)
+	if err := s.cache.Delete(context.WithoutCancel(ctx), cacheKey); err != nil {
+		log.Warn("删除用户缓存失败", "error", err)
+	}
+	if affectsUserList(updates) {
+		s.invalidateUserList(ctx)
 	}
 
 	// 返回更新后的用户
-	return s.repo.GetByID(id)
+	return s.repo.GetByID(ctx, id)
+}
+
+// SetScopes 设置用户的权限，scopes 为 nil 时恢复为 auth.DefaultScopes
+// 已签发的token在过期前保持原有权限，刷新token时按新的权限签发
+func (s *userService) SetScopes(ctx context.Context, id uint, scopes []string) (*models.User, error) {
+	log := s.logger.With("user_id", id)
+
+	if unknown := auth.MissingScope(auth.AllScopes, scopes); unknown != "" {
+		return nil, fmt.Errorf("%w：%s", ErrUnknownScope, unknown)
+	}
+	if _, err := s.repo.GetByID(ctx, id); err != nil {
+		return nil, err
+	}
+
+	if scopes == nil {
+		scopes = auth.DefaultScopes
+	}
+	updates := map[string]interface{}{"scopes": models.StringList(scopes)}
+	if err := s.repo.Update(ctx, id, withUpdatedBy(ctx, updates)); err != nil {
+		log.Error("更新用户权限失败", "error", err)
+		return nil, err
+	}
+
+	s.recordAudit(ctx, id, models.AuditActionUpdate, updates)
+	if err := s.cache.Delete(context.WithoutCancel(ctx), UserCacheKey(id)); err != nil {
+		log.Warn("删除用户缓存失败", "error", err)
+	}
+	s.invalidateUserList(ctx)
+
+	return s.repo.GetByID(ctx, id)
 }
 
 // DeleteUser 删除用户
-func (s *userService) DeleteUser(id uint) error {
-	s.logger.Info("删除用户", "user_id", id)
+func (s *userService) DeleteUser(ctx context.Context, id uint) error {
+	log := s.logger.With("user_id", id)
+	log.Info("删除用户")
 
-	if err := s.repo.Delete(id); err != nil {
-		s.logger.Error("删除用户失败", "user_id", id, "error", err)
+	if err := s.repo.Delete(ctx, id); err != nil {
+		log.Error("删除用户失败", "error", err)
 		return err
 	}
 
+	s.recordAudit(ctx, id, models.AuditActionDelete, nil)
+
 	// 删除缓存
-	cacheKey := fmt.Sprintf("user:%d", id)
-	ctx := context.Background()
-	if err := s.cache.Delete(ctx, cacheKey); err != nil {
-		s.logger.Warn("删除用户缓存失败", "user_id", id, "error", err)
+	cacheKey := UserCacheKey(id)
+	if err := s.cache.Delete(context.WithoutCancel(ctx), cacheKey); err != nil {
+		log.Warn("删除用户缓存失败", "error", err)
+	}
+	s.invalidateUserList(ctx)
+
+	return nil
+}
+
+// RestoreUser 恢复已删除的用户
+// 用户名和邮箱的唯一索引包含已删除的用户，恢复时不会与其他用户冲突
+func (s *userService) RestoreUser(ctx context.Context, id uint) (*models.User, error) {
+	log := s.logger.With("user_id", id)
+	log.Info("恢复用户")
+
+	if err := s.repo.Restore(ctx, id); err != nil {
+		log.Warn("恢复用户失败", "error", err)
+		return nil, err
+	}
+
+	s.recordAudit(ctx, id, models.AuditActionRestore, nil)
+
+	if err := s.cache.Delete(context.WithoutCancel(ctx), UserCacheKey(id)); err != nil {
+		log.Warn("删除用户缓存失败", "error", err)
+	}
+	s.invalidateUserList(ctx)
+
+	return s.repo.GetByID(ctx, id)
+}
+
+// PurgeUser 彻底删除用户及其审计记录、API Key和头像文件，不可恢复，用户名和邮箱随之释放
+func (s *userService) PurgeUser(ctx context.Context, id uint) error {
+	log := s.logger.With("user_id", id)
+	log.Warn("彻底删除用户")
+
+	user, err := s.repo.Purge(ctx, id)
+	if err != nil {
+		log.Error("彻底删除用户失败", "error", err)
+		return err
+	}
+	s.deleteAvatar(ctx, user.ID, user.AvatarURL)
+
+	// 只记录发生过彻底删除，不保留任何用户数据
+	s.recordAudit(ctx, id, models.AuditActionPurge, nil)
+
+	if err := s.cache.Delete(context.WithoutCancel(ctx), UserCacheKey(id)); err != nil {
+		log.Warn("删除用户缓存失败", "error", err)
+	}
+	s.invalidateUserList(ctx)
+
+	return nil
+}
+
+// PurgeDeleted 彻底删除 before 之前软删除的用户及其头像文件，返回删除的数量
+// 软删除的用户不在缓存中，无需清理缓存
+func (s *userService) PurgeDeleted(ctx context.Context, before time.Time) (int64, error) {
+	count, err := s.repo.PurgeDeletedBefore(ctx, before, repository.DefaultBatchSize, func(users []*models.User) {
+		for _, user := range users {
+			s.deleteAvatar(ctx, user.ID, user.AvatarURL)
+		}
+	})
+	if err != nil {
+		s.logger.Error("清理已删除用户失败", "before", before, "purged", count, "error", err)
+		return count, err
+	}
+	s.logger.Info("清理已删除用户完成", "before", before, "purged", count)
+	return count, nil
+}
+
+// deleteAvatar 删除用户的头像文件，失败只记录日志
+// 数据库记录已经变更，即使请求被取消也要完成清理
+func (s *userService) deleteAvatar(ctx context.Context, userID uint, url string) {
+	if url == "" {
+		return
+	}
+	if err := s.avatars.DeleteURL(context.WithoutCancel(ctx), url); err != nil {
+		s.logger.Warn("删除头像文件失败", "user_id", userID, "url", url, "error", err)
+	}
+}
+
+// UpdateAvatar 保存头像文件并更新用户的头像URL
+// contentType 须为 models.AvatarContentTypes 中的类型，由调用方完成校验
+func (s *userService) UpdateAvatar(ctx context.Context, id uint, contentType string, r io.Reader) (*models.User, error) {
+	log := s.logger.With("user_id", id)
+
+	ext, ok := models.AvatarContentTypes[contentType]
+	if !ok {
+		return nil, fmt.Errorf("不支持的图片类型: %s", contentType)
+	}
+
+	user, err := s.repo.GetByID(ctx, id)
+	if err != nil {
+		log.Error("用户不存在", "error", err)
+		return nil, err
 	}
 
+	name := fmt.Sprintf("avatars/%d/%s%s", id, uuid.NewString(), ext)
+	url, err := s.avatars.Save(ctx, name, r)
+	if err != nil {
+		log.Error("保存头像失败", "error", err)
+		return nil, err
+	}
+
+	updates := map[string]interface{}{"avatar_url": url}
+	if err := s.repo.Update(ctx, id, withUpdatedBy(ctx, updates)); err != nil {
+		log.Error("更新头像失败", "error", err)
+		if err := s.avatars.Delete(context.WithoutCancel(ctx), name); err != nil {
+			log.Warn("清理头像文件失败", "error", err)
+		}
+		return nil, err
+	}
+
+	s.recordAudit(ctx, id, models.AuditActionUpdate, updates)
+
+	if err := s.cache.Delete(context.WithoutCancel(ctx), UserCacheKey(id)); err != nil {
+		log.Warn("删除用户缓存失败", "error", err)
+	}
+
+	// 新头像已生效，旧头像文件不再被引用
+	s.deleteAvatar(ctx, id, user.AvatarURL)
+
+	log.Info("头像更新成功", "url", url)
+	return s.repo.GetByID(ctx, id)
+}
+
+// ListUsers 获取用户列表，分页结果会短暂缓存
+func (s *userService) ListUsers(ctx context.Context, page, limit int) ([]*models.User, int64, error) {
+	var generation int64
+	if err := s.cache.Get(ctx, userListGenerationKey, &generation); err != nil {
+		if !errors.Is(err, cache.ErrCacheMiss) {
+			s.logger.Warn("读取用户列表缓存代数失败", "error", err)
+		}
+		generation = 0
+	}
+	cacheKey := fmt.Sprintf("users:list:%d:%d:%d", generation, page, limit)
+
+	var list cachedUserList
+	err := getOrLoad(ctx, s.cache, s.logger, cacheKey, &list, userListTTL, func(ctx context.Context) (interface{}, error) {
+		offset := (page - 1) * limit
+		users, total, err := s.repo.List(ctx, offset, limit)
+		if err != nil {
+			return nil, err
+		}
+		return &cachedUserList{Users: users, Total: total}, nil
+	})
+	if err != nil {
+		s.logger.Error("获取用户列表失败", "error", err)
+		return nil, 0, err
+	}
+
+	return list.Users, list.Total, nil
+}
+
+// invalidateUserList 递增列表缓存代数，使所有分页缓存失效
+// 数据已经写入，即使请求被取消也要完成失效，因此不继承请求的取消信号
+func (s *userService) invalidateUserList(ctx context.Context) {
+	if _, err := s.cache.Incr(context.WithoutCancel(ctx), userListGenerationKey); err != nil {
+		s.logger.Warn("使用户列表缓存失效失败", "error", err)
+	}
+}
+
+// affectsUserList 更新的字段是否会影响列表成员或排序
+func affectsUserList(updates map[string]interface{}) bool {
+	for field := range updates {
+		if userListFields[field] {
+			return true
+		}
+	}
+	return false
+}
+
+// SearchUsers 搜索用户
+func (s *userService) SearchUsers(ctx context.Context, query string, page, limit int) ([]*models.User, int64, error) {
+	offset := (page - 1) * limit
+	users, total, err := s.repo.Search(ctx, query, offset, limit)
+	if err != nil {
+		s.logger.Error("搜索用户失败", "query", query, "error", err)
+		return nil, 0, err
+	}
+	return users, total, nil
+}
+
+// StreamUsers 逐个遍历用户，query 非空时按用户名、邮箱、姓名过滤
+func (s *userService) StreamUsers(ctx context.Context, query string, fn func(*models.User) error) error {
+	if err := s.repo.Stream(ctx, query, fn); err != nil {
+		s.logger.Error("遍历用户失败", "error", err)
+		return err
+	}
 	return nil
 }
 
-// ListUsers 获取用户列表
-func (s *userService) ListUsers(page, limit int) ([]*models.User, int64, error) {
+// GetAuditTrail 获取用户的审计记录
+func (s *userService) GetAuditTrail(ctx context.Context, id uint, page, limit int) (*models.AuditListResponse, error) {
 	offset := (page - 1) * limit
-	return s.repo.List(offset, limit)
+	entries, total, err := s.auditRepo.ListByEntity(ctx, models.AuditEntityUser, id, offset, limit)
+	if err != nil {
+		s.logger.Error("获取审计记录失败", "user_id", id, "error", err)
+		return nil, err
+	}
+
+	return &models.AuditListResponse{
+		Entries:    entries,
+		Pagination: models.NewPagination(total, page, limit),
+	}, nil
+}
+
+// recordAudit 记录用户审计日志，失败只记录警告不影响主流程
+func (s *userService) recordAudit(ctx context.Context, id uint, action string, changes map[string]interface{}) {
+	entry := &models.AuditLog{
+		EntityType: models.AuditEntityUser,
+		EntityID:   id,
+		Action:     action,
+		ActorID:    actorID(ctx),
+	}
+
+	if len(changes) > 0 {
+		data, err := json.Marshal(changes)
+		if err != nil {
+			s.logger.Warn("序列化审计数据失败", "user_id", id, "error", err)
+		} else {
+			entry.Changes = string(data)
+		}
+	}
+
+	// 主操作已经完成，审计记录不随请求取消而丢失
+	if err := s.auditRepo.Create(context.WithoutCancel(ctx), entry); err != nil {
+		s.logger.Warn("记录审计日志失败", "user_id", id, "action", action, "error", err)
+	}
 }
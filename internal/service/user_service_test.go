@@ -0,0 +1,357 @@
+package service
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/binary-1024/go-build-test/internal/auth"
+	"github.com/binary-1024/go-build-test/internal/mailer"
+	"github.com/binary-1024/go-build-test/internal/models"
+	"github.com/binary-1024/go-build-test/internal/repository"
+	"github.com/binary-1024/go-build-test/internal/storage"
+)
+
+func newTestUserService(t *testing.T, env *testEnv, options UserOptions) *userService {
+	t.Helper()
+	if options.CacheTTL == 0 {
+		options.CacheTTL = time.Minute
+	}
+	avatars := storage.NewLocalStorage(filepath.Join(t.TempDir(), "avatars"), "/uploads")
+	svc := NewUserService(repository.NewUserRepository(env.db), repository.NewAuditRepository(env.db), repository.NewTransactioner(env.db),
+		env.cache, avatars, mailer.NewNopMailer(), auth.NewJWTManager("test-secret"), options, env.logger)
+	return svc.(*userService)
+}
+
+func TestCreateUserGrantsDefaultScopes(t *testing.T) {
+	tests := []struct {
+		name   string
+		create func(svc *userService, req *models.CreateUserRequest) (*models.User, error)
+	}{
+		{name: "自助注册", create: func(svc *userService, req *models.CreateUserRequest) (*models.User, error) {
+			return svc.CreateUser(context.Background(), req)
+		}},
+		{name: "批量创建", create: func(svc *userService, req *models.CreateUserRequest) (*models.User, error) {
+			users, errs := svc.CreateUsers(context.Background(), []*models.CreateUserRequest{req})
+			return users[0], errs[0]
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := newTestEnv(t)
+			svc := newTestUserService(t, env, UserOptions{})
+
+			user, err := tt.create(svc, &models.CreateUserRequest{
+				Username: "alice", Email: "alice@example.com", Password: testPassword, FullName: "Alice",
+			})
+			if err != nil {
+				t.Fatalf("create user: %v", err)
+			}
+
+			var stored models.User
+			if err := env.db.First(&stored, user.ID).Error; err != nil {
+				t.Fatalf("get user: %v", err)
+			}
+			if !reflect.DeepEqual([]string(stored.Scopes), auth.DefaultScopes) {
+				t.Errorf("stored scopes = %v, want %v", stored.Scopes, auth.DefaultScopes)
+			}
+			if auth.HasScope(grantedScopes(&stored), auth.ScopeUsersWrite) {
+				t.Error("new user granted users:write")
+			}
+		})
+	}
+}
+
+func TestGrantedScopes(t *testing.T) {
+	tests := []struct {
+		name   string
+		scopes models.StringList
+		want   []string
+	}{
+		{name: "未分配权限", scopes: nil, want: auth.DefaultScopes},
+		{name: "没有任何权限", scopes: models.StringList{}, want: []string{}},
+		{name: "已分配权限", scopes: models.StringList{auth.ScopeProductsWrite}, want: []string{auth.ScopeProductsWrite}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := grantedScopes(&models.User{Scopes: tt.scopes}); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("grantedScopes = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSetScopes(t *testing.T) {
+	tests := []struct {
+		name   string
+		scopes []string
+		want   []string
+	}{
+		{name: "分配写权限", scopes: []string{auth.ScopeProductsRead, auth.ScopeProductsWrite}, want: []string{auth.ScopeProductsRead, auth.ScopeProductsWrite}},
+		{name: "null 恢复为默认权限", scopes: nil, want: auth.DefaultScopes},
+		{name: "收回全部权限", scopes: []string{}, want: []string{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := newTestEnv(t)
+			svc := newTestUserService(t, env, UserOptions{})
+			user := env.createUser(t, "alice", testPassword)
+
+			updated, err := svc.SetScopes(context.Background(), user.ID, tt.scopes)
+			if err != nil {
+				t.Fatalf("SetScopes: %v", err)
+			}
+			if got := grantedScopes(updated); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("scopes = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPurgeUserRemovesAvatarAndAPIKeys(t *testing.T) {
+	env := newTestEnv(t)
+	svc := newTestUserService(t, env, UserOptions{})
+	dir := t.TempDir()
+	svc.avatars = storage.NewLocalStorage(dir, "/uploads")
+	ctx := context.Background()
+
+	user := env.createUser(t, "alice", testPassword)
+	user, err := svc.UpdateAvatar(ctx, user.ID, "image/png", strings.NewReader("png"))
+	if err != nil {
+		t.Fatalf("UpdateAvatar: %v", err)
+	}
+	avatarPath := filepath.Join(dir, strings.TrimPrefix(user.AvatarURL, "/uploads/"))
+	if _, err := os.Stat(avatarPath); err != nil {
+		t.Fatalf("avatar not saved: %v", err)
+	}
+	key := &models.APIKey{UserID: user.ID, Name: "ci", Prefix: "sk_test", KeyHash: "hash"}
+	if err := env.db.Create(key).Error; err != nil {
+		t.Fatalf("create api key: %v", err)
+	}
+
+	if err := svc.PurgeUser(ctx, user.ID); err != nil {
+		t.Fatalf("PurgeUser: %v", err)
+	}
+
+	if _, err := os.Stat(avatarPath); !os.IsNotExist(err) {
+		t.Errorf("avatar file still exists: %v", err)
+	}
+	var keys int64
+	if err := env.db.Model(&models.APIKey{}).Unscoped().Where("user_id = ?", user.ID).Count(&keys).Error; err != nil {
+		t.Fatalf("count api keys: %v", err)
+	}
+	if keys != 0 {
+		t.Errorf("api keys = %d, want 0", keys)
+	}
+}
+
+func TestPurgeDeletedRemovesAvatars(t *testing.T) {
+	env := newTestEnv(t)
+	svc := newTestUserService(t, env, UserOptions{})
+	dir := t.TempDir()
+	svc.avatars = storage.NewLocalStorage(dir, "/uploads")
+	ctx := context.Background()
+
+	user := env.createUser(t, "bob", testPassword)
+	user, err := svc.UpdateAvatar(ctx, user.ID, "image/png", strings.NewReader("png"))
+	if err != nil {
+		t.Fatalf("UpdateAvatar: %v", err)
+	}
+	if err := svc.DeleteUser(ctx, user.ID); err != nil {
+		t.Fatalf("DeleteUser: %v", err)
+	}
+
+	count, err := svc.PurgeDeleted(ctx, time.Now().Add(time.Minute))
+	if err != nil {
+		t.Fatalf("PurgeDeleted: %v", err)
+	}
+	if count != 1 {
+		t.Errorf("purged = %d, want 1", count)
+	}
+	avatarPath := filepath.Join(dir, strings.TrimPrefix(user.AvatarURL, "/uploads/"))
+	if _, err := os.Stat(avatarPath); !os.IsNotExist(err) {
+		t.Errorf("avatar file still exists: %v", err)
+	}
+}
+
+func TestCreateUserNormalizedEmailDuplicates(t *testing.T) {
+	tests := []struct {
+		name      string
+		normalize bool
+		wantErr   bool
+	}{
+		{name: "开启归一化", normalize: true, wantErr: true},
+		{name: "未开启归一化", normalize: false, wantErr: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := newTestEnv(t)
+			svc := newTestUserService(t, env, UserOptions{NormalizeEmail: tt.normalize})
+			ctx := context.Background()
+
+			if _, err := svc.CreateUser(ctx, &models.CreateUserRequest{Username: "alice", Email: "alice@example.com", Password: testPassword}); err != nil {
+				t.Fatalf("CreateUser: %v", err)
+			}
+			_, err := svc.CreateUser(ctx, &models.CreateUserRequest{Username: "alice2", Email: "Alice+news@example.com", Password: testPassword})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("CreateUser plus-addressed err = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			var stored models.User
+			if err := env.db.Where("username = ?", "alice").First(&stored).Error; err != nil {
+				t.Fatalf("get user: %v", err)
+			}
+			if got := stored.NormalizedEmail != nil; got != tt.normalize {
+				t.Errorf("normalized_email stored = %v, want %v", got, tt.normalize)
+			}
+		})
+	}
+}
+
+func TestUpdateAvatarRemovesPreviousFile(t *testing.T) {
+	env := newTestEnv(t)
+	svc := newTestUserService(t, env, UserOptions{})
+	dir := t.TempDir()
+	svc.avatars = storage.NewLocalStorage(dir, "/uploads")
+	ctx := context.Background()
+
+	user := env.createUser(t, "alice", testPassword)
+	first, err := svc.UpdateAvatar(ctx, user.ID, "image/png", strings.NewReader("first"))
+	if err != nil {
+		t.Fatalf("UpdateAvatar: %v", err)
+	}
+	second, err := svc.UpdateAvatar(ctx, user.ID, "image/png", strings.NewReader("second"))
+	if err != nil {
+		t.Fatalf("UpdateAvatar (second): %v", err)
+	}
+
+	path := func(url string) string {
+		return filepath.Join(dir, strings.TrimPrefix(url, "/uploads/"))
+	}
+	if _, err := os.Stat(path(first.AvatarURL)); !os.IsNotExist(err) {
+		t.Errorf("previous avatar still exists: %v", err)
+	}
+	if _, err := os.Stat(path(second.AvatarURL)); err != nil {
+		t.Errorf("current avatar missing: %v", err)
+	}
+}
+
+func TestUpdateUserListCacheInvalidation(t *testing.T) {
+	inactive := false
+	tests := []struct {
+		name            string
+		req             models.UpdateUserRequest
+		updated         func(u *models.User) bool
+		wantInvalidated bool
+	}{
+		{
+			name:    "更新姓名保留列表缓存",
+			req:     models.UpdateUserRequest{FullName: "Alice Liddell"},
+			updated: func(u *models.User) bool { return u.FullName == "Alice Liddell" },
+		},
+		{
+			name:            "更新激活状态使列表缓存失效",
+			req:             models.UpdateUserRequest{IsActive: &inactive},
+			updated:         func(u *models.User) bool { return !u.IsActive },
+			wantInvalidated: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := newTestEnv(t)
+			users := newTestUserService(t, env, UserOptions{})
+			ctx := context.Background()
+			alice := env.createUser(t, "alice", "Passw0rd!")
+
+			if _, _, err := users.ListUsers(ctx, 1, 10); err != nil {
+				t.Fatalf("ListUsers: %v", err)
+			}
+			if _, err := users.UpdateUser(ctx, alice.ID, &tt.req); err != nil {
+				t.Fatalf("UpdateUser: %v", err)
+			}
+
+			list, _, err := users.ListUsers(ctx, 1, 10)
+			if err != nil {
+				t.Fatalf("ListUsers: %v", err)
+			}
+			if len(list) != 1 {
+				t.Fatalf("users = %d, want 1", len(list))
+			}
+			// 列表缓存保留时返回更新前的值，失效后返回更新后的值
+			if got := tt.updated(list[0]); got != tt.wantInvalidated {
+				t.Errorf("list user = %+v, invalidated = %v, want %v", list[0], got, tt.wantInvalidated)
+			}
+		})
+	}
+}
+
+func TestGetAuditTrail(t *testing.T) {
+	env := newTestEnv(t)
+	users := newTestUserService(t, env, UserOptions{})
+	alice := env.createUser(t, "alice", "Passw0rd!")
+	bob := env.createUser(t, "bob", "Passw0rd!")
+	ctx := auth.ContextWithUserID(context.Background(), bob.ID)
+
+	inactive := false
+	if _, err := users.UpdateUser(ctx, alice.ID, &models.UpdateUserRequest{FullName: "Alice Liddell"}); err != nil {
+		t.Fatalf("UpdateUser: %v", err)
+	}
+	if _, err := users.UpdateUser(ctx, alice.ID, &models.UpdateUserRequest{IsActive: &inactive}); err != nil {
+		t.Fatalf("UpdateUser: %v", err)
+	}
+	if err := users.DeleteUser(ctx, alice.ID); err != nil {
+		t.Fatalf("DeleteUser: %v", err)
+	}
+
+	tests := []struct {
+		name        string
+		page, limit int
+		wantActions []string
+		wantChanges []string
+		wantHasNext bool
+	}{
+		{
+			name: "第一页按时间倒序", page: 1, limit: 2,
+			wantActions: []string{models.AuditActionDelete, models.AuditActionUpdate},
+			wantChanges: []string{"", "is_active"},
+			wantHasNext: true,
+		},
+		{
+			name: "第二页", page: 2, limit: 2,
+			wantActions: []string{models.AuditActionUpdate},
+			wantChanges: []string{"full_name"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			trail, err := users.GetAuditTrail(context.Background(), alice.ID, tt.page, tt.limit)
+			if err != nil {
+				t.Fatalf("GetAuditTrail: %v", err)
+			}
+			if trail.Total != 3 || trail.HasNext != tt.wantHasNext {
+				t.Errorf("total = %d, has_next = %v; want 3, %v", trail.Total, trail.HasNext, tt.wantHasNext)
+			}
+			if len(trail.Entries) != len(tt.wantActions) {
+				t.Fatalf("entries = %d, want %d", len(trail.Entries), len(tt.wantActions))
+			}
+			for i, entry := range trail.Entries {
+				if entry.Action != tt.wantActions[i] || !strings.Contains(entry.Changes, tt.wantChanges[i]) {
+					t.Errorf("entry %d = %s %s, want %s containing %q", i, entry.Action, entry.Changes, tt.wantActions[i], tt.wantChanges[i])
+				}
+				if entry.ActorID == nil || *entry.ActorID != bob.ID {
+					t.Errorf("entry %d actor = %v, want %d", i, entry.ActorID, bob.ID)
+				}
+			}
+		})
+	}
+}
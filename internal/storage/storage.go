@@ -0,0 +1,100 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// Storage 文件存储接口，便于之后接入S3等对象存储
+type Storage interface {
+	// Save 保存文件并返回可访问的URL，name 为以 / 分隔的相对路径
+	Save(ctx context.Context, name string, r io.Reader) (string, error)
+	// Delete 删除文件，文件不存在时不返回错误
+	Delete(ctx context.Context, name string) error
+	// DeleteURL 删除 Save 返回的URL对应的文件，URL为空或不属于该存储时不做任何操作
+	DeleteURL(ctx context.Context, url string) error
+}
+
+// localStorage 本地磁盘存储
+type localStorage struct {
+	dir     string
+	baseURL string
+}
+
+// NewLocalStorage 创建本地磁盘存储，文件保存在 dir 下，URL 以 baseURL 为前缀
+func NewLocalStorage(dir, baseURL string) Storage {
+	return &localStorage{
+		dir:     dir,
+		baseURL: strings.TrimSuffix(baseURL, "/"),
+	}
+}
+
+// Save 保存文件
+func (s *localStorage) Save(ctx context.Context, name string, r io.Reader) (string, error) {
+	fullPath, err := s.resolve(name)
+	if err != nil {
+		return "", err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(fullPath), 0o755); err != nil {
+		return "", err
+	}
+
+	// 先写临时文件再重命名，避免读到写了一半的文件
+	tmp, err := os.CreateTemp(filepath.Dir(fullPath), ".upload-*")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := io.Copy(tmp, r); err != nil {
+		tmp.Close()
+		return "", err
+	}
+	if err := tmp.Close(); err != nil {
+		return "", err
+	}
+	if err := os.Chmod(tmp.Name(), 0o644); err != nil {
+		return "", err
+	}
+	if err := os.Rename(tmp.Name(), fullPath); err != nil {
+		return "", err
+	}
+
+	return s.baseURL + "/" + name, nil
+}
+
+// Delete 删除文件
+func (s *localStorage) Delete(ctx context.Context, name string) error {
+	fullPath, err := s.resolve(name)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(fullPath); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// DeleteURL 根据URL删除文件
+func (s *localStorage) DeleteURL(ctx context.Context, url string) error {
+	name, ok := strings.CutPrefix(url, s.baseURL+"/")
+	if !ok || name == "" {
+		return nil
+	}
+	return s.Delete(ctx, name)
+}
+
+// resolve 将相对路径转换为存储目录下的绝对路径，拒绝跳出存储目录的路径
+func (s *localStorage) resolve(name string) (string, error) {
+	cleaned := path.Clean("/" + name)
+	if cleaned == "/" {
+		return "", fmt.Errorf("无效的文件名: %s", name)
+	}
+	return filepath.Join(s.dir, filepath.FromSlash(cleaned)), nil
+}
@@ -5,7 +5,9 @@ import (
 	"fmt"
 	"log"
 	"net/http"
+	"sort"
 	"strconv"
+	"strings"
 	"sync"
 	"time"
 )
@@ -37,8 +39,16 @@ type Response struct {
 	Success bool        `json:"success"`
 	Message string      `json:"message"`
 	Data    interface{} `json:"data,omitempty"`
+	// Fields 校验失败时各字段的错误信息
+	Fields map[string]string `json:"fields,omitempty"`
 }
 
+// 列表分页参数
+const (
+	defaultPageLimit = 10
+	maxPageLimit     = 100
+)
+
 // 内存数据库
 type MemoryDB struct {
 	users    map[uint]*User
@@ -88,6 +98,10 @@ func (db *MemoryDB) GetAllUsers() []*User {
 	for _, user := range db.users {
 		users = append(users, user)
 	}
+	// map遍历顺序随机，按ID升序返回保证结果稳定
+	sort.Slice(users, func(i, j int) bool {
+		return users[i].ID < users[j].ID
+	})
 	return users
 }
 
@@ -110,6 +124,34 @@ func (db *MemoryDB) CreateUser(user *User) *User {
 	return user
 }
 
+// UpdateUser 用新数据替换指定用户，保留ID和创建时间；用户不存在时返回false
+func (db *MemoryDB) UpdateUser(id uint, user *User) (*User, bool) {
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+
+	existing, exists := db.users[id]
+	if !exists {
+		return nil, false
+	}
+
+	user.ID = id
+	user.CreateAt = existing.CreateAt
+	db.users[id] = user
+	return user, true
+}
+
+// DeleteUser 删除指定用户，用户不存在时返回false
+func (db *MemoryDB) DeleteUser(id uint) bool {
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+
+	if _, exists := db.users[id]; !exists {
+		return false
+	}
+	delete(db.users, id)
+	return true
+}
+
 func (db *MemoryDB) GetAllProducts() []*Product {
 	db.mutex.RLock()
 	defer db.mutex.RUnlock()
@@ -118,6 +160,10 @@ func (db *MemoryDB) GetAllProducts() []*Product {
 	for _, product := range db.products {
 		products = append(products, product)
 	}
+	// map遍历顺序随机，按ID升序返回保证结果稳定
+	sort.Slice(products, func(i, j int) bool {
+		return products[i].ID < products[j].ID
+	})
 	return products
 }
 
@@ -141,11 +187,51 @@ func (db *MemoryDB) CreateProduct(product *Product) *Product {
 	return product
 }
 
+// UpdateProduct 用新数据替换指定产品，保留ID和创建时间；产品不存在时返回false
+func (db *MemoryDB) UpdateProduct(id uint, product *Product) (*Product, bool) {
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+
+	existing, exists := db.products[id]
+	if !exists {
+		return nil, false
+	}
+
+	product.ID = id
+	product.CreateAt = existing.CreateAt
+	db.products[id] = product
+	return product, true
+}
+
+// DeleteProduct 删除指定产品，产品不存在时返回false
+func (db *MemoryDB) DeleteProduct(id uint) bool {
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+
+	if _, exists := db.products[id]; !exists {
+		return false
+	}
+	delete(db.products, id)
+	return true
+}
+
+// Counts 返回当前用户数和产品数
+func (db *MemoryDB) Counts() (users, products int) {
+	db.mutex.RLock()
+	defer db.mutex.RUnlock()
+
+	return len(db.users), len(db.products)
+}
+
 // 全局数据库实例
 var db *MemoryDB
 
+// 服务启动时间，在 main 中设置，用于计算运行时长
+var startTime time.Time
+
 func main() {
 	fmt.Println("=== 简化版微服务启动 ===")
+	startTime = time.Now()
 
 	// 初始化内存数据库
 	db = NewMemoryDB()
@@ -153,6 +239,7 @@ func main() {
 	// 设置路由
 	http.HandleFunc("/", homeHandler)
 	http.HandleFunc("/health", healthHandler)
+	http.HandleFunc("/stats", statsHandler)
 	http.HandleFunc("/api/v1/users", usersHandler)
 	http.HandleFunc("/api/v1/users/", userHandler)
 	http.HandleFunc("/api/v1/products", productsHandler)
@@ -177,12 +264,17 @@ func main() {
 	fmt.Println("")
 	fmt.Println("📚 API端点:")
 	fmt.Println("  GET  /health              - 健康检查")
-	fmt.Println("  GET  /api/v1/users        - 获取用户列表")
+	fmt.Println("  GET  /stats               - 数据统计")
+	fmt.Println("  GET  /api/v1/users        - 获取用户列表 (?page=1&limit=10)")
 	fmt.Println("  POST /api/v1/users        - 创建用户")
 	fmt.Println("  GET  /api/v1/users/{id}   - 获取指定用户")
-	fmt.Println("  GET  /api/v1/products     - 获取产品列表")
+	fmt.Println("  PUT  /api/v1/users/{id}   - 更新指定用户")
+	fmt.Println("  DELETE /api/v1/users/{id} - 删除指定用户")
+	fmt.Println("  GET  /api/v1/products     - 获取产品列表 (?page=1&limit=10)")
 	fmt.Println("  POST /api/v1/products     - 创建产品")
 	fmt.Println("  GET  /api/v1/products/{id} - 获取指定产品")
+	fmt.Println("  PUT  /api/v1/products/{id} - 更新指定产品")
+	fmt.Println("  DELETE /api/v1/products/{id} - 删除指定产品")
 	fmt.Println("")
 	fmt.Println("🧪 测试命令:")
 	fmt.Println("  curl http://localhost:8080/health")
@@ -236,14 +328,49 @@ func healthHandler(w http.ResponseWriter, r *http.Request) {
 	sendJSONResponse(w, http.StatusOK, response)
 }
 
+func statsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		sendErrorResponse(w, "不支持的请求方法", http.StatusMethodNotAllowed)
+		return
+	}
+
+	users, products := db.Counts()
+	uptime := time.Since(startTime)
+
+	response := Response{
+		Success: true,
+		Message: "获取统计信息成功",
+		Data: map[string]interface{}{
+			"users":          users,
+			"products":       products,
+			"uptime":         uptime.Round(time.Second).String(),
+			"uptime_seconds": int64(uptime.Seconds()),
+		},
+	}
+
+	sendJSONResponse(w, http.StatusOK, response)
+}
+
 func usersHandler(w http.ResponseWriter, r *http.Request) {
 	switch r.Method {
 	case "GET":
+		page, limit, err := parsePagination(r)
+		if err != nil {
+			sendErrorResponse(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
 		users := db.GetAllUsers()
+
 		response := Response{
 			Success: true,
 			Message: "获取用户列表成功",
-			Data:    users,
+			Data: map[string]interface{}{
+				"items": paginate(users, page, limit),
+				"total": len(users),
+				"page":  page,
+				"limit": limit,
+			},
 		}
 		sendJSONResponse(w, http.StatusOK, response)
 
@@ -254,8 +381,8 @@ func usersHandler(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 
-		if user.Username == "" || user.Email == "" {
-			sendErrorResponse(w, "用户名和邮箱不能为空", http.StatusBadRequest)
+		if errs := validateUser(&user); len(errs) > 0 {
+			sendValidationError(w, errs)
 			return
 		}
 
@@ -303,6 +430,50 @@ func userHandler(w http.ResponseWriter, r *http.Request) {
 		}
 		sendJSONResponse(w, http.StatusOK, response)
 
+	case "PUT":
+		existing, exists := db.GetUserByID(uint(id))
+		if !exists {
+			sendErrorResponse(w, "用户不存在", http.StatusNotFound)
+			return
+		}
+
+		// 在现有数据的副本上解码，未提供的字段保持原值
+		user := *existing
+		if err := json.NewDecoder(r.Body).Decode(&user); err != nil {
+			sendErrorResponse(w, "无效的JSON数据", http.StatusBadRequest)
+			return
+		}
+
+		if errs := validateUser(&user); len(errs) > 0 {
+			sendValidationError(w, errs)
+			return
+		}
+
+		updatedUser, exists := db.UpdateUser(uint(id), &user)
+		if !exists {
+			sendErrorResponse(w, "用户不存在", http.StatusNotFound)
+			return
+		}
+
+		response := Response{
+			Success: true,
+			Message: "用户更新成功",
+			Data:    updatedUser,
+		}
+		sendJSONResponse(w, http.StatusOK, response)
+
+	case "DELETE":
+		if !db.DeleteUser(uint(id)) {
+			sendErrorResponse(w, "用户不存在", http.StatusNotFound)
+			return
+		}
+
+		response := Response{
+			Success: true,
+			Message: "用户删除成功",
+		}
+		sendJSONResponse(w, http.StatusOK, response)
+
 	default:
 		sendErrorResponse(w, "不支持的请求方法", http.StatusMethodNotAllowed)
 	}
@@ -311,11 +482,23 @@ func userHandler(w http.ResponseWriter, r *http.Request) {
 func productsHandler(w http.ResponseWriter, r *http.Request) {
 	switch r.Method {
 	case "GET":
+		page, limit, err := parsePagination(r)
+		if err != nil {
+			sendErrorResponse(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
 		products := db.GetAllProducts()
+
 		response := Response{
 			Success: true,
 			Message: "获取产品列表成功",
-			Data:    products,
+			Data: map[string]interface{}{
+				"items": paginate(products, page, limit),
+				"total": len(products),
+				"page":  page,
+				"limit": limit,
+			},
 		}
 		sendJSONResponse(w, http.StatusOK, response)
 
@@ -326,8 +509,8 @@ func productsHandler(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 
-		if product.Name == "" || product.Price <= 0 {
-			sendErrorResponse(w, "产品名称和价格不能为空", http.StatusBadRequest)
+		if errs := validateProduct(&product); len(errs) > 0 {
+			sendValidationError(w, errs)
 			return
 		}
 
@@ -374,6 +557,50 @@ func productHandler(w http.ResponseWriter, r *http.Request) {
 		}
 		sendJSONResponse(w, http.StatusOK, response)
 
+	case "PUT":
+		existing, exists := db.GetProductByID(uint(id))
+		if !exists {
+			sendErrorResponse(w, "产品不存在", http.StatusNotFound)
+			return
+		}
+
+		// 在现有数据的副本上解码，未提供的字段保持原值
+		product := *existing
+		if err := json.NewDecoder(r.Body).Decode(&product); err != nil {
+			sendErrorResponse(w, "无效的JSON数据", http.StatusBadRequest)
+			return
+		}
+
+		if errs := validateProduct(&product); len(errs) > 0 {
+			sendValidationError(w, errs)
+			return
+		}
+
+		updatedProduct, exists := db.UpdateProduct(uint(id), &product)
+		if !exists {
+			sendErrorResponse(w, "产品不存在", http.StatusNotFound)
+			return
+		}
+
+		response := Response{
+			Success: true,
+			Message: "产品更新成功",
+			Data:    updatedProduct,
+		}
+		sendJSONResponse(w, http.StatusOK, response)
+
+	case "DELETE":
+		if !db.DeleteProduct(uint(id)) {
+			sendErrorResponse(w, "产品不存在", http.StatusNotFound)
+			return
+		}
+
+		response := Response{
+			Success: true,
+			Message: "产品删除成功",
+		}
+		sendJSONResponse(w, http.StatusOK, response)
+
 	default:
 		sendErrorResponse(w, "不支持的请求方法", http.StatusMethodNotAllowed)
 	}
@@ -393,27 +620,91 @@ func sendErrorResponse(w http.ResponseWriter, message string, statusCode int) {
 	sendJSONResponse(w, statusCode, response)
 }
 
-func splitPath(path string) []string {
-	parts := []string{}
-	for _, part := range []string{"", ""} {
-		if part != "" {
-			parts = append(parts, part)
-		}
+// fieldError 单个字段的校验错误
+type fieldError struct {
+	Field   string
+	Message string
+}
+
+// sendValidationError 返回带字段错误的400响应，message 中包含第一个字段错误
+func sendValidationError(w http.ResponseWriter, errs []fieldError) {
+	fields := make(map[string]string, len(errs))
+	for _, e := range errs {
+		fields[e.Field] = e.Message
 	}
 
-	// 手动分割路径
-	start := 0
-	for i, c := range path {
-		if c == '/' {
-			if i > start {
-				parts = append(parts, path[start:i])
-			}
-			start = i + 1
+	response := Response{
+		Success: false,
+		Message: "参数校验失败: " + errs[0].Message,
+		Fields:  fields,
+	}
+	sendJSONResponse(w, http.StatusBadRequest, response)
+}
+
+// validateUser 校验创建用户的字段，按字段顺序返回校验错误
+func validateUser(user *User) []fieldError {
+	var errs []fieldError
+	if user.Username == "" {
+		errs = append(errs, fieldError{Field: "username", Message: "用户名不能为空"})
+	}
+	if user.Email == "" {
+		errs = append(errs, fieldError{Field: "email", Message: "邮箱不能为空"})
+	}
+	return errs
+}
+
+// validateProduct 校验创建产品的字段，按字段顺序返回校验错误
+func validateProduct(product *Product) []fieldError {
+	var errs []fieldError
+	if product.Name == "" {
+		errs = append(errs, fieldError{Field: "name", Message: "产品名称不能为空"})
+	}
+	if product.Price <= 0 {
+		errs = append(errs, fieldError{Field: "price", Message: "价格必须大于0"})
+	}
+	return errs
+}
+
+// parsePagination 解析 page/limit 查询参数，缺省时使用第1页、每页10条
+func parsePagination(r *http.Request) (page, limit int, err error) {
+	page, limit = 1, defaultPageLimit
+	query := r.URL.Query()
+
+	if v := query.Get("page"); v != "" {
+		page, err = strconv.Atoi(v)
+		if err != nil || page < 1 {
+			return 0, 0, fmt.Errorf("page必须是大于0的整数")
 		}
 	}
-	if start < len(path) {
-		parts = append(parts, path[start:])
+	if v := query.Get("limit"); v != "" {
+		limit, err = strconv.Atoi(v)
+		if err != nil || limit < 1 || limit > maxPageLimit {
+			return 0, 0, fmt.Errorf("limit必须是1到%d之间的整数", maxPageLimit)
+		}
 	}
+	return page, limit, nil
+}
 
-	return parts
+// paginate 返回指定页的数据，页码超出范围时返回空列表
+func paginate[T any](items []T, page, limit int) []T {
+	start := (page - 1) * limit
+	if start >= len(items) {
+		return []T{}
+	}
+	end := start + limit
+	if end > len(items) {
+		end = len(items)
+	}
+	return items[start:end]
+}
+
+// splitPath 将URL路径按 / 拆分为非空片段，忽略查询字符串、首尾斜杠和连续斜杠
+// 例如 /api/v1/users/5/ 拆分为 [api v1 users 5]
+func splitPath(path string) []string {
+	if i := strings.IndexAny(path, "?#"); i >= 0 {
+		path = path[:i]
+	}
+	return strings.FieldsFunc(path, func(r rune) bool {
+		return r == '/'
+	})
 }
@@ -0,0 +1,195 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestValidationErrors(t *testing.T) {
+	db = NewMemoryDB()
+
+	tests := []struct {
+		name        string
+		handler     http.HandlerFunc
+		path        string
+		body        string
+		wantStatus  int
+		wantMessage string
+		wantFields  map[string]string
+	}{
+		{
+			name:        "用户缺少全部字段",
+			handler:     usersHandler,
+			path:        "/api/v1/users",
+			body:        `{}`,
+			wantStatus:  http.StatusBadRequest,
+			wantMessage: "参数校验失败: 用户名不能为空",
+			wantFields:  map[string]string{"username": "用户名不能为空", "email": "邮箱不能为空"},
+		},
+		{
+			name:        "用户缺少邮箱",
+			handler:     usersHandler,
+			path:        "/api/v1/users",
+			body:        `{"username":"alice"}`,
+			wantStatus:  http.StatusBadRequest,
+			wantMessage: "参数校验失败: 邮箱不能为空",
+			wantFields:  map[string]string{"email": "邮箱不能为空"},
+		},
+		{
+			name:        "产品价格无效",
+			handler:     productsHandler,
+			path:        "/api/v1/products",
+			body:        `{"name":"Widget","price":0}`,
+			wantStatus:  http.StatusBadRequest,
+			wantMessage: "参数校验失败: 价格必须大于0",
+			wantFields:  map[string]string{"price": "价格必须大于0"},
+		},
+		{
+			name:        "产品缺少名称和价格",
+			handler:     productsHandler,
+			path:        "/api/v1/products",
+			body:        `{}`,
+			wantStatus:  http.StatusBadRequest,
+			wantMessage: "参数校验失败: 产品名称不能为空",
+			wantFields:  map[string]string{"name": "产品名称不能为空", "price": "价格必须大于0"},
+		},
+		{
+			name:       "用户参数有效",
+			handler:    usersHandler,
+			path:       "/api/v1/users",
+			body:       `{"username":"bob","email":"bob@example.com"}`,
+			wantStatus: http.StatusCreated,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, tt.path, strings.NewReader(tt.body))
+			w := httptest.NewRecorder()
+			tt.handler(w, req)
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.wantStatus, w.Body.String())
+			}
+			var resp Response
+			if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+				t.Fatalf("decode response: %v", err)
+			}
+			if tt.wantFields == nil {
+				if resp.Fields != nil {
+					t.Errorf("fields = %v, want none", resp.Fields)
+				}
+				return
+			}
+			if resp.Message != tt.wantMessage {
+				t.Errorf("message = %q, want %q", resp.Message, tt.wantMessage)
+			}
+			if !reflect.DeepEqual(resp.Fields, tt.wantFields) {
+				t.Errorf("fields = %v, want %v", resp.Fields, tt.wantFields)
+			}
+		})
+	}
+}
+
+func TestSplitPath(t *testing.T) {
+	tests := []struct {
+		path string
+		want []string
+	}{
+		{path: "/api/v1/users/5", want: []string{"api", "v1", "users", "5"}},
+		{path: "/api/v1/users/5/", want: []string{"api", "v1", "users", "5"}},
+		{path: "//api//v1/users//5", want: []string{"api", "v1", "users", "5"}},
+		{path: "/api/v1/users/5?fields=id", want: []string{"api", "v1", "users", "5"}},
+		{path: "/api/v1/users/5#top", want: []string{"api", "v1", "users", "5"}},
+		{path: "/", want: []string{}},
+		{path: "", want: []string{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			got := splitPath(tt.path)
+			if len(got) != len(tt.want) || (len(got) > 0 && !reflect.DeepEqual(got, tt.want)) {
+				t.Errorf("splitPath(%q) = %q, want %q", tt.path, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUserHandlerPathID(t *testing.T) {
+	db = NewMemoryDB()
+	user := db.CreateUser(&User{Username: "alice", Email: "alice@example.com"})
+
+	tests := []struct {
+		name string
+		path string
+		want int
+	}{
+		{name: "标准路径", path: fmt.Sprintf("/api/v1/users/%d", user.ID), want: http.StatusOK},
+		{name: "末尾斜杠", path: fmt.Sprintf("/api/v1/users/%d/", user.ID), want: http.StatusOK},
+		{name: "连续斜杠", path: fmt.Sprintf("/api//v1/users//%d", user.ID), want: http.StatusOK},
+		{name: "缺少ID", path: "/api/v1/users/", want: http.StatusBadRequest},
+		{name: "ID格式错误", path: "/api/v1/users/abc", want: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			userHandler(w, httptest.NewRequest(http.MethodGet, tt.path, nil))
+
+			if w.Code != tt.want {
+				t.Errorf("status = %d, want %d: %s", w.Code, tt.want, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestMemoryDBListOrder(t *testing.T) {
+	tests := []struct {
+		name    string
+		count   int
+		deleted uint
+		wantIDs []uint
+	}{
+		{name: "只有初始数据", count: 0, wantIDs: []uint{1}},
+		{name: "多条记录按ID升序", count: 20, wantIDs: []uint{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16, 17, 18, 19, 20}},
+		{name: "删除后仍然有序", count: 5, deleted: 3, wantIDs: []uint{1, 2, 4, 5}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// NewMemoryDB 自带ID为1的初始数据，新建记录从ID 1开始编号
+			memDB := NewMemoryDB()
+			for i := 0; i < tt.count; i++ {
+				memDB.CreateUser(&User{Username: fmt.Sprintf("user%d", i)})
+				memDB.CreateProduct(&Product{Name: fmt.Sprintf("product%d", i), Price: 1})
+			}
+			if tt.deleted != 0 {
+				memDB.DeleteUser(tt.deleted)
+				memDB.DeleteProduct(tt.deleted)
+			}
+
+			// 多次调用结果一致，不受 map 遍历顺序影响
+			for call := 0; call < 3; call++ {
+				userIDs := make([]uint, 0, tt.count)
+				for _, user := range memDB.GetAllUsers() {
+					userIDs = append(userIDs, user.ID)
+				}
+				productIDs := make([]uint, 0, tt.count)
+				for _, product := range memDB.GetAllProducts() {
+					productIDs = append(productIDs, product.ID)
+				}
+				if !reflect.DeepEqual(userIDs, tt.wantIDs) {
+					t.Fatalf("user IDs = %v, want %v", userIDs, tt.wantIDs)
+				}
+				if !reflect.DeepEqual(productIDs, tt.wantIDs) {
+					t.Fatalf("product IDs = %v, want %v", productIDs, tt.wantIDs)
+				}
+			}
+		})
+	}
+}